@@ -0,0 +1,163 @@
+package toolbox
+
+import (
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// ErrUnsupportedMediaType matches, with errors.Is, the *UnsupportedMediaTypeError returned by
+// ReadBody and ReadForm for a body they can't read, so that a handler can respond with 415
+// Unsupported Media Type.
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// UnsupportedMediaTypeError is returned by ReadBody, ReadForm and ReadJSON when the Content-Type of
+// the request is not one they can read.
+type UnsupportedMediaTypeError struct {
+	ContentType string   // the Content-Type header sent
+	Accepted    []string // the types that would have been accepted, if there is a short list of them
+}
+
+// Error satisfies the error interface.
+func (e *UnsupportedMediaTypeError) Error() string {
+	if len(e.Accepted) > 0 {
+		return fmt.Sprintf("the Content-Type header is %q, but only %s are accepted", e.ContentType, strings.Join(e.Accepted, ", "))
+	}
+	return fmt.Sprintf("the Content-Type %q is not supported", e.ContentType)
+}
+
+// Is makes errors.Is(err, ErrUnsupportedMediaType) true.
+func (e *UnsupportedMediaTypeError) Is(target error) bool {
+	return target == ErrUnsupportedMediaType
+}
+
+// ReadBody decodes the body of r into dst, however the client chose to send it, for endpoints that
+// accept the same data from old clients as a form, and from newer ones as JSON. The Content-Type
+// decides how:
+//
+//   - application/json, or another type ReadJSON accepts (or none at all), with ReadJSON
+//   - application/xml or text/xml, with ReadXML
+//   - application/x-www-form-urlencoded, with ReadForm
+//   - multipart/form-data, as ReadMultipartForm does, with any files saved to BaseUploadDir, which
+//     must be set if dst has file fields
+//
+// Anything else gets an *UnsupportedMediaTypeError. For the form types, dst must be a pointer to a
+// struct, whose fields are named with form tags, as for ReadMultipartForm; for JSON and XML, the
+// usual json and xml tags are used.
+func (t *Tools) ReadBody(w http.ResponseWriter, r *http.Request, dst any) error {
+	contentType := r.Header.Get("Content-Type")
+
+	switch {
+	case contentType == "" || t.isJSONMediaType(contentType):
+		return t.ReadJSON(w, r, dst)
+
+	case hasMediaType(contentType, "application/xml", "text/xml"):
+		return t.ReadXML(w, r, dst)
+
+	case hasMediaType(contentType, "application/x-www-form-urlencoded"):
+		return t.ReadForm(w, r, dst)
+
+	case hasMediaType(contentType, "multipart/form-data"):
+		rv, err := formDestination("ReadBody", dst)
+		if err != nil {
+			return err
+		}
+
+		if hasFileFields(rv.Elem().Type()) {
+			if t.BaseUploadDir == "" {
+				return errors.New("ReadBody: BaseUploadDir must be set to receive files in a multipart form")
+			}
+			return t.ReadMultipartForm(r, dst, "")
+		}
+
+		// Without files, the whole form is just text, so it is limited like any other form.
+		maxBytes := t.maxFormSize()
+		r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+		err = r.ParseMultipartForm(int64(maxBytes))
+		if isMaxBytesError(err) {
+			return &BodyTooLargeError{Limit: maxBytes}
+		}
+		if err != nil {
+			return formError(err)
+		}
+
+		return t.decodeForm(r, r.MultipartForm, rv)
+
+	default:
+		return &UnsupportedMediaTypeError{ContentType: contentType}
+	}
+}
+
+// ReadForm decodes an application/x-www-form-urlencoded request body into the struct pointed to by
+// dst, with the same rules for naming, converting, and requiring fields as ReadMultipartForm. The
+// body is limited to MaxFormSize bytes (10MB by default). A body of any other type gets an
+// *UnsupportedMediaTypeError.
+func (t *Tools) ReadForm(w http.ResponseWriter, r *http.Request, dst any) error {
+	rv, err := formDestination("ReadForm", dst)
+	if err != nil {
+		return err
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if !hasMediaType(contentType, "application/x-www-form-urlencoded") {
+		return &UnsupportedMediaTypeError{ContentType: contentType}
+	}
+
+	maxBytes := t.maxFormSize()
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	err = r.ParseForm()
+	if isMaxBytesError(err) {
+		return &BodyTooLargeError{Limit: maxBytes}
+	}
+	if err != nil {
+		return formError(err)
+	}
+
+	return t.decodeForm(r, &multipart.Form{Value: r.PostForm}, rv)
+}
+
+// decodeForm decodes form, which has no files that need saving, into the struct rv.
+func (t *Tools) decodeForm(r *http.Request, form *multipart.Form, rv reflect.Value) error {
+	d := formDecoder{t: t, r: r, form: form, opts: t.uploadOptions(r.Context(), UploadOptions{})}
+	d.decodeStruct(rv.Elem())
+
+	if len(d.errs) > 0 {
+		return &FormError{Fields: d.errs}
+	}
+	return nil
+}
+
+// formDestination returns dst as a reflect.Value, or, if it isn't a non-nil pointer to a struct, an
+// error for fn.
+func formDestination(fn string, dst any) (reflect.Value, error) {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return rv, fmt.Errorf("%s: destination must be a non-nil pointer to a struct, got %T", fn, dst)
+	}
+	return rv, nil
+}
+
+// hasFileFields reports whether the struct type rt, or a struct embedded in it, has a field that
+// receives uploaded files.
+func hasFileFields(rt reflect.Type) bool {
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() || field.Tag.Get("form") == "-" {
+			continue
+		}
+
+		switch {
+		case field.Type == uploadedFileType || (field.Type.Kind() == reflect.Slice && field.Type.Elem() == uploadedFileType):
+			return true
+		case field.Anonymous && field.Tag.Get("form") == "" && field.Type.Kind() == reflect.Struct:
+			if hasFileFields(field.Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package toolbox
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bodyPost struct {
+	Title string   `json:"title" xml:"title" form:"title,required"`
+	Views int      `json:"views" xml:"views" form:"views"`
+	Tags  []string `json:"tags" xml:"tags" form:"tags"`
+}
+
+var readBodyTests = []struct {
+	name        string
+	contentType string
+	body        string
+}{
+	{name: "json", contentType: "application/json", body: `{"title":"Hello","views":42,"tags":["a","b"]}`},
+	{name: "no content type", contentType: "", body: `{"title":"Hello","views":42,"tags":["a","b"]}`},
+	{name: "xml", contentType: "application/xml", body: `<post><title>Hello</title><views>42</views><tags>a</tags><tags>b</tags></post>`},
+	{name: "urlencoded", contentType: "application/x-www-form-urlencoded", body: "title=Hello&views=42&tags=a&tags=b"},
+	{name: "urlencoded with charset", contentType: "application/x-www-form-urlencoded; charset=utf-8", body: "title=Hello&views=42&tags=a&tags=b"},
+}
+
+func TestTools_ReadBody(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range readBodyTests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.body))
+		if e.contentType != "" {
+			req.Header.Set("Content-Type", e.contentType)
+		}
+
+		var post bodyPost
+		err := testTools.ReadBody(httptest.NewRecorder(), req, &post)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		if post.Title != "Hello" || post.Views != 42 || strings.Join(post.Tags, ",") != "a,b" {
+			t.Errorf("%s: wrong result: %+v", e.name, post)
+		}
+	}
+}
+
+func TestTools_ReadBodyMultipart(t *testing.T) {
+	var testTools Tools
+
+	// Without file fields, a multipart form is decoded like any other.
+	req, err := NewMultipartRequestFromReaders("/", nil, map[string]string{"title": "Hello", "views": "42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var post bodyPost
+	err = testTools.ReadBody(httptest.NewRecorder(), req, &post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Title != "Hello" || post.Views != 42 {
+		t.Errorf("wrong result: %+v", post)
+	}
+
+	// With them, the files are saved to BaseUploadDir, which must be set.
+	png, _ := os.ReadFile("./testdata/img.png")
+	newRequest := func() *http.Request {
+		req, err := NewMultipartRequestFromReaders("/", []MultipartFile{
+			{FieldName: "cover", FileName: "cover.png", Content: bytes.NewReader(png)},
+		}, map[string]string{"title": "Hello"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	var withFiles formPost
+	if err := testTools.ReadBody(httptest.NewRecorder(), newRequest(), &withFiles); err == nil {
+		t.Error("expected an error without BaseUploadDir")
+	}
+
+	testTools.BaseUploadDir = t.TempDir()
+	err = testTools.ReadBody(httptest.NewRecorder(), newRequest(), &withFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withFiles.Title != "Hello" || withFiles.Cover == nil || withFiles.Cover.OriginalFileName != "cover.png" {
+		t.Errorf("wrong result: %+v", withFiles)
+	}
+}
+
+func TestTools_ReadBodyErrors(t *testing.T) {
+	testTools := Tools{MaxFormSize: 16}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("title,views\nHello,42\n"))
+	req.Header.Set("Content-Type", "text/csv")
+
+	var post bodyPost
+	err := testTools.ReadBody(httptest.NewRecorder(), req, &post)
+
+	var mediaErr *UnsupportedMediaTypeError
+	if !errors.As(err, &mediaErr) || mediaErr.ContentType != "text/csv" || !errors.Is(err, ErrUnsupportedMediaType) {
+		t.Errorf("expected an *UnsupportedMediaTypeError for text/csv, but got %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("title=Hello&views=lots"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var tooLarge *BodyTooLargeError
+	if err := testTools.ReadBody(httptest.NewRecorder(), req, &post); !errors.As(err, &tooLarge) || tooLarge.Limit != 16 {
+		t.Errorf("expected a *BodyTooLargeError, but got %v", err)
+	}
+
+	testTools.MaxFormSize = 0
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("views=lots"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var formErr *FormError
+	if err := testTools.ReadBody(httptest.NewRecorder(), req, &post); !errors.As(err, &formErr) || len(formErr.Fields) != 2 {
+		t.Errorf("expected a *FormError for title and views, but got %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"title":"Hello"}`))
+	req.Header.Set("Content-Type", "application/json")
+	if err := testTools.ReadForm(httptest.NewRecorder(), req, &post); !errors.Is(err, ErrUnsupportedMediaType) {
+		t.Errorf("expected ReadForm to reject JSON, but got %v", err)
+	}
+}
+
+func TestTools_ReadFormTimes(t *testing.T) {
+	var testTools Tools
+
+	type event struct {
+		Start time.Time  `form:"start"`
+		End   *time.Time `form:"end"`
+		Day   time.Time  `form:"day" layout:"02/01/2006"`
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("start=2024-01-02T15:04:05Z&end=1704207845&day=25/12/2024"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var e event
+	if err := testTools.ReadForm(httptest.NewRecorder(), req, &e); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	if !e.Start.Equal(want) {
+		t.Errorf("wrong start: %v", e.Start)
+	}
+	if e.End == nil || !e.End.Equal(want) {
+		t.Errorf("wrong end: %v", e.End)
+	}
+	if !e.Day.Equal(time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("wrong day: %v", e.Day)
+	}
+
+	// A value that doesn't match the layout is a field error, even if ParseTimeFlexible would accept it.
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("start=soon&day=2024-12-25"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var formErr *FormError
+	if err := testTools.ReadForm(httptest.NewRecorder(), req, &e); !errors.As(err, &formErr) || len(formErr.Fields) != 2 {
+		t.Errorf("expected a *FormError for start and day, but got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package toolbox
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrDigestMismatch matches, with errors.Is, the *DigestMismatchError returned when a request body
+// does not have the digest its headers say it has.
+var ErrDigestMismatch = errors.New("body digest mismatch")
+
+// DigestMismatchError is returned by UploadFiles and ReadJSON, when VerifyContentDigest is set, for a
+// body whose digest is not the one sent in its Content-MD5 or Digest header, which usually means it
+// was corrupted on the way. The digests are base64 encoded, as they are in the headers.
+type DigestMismatchError struct {
+	Algorithm string // the algorithm, as named in a Digest header (e.g. sha-256, or md5)
+	Expected  string // the digest sent by the client
+	Actual    string // the digest of the body received
+}
+
+// Error satisfies the error interface.
+func (e *DigestMismatchError) Error() string {
+	return fmt.Sprintf("%s digest mismatch for the request body: expected %s, got %s", e.Algorithm, e.Expected, e.Actual)
+}
+
+// Is makes errors.Is(err, ErrDigestMismatch) true.
+func (e *DigestMismatchError) Is(target error) bool {
+	return target == ErrDigestMismatch
+}
+
+// digestStrength ranks the algorithms we can verify, strongest first; a Digest header naming more
+// than one is checked with the strongest.
+var digestStrength = []string{"sha-256", "sha", "md5"}
+
+// digestReader hashes everything read from the request body it wraps.
+type digestReader struct {
+	io.ReadCloser
+	h hash.Hash
+}
+
+// Read satisfies the io.Reader interface.
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.ReadCloser.Read(p)
+	d.h.Write(p[:n])
+	return n, err
+}
+
+// verifyBodyDigest, if VerifyContentDigest is set and r has a Content-MD5 or Digest header we can
+// check, starts hashing r.Body, and returns a function to be called once the body has been read,
+// which reads whatever is left of it into the hash, and compares the result with the header. With
+// nothing to check, the function returned does nothing. It must be called before r.Body is wrapped
+// in a limit, so that the remainder is read through that limit.
+func (t *Tools) verifyBodyDigest(r *http.Request) (func() error, error) {
+	none := func() error { return nil }
+	if !t.VerifyContentDigest {
+		return none, nil
+	}
+
+	algorithm, expected, err := t.requestDigest(r)
+	if err != nil || algorithm == "" {
+		return none, err
+	}
+
+	h, _, err := newChecksumHash(algorithm)
+	if err != nil {
+		return none, err
+	}
+
+	body := &digestReader{ReadCloser: r.Body, h: h}
+	r.Body = body
+
+	return func() error {
+		_, err := io.Copy(io.Discard, r.Body)
+		if err != nil {
+			return err
+		}
+
+		actual := body.h.Sum(nil)
+		if subtle.ConstantTimeCompare(actual, expected) != 1 {
+			return &DigestMismatchError{
+				Algorithm: algorithm,
+				Expected:  base64.StdEncoding.EncodeToString(expected),
+				Actual:    base64.StdEncoding.EncodeToString(actual),
+			}
+		}
+		return nil
+	}, nil
+}
+
+// requestDigest returns the strongest algorithm, and the digest, sent in the Content-MD5 and Digest
+// (RFC 3230) headers of r, or an empty algorithm if there are none we support; those we don't are
+// logged and ignored.
+func (t *Tools) requestDigest(r *http.Request) (string, []byte, error) {
+	sent := map[string]string{}
+
+	if v := r.Header.Get("Content-MD5"); v != "" {
+		sent["md5"] = strings.TrimSpace(v)
+	}
+
+	for _, header := range r.Header.Values("Digest") {
+		for _, part := range strings.Split(header, ",") {
+			name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+			if !ok {
+				continue
+			}
+			sent[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+		}
+	}
+
+	for _, algorithm := range digestStrength {
+		value, ok := sent[algorithm]
+		if !ok {
+			continue
+		}
+
+		digest, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return "", nil, fmt.Errorf("the %s digest of the request body is not valid base64", algorithm)
+		}
+		return algorithm, digest, nil
+	}
+
+	if len(sent) > 0 {
+		names := make([]string, 0, len(sent))
+		for name := range sent {
+			names = append(names, name)
+		}
+		t.logDigestSkipped(strings.Join(names, ", "))
+	}
+
+	return "", nil, nil
+}
+
+// logDigestSkipped logs that a body digest wasn't verified, as it used none of the algorithms we
+// support.
+func (t *Tools) logDigestSkipped(algorithms string) {
+	switch {
+	case t.Logger != nil:
+		t.Logger.Warn("request body digest not verified; unsupported algorithm", "algorithms", algorithms)
+	case t.InfoLog != nil:
+		t.InfoLog.Printf("request body digest not verified; unsupported algorithm: %s", algorithms)
+	}
+}
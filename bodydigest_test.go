@@ -0,0 +1,121 @@
+package toolbox
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func base64MD5(b []byte) string {
+	sum := md5.Sum(b)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func base64SHA256(b []byte) string {
+	sum := sha256.Sum256(b)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+var bodyDigestTests = []struct {
+	name      string
+	header    string
+	value     func(body []byte) string
+	mismatch  bool
+	algorithm string
+	logged    bool
+}{
+	{name: "no header"},
+	{name: "correct md5", header: "Content-MD5", value: base64MD5},
+	{name: "wrong md5", header: "Content-MD5", value: func([]byte) string { return base64MD5([]byte("something else")) }, mismatch: true, algorithm: "md5"},
+	{name: "correct sha-256", header: "Digest", value: func(b []byte) string { return "SHA-256=" + base64SHA256(b) }},
+	{name: "strongest is checked", header: "Digest", value: func(b []byte) string { return "md5=" + base64MD5(b) + ", sha-256=" + base64SHA256([]byte("x")) }, mismatch: true, algorithm: "sha-256"},
+	{name: "unsupported algorithm", header: "Digest", value: func([]byte) string { return "unixsum=30637" }, logged: true},
+}
+
+func TestTools_UploadFilesVerifyContentDigest(t *testing.T) {
+	for _, e := range bodyDigestTests {
+		var logged bytes.Buffer
+		testTools := Tools{VerifyContentDigest: true, InfoLog: log.New(&logged, "", 0)}
+
+		request, err := NewMultipartRequest("/", map[string]string{"file": "./testdata/img.png"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(request.Body)
+		request.Body = io.NopCloser(bytes.NewReader(body))
+		if e.header != "" {
+			request.Header.Set(e.header, e.value(body))
+		}
+
+		uploadDir := t.TempDir()
+		_, err = testTools.UploadFiles(request, uploadDir)
+
+		var mismatch *DigestMismatchError
+		switch {
+		case e.mismatch:
+			if !errors.As(err, &mismatch) || !errors.Is(err, ErrDigestMismatch) || mismatch.Algorithm != e.algorithm {
+				t.Errorf("%s: expected a %s *DigestMismatchError, but got %v", e.name, e.algorithm, err)
+			}
+			if entries, _ := os.ReadDir(uploadDir); len(entries) != 0 {
+				t.Errorf("%s: expected nothing to be saved, but found %d files", e.name, len(entries))
+			}
+		case err != nil:
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+		}
+
+		if e.logged != strings.Contains(logged.String(), "unixsum") {
+			t.Errorf("%s: wrong log output: %q", e.name, logged.String())
+		}
+	}
+}
+
+func TestTools_ReadJSONVerifyContentDigest(t *testing.T) {
+	for _, e := range bodyDigestTests {
+		for _, dupes := range []bool{false, true} {
+			testTools := Tools{VerifyContentDigest: true, RejectDuplicateJSONKeys: dupes}
+
+			body := []byte(`{"foo": "bar"}` + "\n")
+			request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+			if e.header != "" {
+				request.Header.Set(e.header, e.value(body))
+			}
+
+			var decoded struct {
+				Foo string `json:"foo"`
+			}
+			err := testTools.ReadJSON(httptest.NewRecorder(), request, &decoded)
+
+			if e.mismatch != errors.Is(err, ErrDigestMismatch) || (!e.mismatch && err != nil) {
+				t.Errorf("%s (duplicate key check: %t): unexpected result: %v", e.name, dupes, err)
+			}
+		}
+	}
+
+	// Without VerifyContentDigest, the headers are ignored.
+	var testTools Tools
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"foo": "bar"}`))
+	request.Header.Set("Content-MD5", base64MD5([]byte("something else")))
+	var decoded struct {
+		Foo string `json:"foo"`
+	}
+	if err := testTools.ReadJSON(httptest.NewRecorder(), request, &decoded); err != nil {
+		t.Error(err)
+	}
+
+	// A digest that isn't base64 is an error of its own.
+	testTools.VerifyContentDigest = true
+	request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"foo": "bar"}`))
+	request.Header.Set("Content-MD5", "not base64!")
+	if err := testTools.ReadJSON(httptest.NewRecorder(), request, &decoded); err == nil || errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("expected an error for an invalid digest, but got %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package toolbox
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// CanonicalJSON returns the JSON encoding of data in the canonical form described by RFC 8785 (the
+// JSON Canonicalization Scheme), so that equal values always give the same bytes, whatever the order
+// of map keys or struct fields: object keys are sorted by their UTF-16 code units, there is no
+// whitespace, strings use the shortest escaping, and numbers are formatted as JavaScript would format
+// them. data is first encoded with encoding/json, so struct tags and MarshalJSON methods apply; to
+// canonicalize JSON text you already have, pass it as a json.RawMessage. As in RFC 8785, numbers are
+// IEEE 754 doubles, so integers beyond 2^53 lose precision.
+func CanonicalJSON(data any) ([]byte, error) {
+	out, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := decodeJSONValue(out)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	err = writeCanonicalJSON(&buf, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SignJSON returns the canonical JSON encoding of data (see CanonicalJSON), and the hex encoded
+// HMAC-SHA256 signature of it, made with secret. Send the returned body, rather than encoding data
+// again, so that the receiver sees exactly what was signed.
+func SignJSON(secret []byte, data any) ([]byte, string, error) {
+	body, err := CanonicalJSON(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	return body, hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// VerifyJSONSignature checks signature, a hex encoded HMAC-SHA256 signature made by SignJSON, against
+// the canonical JSON encoding of data, and returns ErrInvalidSignature if it doesn't match. Since the
+// check is of the canonical form, data may have been decoded and re-encoded, or have its keys in a
+// different order, since it was signed; pass a json.RawMessage to check a body as received.
+func VerifyJSONSignature(secret []byte, data any, signature string) error {
+	body, err := CanonicalJSON(data)
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+
+	decoded, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(decoded, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// writeCanonicalJSON writes value, as decoded by decodeJSONValue, to buf in canonical form.
+func writeCanonicalJSON(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+
+	case string:
+		writeCanonicalString(buf, v)
+
+	case json.Number:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		if err != nil || math.IsInf(f, 0) {
+			return fmt.Errorf("number %s cannot be represented in canonical JSON", v)
+		}
+		buf.WriteString(canonicalNumber(f))
+
+	case []any:
+		buf.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonicalJSON(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUTF16(keys[i], keys[j])
+		})
+
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, key)
+			buf.WriteByte(':')
+			if err := writeCanonicalJSON(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+
+	default:
+		return fmt.Errorf("unexpected JSON value of type %T", value)
+	}
+
+	return nil
+}
+
+// writeCanonicalString writes s to buf as a JSON string, escaping only what RFC 8785 requires.
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// canonicalNumber formats f as JavaScript's Number.prototype.toString does, as RFC 8785 requires.
+func canonicalNumber(f float64) string {
+	if f == 0 {
+		// This includes negative zero.
+		return "0"
+	}
+
+	sign := ""
+	if f < 0 {
+		sign = "-"
+		f = -f
+	}
+
+	format := byte('e')
+	if f >= 1e-6 && f < 1e21 {
+		format = 'f'
+	}
+	s := strconv.FormatFloat(f, format, -1, 64)
+
+	// Go writes exponents with at least two digits (1e+09), but JavaScript doesn't (1e+9).
+	if i := strings.IndexByte(s, 'e'); i > 0 && s[i+2] == '0' {
+		s = s[:i+2] + s[i+3:]
+	}
+
+	return sign + s
+}
+
+// lessUTF16 reports whether a sorts before b when both are compared as UTF-16 code units, which
+// differs from comparing their UTF-8 bytes for characters outside the Basic Multilingual Plane.
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
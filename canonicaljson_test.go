@@ -0,0 +1,125 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+var canonicalJSONTests = []struct {
+	name     string
+	input    string
+	expected string
+}{
+	// From section 3.2.2 of RFC 8785.
+	{
+		name:     "rfc 8785 example",
+		input:    `{"numbers":[333333333.33333329,1E30,4.50,2e-3,0.000000000000000000000000001],"string":"\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/","literals":[null,true,false]}`,
+		expected: `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+	},
+	// From section 3.2.3 of RFC 8785: keys are sorted by UTF-16 code units.
+	{
+		name:     "rfc 8785 sorting",
+		input:    `{"\u20ac":"Euro Sign","\r":"Carriage Return","\ufb33":"Hebrew Letter Dalet With Dagesh","1":"One","\ud83d\ude00":"Emoji: Grinning Face","\u0080":"Control","\u00f6":"Latin Small Letter O With Diaeresis"}`,
+		expected: "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\",\"\u20ac\":\"Euro Sign\",\"\U0001f600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}",
+	},
+	{name: "whitespace", input: " { \"b\" : [ 1 , 2 ] , \"a\" : { } } ", expected: `{"a":{},"b":[1,2]}`},
+	{name: "numbers", input: `[0,-0,1.0,-1.5,1e21,1e20,1e-6,1e-7,123456789012345678]`, expected: `[0,0,1,-1.5,1e+21,100000000000000000000,0.000001,1e-7,123456789012345680]`},
+	{name: "html is not escaped", input: `{"a":"<b>&"}`, expected: `{"a":"<b>&"}`},
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	for _, e := range canonicalJSONTests {
+		out, err := CanonicalJSON(json.RawMessage(e.input))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+		if string(out) != e.expected {
+			t.Errorf("%s: expected\n%s\nbut got\n%s", e.name, e.expected, out)
+		}
+	}
+}
+
+func TestCanonicalJSONMapOrder(t *testing.T) {
+	a := make(map[string]any)
+	b := make(map[string]any)
+	keys := []string{"zebra", "apple", "mango", "kiwi", "banana"}
+	for i, key := range keys {
+		a[key] = i
+		b[keys[len(keys)-1-i]] = len(keys) - 1 - i
+	}
+	a["nested"] = map[string]int{"y": 1, "x": 2}
+	b["nested"] = map[string]int{"x": 2, "y": 1}
+
+	outA, err := CanonicalJSON(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outB, err := CanonicalJSON(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(outA) != string(outB) {
+		t.Errorf("expected identical output, but got\n%s\n%s", outA, outB)
+	}
+	if string(outA) != `{"apple":1,"banana":4,"kiwi":3,"mango":2,"nested":{"x":2,"y":1},"zebra":0}` {
+		t.Errorf("unexpected canonical form %s", outA)
+	}
+}
+
+func TestCanonicalJSONStruct(t *testing.T) {
+	type payload struct {
+		Zulu  string `json:"zulu"`
+		Alpha int    `json:"alpha"`
+	}
+
+	out, err := CanonicalJSON(payload{Zulu: "z", Alpha: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `{"alpha":1,"zulu":"z"}` {
+		t.Errorf("expected the struct fields sorted, but got %s", out)
+	}
+}
+
+func TestCanonicalJSONInvalid(t *testing.T) {
+	if _, err := CanonicalJSON(json.RawMessage(`[1e400]`)); err == nil {
+		t.Error("expected an error for a number out of range, but got none")
+	}
+	if _, err := CanonicalJSON(make(chan int)); err == nil {
+		t.Error("expected an error for a value that can't be encoded, but got none")
+	}
+}
+
+func TestSignJSON(t *testing.T) {
+	secret := []byte("secret")
+
+	body, signature, err := SignJSON(secret, map[string]any{"b": 2, "a": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"a":1,"b":2}` {
+		t.Errorf("expected the canonical body, but got %s", body)
+	}
+	if signature != sign("secret", `{"a":1,"b":2}`) {
+		t.Errorf("expected the signature of the canonical body, but got %s", signature)
+	}
+
+	// The same data, encoded differently, still verifies.
+	err = VerifyJSONSignature(secret, json.RawMessage(`{ "b": 2.0, "a": 1 }`), signature)
+	if err != nil {
+		t.Errorf("expected the signature to verify, but got %v", err)
+	}
+
+	err = VerifyJSONSignature(secret, json.RawMessage(`{"a":1,"b":3}`), signature)
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for different data, but got %v", err)
+	}
+
+	err = VerifyJSONSignature([]byte("wrong"), json.RawMessage(body), signature)
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for the wrong secret, but got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package toolbox
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// ChecksumMismatchError is returned by VerifyFileChecksum when a file does not have the expected checksum.
+type ChecksumMismatchError struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+// Error satisfies the error interface.
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s: expected %s, got %s", e.Path, e.Expected, e.Actual)
+}
+
+// newChecksumHash returns a new hash for algo, which may be sha256, sha1, or md5 (with or without a
+// hyphen, in any case), along with the name used for it in a Digest header.
+func newChecksumHash(algo string) (hash.Hash, string, error) {
+	switch strings.ReplaceAll(strings.ToLower(algo), "-", "") {
+	case "sha256":
+		return sha256.New(), "sha-256", nil
+	case "sha1", "sha":
+		return sha1.New(), "sha", nil
+	case "md5":
+		return md5.New(), "md5", nil
+	}
+
+	return nil, "", fmt.Errorf("unsupported checksum algorithm %q", algo)
+}
+
+// fileHash streams the file at path through the hash for algo, and returns the digest along with
+// the name of the algorithm for use in a Digest header.
+func fileHash(path, algo string) ([]byte, string, error) {
+	h, name, err := newChecksumHash(algo)
+	if err != nil {
+		return nil, "", err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return h.Sum(nil), name, nil
+}
+
+// FileChecksum returns the hex encoded checksum of the file at path, using algo, which may be sha256,
+// sha1, or md5. The file is streamed, rather than read into memory.
+func (t *Tools) FileChecksum(path string, algo string) (string, error) {
+	sum, _, err := fileHash(path, algo)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(sum), nil
+}
+
+// VerifyFileChecksum computes the checksum of the file at path using algo, and compares it in constant
+// time with expected, which is hex encoded. If they differ, a *ChecksumMismatchError is returned.
+func (t *Tools) VerifyFileChecksum(path, expected, algo string) error {
+	actual, err := t.FileChecksum(path, algo)
+	if err != nil {
+		return err
+	}
+
+	expected = strings.ToLower(strings.TrimSpace(expected))
+	if subtle.ConstantTimeCompare([]byte(actual), []byte(expected)) != 1 {
+		return &ChecksumMismatchError{Path: path, Expected: expected, Actual: actual}
+	}
+
+	return nil
+}
+
+// digestHeader returns the value for an RFC 3230 Digest header for the file at path, e.g.
+// sha-256=X48E9qOokqqrvdts8nOJRJN3OWDUoyWxBf7kbu9DBPE=
+func digestHeader(path, algo string) (string, error) {
+	sum, name, err := fileHash(path, algo)
+	if err != nil {
+		return "", err
+	}
+
+	return name + "=" + base64.StdEncoding.EncodeToString(sum), nil
+}
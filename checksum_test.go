@@ -0,0 +1,74 @@
+package toolbox
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTools_FileChecksum(t *testing.T) {
+	var testTools Tools
+
+	for _, algo := range []string{"sha256", "SHA-1", "md5"} {
+		sum, err := testTools.FileChecksum("./testdata/img.png", algo)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", algo, err)
+			continue
+		}
+
+		err = testTools.VerifyFileChecksum("./testdata/img.png", strings.ToUpper(sum), algo)
+		if err != nil {
+			t.Errorf("%s: verification failed: %v", algo, err)
+		}
+	}
+
+	_, err := testTools.FileChecksum("./testdata/img.png", "crc32")
+	if err == nil {
+		t.Error("expected error for unknown algorithm, but none received")
+	}
+}
+
+func TestTools_VerifyFileChecksumMismatch(t *testing.T) {
+	var testTools Tools
+
+	expected, err := testTools.FileChecksum("./testdata/img.png", "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// corrupt one byte in a copy of the file
+	data, _ := os.ReadFile("./testdata/img.png")
+	data[len(data)/2] ^= 0xff
+	corrupt := filepath.Join(t.TempDir(), "img.png")
+	_ = os.WriteFile(corrupt, data, 0644)
+
+	err = testTools.VerifyFileChecksum(corrupt, expected, "sha256")
+
+	var mismatch *ChecksumMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected ChecksumMismatchError, but got %v", err)
+	}
+
+	if mismatch.Expected != expected || mismatch.Actual == expected || len(mismatch.Actual) != 64 {
+		t.Errorf("wrong values in mismatch error: %+v", mismatch)
+	}
+}
+
+func TestTools_DownloadStaticFileDigest(t *testing.T) {
+	var testTools Tools
+	testTools.DownloadDigestAlgorithm = "sha256"
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	testTools.DownloadStaticFile(rr, req, "./testdata", "img.png", "img.png")
+
+	expected, _ := digestHeader("./testdata/img.png", "sha256")
+	if digest := rr.Header().Get("Digest"); digest != expected || !strings.HasPrefix(digest, "sha-256=") {
+		t.Errorf("wrong digest header; expected %s but got %s", expected, digest)
+	}
+}
@@ -0,0 +1,49 @@
+package toolbox
+
+import (
+	"maps"
+	"slices"
+)
+
+// Option configures a Tools, for New and With. Since every setting is a field of Tools, an Option is
+// just a function that sets some of them, e.g.
+//
+//	func(t *toolbox.Tools) { t.MaxJSONSize = 1 << 20 }
+type Option func(*Tools)
+
+// Clone returns a copy of t that can be changed without affecting t, and vice versa: its slices, its
+// map, and the ImageNormalization and EnvelopeNames it points to are copied too. Loggers, hooks and
+// functions, and RemoteClient, are shared, since they are meant to be used by many Tools at once, and
+// should not be modified once in use.
+func (t *Tools) Clone() *Tools {
+	clone := *t
+
+	clone.AllowedFileTypes = slices.Clone(t.AllowedFileTypes)
+	clone.AcceptedJSONTypes = slices.Clone(t.AcceptedJSONTypes)
+	clone.DebugRedactHeaders = slices.Clone(t.DebugRedactHeaders)
+	clone.DebugRedactFields = slices.Clone(t.DebugRedactFields)
+	clone.RemoteRedactHeaders = slices.Clone(t.RemoteRedactHeaders)
+	clone.SlugTransliterations = maps.Clone(t.SlugTransliterations)
+	if t.NormalizeImages != nil {
+		n := *t.NormalizeImages
+		clone.NormalizeImages = &n
+	}
+	if t.EnvelopeNames != nil {
+		n := *t.EnvelopeNames
+		clone.EnvelopeNames = &n
+	}
+
+	return &clone
+}
+
+// With returns a Clone of t, with opts applied to it, for handlers that need slightly different
+// settings than the rest of the application, e.g.
+//
+//	avatars := app.Tools.With(func(t *toolbox.Tools) { t.AllowedFileTypes = []string{"image/png"} })
+func (t *Tools) With(opts ...Option) *Tools {
+	clone := t.Clone()
+	for _, opt := range opts {
+		opt(clone)
+	}
+	return clone
+}
@@ -0,0 +1,111 @@
+package toolbox
+
+import (
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestTools_Clone(t *testing.T) {
+	original := New(func(t *Tools) {
+		t.AllowedFileTypes = []string{"image/png", "image/jpeg"}
+		t.DebugRedactFields = []string{"token"}
+		t.SlugTransliterations = map[rune]string{'ü': "ue"}
+		t.NormalizeImages = &ImageNormalization{Format: "png"}
+	})
+
+	clone := original.Clone()
+	clone.AllowedFileTypes[0] = "application/pdf"
+	clone.AllowedFileTypes = append(clone.AllowedFileTypes, "text/plain")
+	clone.DebugRedactFields[0] = "secret"
+	clone.SlugTransliterations['ö'] = "oe"
+	clone.NormalizeImages.Format = "jpeg"
+	clone.MaxJSONSize = 1
+
+	if !slices.Equal(original.AllowedFileTypes, []string{"image/png", "image/jpeg"}) || original.DebugRedactFields[0] != "token" {
+		t.Errorf("changing the clone's slices changed the original: %v, %v", original.AllowedFileTypes, original.DebugRedactFields)
+	}
+	if len(original.SlugTransliterations) != 1 || original.NormalizeImages.Format != "png" || original.MaxJSONSize != defaultMaxUpload {
+		t.Error("changing the clone changed the original")
+	}
+	if clone.InfoLog != original.InfoLog || clone.ErrorLog != original.ErrorLog {
+		t.Error("expected the loggers to be shared")
+	}
+}
+
+// sharedFields are the fields of Tools that Clone deliberately doesn't copy.
+var sharedFields = map[string]bool{"ErrorLog": true, "InfoLog": true, "Logger": true, "RemoteClient": true}
+
+func TestTools_CloneCopiesEverything(t *testing.T) {
+	// Give every slice, map and pointer a value, so that a field added later without being copied
+	// by Clone is caught here.
+	var original Tools
+	v := reflect.ValueOf(&original).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.Slice:
+			f.Set(reflect.MakeSlice(f.Type(), 1, 1))
+		case reflect.Map:
+			f.Set(reflect.MakeMap(f.Type()))
+		case reflect.Pointer:
+			f.Set(reflect.New(f.Type().Elem()))
+		}
+	}
+
+	c := reflect.ValueOf(original.Clone()).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Name
+		switch v.Field(i).Kind() {
+		case reflect.Slice, reflect.Map, reflect.Pointer:
+			if sharedFields[name] {
+				continue
+			}
+			if v.Field(i).UnsafePointer() == c.Field(i).UnsafePointer() {
+				t.Errorf("%s is shared by the clone and the original", name)
+			}
+		}
+	}
+}
+
+func TestTools_CloneConcurrent(t *testing.T) {
+	original := Tools{AllowedFileTypes: []string{"image/png"}, SlugTransliterations: map[rune]string{'ü': "ue"}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			clone := original.With(func(t *Tools) { t.MaxFileSize = 1 })
+			clone.AllowedFileTypes[0] = "text/plain"
+			clone.SlugTransliterations['ö'] = "oe"
+		}()
+		go func() {
+			defer wg.Done()
+			_, _ = original.Slugify("grüße")
+			_ = fileTypeAllowed(original.AllowedFileTypes, "image/png")
+		}()
+	}
+	wg.Wait()
+
+	if original.AllowedFileTypes[0] != "image/png" || len(original.SlugTransliterations) != 1 || original.MaxFileSize != 0 {
+		t.Errorf("the original was changed: %+v", original)
+	}
+}
+
+func TestTools_With(t *testing.T) {
+	original := New()
+
+	scoped := original.With(
+		func(t *Tools) { t.MaxJSONSize = 1 << 20 },
+		func(t *Tools) { t.AllowedFileTypes = []string{"image/png"} },
+	)
+
+	if scoped.MaxJSONSize != 1<<20 || !slices.Equal(scoped.AllowedFileTypes, []string{"image/png"}) || scoped.MaxFileSize != defaultMaxUpload {
+		t.Errorf("options were not applied to the clone: %+v", scoped)
+	}
+	if original.MaxJSONSize != defaultMaxUpload || original.AllowedFileTypes != nil {
+		t.Errorf("options were applied to the original: %+v", original)
+	}
+}
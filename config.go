@@ -0,0 +1,276 @@
+package toolbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// ToolsConfig is the configuration a Tools actually uses, with every default applied, as returned by
+// Config, for diagnostics: it can be logged, or sent from an admin endpoint with WriteJSON. Hooks,
+// clients, loggers and other values that can't be shown, or that might hold credentials, are given
+// only as "[set]" or "[unset]".
+type ToolsConfig struct {
+	// Reading request bodies.
+	MaxJSONSize             int           `json:"max_json_size"`
+	MaxXMLSize              int           `json:"max_xml_size"`
+	MaxFormSize             int           `json:"max_form_size"`
+	MaxDataURISize          int           `json:"max_data_uri_size"`
+	MaxArrayElements        int           `json:"max_array_elements"`
+	MaxJSONDepth            int           `json:"max_json_depth"` // zero for no limit
+	AcceptedJSONTypes       []string      `json:"accepted_json_types"`
+	AllowUnknownFields      bool          `json:"allow_unknown_fields"`
+	ReportAllUnknownFields  bool          `json:"report_all_unknown_fields"`
+	DisallowNullFields      bool          `json:"disallow_null_fields"`
+	RejectDuplicateJSONKeys bool          `json:"reject_duplicate_json_keys"`
+	RequireJSONFields       bool          `json:"require_json_fields"`
+	AllowEmptyBody          bool          `json:"allow_empty_body"`
+	SkipValidation          bool          `json:"skip_validation"`
+	VerifyContentDigest     bool          `json:"verify_content_digest"`
+	JSONReadTimeout         time.Duration `json:"json_read_timeout"` // zero for no timeout
+	BodyReadTimeout         time.Duration `json:"body_read_timeout"` // zero for no timeout
+
+	// Uploads.
+	MaxFileSize          int              `json:"max_file_size"`
+	MaxTotalUploadSize   int              `json:"max_total_upload_size"` // zero for no limit
+	AllowedFileTypes     []string         `json:"allowed_file_types"`    // empty for any type
+	FileNameStrategy     FileNameStrategy `json:"file_name_strategy"`
+	FileNameLength       int              `json:"file_name_length"`
+	MaxFileNameLength    int              `json:"max_file_name_length"`
+	BaseUploadDir        string           `json:"base_upload_dir"`
+	TempDir              string           `json:"temp_dir"`
+	UploadCopyBufferSize int              `json:"upload_copy_buffer_size"`
+	MaxUploadDuration    time.Duration    `json:"max_upload_duration"` // zero for no limit
+	MinUploadRate        int64            `json:"min_upload_rate"`     // zero for no limit
+	ScanFunc             string           `json:"scan_func"`
+	NormalizeImages      string           `json:"normalize_images"`
+
+	// Responses and downloads.
+	JSONIndent              string        `json:"json_indent"`
+	JSONDisableHTMLEscape   bool          `json:"json_disable_html_escape"`
+	JSONContentType         string        `json:"json_content_type"`
+	XMLIndent               string        `json:"xml_indent"`
+	JSONHeartbeatByte       string        `json:"json_heartbeat_byte"`
+	NDJSONFlushItems        int           `json:"ndjson_flush_items"`
+	NDJSONFlushInterval     time.Duration `json:"ndjson_flush_interval"`
+	JSONMarshalHook         string        `json:"json_marshal_hook"`
+	SuppressInternalErrors  bool          `json:"suppress_internal_errors"`
+	InternalErrorMessage    string        `json:"internal_error_message"`
+	DownloadDigestAlgorithm string        `json:"download_digest_algorithm"`
+	ServePrecompressed      bool          `json:"serve_precompressed"`
+	RequestIDHeader         string        `json:"request_id_header"`
+	RequestIDField          string        `json:"request_id_field"`
+
+	// Logging, hooks and remote calls.
+	Logger           string        `json:"logger"`
+	Debug            bool          `json:"debug"`
+	LogOperations    bool          `json:"log_operations"`
+	DebugBodyLimit   int           `json:"debug_body_limit"`
+	OnDownload       string        `json:"on_download"`
+	ResponseMetrics  string        `json:"response_metrics"`
+	DecodeMetrics    string        `json:"decode_metrics"`
+	RemoteClient     string        `json:"remote_client"`
+	RemoteTimeout    time.Duration `json:"remote_timeout"` // only used if RemoteClient is unset
+	OnRemoteRequest  string        `json:"on_remote_request"`
+	OnRemoteResponse string        `json:"on_remote_response"`
+}
+
+// Config returns the configuration t actually uses, with the defaults applied for anything that isn't
+// set, so that a MaxJSONSize of zero, for example, is shown as the 10MB that is used instead. Limits
+// set for a single request, with WithMaxJSONSize and the like, aren't included.
+func (t *Tools) Config() ToolsConfig {
+	strategy := t.FileNameStrategy
+	if strategy == "" {
+		strategy = FileNameRandom
+	}
+
+	heartbeat := t.JSONHeartbeatByte
+	if heartbeat != ' ' && heartbeat != '\n' && heartbeat != '\r' && heartbeat != '\t' {
+		heartbeat = ' '
+	}
+
+	internalMessage := t.InternalErrorMessage
+	if internalMessage == "" {
+		internalMessage = "internal server error"
+	}
+
+	remoteTimeout := t.RemoteTimeout
+	if remoteTimeout == 0 {
+		remoteTimeout = defaultRemoteTimeout
+	}
+
+	// Uploads fill in their limits the same way, but per call.
+	opts := t.uploadOptions(context.Background(), UploadOptions{})
+
+	return ToolsConfig{
+		MaxJSONSize:             firstLimit(t.MaxJSONSize),
+		MaxXMLSize:              firstLimit(t.MaxXMLSize),
+		MaxFormSize:             t.maxFormSize(),
+		MaxDataURISize:          positiveOr(t.MaxDataURISize, defaultMaxDataURISize),
+		MaxArrayElements:        positiveOr(t.MaxArrayElements, defaultMaxJSONArrayElements),
+		MaxJSONDepth:            max(t.MaxJSONDepth, 0),
+		AcceptedJSONTypes:       append([]string{"application/json", "application/*+json"}, t.AcceptedJSONTypes...),
+		AllowUnknownFields:      t.AllowUnknownFields,
+		ReportAllUnknownFields:  t.ReportAllUnknownFields,
+		DisallowNullFields:      t.DisallowNullFields,
+		RejectDuplicateJSONKeys: t.RejectDuplicateJSONKeys,
+		RequireJSONFields:       t.RequireJSONFields,
+		AllowEmptyBody:          t.AllowEmptyBody,
+		SkipValidation:          t.SkipValidation,
+		VerifyContentDigest:     t.VerifyContentDigest,
+		JSONReadTimeout:         max(t.JSONReadTimeout, 0),
+		BodyReadTimeout:         max(t.BodyReadTimeout, 0),
+
+		MaxFileSize:          opts.MaxFileSize,
+		MaxTotalUploadSize:   max(t.MaxTotalUploadSize, 0),
+		AllowedFileTypes:     append([]string(nil), opts.AllowedTypes...),
+		FileNameStrategy:     strategy,
+		FileNameLength:       positiveOr(t.FileNameLength, defaultFileNameLength),
+		MaxFileNameLength:    opts.MaxFileNameLength,
+		BaseUploadDir:        t.BaseUploadDir,
+		TempDir:              t.tempDir(),
+		UploadCopyBufferSize: t.uploadCopyBufferSize(),
+		MaxUploadDuration:    max(opts.MaxUploadDuration, 0),
+		MinUploadRate:        max(opts.MinUploadRate, 0),
+		ScanFunc:             setOrUnset(t.ScanFunc != nil),
+		NormalizeImages:      setOrUnset(t.NormalizeImages != nil),
+
+		JSONIndent:              t.JSONIndent,
+		JSONDisableHTMLEscape:   t.JSONDisableHTMLEscape,
+		JSONContentType:         t.jsonContentType(),
+		XMLIndent:               t.XMLIndent,
+		JSONHeartbeatByte:       string(heartbeat),
+		NDJSONFlushItems:        positiveOr(t.NDJSONFlushItems, defaultNDJSONFlushItems),
+		NDJSONFlushInterval:     positiveOr(t.NDJSONFlushInterval, defaultNDJSONFlushInterval),
+		JSONMarshalHook:         setOrUnset(t.JSONMarshalHook != nil),
+		SuppressInternalErrors:  t.SuppressInternalErrors,
+		InternalErrorMessage:    internalMessage,
+		DownloadDigestAlgorithm: t.DownloadDigestAlgorithm,
+		ServePrecompressed:      t.ServePrecompressed,
+		RequestIDHeader:         t.requestIDHeader(),
+		RequestIDField:          t.requestIDField(),
+
+		Logger:           setOrUnset(t.Logger != nil),
+		Debug:            t.Debug,
+		LogOperations:    t.LogOperations,
+		DebugBodyLimit:   positiveOr(t.DebugBodyLimit, defaultDebugBodyLimit),
+		OnDownload:       setOrUnset(t.OnDownload != nil),
+		ResponseMetrics:  setOrUnset(t.ResponseMetrics != nil),
+		DecodeMetrics:    setOrUnset(t.DecodeMetrics != nil),
+		RemoteClient:     setOrUnset(t.RemoteClient != nil),
+		RemoteTimeout:    remoteTimeout,
+		OnRemoteRequest:  setOrUnset(t.OnRemoteRequest != nil),
+		OnRemoteResponse: setOrUnset(t.OnRemoteResponse != nil),
+	}
+}
+
+// Validate reports the settings on t that are wrong, rather than just unusual: negative sizes, limits
+// and timeouts; empty entries in AllowedFileTypes and AcceptedJSONTypes; a FileNameStrategy,
+// DownloadDigestAlgorithm or JSONHeartbeatByte that isn't one of those allowed; and a BaseUploadDir
+// or TempDir that isn't an existing directory. Each problem is a FieldError, named for the field, in
+// the error's FieldErrors, so that all of them can be fixed at once. It is meant to be called once, at
+// startup; nothing else in the package calls it.
+func (t *Tools) Validate() error {
+	var errs FieldErrors
+
+	sizes := []struct {
+		field string
+		value int64
+	}{
+		{"MaxJSONSize", int64(t.MaxJSONSize)},
+		{"MaxXMLSize", int64(t.MaxXMLSize)},
+		{"MaxFileSize", int64(t.MaxFileSize)},
+		{"MaxFormSize", int64(t.MaxFormSize)},
+		{"MaxDataURISize", int64(t.MaxDataURISize)},
+		{"MaxArrayElements", int64(t.MaxArrayElements)},
+		{"MaxJSONDepth", int64(t.MaxJSONDepth)},
+		{"MaxTotalUploadSize", int64(t.MaxTotalUploadSize)},
+		{"FileNameLength", int64(t.FileNameLength)},
+		{"MaxFileNameLength", int64(t.MaxFileNameLength)},
+		{"UploadCopyBufferSize", int64(t.UploadCopyBufferSize)},
+		{"MinUploadRate", t.MinUploadRate},
+		{"NDJSONFlushItems", int64(t.NDJSONFlushItems)},
+		{"DebugBodyLimit", int64(t.DebugBodyLimit)},
+		{"JSONReadTimeout", int64(t.JSONReadTimeout)},
+		{"BodyReadTimeout", int64(t.BodyReadTimeout)},
+		{"MaxUploadDuration", int64(t.MaxUploadDuration)},
+		{"NDJSONFlushInterval", int64(t.NDJSONFlushInterval)},
+		{"RemoteTimeout", int64(t.RemoteTimeout)},
+	}
+	for _, size := range sizes {
+		if size.value < 0 {
+			errs.Add(size.field, "negative", "must not be negative")
+		}
+	}
+
+	lists := []struct {
+		field string
+		value []string
+	}{
+		{"AllowedFileTypes", t.AllowedFileTypes},
+		{"AcceptedJSONTypes", t.AcceptedJSONTypes},
+	}
+	for _, list := range lists {
+		for i, s := range list.value {
+			if strings.TrimSpace(s) == "" {
+				errs = append(errs, FieldError{Field: list.field, Index: i + 1, Code: "empty", Message: "must not have empty entries"})
+			}
+		}
+	}
+
+	switch t.FileNameStrategy {
+	case "", FileNameRandom, FileNameUUID, FileNameTimestamp:
+	default:
+		errs.Add("FileNameStrategy", "invalid", fmt.Sprintf("unknown strategy %q", t.FileNameStrategy))
+	}
+
+	if t.DownloadDigestAlgorithm != "" {
+		if _, _, err := newChecksumHash(t.DownloadDigestAlgorithm); err != nil {
+			errs.Add("DownloadDigestAlgorithm", "invalid", err.Error())
+		}
+	}
+
+	switch t.JSONHeartbeatByte {
+	case 0, ' ', '\n', '\r', '\t':
+	default:
+		errs.Add("JSONHeartbeatByte", "invalid", "must be JSON whitespace")
+	}
+
+	dirs := []struct {
+		field string
+		value string
+	}{
+		{"BaseUploadDir", t.BaseUploadDir},
+		{"TempDir", t.TempDir},
+	}
+	for _, dir := range dirs {
+		if dir.value == "" {
+			continue
+		}
+		if info, err := os.Stat(dir.value); err != nil {
+			errs.Add(dir.field, "missing", fmt.Sprintf("%s does not exist", dir.value))
+		} else if !info.IsDir() {
+			errs.Add(dir.field, "invalid", fmt.Sprintf("%s is not a directory", dir.value))
+		}
+	}
+
+	return errs.Err()
+}
+
+// positiveOr returns n, or def if n isn't positive.
+func positiveOr[T int | int64 | time.Duration](n, def T) T {
+	if n > 0 {
+		return n
+	}
+	return def
+}
+
+// setOrUnset is how ToolsConfig shows a value that can't, or shouldn't, be shown.
+func setOrUnset(set bool) string {
+	if set {
+		return "[set]"
+	}
+	return "[unset]"
+}
@@ -0,0 +1,134 @@
+package toolbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTools_Config(t *testing.T) {
+	// The zero value shows the defaults that are used in place of what isn't set.
+	var testTools Tools
+	config := testTools.Config()
+
+	if config.MaxJSONSize != defaultMaxUpload || config.MaxXMLSize != defaultMaxUpload || config.MaxFormSize != defaultMaxUpload ||
+		config.MaxFileSize != defaultMaxUpload {
+		t.Errorf("expected the default sizes, but got %+v", config)
+	}
+	if config.MaxJSONDepth != 0 || config.MaxArrayElements != defaultMaxJSONArrayElements || config.FileNameLength != defaultFileNameLength ||
+		config.MaxFileNameLength != defaultMaxFileNameLength || config.UploadCopyBufferSize != defaultUploadCopyBufferSize {
+		t.Errorf("expected the default limits, but got %+v", config)
+	}
+	if config.FileNameStrategy != FileNameRandom || config.TempDir != os.TempDir() || config.RemoteTimeout != defaultRemoteTimeout ||
+		config.RequestIDHeader != "X-Request-ID" || config.InternalErrorMessage != "internal server error" || config.JSONHeartbeatByte != " " {
+		t.Errorf("wrong defaults: %+v", config)
+	}
+
+	// New's own defaults are shown as they are, and so is anything set.
+	testTools = New()
+	testTools.MaxFileSize = 1024
+	testTools.AllowedFileTypes = []string{"image/png"}
+	testTools.RemoteTimeout = 5 * time.Second
+	config = testTools.Config()
+
+	if config.MaxJSONDepth != defaultMaxJSONDepth || config.MaxFileSize != 1024 || config.RemoteTimeout != 5*time.Second {
+		t.Errorf("expected the values set, but got %+v", config)
+	}
+	if len(config.AllowedFileTypes) != 1 || config.AllowedFileTypes[0] != "image/png" {
+		t.Errorf("wrong allowed file types: %v", config.AllowedFileTypes)
+	}
+	config.AllowedFileTypes[0] = "changed"
+	if testTools.AllowedFileTypes[0] != "image/png" {
+		t.Error("expected Config to copy AllowedFileTypes")
+	}
+}
+
+func TestTools_ConfigRedaction(t *testing.T) {
+	testTools := Tools{
+		RemoteClient: &http.Client{},
+		Logger:       slog.Default(),
+		ScanFunc:     func(context.Context, string, io.Reader) error { return nil },
+	}
+
+	body, err := json.Marshal(testTools.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config map[string]any
+	if err := json.Unmarshal(body, &config); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"remote_client": "[set]", "logger": "[set]", "scan_func": "[set]", "on_download": "[unset]", "on_remote_request": "[unset]"} {
+		if config[key] != want {
+			t.Errorf("expected %s to be %s, but got %v", key, want, config[key])
+		}
+	}
+	if config["max_json_size"] != float64(defaultMaxUpload) {
+		t.Errorf("expected max_json_size in the JSON, but got %s", body)
+	}
+}
+
+func TestTools_Validate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var validateTests = []struct {
+		name  string
+		tools Tools
+		field string
+		code  string
+	}{
+		{name: "negative size", tools: Tools{MaxJSONSize: -1}, field: "MaxJSONSize", code: "negative"},
+		{name: "negative file size", tools: Tools{MaxFileSize: -10}, field: "MaxFileSize", code: "negative"},
+		{name: "negative timeout", tools: Tools{RemoteTimeout: -time.Second}, field: "RemoteTimeout", code: "negative"},
+		{name: "empty allowed type", tools: Tools{AllowedFileTypes: []string{"image/png", ""}}, field: "AllowedFileTypes", code: "empty"},
+		{name: "empty accepted type", tools: Tools{AcceptedJSONTypes: []string{" "}}, field: "AcceptedJSONTypes", code: "empty"},
+		{name: "unknown strategy", tools: Tools{FileNameStrategy: "sequential"}, field: "FileNameStrategy", code: "invalid"},
+		{name: "unknown digest", tools: Tools{DownloadDigestAlgorithm: "crc32"}, field: "DownloadDigestAlgorithm", code: "invalid"},
+		{name: "heartbeat", tools: Tools{JSONHeartbeatByte: 'x'}, field: "JSONHeartbeatByte", code: "invalid"},
+		{name: "missing upload dir", tools: Tools{BaseUploadDir: filepath.Join(dir, "nope")}, field: "BaseUploadDir", code: "missing"},
+		{name: "upload dir is a file", tools: Tools{BaseUploadDir: file}, field: "BaseUploadDir", code: "invalid"},
+		{name: "missing temp dir", tools: Tools{TempDir: filepath.Join(dir, "nope")}, field: "TempDir", code: "missing"},
+	}
+
+	for _, e := range validateTests {
+		err := e.tools.Validate()
+
+		var fieldErrs FieldErrors
+		if !errors.As(err, &fieldErrs) || len(fieldErrs) != 1 {
+			t.Errorf("%s: expected one problem, but got %v", e.name, err)
+			continue
+		}
+		if fieldErrs[0].Field != e.field || fieldErrs[0].Code != e.code {
+			t.Errorf("%s: expected %s (%s), but got %+v", e.name, e.field, e.code, fieldErrs[0])
+		}
+	}
+
+	// Every problem is reported, not just the first.
+	testTools := Tools{MaxJSONSize: -1, MaxXMLSize: -1, AllowedFileTypes: []string{""}}
+	if err := testTools.Validate(); err == nil || strings.Count(err.Error(), ";") != 2 {
+		t.Errorf("expected 3 problems, but got %v", err)
+	}
+
+	// The defaults, and a sensible configuration, are fine.
+	testTools = New()
+	testTools.BaseUploadDir = dir
+	testTools.AllowedFileTypes = []string{"image/png"}
+	testTools.DownloadDigestAlgorithm = "SHA-256"
+	testTools.FileNameStrategy = FileNameUUID
+	if err := testTools.Validate(); err != nil {
+		t.Errorf("expected no problems, but got %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package toolbox
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// defaultCSVMaxErrors is the number of row errors kept in a CSVReport, unless WithCSVMaxErrors is used.
+const defaultCSVMaxErrors = 100
+
+// SkipRow can be returned by the callback given to ReadCSVStream to skip a row on purpose. The row
+// is counted in RowsSkipped, rather than being treated as an error.
+//
+//lint:ignore ST1012 named like filepath.SkipDir, which plays the same role
+var SkipRow = errors.New("skip this row")
+
+// ErrCSVRaggedRow is the error recorded for a row that doesn't have the same number of fields as
+// the header, unless WithCSVRaggedRows is used.
+var ErrCSVRaggedRow = errors.New("wrong number of fields")
+
+// CSVRowError is a problem with a single row read by ReadCSVStream.
+type CSVRowError struct {
+	Row  int   // the number of the row, counting from 1 for the first row after the header
+	Line int   // the line in the input where the row starts
+	Err  error // what went wrong
+}
+
+// Error satisfies the error interface.
+func (e *CSVRowError) Error() string {
+	return fmt.Sprintf("row %d (line %d): %s", e.Row, e.Line, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *CSVRowError) Unwrap() error {
+	return e.Err
+}
+
+// CSVReport summarizes a call to ReadCSVStream.
+type CSVReport struct {
+	RowsProcessed   int           // rows passed to the callback, which returned nil
+	RowsSkipped     int           // rows for which the callback returned SkipRow
+	RowsFailed      int           // rows which could not be read, or for which the callback returned an error
+	Errors          []CSVRowError // the first row errors, up to the limit set with WithCSVMaxErrors
+	ErrorsTruncated bool          // true if there were more row errors than are kept in Errors
+}
+
+// FieldErrors returns the row errors in the report as FieldErrors, with each row number as the index,
+// so that they can be sent to the client with ErrorJSON. A row whose error holds FieldErrors of its own
+// (for example, a *FormError from checking the row) gives one entry for each of them; other rows give
+// a single entry, with the code ragged_row or invalid.
+func (r CSVReport) FieldErrors() FieldErrors {
+	var fieldErrs FieldErrors
+	for _, rowErr := range r.Errors {
+		var inner FieldErrors
+		if errors.As(rowErr.Err, &inner) {
+			for _, e := range inner {
+				e.Index = rowErr.Row
+				fieldErrs = append(fieldErrs, e)
+			}
+			continue
+		}
+
+		code := "invalid"
+		if errors.Is(rowErr.Err, ErrCSVRaggedRow) {
+			code = "ragged_row"
+		}
+		fieldErrs = append(fieldErrs, FieldError{Index: rowErr.Row, Code: code, Err: rowErr.Err})
+	}
+	return fieldErrs
+}
+
+// CSVOption changes how ReadCSVStream reads its input.
+type CSVOption func(*csvOptions)
+
+type csvOptions struct {
+	comma           rune
+	continueOnError bool
+	maxErrors       int
+	raggedRows      bool
+}
+
+// WithCSVDelimiter sets the field delimiter, which is a comma by default.
+func WithCSVDelimiter(r rune) CSVOption {
+	return func(o *csvOptions) {
+		o.comma = r
+	}
+}
+
+// WithCSVContinueOnError makes ReadCSVStream record rows which can't be read, or for which the callback
+// returns an error, and carry on with the next row, rather than stopping at the first one.
+func WithCSVContinueOnError() CSVOption {
+	return func(o *csvOptions) {
+		o.continueOnError = true
+	}
+}
+
+// WithCSVMaxErrors sets how many row errors are kept in the CSVReport (100 by default). Rows after that
+// are still counted in RowsFailed.
+func WithCSVMaxErrors(n int) CSVOption {
+	return func(o *csvOptions) {
+		o.maxErrors = n
+	}
+}
+
+// WithCSVRaggedRows accepts rows with a different number of fields than the header: missing fields
+// are given empty values, and extra fields are ignored.
+func WithCSVRaggedRows() CSVOption {
+	return func(o *csvOptions) {
+		o.raggedRows = true
+	}
+}
+
+// ReadCSVStream reads CSV from r one row at a time, so that files of any size can be processed. The
+// first row is the header, and each row after it is passed to fn as a map from column name to value,
+// along with its row number, starting from 1. Column names are trimmed of spaces (and a leading byte
+// order mark); empty or duplicated column names are an error, since they can't be told apart.
+//
+// If fn returns SkipRow, the row is counted as skipped. Any other error from fn, or a row that can't
+// be read, stops the import with a *CSVRowError, unless WithCSVContinueOnError is used, in which case
+// it is recorded in the report and the next row is read. Rows with the wrong number of fields fail
+// with ErrCSVRaggedRow, unless WithCSVRaggedRows is used. The report is returned even when there is an
+// error, so the caller can see how far the import got.
+func (t *Tools) ReadCSVStream(r io.Reader, fn func(rowNumber int, record map[string]string) error, opts ...CSVOption) (CSVReport, error) {
+	o := csvOptions{comma: ',', maxErrors: defaultCSVMaxErrors}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var report CSVReport
+
+	reader := csv.NewReader(r)
+	reader.Comma = o.comma
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return report, errors.New("the CSV has no header row")
+	}
+	if err != nil {
+		return report, fmt.Errorf("error reading CSV header: %w", err)
+	}
+
+	columns, err := csvColumns(header)
+	if err != nil {
+		return report, err
+	}
+
+	// fail records a problem with a row, and returns an error if we should stop.
+	fail := func(row, line int, err error) error {
+		report.RowsFailed++
+		rowErr := CSVRowError{Row: row, Line: line, Err: err}
+		if !o.continueOnError {
+			return &rowErr
+		}
+
+		if len(report.Errors) < o.maxErrors {
+			report.Errors = append(report.Errors, rowErr)
+		} else {
+			report.ErrorsTruncated = true
+		}
+		return nil
+	}
+
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return report, fmt.Errorf("error reading CSV: %w", err)
+			}
+			if stop := fail(row, parseErr.StartLine, parseErr.Err); stop != nil {
+				return report, stop
+			}
+			continue
+		}
+
+		// Only a row that was read has field positions; asking for them after an error panics.
+		line, _ := reader.FieldPos(0)
+
+		if len(record) != len(columns) && !o.raggedRows {
+			if stop := fail(row, line, fmt.Errorf("%w: expected %d, but got %d", ErrCSVRaggedRow, len(columns), len(record))); stop != nil {
+				return report, stop
+			}
+			continue
+		}
+
+		values := make(map[string]string, len(columns))
+		for i, column := range columns {
+			if i < len(record) {
+				values[column] = record[i]
+			} else {
+				values[column] = ""
+			}
+		}
+
+		err = fn(row, values)
+		switch {
+		case err == nil:
+			report.RowsProcessed++
+		case errors.Is(err, SkipRow):
+			report.RowsSkipped++
+		default:
+			if stop := fail(row, line, err); stop != nil {
+				return report, stop
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// csvColumns returns the column names in header, trimmed, and checks that they are usable.
+func csvColumns(header []string) ([]string, error) {
+	columns := make([]string, len(header))
+	seen := make(map[string]bool, len(header))
+
+	for i, name := range header {
+		if i == 0 {
+			name = string(bytes.TrimPrefix([]byte(name), []byte("\xef\xbb\xbf")))
+		}
+		name = strings.TrimSpace(name)
+
+		if name == "" {
+			return nil, fmt.Errorf("CSV column %d has no name", i+1)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("CSV column name %q is used more than once", name)
+		}
+
+		seen[name] = true
+		columns[i] = name
+	}
+
+	return columns, nil
+}
@@ -0,0 +1,111 @@
+package toolbox
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTools_ReadCSVStream(t *testing.T) {
+	// Row 3 has a bad age, row 4 is missing a field, and row 5 is skipped by the callback.
+	content := "\xef\xbb\xbfname, age\nalice,30\nbob,forty\ncarol\ndave,50\nerin,25\n"
+	path := filepath.Join(t.TempDir(), "people.csv")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var testTools Tools
+	var names []string
+	total := 0
+
+	report, err := testTools.ReadCSVStream(f, func(rowNumber int, record map[string]string) error {
+		if record["name"] == "dave" {
+			return SkipRow
+		}
+
+		age, err := strconv.Atoi(record["age"])
+		if err != nil {
+			return err
+		}
+
+		names = append(names, record["name"])
+		total += age
+		return nil
+	}, WithCSVContinueOnError())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.RowsProcessed != 2 || report.RowsSkipped != 1 || report.RowsFailed != 2 {
+		t.Errorf("wrong counts: %+v", report)
+	}
+	if strings.Join(names, ",") != "alice,erin" || total != 55 {
+		t.Errorf("wrong rows processed: %v (total age %d)", names, total)
+	}
+
+	if len(report.Errors) != 2 || report.ErrorsTruncated {
+		t.Fatalf("expected 2 row errors, but got %+v", report.Errors)
+	}
+	if report.Errors[0].Row != 2 || report.Errors[0].Line != 3 {
+		t.Errorf("wrong position for bad age: %+v", report.Errors[0])
+	}
+	if report.Errors[1].Row != 3 || !errors.Is(&report.Errors[1], ErrCSVRaggedRow) {
+		t.Errorf("expected a ragged row error for row 3, but got %+v", report.Errors[1])
+	}
+}
+
+var csvStreamTests = []struct {
+	name          string
+	content       string
+	opts          []CSVOption
+	processed     int
+	failed        int
+	errors        int
+	truncated     bool
+	errorExpected string
+}{
+	{name: "stops at first error", content: "a,b\n1,2\n3\n4,5\n", processed: 1, failed: 1, errorExpected: "row 2 (line 3): wrong number of fields"},
+	{name: "ragged rows allowed", content: "a,b\n1,2\n3\n4,5,6\n", opts: []CSVOption{WithCSVRaggedRows()}, processed: 3},
+	{name: "error cap", content: "a,b\n1\n2\n3\n", opts: []CSVOption{WithCSVContinueOnError(), WithCSVMaxErrors(2)}, failed: 3, errors: 2, truncated: true},
+	{name: "bad quotes", content: "a,b\n1,\"2\n", opts: []CSVOption{WithCSVContinueOnError()}, failed: 1, errors: 1},
+	{name: "bare quote", content: "a,b\nx\"y,2\n", opts: []CSVOption{WithCSVContinueOnError()}, failed: 1, errors: 1},
+	{name: "bare quote, then a good row", content: "a,b\nx\"y,2\n3,4\n", opts: []CSVOption{WithCSVContinueOnError()}, processed: 1, failed: 1, errors: 1},
+	{name: "delimiter", content: "a;b\n1;2\n", opts: []CSVOption{WithCSVDelimiter(';')}, processed: 1},
+	{name: "duplicate column", content: "a,b,a\n1,2,3\n", errorExpected: `"a" is used more than once`},
+	{name: "empty column", content: "a,,c\n1,2,3\n", errorExpected: "column 2 has no name"},
+	{name: "no header", content: "", errorExpected: "no header row"},
+}
+
+func TestTools_ReadCSVStreamOptions(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range csvStreamTests {
+		report, err := testTools.ReadCSVStream(strings.NewReader(e.content), func(rowNumber int, record map[string]string) error {
+			if _, ok := record["b"]; !ok {
+				t.Errorf("%s: row %d has no b: %v", e.name, rowNumber, record)
+			}
+			return nil
+		}, e.opts...)
+
+		if e.errorExpected != "" {
+			if err == nil || !strings.Contains(err.Error(), e.errorExpected) {
+				t.Errorf("%s: expected error containing %q, but got %v", e.name, e.errorExpected, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+		}
+
+		if report.RowsProcessed != e.processed || report.RowsFailed != e.failed || len(report.Errors) != e.errors || report.ErrorsTruncated != e.truncated {
+			t.Errorf("%s: wrong report: %+v", e.name, report)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package toolbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultMaxDataURISize is the default maximum size of the source data for a data URI (1 mb).
+const defaultMaxDataURISize = 1048576
+
+// ErrDataURITooLarge is returned when the source for a data URI exceeds MaxDataURISize.
+var ErrDataURITooLarge = errors.New("data too large to encode as a data URI")
+
+// ErrInvalidDataURI is returned when a string cannot be parsed as a data URI.
+var ErrInvalidDataURI = errors.New("invalid data URI")
+
+// EncodeFileToDataURI reads the file at path and returns it as a base64 encoded data URI, e.g.
+// data:image/png;base64,iVBORw0KGgo... The content type is detected from the file contents.
+func (t *Tools) EncodeFileToDataURI(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return t.EncodeReaderToDataURI(f, "")
+}
+
+// EncodeReaderToDataURI reads all of r and returns it as a base64 encoded data URI. If contentType
+// is empty, it is detected from the data. If r holds more than MaxDataURISize bytes (1 mb by default),
+// an error wrapping ErrDataURITooLarge is returned.
+func (t *Tools) EncodeReaderToDataURI(r io.Reader, contentType string) (string, error) {
+	maxBytes := int64(defaultMaxDataURISize)
+	if t.MaxDataURISize != 0 {
+		maxBytes = int64(t.MaxDataURISize)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return "", err
+	}
+
+	if int64(len(data)) > maxBytes {
+		return "", fmt.Errorf("%w: must not be larger than %d bytes", ErrDataURITooLarge, maxBytes)
+	}
+
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	// Parameters in a data URI are separated by a semicolon with no whitespace.
+	contentType = strings.ReplaceAll(contentType, "; ", ";")
+
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// DataURIError is returned when a string can't be parsed as a data URI, or its data can't be
+// decoded. It matches ErrInvalidDataURI with errors.Is, and, for base64 data that is cut short or
+// isn't padded with = to a multiple of four characters, ErrDataURIPadding.
+type DataURIError struct {
+	Reason string // what is wrong, for people
+	Offset int    // how far into the data the problem was found, or -1 if it isn't about the data
+	Err    error  // the underlying error, if there is one
+}
+
+// Error satisfies the error interface.
+func (e *DataURIError) Error() string {
+	return ErrInvalidDataURI.Error() + ": " + e.Reason
+}
+
+// Is makes errors.Is(err, ErrInvalidDataURI) true.
+func (e *DataURIError) Is(target error) bool {
+	return target == ErrInvalidDataURI
+}
+
+// Unwrap returns the underlying error.
+func (e *DataURIError) Unwrap() error {
+	return e.Err
+}
+
+// ErrDataURIPadding is matched, with errors.Is, by the *DataURIError for base64 data with the wrong
+// length or padding.
+var ErrDataURIPadding = errors.New("base64 data is not correctly padded")
+
+// DecodeDataURI parses a data URI, and returns its content type and decoded data. Both base64
+// and percent-encoded data URIs are supported. If the URI does not specify a content type,
+// text/plain;charset=US-ASCII is returned, per RFC 2397, or text/plain with the parameters given,
+// such as charset=utf-8. If s is not a valid data URI, the error is a *DataURIError.
+func (t *Tools) DecodeDataURI(s string) (string, []byte, error) {
+	contentType, payload, isBase64, err := parseDataURI(s)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data, err := decodeDataURIPayload(payload, isBase64)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return contentType, data, nil
+}
+
+// parseDataURI splits the data URI s into its content type, which defaults as RFC 2397 says, and
+// its still encoded data.
+func parseDataURI(s string) (contentType, payload string, isBase64 bool, err error) {
+	if !strings.HasPrefix(s, "data:") {
+		return "", "", false, &DataURIError{Reason: "missing data: prefix", Offset: -1}
+	}
+
+	meta, payload, found := strings.Cut(strings.TrimPrefix(s, "data:"), ",")
+	if !found {
+		return "", "", false, &DataURIError{Reason: "missing comma separator", Offset: -1}
+	}
+
+	if len(meta) >= len(";base64") && strings.EqualFold(meta[len(meta)-len(";base64"):], ";base64") {
+		isBase64 = true
+		meta = meta[:len(meta)-len(";base64")]
+	}
+
+	// Only the type defaults to text/plain, and the charset to US-ASCII only if none is given.
+	contentType = meta
+	if contentType == "" || strings.HasPrefix(contentType, ";") {
+		params := contentType
+		contentType = "text/plain" + params
+		if !hasCharsetParam(params) {
+			contentType = "text/plain;charset=US-ASCII" + params
+		}
+	}
+
+	return contentType, payload, isBase64, nil
+}
+
+// hasCharsetParam reports whether params, the parameters of a media type, each after a semicolon,
+// include a charset.
+func hasCharsetParam(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		name, _, _ := strings.Cut(param, "=")
+		if strings.EqualFold(strings.TrimSpace(name), "charset") {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeDataURIPayload decodes the data from a data URI, which is base64 if isBase64 is true, and
+// percent-encoded otherwise.
+func decodeDataURIPayload(payload string, isBase64 bool) ([]byte, error) {
+	if !isBase64 {
+		data, err := url.PathUnescape(payload)
+		if err != nil {
+			return nil, &DataURIError{Reason: err.Error(), Offset: -1, Err: err}
+		}
+		return []byte(data), nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err == nil {
+		return data, nil
+	}
+
+	var corrupt base64.CorruptInputError
+	if !errors.As(err, &corrupt) {
+		return nil, &DataURIError{Reason: err.Error(), Offset: -1, Err: err}
+	}
+
+	// If every character belongs in base64, what's wrong is the length of the data, or its padding.
+	offset := int(corrupt)
+	if !strings.ContainsFunc(payload, func(r rune) bool { return !isBase64Char(r) }) {
+		return nil, &DataURIError{Reason: fmt.Sprintf("base64 data of %d characters is not correctly padded to a multiple of 4", len(payload)),
+			Offset: offset, Err: ErrDataURIPadding}
+	}
+	return nil, &DataURIError{Reason: err.Error(), Offset: offset, Err: err}
+}
+
+// isBase64Char reports whether r can appear in standard, padded, base64.
+func isBase64Char(r rune) bool {
+	return r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '+' || r == '/' || r == '='
+}
+
+// SaveDataURI saves the data in the data URI dataURI, such as an image sent as a string in a JSON body,
+// to uploadDir, with the same rules as UploadFiles: the type declared in the URI must be in
+// AllowedFileTypes, and so must the type sniffed from the data, which must also match the declared
+// one (unless it is too generic to tell, such as text/plain); and the data must be no larger than
+// MaxFileSize, which is checked from the length of the encoded data before any of it is decoded. If
+// rename is true, the file is given a random name; otherwise it keeps the name given in the URI's name
+// (or filename) parameter, if it has one, or is called file, with the extension for its type. A
+// malformed URI, or data that can't be decoded, is reported with a *DataURIError.
+func (t *Tools) SaveDataURI(dataURI string, uploadDir string, rename bool) (*UploadedFile, error) {
+	ctx := context.Background()
+	opts := t.uploadOptions(ctx, UploadOptions{KeepOriginalName: !rename})
+
+	contentType, payload, isBase64, err := parseDataURI(dataURI)
+	if err != nil {
+		return nil, err
+	}
+
+	declared, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, &DataURIError{Reason: fmt.Sprintf("bad media type %q", contentType), Offset: -1, Err: err}
+	}
+	if !fileTypeAllowed(opts.AllowedTypes, declared) {
+		return nil, &FileTypeError{DeclaredType: declared}
+	}
+
+	// Base64 decodes to three bytes for every four characters, less any padding, and percent-encoding
+	// to no more bytes than there are characters, so an oversized file can be turned away unread.
+	size := int64(len(payload))
+	if isBase64 {
+		size = int64(len(payload)/4*3 - strings.Count(payload[max(len(payload)-2, 0):], "="))
+	}
+	if size > int64(opts.MaxFileSize) {
+		return nil, &FileTooBigError{Limit: int64(opts.MaxFileSize), Size: size}
+	}
+
+	data, err := decodeDataURIPayload(payload, isBase64)
+	if err != nil {
+		return nil, err
+	}
+
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if sniffed != declared && sniffed != "application/octet-stream" && sniffed != "text/plain" {
+		return nil, &FileTypeError{ContentType: sniffed, DeclaredType: declared}
+	}
+
+	uploadDir, err = t.uploadPath(uploadDir)
+	if err != nil {
+		return nil, err
+	}
+	err = t.CreateDirIfNotExist(uploadDir)
+	if err != nil {
+		return nil, err
+	}
+
+	name := params["name"]
+	if name == "" {
+		name = params["filename"]
+	}
+	fileName := baseFileName(name, "file"+extensionForType(declared))
+
+	start := time.Now()
+	uploadedFile, err := t.saveFile(ctx, bytes.NewReader(data), fileName, uploadDir, opts)
+	if t.logsOperations() {
+		var saved int64
+		if uploadedFile != nil {
+			saved = uploadedFile.FileSize
+		}
+		t.logOperation(ctx, slog.LevelInfo, "SaveDataURI", saved, time.Since(start), 0, err, slog.String("file", fileName))
+	}
+
+	return uploadedFile, err
+}
+
+// extensionForType returns the usual extension, such as .png, for files of the media type mediaType,
+// or an empty string if it isn't known.
+func extensionForType(mediaType string) string {
+	exts, _ := mime.ExtensionsByType(mediaType)
+	if len(exts) == 0 {
+		return ""
+	}
+
+	// Prefer the extension named for the subtype, such as .jpeg, over alternatives, such as .jfif.
+	_, subtype, _ := strings.Cut(mediaType, "/")
+	for _, ext := range exts {
+		if ext == "."+subtype {
+			return ext
+		}
+	}
+	return exts[0]
+}
@@ -0,0 +1,185 @@
+package toolbox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTools_EncodeFileToDataURI(t *testing.T) {
+	var testTools Tools
+
+	uri, err := testTools.EncodeFileToDataURI("./testdata/img.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(uri, "data:image/png;base64,") {
+		t.Errorf("wrong data URI prefix: %s", uri[:30])
+	}
+
+	contentType, data, err := testTools.DecodeDataURI(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if contentType != "image/png" {
+		t.Errorf("wrong content type; expected image/png but got %s", contentType)
+	}
+
+	original, _ := os.ReadFile("./testdata/img.png")
+	if !bytes.Equal(original, data) {
+		t.Error("decoded data does not match the original file")
+	}
+}
+
+func TestTools_EncodeReaderToDataURI(t *testing.T) {
+	var testTools Tools
+	testTools.MaxDataURISize = 10
+
+	uri, err := testTools.EncodeReaderToDataURI(strings.NewReader("hello"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uri != "data:text/plain;charset=utf-8;base64,aGVsbG8=" {
+		t.Errorf("wrong data URI: %s", uri)
+	}
+
+	_, err = testTools.EncodeReaderToDataURI(strings.NewReader("hello, world"), "text/plain")
+	if !errors.Is(err, ErrDataURITooLarge) {
+		t.Errorf("expected ErrDataURITooLarge, but got %v", err)
+	}
+}
+
+var decodeDataURITests = []struct {
+	name          string
+	uri           string
+	contentType   string
+	data          string
+	errorExpected bool
+}{
+	{name: "base64", uri: "data:text/plain;base64,aGVsbG8=", contentType: "text/plain", data: "hello"},
+	{name: "percent encoded", uri: "data:,hello%20world", contentType: "text/plain;charset=US-ASCII", data: "hello world"},
+	{name: "charset without a type", uri: "data:;charset=utf-8,hello", contentType: "text/plain;charset=utf-8", data: "hello"},
+	{name: "other parameters without a type", uri: "data:;name=a.txt,hello", contentType: "text/plain;charset=US-ASCII;name=a.txt", data: "hello"},
+	{name: "base64 in upper case", uri: "data:text/plain;BASE64,aGVsbG8=", contentType: "text/plain", data: "hello"},
+	{name: "no prefix", uri: "text/plain;base64,aGVsbG8=", errorExpected: true},
+	{name: "no comma", uri: "data:text/plain;base64", errorExpected: true},
+	{name: "bad base64", uri: "data:text/plain;base64,a$GVsbG8=", errorExpected: true},
+}
+
+func TestTools_DecodeDataURI(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range decodeDataURITests {
+		contentType, data, err := testTools.DecodeDataURI(e.uri)
+		if e.errorExpected {
+			if !errors.Is(err, ErrInvalidDataURI) {
+				t.Errorf("%s: expected ErrInvalidDataURI, but got %v", e.name, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		if contentType != e.contentType || string(data) != e.data {
+			t.Errorf("%s: expected %s %q but got %s %q", e.name, e.contentType, e.data, contentType, data)
+		}
+	}
+}
+
+func TestTools_SaveDataURI(t *testing.T) {
+	png, _ := os.ReadFile("./testdata/img.png")
+	uri := "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+
+	testTools := Tools{AllowedFileTypes: []string{"image/png", "image/jpeg"}}
+	dir := t.TempDir()
+
+	file, err := testTools.SaveDataURI(uri, dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.ContentType != "image/png" || file.FileSize != int64(len(png)) || filepath.Ext(file.NewFileName) != ".png" ||
+		file.SavedPath != filepath.Join(dir, file.NewFileName) || file.Width == 0 {
+		t.Errorf("wrong file: %+v", file)
+	}
+	if saved, _ := os.ReadFile(file.SavedPath); !bytes.Equal(saved, png) {
+		t.Error("the saved file doesn't match the data")
+	}
+
+	// Without rename, the name parameter is kept.
+	file, err = testTools.SaveDataURI("data:image/png;name=avatar.png;base64,"+base64.StdEncoding.EncodeToString(png), dir, false)
+	if err != nil || file.NewFileName != "avatar.png" {
+		t.Errorf("expected avatar.png, but got %v, %v", file, err)
+	}
+
+	// A charset without a type is still a valid media type, of text/plain.
+	var plainTools Tools
+	file, err = plainTools.SaveDataURI("data:;charset=utf-8,hello", dir, true)
+	if err != nil || !strings.HasPrefix(file.ContentType, "text/plain") {
+		t.Errorf("expected a text/plain file, but got %v, %v", file, err)
+	}
+
+	// A name that isn't a file name gets the default.
+	for _, name := range []string{"..", ".", "/", "a/..", ""} {
+		file, err = testTools.SaveDataURI("data:image/png;name=\""+name+"\";base64,"+base64.StdEncoding.EncodeToString(png), dir, false)
+		if err != nil || file.NewFileName != "file.png" || file.SavedPath != filepath.Join(dir, "file.png") {
+			t.Errorf("%q: expected file.png, but got %v, %v", name, file, err)
+		}
+	}
+}
+
+func TestTools_SaveDataURIRejected(t *testing.T) {
+	png, _ := os.ReadFile("./testdata/img.png")
+	encoded := base64.StdEncoding.EncodeToString(png)
+
+	testTools := Tools{AllowedFileTypes: []string{"image/png", "image/jpeg"}, MaxFileSize: len(png) - 1}
+
+	// An oversized file is turned away from its length alone, so even data that isn't base64 at all
+	// gives the size error, not a decoding one.
+	_, err := testTools.SaveDataURI("data:image/png;base64,"+strings.Repeat("!", len(encoded)), t.TempDir(), true)
+	var tooBig *FileTooBigError
+	if !errors.As(err, &tooBig) || tooBig.Size < int64(len(png))-2 {
+		t.Errorf("expected a *FileTooBigError, but got %v", err)
+	}
+
+	testTools.MaxFileSize = 0
+	var typeErr *FileTypeError
+
+	// A declared type that doesn't match the data.
+	_, err = testTools.SaveDataURI("data:image/jpeg;base64,"+encoded, t.TempDir(), true)
+	if !errors.As(err, &typeErr) || typeErr.DeclaredType != "image/jpeg" || typeErr.ContentType != "image/png" {
+		t.Errorf("expected a *FileTypeError for the mismatch, but got %v", err)
+	}
+
+	// A declared type that isn't allowed.
+	_, err = testTools.SaveDataURI("data:text/plain;base64,aGVsbG8=", t.TempDir(), true)
+	if !errors.As(err, &typeErr) || typeErr.DeclaredType != "text/plain" {
+		t.Errorf("expected a *FileTypeError for the declared type, but got %v", err)
+	}
+
+	var uriErr *DataURIError
+	for _, uri := range []string{"image/png;base64," + encoded, "data:image/png;base64"} {
+		if _, err := testTools.SaveDataURI(uri, t.TempDir(), true); !errors.Is(err, ErrInvalidDataURI) || !errors.As(err, &uriErr) {
+			t.Errorf("expected a *DataURIError for %.30s, but got %v", uri, err)
+		}
+	}
+
+	// Padding that is missing, or wrong, has its own error.
+	_, err = testTools.SaveDataURI("data:image/png;base64,"+encoded[:len(encoded)-1], t.TempDir(), true)
+	if !errors.Is(err, ErrDataURIPadding) {
+		t.Errorf("expected ErrDataURIPadding, but got %v", err)
+	}
+	_, err = testTools.SaveDataURI("data:image/png;base64,iVBO$w0K", t.TempDir(), true)
+	if !errors.As(err, &uriErr) || errors.Is(err, ErrDataURIPadding) || uriErr.Offset != 4 {
+		t.Errorf("expected an error at offset 4, but got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package toolbox
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultDebugBodyLimit is how much of each request body DebugRequestLogger logs, unless
+// DebugBodyLimit is set.
+const defaultDebugBodyLimit = 4096
+
+// defaultDebugRedactFields are the JSON and form fields whose values are always hidden by DebugRequestLogger.
+var defaultDebugRedactFields = []string{"password"}
+
+// DebugRequestLogger is middleware for development which, when Debug is true, logs the method, path,
+// headers and the start of the body (up to DebugBodyLimit bytes) of every request, at debug level,
+// to Logger (or the default slog logger). The body is put back, so handlers still read all of it.
+// Sensitive headers (Authorization, Cookie, and DebugRedactHeaders) are masked, as are the values
+// of JSON and form fields named password, or in DebugRedactFields. Bodies that don't look like text
+// are logged as a length and a hash. When Debug is false, requests are passed straight through.
+func (t *Tools) DebugRequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !t.Debug {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limit := t.DebugBodyLimit
+		if limit <= 0 {
+			limit = defaultDebugBodyLimit
+		}
+
+		var body []byte
+		if r.Body != nil && r.Body != http.NoBody {
+			body, _ = io.ReadAll(io.LimitReader(r.Body, int64(limit)))
+			r.Body = preservedBody{Reader: io.MultiReader(bytes.NewReader(body), r.Body), Closer: r.Body}
+		}
+
+		logger := t.Logger
+		if logger == nil {
+			logger = slog.Default()
+		}
+
+		headers := t.redactHeaders(r.Header)
+		for _, name := range t.DebugRedactHeaders {
+			if _, ok := headers[http.CanonicalHeaderKey(name)]; ok {
+				headers.Set(name, redactedValue)
+			}
+		}
+
+		attrs := []any{"method", r.Method, "path", r.URL.Path, "headers", headers}
+		contentType := r.Header.Get("Content-Type")
+		switch {
+		case len(body) == 0:
+		case isBinaryBody(contentType, body):
+			sum := sha256.Sum256(body)
+			attrs = append(attrs, "body_length", len(body), "body_sha256", hex.EncodeToString(sum[:]))
+		default:
+			attrs = append(attrs, "body", t.redactBody(contentType, string(body)))
+		}
+		if len(body) == limit {
+			attrs = append(attrs, "body_truncated", true)
+		}
+
+		logger.Debug("request", attrs...)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// preservedBody gives a handler the bytes we have already read from a request body, followed by the
+// rest of it, and closes the original body.
+type preservedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// isBinaryBody reports whether a body with the given content type, which starts with body, should
+// not be logged as text.
+func isBinaryBody(contentType string, body []byte) bool {
+	if contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err == nil && !isTextMediaType(mediaType) {
+			return true
+		}
+	}
+
+	// The body may have been cut off in the middle of a character.
+	for i := 0; i < utf8.UTFMax && len(body) > 0 && !utf8.Valid(body); i++ {
+		body = body[:len(body)-1]
+	}
+	return !utf8.Valid(body)
+}
+
+// isTextMediaType reports whether mediaType is something we can sensibly log as text.
+func isTextMediaType(mediaType string) bool {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "json"),
+		strings.HasSuffix(mediaType, "xml"),
+		mediaType == "application/x-www-form-urlencoded",
+		mediaType == "application/javascript":
+		return true
+	}
+	return false
+}
+
+// redactBody hides the values of sensitive fields in body, which may be JSON or form encoded, and
+// may have been cut off.
+func (t *Tools) redactBody(contentType, body string) string {
+	fields := append(append([]string{}, defaultDebugRedactFields...), t.DebugRedactFields...)
+
+	for _, field := range fields {
+		name := regexp.QuoteMeta(field)
+		if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+			re := regexp.MustCompile(`(?i)((?:^|&)` + name + `=)[^&]*`)
+			body = re.ReplaceAllString(body, "${1}"+redactedValue)
+			continue
+		}
+
+		re := regexp.MustCompile(`(?i)("` + name + `"\s*:\s*)("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+		body = re.ReplaceAllString(body, `${1}"`+redactedValue+`"`)
+	}
+
+	return body
+}
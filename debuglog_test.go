@@ -0,0 +1,106 @@
+package toolbox
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// debugTools returns Tools with Debug on, logging at debug level to logs.
+func debugTools(logs *bytes.Buffer) *Tools {
+	return &Tools{
+		Debug:  true,
+		Logger: slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}
+}
+
+func TestTools_DebugRequestLogger(t *testing.T) {
+	var logs bytes.Buffer
+	testTools := debugTools(&logs)
+	testTools.DebugBodyLimit = 64
+	testTools.DebugRedactFields = []string{"card_number"}
+
+	body := `{"user": "jack", "password": "hunter2", "card_number": 4111111111111111, "notes": "` + strings.Repeat("x", 100) + `"}`
+
+	var received string
+	handler := testTools.DebugRequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = string(b)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret-token")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if received != body {
+		t.Errorf("downstream handler did not get the full body: %s", received)
+	}
+
+	out := logs.String()
+	for _, leaked := range []string{"hunter2", "4111111111111111", "secret-token"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("%s leaked into the log: %s", leaked, out)
+		}
+	}
+	for _, expected := range []string{"method=POST", "path=/users", "jack", "body_truncated=true"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected log to contain %s, but got %s", expected, out)
+		}
+	}
+}
+
+func TestTools_DebugRequestLoggerForm(t *testing.T) {
+	var logs bytes.Buffer
+	testTools := debugTools(&logs)
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader("user=jack&Password=hunter2&remember=1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	testTools.DebugRequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.Form.Get("Password") != "hunter2" {
+			t.Error("form value not available downstream")
+		}
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	if strings.Contains(logs.String(), "hunter2") || !strings.Contains(logs.String(), "remember=1") {
+		t.Errorf("wrong form redaction: %s", logs.String())
+	}
+}
+
+func TestTools_DebugRequestLoggerBinary(t *testing.T) {
+	var logs bytes.Buffer
+	testTools := debugTools(&logs)
+
+	for _, contentType := range []string{"image/png", ""} {
+		logs.Reset()
+
+		req := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader([]byte{0x89, 'P', 'N', 'G', 0xff, 0xfe, 0x00}))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		testTools.DebugRequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+
+		out := logs.String()
+		if !strings.Contains(out, "body_length=7") || !strings.Contains(out, "body_sha256=") || strings.Contains(out, "body=") {
+			t.Errorf("%q: expected only a length and hash for a binary body, but got %s", contentType, out)
+		}
+	}
+}
+
+func TestTools_DebugRequestLoggerOff(t *testing.T) {
+	var logs bytes.Buffer
+	testTools := debugTools(&logs)
+	testTools.Debug = false
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+	testTools.DebugRequestLogger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+
+	if logs.Len() != 0 {
+		t.Errorf("expected nothing to be logged, but got %s", logs.String())
+	}
+}
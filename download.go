@@ -0,0 +1,241 @@
+package toolbox
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// contentDisposition returns a Content-Disposition header value, such as attachment; filename="a.pdf".
+// Quotes and backslashes in filename are escaped, and control characters are dropped. If filename
+// is not plain ASCII, an ASCII fallback is sent in filename, and the real name in filename*, as
+// described in RFC 6266.
+func contentDisposition(disposition, filename string) string {
+	var fallback strings.Builder
+	ascii := true
+	for _, r := range filename {
+		switch {
+		case r < ' ' || r == 0x7f:
+			// drop control characters entirely
+		case r > 0x7f:
+			ascii = false
+			fallback.WriteByte('_')
+		case r == '"' || r == '\\':
+			fallback.WriteByte('\\')
+			fallback.WriteRune(r)
+		default:
+			fallback.WriteRune(r)
+		}
+	}
+
+	value := fmt.Sprintf("%s; filename=\"%s\"", disposition, fallback.String())
+	if !ascii {
+		value += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+
+	return value
+}
+
+// encodeRFC5987 percent-encodes s for use in an extended header parameter like filename*.
+func encodeRFC5987(s string) string {
+	const attrChars = "!#$&+-.^_`|~"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte(attrChars, c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// DownloadJSON sends data to the client as a downloadable JSON file named filename, using the same
+// encoding as WriteJSON (including JSONDisableHTMLEscape). If indent is true, the JSON is indented
+// with JSONIndent, or two spaces if that is not set. If data cannot be encoded, the error is
+// returned before anything is written, so the caller can still send an error response. In reply to
+// a HEAD request, net/http sends the same headers, and discards the body.
+func (t *Tools) DownloadJSON(w http.ResponseWriter, data any, filename string, indent bool) error {
+	indentWith := ""
+	if indent {
+		indentWith = t.JSONIndent
+		if indentWith == "" {
+			indentWith = "  "
+		}
+	}
+
+	out, err := t.marshalJSONIndent(data, indentWith)
+	if err != nil {
+		return err
+	}
+
+	w, done := t.trackDownload(w, nil, filename)
+	defer done()
+
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", t.jsonContentType())
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(out)))
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", filename))
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write(out)
+	return err
+}
+
+// DownloadFromReader sends content to the client as a downloadable file named displayName, using
+// http.ServeContent, so that HEAD, Range and conditional (If-Modified-Since, If-None-Match) requests
+// all work. If modTime is not zero, it is sent as Last-Modified. If contentType is empty, it is
+// guessed from the extension of displayName, or else by sniffing the start of content. A response
+// to a HEAD request has every header, but content isn't read beyond what sniffing needs.
+func (t *Tools) DownloadFromReader(w http.ResponseWriter, r *http.Request, content io.ReadSeeker, modTime time.Time, displayName, contentType string) {
+	w, done := t.trackDownload(w, r, displayName)
+	defer done()
+
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", displayName))
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	http.ServeContent(w, r, displayName, modTime, content)
+}
+
+// DownloadOption changes how DownloadFromReaderAt sends a file.
+type DownloadOption func(*downloadOptions)
+
+type downloadOptions struct {
+	etag string
+}
+
+// WithETag sends etag as the ETag of the file, so that If-None-Match, If-Match and If-Range requests
+// can be answered with it, as well as with the modification time. It is quoted, if it isn't already
+// (a weak ETag, such as W/"v2", is sent as it is).
+func WithETag(etag string) DownloadOption {
+	return func(o *downloadOptions) {
+		if !strings.HasPrefix(etag, `"`) && !strings.HasPrefix(etag, `W/"`) {
+			etag = strconv.Quote(etag)
+		}
+		o.etag = etag
+	}
+}
+
+// DownloadFromReaderAt is DownloadFromReader for content that can be read at any offset, such as a
+// file in a blob store, of a known size. Each call reads content through its own io.SectionReader,
+// so that the same content can be sent to many clients at once, as io.ReaderAt allows, without them
+// sharing a position. HEAD, conditional requests and If-Range are all handled, with modTime and the
+// ETag given with WithETag as the validators, and a single Range is sent as a 206 response with
+// Content-Range, or refused with 416 if it is outside content. A request for several ranges is
+// answered with the whole file, which is always allowed. If content is nil or size is negative, the
+// error is returned before anything is written.
+func (t *Tools) DownloadFromReaderAt(w http.ResponseWriter, r *http.Request, content io.ReaderAt, size int64, modTime time.Time, displayName, contentType string, opts ...DownloadOption) error {
+	if content == nil {
+		return errors.New("no content to download")
+	}
+	if size < 0 {
+		return fmt.Errorf("invalid size %d", size)
+	}
+
+	var o downloadOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.etag != "" {
+		w.Header().Set("ETag", o.etag)
+	}
+
+	if strings.Contains(r.Header.Get("Range"), ",") {
+		r = r.Clone(r.Context())
+		r.Header.Del("Range")
+	}
+
+	t.DownloadFromReader(w, r, io.NewSectionReader(content, 0, size), modTime, displayName, contentType)
+	return nil
+}
+
+// DownloadFSFile sends the file name in fsys (which could be an embed.FS, or os.DirFS) to the client
+// as a downloadable file named displayName, or with its own name if displayName is empty. Like
+// DownloadFromReader, it handles HEAD, Range and conditional requests, though files that can't seek,
+// such as those in a zip file, are always sent whole. If the file can't be opened, or is a directory,
+// the error is returned before anything is written, so the caller can send an error response.
+func (t *Tools) DownloadFSFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, name, displayName string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", name)
+	}
+
+	if displayName == "" {
+		displayName = path.Base(name)
+	}
+
+	w, done := t.trackDownload(w, r, name)
+	defer done()
+
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", displayName))
+
+	if content, ok := f.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, name, info.ModTime(), content)
+		return nil
+	}
+
+	return serveUnseekable(w, r, f, info, name)
+}
+
+// serveUnseekable sends f, which can't seek, so doesn't support Range requests, with the same
+// headers http.ServeContent would send.
+func serveUnseekable(w http.ResponseWriter, r *http.Request, f io.Reader, info fs.FileInfo, name string) error {
+	modTime := info.ModTime()
+	if !modTime.IsZero() {
+		if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modTime.Truncate(time.Second).After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+		w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+
+	// Sniff the content type, if the extension doesn't tell us, keeping what was read to send later.
+	var sniffed []byte
+	if w.Header().Get("Content-Type") == "" {
+		contentType := mime.TypeByExtension(path.Ext(name))
+		if contentType == "" {
+			buf := make([]byte, 512)
+			n, err := io.ReadFull(f, buf)
+			if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+				return err
+			}
+			sniffed = buf[:n]
+			contentType = http.DetectContentType(sniffed)
+		}
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	w.Header().Set("Accept-Ranges", "none")
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodHead {
+		return nil
+	}
+
+	_, err := io.Copy(w, io.MultiReader(bytes.NewReader(sniffed), f))
+	return err
+}
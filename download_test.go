@@ -0,0 +1,278 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var contentDispositionTests = []struct {
+	name     string
+	filename string
+	expected string
+}{
+	{name: "plain", filename: "report.json", expected: `attachment; filename="report.json"`},
+	{name: "quotes", filename: `my "best" file.json`, expected: `attachment; filename="my \"best\" file.json"`},
+	{name: "control characters", filename: "bad\r\nname.json", expected: `attachment; filename="badname.json"`},
+	{name: "unicode", filename: "résumé.json", expected: `attachment; filename="r_sum_.json"; filename*=UTF-8''r%C3%A9sum%C3%A9.json`},
+}
+
+func TestContentDisposition(t *testing.T) {
+	for _, e := range contentDispositionTests {
+		if got := contentDisposition("attachment", e.filename); got != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestTools_DownloadJSON(t *testing.T) {
+	var testTools Tools
+
+	data := JSONResponse{Message: "<hello>", Data: map[string]any{"id": float64(7)}}
+
+	rr := httptest.NewRecorder()
+	err := testTools.DownloadJSON(rr, data, "record-7.json", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("wrong content type: %s", rr.Header().Get("Content-Type"))
+	}
+	if rr.Header().Get("Content-Length") != strconv.Itoa(rr.Body.Len()) {
+		t.Errorf("wrong content length: %s for %d bytes", rr.Header().Get("Content-Length"), rr.Body.Len())
+	}
+	if rr.Header().Get("Content-Disposition") != `attachment; filename="record-7.json"` {
+		t.Errorf("wrong content disposition: %s", rr.Header().Get("Content-Disposition"))
+	}
+	if !strings.Contains(rr.Body.String(), "\n  \"error\"") || !strings.Contains(rr.Body.String(), `\u003chello\u003e`) {
+		t.Errorf("expected indented, HTML escaped JSON, but got %s", rr.Body.String())
+	}
+
+	var decoded JSONResponse
+	err = json.Unmarshal(rr.Body.Bytes(), &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Message != data.Message || decoded.Data.(map[string]any)["id"] != float64(7) {
+		t.Errorf("body did not round trip: %+v", decoded)
+	}
+}
+
+func TestTools_DownloadJSONContentType(t *testing.T) {
+	testTools := Tools{JSONContentType: "application/json; charset=utf-8"}
+
+	rr := httptest.NewRecorder()
+	if err := testTools.DownloadJSON(rr, map[string]int{"id": 7}, "record.json", false); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Errorf("expected the configured content type, but got %s", rr.Header().Get("Content-Type"))
+	}
+
+	// One set by the caller is kept.
+	rr = httptest.NewRecorder()
+	rr.Header().Set("Content-Type", "application/geo+json")
+	if err := testTools.DownloadJSON(rr, map[string]int{"id": 7}, "record.geojson", false); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Header().Get("Content-Type") != "application/geo+json" {
+		t.Errorf("expected the caller's content type, but got %s", rr.Header().Get("Content-Type"))
+	}
+}
+
+func TestTools_DownloadJSONError(t *testing.T) {
+	var testTools Tools
+
+	rr := httptest.NewRecorder()
+	err := testTools.DownloadJSON(rr, make(chan int), "bad.json", false)
+	if err == nil {
+		t.Fatal("expected error, but none received")
+	}
+
+	if len(rr.Header()) != 0 || rr.Body.Len() != 0 {
+		t.Errorf("nothing should be written when encoding fails, but got headers %v", rr.Header())
+	}
+}
+
+func TestTools_DownloadFromReaderHead(t *testing.T) {
+	var testTools Tools
+	content := strings.Repeat("hello, world\n", 100)
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name   string
+		header http.Header
+		status int
+		length string
+		ranges string
+	}{
+		{name: "head", status: http.StatusOK, length: strconv.Itoa(len(content))},
+		{name: "head with range", header: http.Header{"Range": {"bytes=0-9"}}, status: http.StatusPartialContent, length: "10", ranges: "bytes 0-9/1300"},
+		{name: "head not modified", header: http.Header{"If-Modified-Since": {modTime.Format(http.TimeFormat)}}, status: http.StatusNotModified},
+	}
+
+	for _, e := range tests {
+		req := httptest.NewRequest(http.MethodHead, "/", nil)
+		for key, values := range e.header {
+			req.Header[key] = values
+		}
+		rr := httptest.NewRecorder()
+
+		testTools.DownloadFromReader(rr, req, strings.NewReader(content), modTime, "notes.txt", "")
+
+		if rr.Code != e.status {
+			t.Errorf("%s: expected status %d, but got %d", e.name, e.status, rr.Code)
+		}
+		if rr.Body.Len() != 0 {
+			t.Errorf("%s: expected no body, but got %d bytes", e.name, rr.Body.Len())
+		}
+		if e.status == http.StatusNotModified {
+			continue
+		}
+		if rr.Header().Get("Content-Length") != e.length || rr.Header().Get("Content-Range") != e.ranges {
+			t.Errorf("%s: wrong length headers: %v", e.name, rr.Header())
+		}
+		if rr.Header().Get("Content-Type") != "text/plain; charset=utf-8" ||
+			rr.Header().Get("Content-Disposition") != `attachment; filename="notes.txt"` ||
+			rr.Header().Get("Last-Modified") != modTime.Format(http.TimeFormat) {
+			t.Errorf("%s: missing headers: %v", e.name, rr.Header())
+		}
+	}
+}
+
+// readerAtOnly hides every method of an io.ReaderAt but ReadAt, like a blob store's reader would.
+type readerAtOnly struct{ ra io.ReaderAt }
+
+func (r readerAtOnly) ReadAt(p []byte, off int64) (int, error) {
+	return r.ra.ReadAt(p, off)
+}
+
+func TestTools_DownloadFromReaderAt(t *testing.T) {
+	var testTools Tools
+	content := strings.Repeat("0123456789", 10)
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	stale := modTime.Add(-time.Hour).Format(http.TimeFormat)
+
+	var tests = []struct {
+		name   string
+		method string
+		header http.Header
+		status int
+		body   string
+		ranges string
+	}{
+		{name: "full", status: http.StatusOK, body: content},
+		{name: "middle range", header: http.Header{"Range": {"bytes=15-24"}}, status: http.StatusPartialContent, body: "5678901234", ranges: "bytes 15-24/100"},
+		{name: "suffix range", header: http.Header{"Range": {"bytes=-5"}}, status: http.StatusPartialContent, body: "56789", ranges: "bytes 95-99/100"},
+		{name: "out of bounds", header: http.Header{"Range": {"bytes=200-300"}}, status: http.StatusRequestedRangeNotSatisfiable, body: "invalid range: failed to overlap\n", ranges: "bytes */100"},
+		{name: "several ranges", header: http.Header{"Range": {"bytes=0-4,10-14"}}, status: http.StatusOK, body: content},
+		{name: "if-range with current date", header: http.Header{"Range": {"bytes=0-4"}, "If-Range": {modTime.Format(http.TimeFormat)}}, status: http.StatusPartialContent, body: "01234", ranges: "bytes 0-4/100"},
+		{name: "if-range with stale date", header: http.Header{"Range": {"bytes=0-4"}, "If-Range": {stale}}, status: http.StatusOK, body: content},
+		{name: "if-range with current etag", header: http.Header{"Range": {"bytes=0-4"}, "If-Range": {`"v2"`}}, status: http.StatusPartialContent, body: "01234", ranges: "bytes 0-4/100"},
+		{name: "if-range with stale etag", header: http.Header{"Range": {"bytes=0-4"}, "If-Range": {`"v1"`}}, status: http.StatusOK, body: content},
+		{name: "if-none-match", header: http.Header{"If-None-Match": {`"v2"`}}, status: http.StatusNotModified},
+		{name: "head with range", method: http.MethodHead, header: http.Header{"Range": {"bytes=15-24"}}, status: http.StatusPartialContent, ranges: "bytes 15-24/100"},
+	}
+
+	for _, e := range tests {
+		method := e.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		req := httptest.NewRequest(method, "/", nil)
+		for key, values := range e.header {
+			req.Header[key] = values
+		}
+		rr := httptest.NewRecorder()
+
+		err := testTools.DownloadFromReaderAt(rr, req, readerAtOnly{strings.NewReader(content)}, int64(len(content)), modTime, "digits.txt", "", WithETag("v2"))
+		if err != nil {
+			t.Fatalf("%s: %v", e.name, err)
+		}
+
+		if rr.Code != e.status || rr.Body.String() != e.body {
+			t.Errorf("%s: expected %d %q, but got %d %q", e.name, e.status, e.body, rr.Code, rr.Body)
+		}
+		if rr.Header().Get("Content-Range") != e.ranges {
+			t.Errorf("%s: expected Content-Range %q, but got %q", e.name, e.ranges, rr.Header().Get("Content-Range"))
+		}
+		if e.status == http.StatusOK || e.status == http.StatusPartialContent {
+			length := len(e.body)
+			if method == http.MethodHead {
+				length = 10
+			}
+			if rr.Header().Get("Content-Length") != strconv.Itoa(length) || rr.Header().Get("ETag") != `"v2"` {
+				t.Errorf("%s: wrong headers: %v", e.name, rr.Header())
+			}
+		}
+	}
+
+	if err := testTools.DownloadFromReaderAt(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), strings.NewReader(""), -1, modTime, "x", ""); err == nil {
+		t.Error("expected an error for a negative size")
+	}
+}
+
+// unseekableFS hides the Seek method of the files in an fs.FS, like a zip file would.
+type unseekableFS struct{ fs.FS }
+
+func (u unseekableFS) Open(name string) (fs.File, error) {
+	f, err := u.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return struct{ fs.File }{f}, nil
+}
+
+func TestTools_DownloadFSFile(t *testing.T) {
+	var testTools Tools
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	mapFS := fstest.MapFS{
+		"files/report": {Data: []byte("%PDF-1.4 pretend this is a report"), ModTime: modTime},
+		"files/dir/x":  {Data: []byte("x")},
+	}
+
+	for _, fsys := range []fs.FS{mapFS, unseekableFS{mapFS}} {
+		for _, method := range []string{http.MethodHead, http.MethodGet} {
+			name := fmt.Sprintf("%T %s", fsys, method)
+			rr := httptest.NewRecorder()
+
+			err := testTools.DownloadFSFile(rr, httptest.NewRequest(method, "/", nil), fsys, "files/report", "report.pdf")
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", name, err)
+				continue
+			}
+
+			if rr.Code != http.StatusOK || rr.Header().Get("Content-Length") != "33" ||
+				rr.Header().Get("Content-Type") != "application/pdf" ||
+				rr.Header().Get("Content-Disposition") != `attachment; filename="report.pdf"` ||
+				rr.Header().Get("Last-Modified") != modTime.Format(http.TimeFormat) {
+				t.Errorf("%s: wrong response: %d %v", name, rr.Code, rr.Header())
+			}
+
+			expected := 33
+			if method == http.MethodHead {
+				expected = 0
+			}
+			if rr.Body.Len() != expected {
+				t.Errorf("%s: expected %d bytes of body, but got %d", name, expected, rr.Body.Len())
+			}
+		}
+
+		if err := testTools.DownloadFSFile(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), fsys, "files/missing", ""); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%T: expected fs.ErrNotExist, but got %v", fsys, err)
+		}
+		if err := testTools.DownloadFSFile(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil), fsys, "files/dir", ""); err == nil {
+			t.Errorf("%T: expected an error for a directory", fsys)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package toolbox
+
+import (
+	"net/http"
+	"time"
+)
+
+// DownloadHook is called by the download helpers (DownloadStaticFile, DownloadFromReader,
+// DownloadFromReaderAt, DownloadFSFile, DownloadJSON, DownloadNDJSON and StaticDir) once a response
+// has been sent, with the name of the file, how many bytes of it were sent (which, for a Range
+// request, is the size of the range, and for a HEAD request, zero), the status code, and how long it
+// took. It is called for error responses written by the helpers too, such as a 404 for a missing
+// file, or a 404 from StaticDir for a path outside its directory, but not when a helper returns an
+// error without writing anything, since the caller sends that response. DownloadJSON and
+// DownloadNDJSON have no request, so r is nil for them, and since they can't tell a HEAD request
+// from a GET, they write, and count, the whole body for both; net/http discards it for HEAD.
+type DownloadHook func(r *http.Request, file string, bytesSent int64, status int, duration time.Duration)
+
+// trackDownload returns the http.ResponseWriter a download helper should write file to, and a
+// function to call once the response is complete, which calls OnDownload. If OnDownload is not set,
+// w is returned as it is.
+func (t *Tools) trackDownload(w http.ResponseWriter, r *http.Request, file string) (http.ResponseWriter, func()) {
+	if t.OnDownload == nil {
+		return w, func() {}
+	}
+
+	rr := WrapResponseWriter(w)
+	start := time.Now()
+
+	return rr, func() {
+		t.OnDownload(r, file, rr.BytesWritten(), rr.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,163 @@
+package toolbox
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// downloadRecord is one call to OnDownload.
+type downloadRecord struct {
+	request   *http.Request
+	file      string
+	bytesSent int64
+	status    int
+}
+
+// recordDownloads returns Tools that record every call to OnDownload in records.
+func recordDownloads(records *[]downloadRecord) Tools {
+	return Tools{OnDownload: func(r *http.Request, file string, bytesSent int64, status int, duration time.Duration) {
+		*records = append(*records, downloadRecord{request: r, file: file, bytesSent: bytesSent, status: status})
+	}}
+}
+
+var downloadHookTests = []struct {
+	name           string
+	file           string
+	method         string
+	rangeHeader    string
+	expectedBytes  int64
+	expectedStatus int
+}{
+	{name: "whole file", file: "report.txt", expectedBytes: 26, expectedStatus: http.StatusOK},
+	{name: "byte range", file: "report.txt", rangeHeader: "bytes=5-9", expectedBytes: 5, expectedStatus: http.StatusPartialContent},
+	{name: "head", file: "report.txt", method: http.MethodHead, expectedBytes: 0, expectedStatus: http.StatusOK},
+	{name: "missing", file: "missing.txt", expectedStatus: http.StatusNotFound},
+}
+
+func TestTools_OnDownloadStaticFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "report.txt"), []byte("abcdefghijklmnopqrstuvwxyz"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range downloadHookTests {
+		var records []downloadRecord
+		testTools := recordDownloads(&records)
+
+		method := e.method
+		if method == "" {
+			method = http.MethodGet
+		}
+		req := httptest.NewRequest(method, "/download", nil)
+		if e.rangeHeader != "" {
+			req.Header.Set("Range", e.rangeHeader)
+		}
+
+		rr := httptest.NewRecorder()
+		testTools.DownloadStaticFile(rr, req, dir, e.file, "report.txt")
+
+		if len(records) != 1 {
+			t.Errorf("%s: expected one call to OnDownload, but got %d", e.name, len(records))
+			continue
+		}
+		rec := records[0]
+		if e.expectedStatus != http.StatusNotFound && rec.bytesSent != e.expectedBytes {
+			t.Errorf("%s: expected %d bytes sent, but got %d", e.name, e.expectedBytes, rec.bytesSent)
+		}
+		if rec.status != e.expectedStatus || rr.Code != e.expectedStatus {
+			t.Errorf("%s: expected status %d, but the hook got %d, and the client %d", e.name, e.expectedStatus, rec.status, rr.Code)
+		}
+		if rec.bytesSent != int64(rr.Body.Len()) {
+			t.Errorf("%s: the hook got %d bytes, but the client %d", e.name, rec.bytesSent, rr.Body.Len())
+		}
+		if rec.request != req || rec.file != filepath.Join(dir, e.file) {
+			t.Errorf("%s: wrong request or file passed to the hook: %s", e.name, rec.file)
+		}
+	}
+}
+
+func TestTools_OnDownloadOtherHelpers(t *testing.T) {
+	var records []downloadRecord
+	testTools := recordDownloads(&records)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	testTools.DownloadFromReader(httptest.NewRecorder(), req, strings.NewReader("hello"), time.Time{}, "hello.txt", "")
+
+	err := testTools.DownloadJSON(httptest.NewRecorder(), map[string]int{"a": 1}, "data.json", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is written when encoding fails, so the hook isn't called.
+	_ = testTools.DownloadJSON(httptest.NewRecorder(), make(chan int), "bad.json", false)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = testTools.DownloadFSFile(httptest.NewRecorder(), req, os.DirFS(dir), "a.txt", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An error for a missing file is returned, rather than written, so the hook isn't called.
+	_ = testTools.DownloadFSFile(httptest.NewRecorder(), req, os.DirFS(dir), "missing.txt", "")
+
+	testTools.StaticDir(dir).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a.txt", nil))
+	testTools.StaticDir(dir).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nope.txt", nil))
+
+	expected := []downloadRecord{
+		{request: req, file: "hello.txt", bytesSent: 5, status: http.StatusOK},
+		{file: "data.json", bytesSent: 7, status: http.StatusOK},
+		{request: req, file: "a.txt", bytesSent: 3, status: http.StatusOK},
+		{file: "/a.txt", bytesSent: 3, status: http.StatusOK},
+		{file: "/nope.txt", status: http.StatusNotFound},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d calls to OnDownload, but got %d: %+v", len(expected), len(records), records)
+	}
+	for i, want := range expected {
+		got := records[i]
+		if got.file != want.file || got.status != want.status || (want.bytesSent != 0 && got.bytesSent != want.bytesSent) {
+			t.Errorf("call %d: expected %+v, but got %+v", i, want, got)
+		}
+		if want.request != nil && got.request != want.request {
+			t.Errorf("call %d: wrong request", i)
+		}
+	}
+	if records[1].request != nil {
+		t.Error("expected DownloadJSON to pass a nil request")
+	}
+}
+
+func TestTools_OnDownloadJSONHead(t *testing.T) {
+	var records []downloadRecord
+	testTools := recordDownloads(&records)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = testTools.DownloadJSON(w, map[string]int{"a": 1}, "data.json", false)
+	}))
+	defer server.Close()
+
+	resp, err := http.Head(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	// DownloadJSON can't see that this is a HEAD request, so the whole body is counted, but the
+	// client gets only the headers.
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Length") != "7" || len(body) != 0 {
+		t.Errorf("wrong response: %d, %s, %q", resp.StatusCode, resp.Header.Get("Content-Length"), body)
+	}
+	if len(records) != 1 || records[0].bytesSent != 7 || records[0].status != http.StatusOK {
+		t.Errorf("expected the whole body to be counted, but got %+v", records)
+	}
+}
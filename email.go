@@ -0,0 +1,65 @@
+package toolbox
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+// maxEmailLength is the maximum length of an email address, per RFC 5321 (as amended by errata 1690).
+const maxEmailLength = 254
+
+// IsValidEmail reports whether s is a single, bare email address (e.g. user@example.com). Addresses
+// with a display name or angle brackets are rejected, as are addresses longer than 254 characters.
+// The domain must contain at least one dot unless EmailAllowBareTLD is true, and if
+// EmailRejectConsecutiveDots is true, a local part containing ".." (even if quoted) is rejected.
+func (t *Tools) IsValidEmail(s string) bool {
+	_, err := t.parseEmail(s)
+	return err == nil
+}
+
+// NormalizeEmail validates s using the same rules as IsValidEmail, and returns it with the domain
+// part lowercased. The local part is left untouched, since it is case-sensitive per RFC 5321.
+func (t *Tools) NormalizeEmail(s string) (string, error) {
+	local, err := t.parseEmail(s)
+	if err != nil {
+		return "", err
+	}
+
+	domain := s[len(local)+1:]
+	return local + "@" + strings.ToLower(domain), nil
+}
+
+// parseEmail validates s as an email address, and returns the raw local part (everything
+// before the final @).
+func (t *Tools) parseEmail(s string) (string, error) {
+	if s == "" || len(s) > maxEmailLength {
+		return "", errors.New("email address must be between 1 and 254 characters")
+	}
+
+	if strings.TrimSpace(s) != s || strings.HasSuffix(s, ">") {
+		return "", errors.New("email address must not contain a display name")
+	}
+
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return "", err
+	}
+
+	if addr.Name != "" {
+		return "", errors.New("email address must not contain a display name")
+	}
+
+	at := strings.LastIndex(s, "@")
+	local, domain := s[:at], s[at+1:]
+
+	if !t.EmailAllowBareTLD && !strings.Contains(domain, ".") {
+		return "", errors.New("email address domain must contain a dot")
+	}
+
+	if t.EmailRejectConsecutiveDots && strings.Contains(local, "..") {
+		return "", errors.New("email address local part must not contain consecutive dots")
+	}
+
+	return local, nil
+}
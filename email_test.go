@@ -0,0 +1,60 @@
+package toolbox
+
+import (
+	"strings"
+	"testing"
+)
+
+var emailTests = []struct {
+	name             string
+	email            string
+	allowBareTLD     bool
+	rejectDoubleDots bool
+	valid            bool
+}{
+	{name: "simple", email: "jack@example.com", valid: true},
+	{name: "plus addressing", email: "jack+news@example.com", valid: true},
+	{name: "quoted local part", email: `"jack smith"@example.com`, valid: true},
+	{name: "unicode domain", email: "jack@bücher.de", valid: true},
+	{name: "missing tld", email: "jack@localhost", valid: false},
+	{name: "missing tld allowed", email: "jack@localhost", allowBareTLD: true, valid: true},
+	{name: "display name", email: "Jack Smith <jack@example.com>", valid: false},
+	{name: "angle brackets", email: "<jack@example.com>", valid: false},
+	{name: "two addresses", email: "jack@example.com, jill@example.com", valid: false},
+	{name: "no at sign", email: "jack.example.com", valid: false},
+	{name: "leading space", email: " jack@example.com", valid: false},
+	{name: "too long", email: strings.Repeat("a", 64) + "@" + strings.Repeat("b", 190) + ".com", valid: false},
+	{name: "quoted consecutive dots", email: `"jack..smith"@example.com`, valid: true},
+	{name: "quoted consecutive dots rejected", email: `"jack..smith"@example.com`, rejectDoubleDots: true, valid: false},
+	{name: "empty", email: "", valid: false},
+}
+
+func TestTools_IsValidEmail(t *testing.T) {
+	for _, e := range emailTests {
+		var testTools Tools
+		testTools.EmailAllowBareTLD = e.allowBareTLD
+		testTools.EmailRejectConsecutiveDots = e.rejectDoubleDots
+
+		if valid := testTools.IsValidEmail(e.email); valid != e.valid {
+			t.Errorf("%s: expected valid to be %t for %q, but got %t", e.name, e.valid, e.email, valid)
+		}
+	}
+}
+
+func TestTools_NormalizeEmail(t *testing.T) {
+	var testTools Tools
+
+	email, err := testTools.NormalizeEmail("Jack.Smith@Example.COM")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if email != "Jack.Smith@example.com" {
+		t.Errorf("wrong normalized email; expected Jack.Smith@example.com but got %s", email)
+	}
+
+	_, err = testTools.NormalizeEmail("Jack <jack@example.com>")
+	if err == nil {
+		t.Error("expected error for address with display name, but none received")
+	}
+}
@@ -0,0 +1,158 @@
+package toolbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+)
+
+// encodeChunkSize is how much EncodeJSONContext writes at a time, checking for cancellation in between.
+const encodeChunkSize = 32 * 1024
+
+// EncodeJSON writes data to w as JSON, exactly as WriteJSON would write it to a client, honoring
+// JSONIndent and JSONDisableHTMLEscape. It returns the number of bytes written. Nothing is written
+// if data cannot be encoded.
+func (t *Tools) EncodeJSON(w io.Writer, data any) (int, error) {
+	out, err := t.marshalJSON(data)
+	if err != nil {
+		return 0, err
+	}
+
+	return w.Write(out)
+}
+
+// EncodeJSONContext is EncodeJSON for very large values: the encoded data is written to w in chunks,
+// and writing stops with ctx.Err() as soon as ctx is done. The returned count is the number of bytes
+// written before that happened.
+func (t *Tools) EncodeJSONContext(ctx context.Context, w io.Writer, data any) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	out, err := t.marshalJSON(data)
+	if err != nil {
+		return 0, err
+	}
+
+	written := 0
+	for written < len(out) {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+
+		end := written + encodeChunkSize
+		if end > len(out) {
+			end = len(out)
+		}
+
+		n, err := w.Write(out[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// EncodeXML writes data to w as XML, with the standard XML header, exactly as WriteXML would write it to
+// a client, honoring XMLIndent. It returns the number of bytes written. Nothing is written if data cannot
+// be encoded.
+func (t *Tools) EncodeXML(w io.Writer, data any) (int, error) {
+	out, err := t.marshalXML(data)
+	if err != nil {
+		return 0, err
+	}
+
+	return w.Write(out)
+}
+
+// marshalJSON encodes data using the JSON options set on t. With no options set, the output is the
+// same as json.Marshal.
+func (t *Tools) marshalJSON(data any) ([]byte, error) {
+	return t.marshalJSONIndent(data, t.JSONIndent)
+}
+
+// marshalJSONIndent is marshalJSON, but indents with indent (if it is not empty) instead of JSONIndent.
+func (t *Tools) marshalJSONIndent(data any, indent string) ([]byte, error) {
+	if t.JSONMarshalHook != nil {
+		var err error
+		data, err = t.JSONMarshalHook(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(!t.JSONDisableHTMLEscape)
+	if indent != "" {
+		enc.SetIndent("", indent)
+	}
+
+	err := enc.Encode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Encode adds a newline, which json.Marshal (and so WriteJSON) never did.
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// marshalXML encodes data using the XML options set on t, and puts the XML header in front of it.
+func (t *Tools) marshalXML(data any) ([]byte, error) {
+	buf := bytes.NewBufferString(xml.Header)
+
+	enc := xml.NewEncoder(buf)
+	if t.XMLIndent != "" {
+		enc.Indent("", t.XMLIndent)
+	}
+
+	err := enc.Encode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// jsonField is a single member of a jsonObject.
+type jsonField struct {
+	Key   string
+	Value any
+}
+
+// jsonObject is a JSON object whose members are encoded in order, for payloads whose field names are
+// only known at run time.
+type jsonObject []jsonField
+
+// MarshalJSON encodes o as a JSON object, preserving the order of its members.
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(field.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
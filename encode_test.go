@@ -0,0 +1,133 @@
+package toolbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var encodeTests = []struct {
+	name  string
+	tools Tools
+	data  any
+}{
+	{name: "defaults", data: JSONResponse{Message: "<b>fish & chips</b>", Data: []int{1, 2, 3}}},
+	{name: "indented", tools: Tools{JSONIndent: "  ", XMLIndent: "  "}, data: JSONResponse{Message: "hello", Data: map[string]string{"a": "b"}}},
+	{name: "html not escaped", tools: Tools{JSONDisableHTMLEscape: true}, data: JSONResponse{Message: "<b>fish & chips</b>"}},
+}
+
+func TestTools_EncodeJSON(t *testing.T) {
+	for _, e := range encodeTests {
+		var buf bytes.Buffer
+		n, err := e.tools.EncodeJSON(&buf, e.data)
+		if err != nil {
+			t.Fatalf("%s: %v", e.name, err)
+		}
+
+		if n != buf.Len() {
+			t.Errorf("%s: reported %d bytes written, but wrote %d", e.name, n, buf.Len())
+		}
+
+		rr := httptest.NewRecorder()
+		err = e.tools.WriteJSON(rr, http.StatusOK, e.data)
+		if err != nil {
+			t.Fatalf("%s: %v", e.name, err)
+		}
+
+		if !bytes.Equal(buf.Bytes(), rr.Body.Bytes()) {
+			t.Errorf("%s: EncodeJSON and WriteJSON differ:\n%s\n%s", e.name, buf.String(), rr.Body.String())
+		}
+	}
+}
+
+func TestTools_EncodeJSONOptions(t *testing.T) {
+	var testTools Tools
+
+	var buf bytes.Buffer
+	_, _ = testTools.EncodeJSON(&buf, map[string]string{"a": "<b>"})
+	if buf.String() != `{"a":"\u003cb\u003e"}` {
+		t.Errorf("wrong default output: %s", buf.String())
+	}
+
+	testTools.JSONDisableHTMLEscape = true
+	testTools.JSONIndent = "\t"
+	buf.Reset()
+	_, _ = testTools.EncodeJSON(&buf, map[string]string{"a": "<b>"})
+	if buf.String() != "{\n\t\"a\": \"<b>\"\n}" {
+		t.Errorf("wrong output with options: %q", buf.String())
+	}
+
+	buf.Reset()
+	_, err := testTools.EncodeJSON(&buf, make(chan int))
+	if err == nil {
+		t.Error("expected error encoding a channel, but none received")
+	}
+	if buf.Len() != 0 {
+		t.Error("nothing should be written when encoding fails")
+	}
+}
+
+func TestTools_EncodeXML(t *testing.T) {
+	for _, e := range encodeTests {
+		data := XMLResponse{Message: "hello & goodbye"}
+
+		var buf bytes.Buffer
+		n, err := e.tools.EncodeXML(&buf, data)
+		if err != nil {
+			t.Fatalf("%s: %v", e.name, err)
+		}
+
+		if n != buf.Len() {
+			t.Errorf("%s: reported %d bytes written, but wrote %d", e.name, n, buf.Len())
+		}
+
+		rr := httptest.NewRecorder()
+		err = e.tools.WriteXML(rr, http.StatusOK, data)
+		if err != nil {
+			t.Fatalf("%s: %v", e.name, err)
+		}
+
+		if !bytes.Equal(buf.Bytes(), rr.Body.Bytes()) {
+			t.Errorf("%s: EncodeXML and WriteXML differ:\n%s\n%s", e.name, buf.String(), rr.Body.String())
+		}
+	}
+}
+
+// cancellingWriter cancels a context after its first write.
+type cancellingWriter struct {
+	bytes.Buffer
+	cancel context.CancelFunc
+}
+
+func (c *cancellingWriter) Write(p []byte) (int, error) {
+	c.cancel()
+	return c.Buffer.Write(p)
+}
+
+func TestTools_EncodeJSONContext(t *testing.T) {
+	var testTools Tools
+	big := strings.Repeat("x", 3*encodeChunkSize)
+
+	var buf bytes.Buffer
+	n, err := testTools.EncodeJSONContext(context.Background(), &buf, big)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(big)+2 || buf.String() != `"`+big+`"` {
+		t.Errorf("wrong output; wrote %d bytes", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &cancellingWriter{cancel: cancel}
+	n, err = testTools.EncodeJSONContext(ctx, w, big)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, but got %v", err)
+	}
+	if n != encodeChunkSize || w.Len() != encodeChunkSize {
+		t.Errorf("expected writing to stop after one chunk, but wrote %d bytes", n)
+	}
+}
@@ -0,0 +1,175 @@
+package toolbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// durationType is the reflect.Type of time.Duration, which is parsed with time.ParseDuration.
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// LoadEnvConfig populates the struct pointed to by dst from environment variables. Each field is
+// read from the variable named in its env tag, or from the field name converted to upper snake case
+// (MaxConns becomes MAX_CONNS); in both cases, prefix and an underscore are prepended if prefix is
+// not empty. Nested structs are flattened, with their own name as an extra segment, so with a prefix
+// of APP, the field DB.Host is read from APP_DB_HOST. For example:
+//
+//	type Config struct {
+//		Port    int           `env:"PORT" default:"8080"`
+//		DSN     string        `env:"DSN,required"`
+//		Timeout time.Duration `default:"5s"`
+//		Hosts   []string      // comma separated
+//	}
+//
+// Supported field types are string, bool, integers, floats, time.Duration, and []string. Fields tagged
+// env:"-" are skipped. Instead of stopping at the first problem, the returned error lists every
+// required variable that is missing, and every variable that could not be converted.
+func (t *Tools) LoadEnvConfig(dst any, prefix string) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("LoadEnvConfig: destination must be a non-nil pointer to a struct, got %T", dst)
+	}
+
+	var errs []error
+	loadEnvStruct(rv.Elem(), prefix, &errs)
+
+	return errors.Join(errs...)
+}
+
+// loadEnvStruct sets the fields of the struct rv, appending any problems to errs.
+func loadEnvStruct(rv reflect.Value, prefix string, errs *[]error) {
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("env")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = upperSnakeCase(field.Name)
+		}
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+
+		fv := rv.Field(i)
+
+		if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+			loadEnvStruct(fv, name, errs)
+			continue
+		}
+
+		value, found := os.LookupEnv(name)
+		if !found {
+			if def, ok := field.Tag.Lookup("default"); ok {
+				value, found = def, true
+			}
+		}
+
+		if !found {
+			if hasTagOption(opts, "required") {
+				*errs = append(*errs, fmt.Errorf("%s is required, but not set", name))
+			}
+			continue
+		}
+
+		err := setFieldFromString(fv, value)
+		if err != nil {
+			*errs = append(*errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+}
+
+// setFieldFromString converts s to the type of fv, and stores it there.
+func setFieldFromString(fv reflect.Value, s string) error {
+	if fv.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		fv.SetInt(int64(d))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		fv.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		fv.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", s)
+		}
+		fv.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		fv.SetFloat(f)
+
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", fv.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		fv.Set(reflect.ValueOf(items).Convert(fv.Type()))
+
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+
+	return nil
+}
+
+// upperSnakeCase converts a Go identifier like MaxConns or HTTPPort to MAX_CONNS or HTTP_PORT.
+func upperSnakeCase(s string) string {
+	runes := []rune(s)
+
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,102 @@
+package toolbox
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type envTestConfig struct {
+	Port     int           `env:"PORT" default:"8080"`
+	DSN      string        `env:"DSN,required"`
+	Secret   string        `env:"SECRET,required"`
+	Timeout  time.Duration `default:"5s"`
+	Debug    bool
+	Hosts    []string
+	MaxConns uint
+	Ratio    float64
+	Skipped  string `env:"-"`
+	DB       struct {
+		Host string
+	}
+}
+
+func TestTools_LoadEnvConfig(t *testing.T) {
+	var testTools Tools
+
+	t.Setenv("APP_DSN", "postgres://localhost")
+	t.Setenv("APP_SECRET", "shh")
+	t.Setenv("APP_DEBUG", "true")
+	t.Setenv("APP_HOSTS", "a.example.com, b.example.com")
+	t.Setenv("APP_MAX_CONNS", "20")
+	t.Setenv("APP_RATIO", "0.75")
+	t.Setenv("APP_SKIPPED", "nope")
+	t.Setenv("APP_DB_HOST", "db.internal")
+
+	var cfg envTestConfig
+	err := testTools.LoadEnvConfig(&cfg, "APP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Port != 8080 || cfg.Timeout != 5*time.Second {
+		t.Errorf("defaults not applied: %+v", cfg)
+	}
+
+	if cfg.DSN != "postgres://localhost" || !cfg.Debug || cfg.MaxConns != 20 || cfg.Ratio != 0.75 {
+		t.Errorf("wrong values loaded: %+v", cfg)
+	}
+
+	if len(cfg.Hosts) != 2 || cfg.Hosts[1] != "b.example.com" {
+		t.Errorf("wrong slice loaded: %v", cfg.Hosts)
+	}
+
+	if cfg.Skipped != "" {
+		t.Error("field tagged - should be skipped")
+	}
+
+	if cfg.DB.Host != "db.internal" {
+		t.Errorf("nested field not loaded: %s", cfg.DB.Host)
+	}
+}
+
+func TestTools_LoadEnvConfigErrors(t *testing.T) {
+	var testTools Tools
+
+	t.Setenv("APP_TIMEOUT", "five seconds")
+	t.Setenv("APP_PORT", "eighty")
+
+	var cfg envTestConfig
+	err := testTools.LoadEnvConfig(&cfg, "APP")
+	if err == nil {
+		t.Fatal("expected error, but none received")
+	}
+
+	for _, expected := range []string{"APP_DSN is required", "APP_SECRET is required", "APP_TIMEOUT: invalid duration", "APP_PORT: invalid integer"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error to contain %q, but got: %v", expected, err)
+		}
+	}
+
+	err = testTools.LoadEnvConfig(cfg, "APP")
+	if err == nil {
+		t.Error("expected error for non-pointer destination, but none received")
+	}
+}
+
+func TestUpperSnakeCase(t *testing.T) {
+	tests := map[string]string{
+		"Port":      "PORT",
+		"MaxConns":  "MAX_CONNS",
+		"HTTPPort":  "HTTP_PORT",
+		"DBHost2":   "DB_HOST2",
+		"APIKeyID":  "API_KEY_ID",
+		"userAgent": "USER_AGENT",
+	}
+
+	for in, expected := range tests {
+		if got := upperSnakeCase(in); got != expected {
+			t.Errorf("%s: expected %s but got %s", in, expected, got)
+		}
+	}
+}
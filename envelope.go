@@ -0,0 +1,179 @@
+package toolbox
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+)
+
+// EnvelopeNames renames the members of the envelope sent by WriteEnvelope, WriteEnvelopeXML, and the
+// error helpers (ErrorJSON, ErrorXML, and their Ctx versions), for APIs whose clients expect
+// something other than JSONResponse and XMLResponse, e.g. {"success": false, "msg": "..."}. Empty
+// names keep their defaults.
+type EnvelopeNames struct {
+	Flag             string // name of the flag saying whether the response is an error (defaults to error)
+	FlagMeansSuccess bool   // if true, the flag is true for successful responses, and false for errors, as for a flag named success
+	Message          string // name of the message (defaults to message)
+	Data             string // name of the data, which is left out if there is none (defaults to data)
+	Errors           string // name of the FieldErrors list sent by ErrorJSON, if the error has one (defaults to errors)
+	XMLRoot          string // name of the root element of XML envelopes (defaults to XMLResponse)
+}
+
+// envelopeNames returns the names used for envelopes, with defaults for any not set in EnvelopeNames.
+func (t *Tools) envelopeNames() EnvelopeNames {
+	names := EnvelopeNames{Flag: "error", Message: "message", Data: "data", Errors: "errors", XMLRoot: "XMLResponse"}
+	if n := t.EnvelopeNames; n != nil {
+		names.FlagMeansSuccess = n.FlagMeansSuccess
+		if n.Flag != "" {
+			names.Flag = n.Flag
+		}
+		if n.Message != "" {
+			names.Message = n.Message
+		}
+		if n.Data != "" {
+			names.Data = n.Data
+		}
+		if n.Errors != "" {
+			names.Errors = n.Errors
+		}
+		if n.XMLRoot != "" {
+			names.XMLRoot = n.XMLRoot
+		}
+	}
+	return names
+}
+
+// WriteEnvelope sends message and data as JSON, wrapped in the standard envelope: a JSONResponse, or
+// the members named by EnvelopeNames, if it is set. The response is an error if status is 400 or more.
+func (t *Tools) WriteEnvelope(w http.ResponseWriter, status int, message string, data any) error {
+	kind := "json"
+	if status >= http.StatusBadRequest {
+		kind = "json_error"
+	}
+	return t.writeJSON(w, kind, status, t.jsonEnvelope(status >= http.StatusBadRequest, message, data))
+}
+
+// WriteJSONNamed is WriteEnvelope, with data sent as the member called key, rather than data, so
+// that a list can go out as, say, {"error":false,"message":"","users":[...]}. The flag and message
+// come first, and are named as for WriteEnvelope. An error is returned, before anything is sent, if
+// key is empty, or is the name of the flag or the message.
+func (t *Tools) WriteJSONNamed(w http.ResponseWriter, status int, message string, key string, data any, headers ...http.Header) error {
+	names := t.envelopeNames()
+	if key == "" || !utf8.ValidString(key) {
+		return errors.New("WriteJSONNamed: key must be a non-empty UTF-8 string")
+	}
+	if key == names.Flag || key == names.Message {
+		return fmt.Errorf("WriteJSONNamed: key %q is already used by the envelope", key)
+	}
+
+	isError := status >= http.StatusBadRequest
+	payload := jsonObject{
+		{Key: names.Flag, Value: isError != names.FlagMeansSuccess},
+		{Key: names.Message, Value: message},
+		{Key: key, Value: data},
+	}
+
+	kind := "json"
+	if isError {
+		kind = "json_error"
+	}
+	return t.writeJSON(w, kind, status, payload, headers...)
+}
+
+// WriteEnvelopeXML is WriteEnvelope, for XML: the envelope is an XMLResponse, or the elements named
+// by EnvelopeNames, if it is set.
+func (t *Tools) WriteEnvelopeXML(w http.ResponseWriter, status int, message string, data any) error {
+	kind := "xml"
+	if status >= http.StatusBadRequest {
+		kind = "xml_error"
+	}
+	return t.writeXML(w, kind, status, t.xmlEnvelope(status >= http.StatusBadRequest, message, data))
+}
+
+// jsonEnvelope returns the JSON envelope for message and data, followed by extra, if there is any.
+// Without EnvelopeNames or extra, that's a JSONResponse, as it always was.
+func (t *Tools) jsonEnvelope(isError bool, message string, data any, extra ...jsonField) any {
+	if t.EnvelopeNames == nil && len(extra) == 0 {
+		return JSONResponse{Error: isError, Message: message, Data: data}
+	}
+
+	names := t.envelopeNames()
+	envelope := jsonObject{
+		{Key: names.Flag, Value: isError != names.FlagMeansSuccess},
+		{Key: names.Message, Value: message},
+	}
+	if data != nil {
+		envelope = append(envelope, jsonField{Key: names.Data, Value: data})
+	}
+
+	return append(envelope, extra...)
+}
+
+// fieldErrorsMember returns the envelope member listing the FieldErrors held by err, as an extra for
+// jsonEnvelope, or nothing if err holds none, or its message is being suppressed (see errorMessage).
+func (t *Tools) fieldErrorsMember(err error, statusCode int) []jsonField {
+	if t.SuppressInternalErrors && !t.Debug && statusCode >= http.StatusInternalServerError {
+		return nil
+	}
+
+	var fieldErrs FieldErrors
+	if !errors.As(err, &fieldErrs) || !fieldErrs.Any() {
+		return nil
+	}
+	return []jsonField{{Key: t.envelopeNames().Errors, Value: fieldErrs}}
+}
+
+// xmlEnvelope returns the XML envelope for message and data, followed by extra, if there is any.
+// Without EnvelopeNames or extra, that's an XMLResponse, as it always was.
+func (t *Tools) xmlEnvelope(isError bool, message string, data any, extra ...xmlElement) any {
+	if t.EnvelopeNames == nil && len(extra) == 0 {
+		return XMLResponse{Error: isError, Message: message, Data: data}
+	}
+
+	names := t.envelopeNames()
+	envelope := xmlDocument{
+		name: names.XMLRoot,
+		elements: []xmlElement{
+			{Name: names.Flag, Value: isError != names.FlagMeansSuccess},
+			{Name: names.Message, Value: message},
+		},
+	}
+	if data != nil {
+		envelope.elements = append(envelope.elements, xmlElement{Name: names.Data, Value: data})
+	}
+	envelope.elements = append(envelope.elements, extra...)
+
+	return envelope
+}
+
+// xmlElement is an element of an xmlDocument.
+type xmlElement struct {
+	Name  string
+	Value any
+}
+
+// xmlDocument is an XML element, called name, whose children are elements, in order, for payloads
+// whose element names are only known at run time.
+type xmlDocument struct {
+	name     string
+	elements []xmlElement
+}
+
+// MarshalXML encodes d as an element called d.name, holding each of its elements.
+func (d xmlDocument) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: d.name}}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	for _, element := range d.elements {
+		err := e.EncodeElement(element.Value, xml.StartElement{Name: xml.Name{Local: element.Name}})
+		if err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
@@ -0,0 +1,164 @@
+package toolbox
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// legacyEnvelope is the envelope of an API with a success flag, rather than an error flag.
+var legacyEnvelope = &EnvelopeNames{Flag: "success", FlagMeansSuccess: true, Message: "msg", Data: "payload", XMLRoot: "response"}
+
+var envelopeTests = []struct {
+	name     string
+	names    *EnvelopeNames
+	status   int
+	message  string
+	data     any
+	expected string
+}{
+	{name: "default", status: http.StatusOK, message: "ok", data: map[string]int{"id": 1}, expected: `{"error":false,"message":"ok","data":{"id":1}}`},
+	{name: "default error", status: http.StatusNotFound, message: "missing", expected: `{"error":true,"message":"missing"}`},
+	{name: "renamed", names: legacyEnvelope, status: http.StatusOK, message: "ok", data: map[string]int{"id": 1}, expected: `{"success":true,"msg":"ok","payload":{"id":1}}`},
+	{name: "renamed error", names: legacyEnvelope, status: http.StatusConflict, message: "taken", expected: `{"success":false,"msg":"taken"}`},
+	{name: "partly renamed", names: &EnvelopeNames{Message: "detail"}, status: http.StatusBadRequest, message: "bad", expected: `{"error":true,"detail":"bad"}`},
+}
+
+func TestTools_WriteEnvelope(t *testing.T) {
+	for _, e := range envelopeTests {
+		testTools := Tools{EnvelopeNames: e.names}
+
+		rr := httptest.NewRecorder()
+		if err := testTools.WriteEnvelope(rr, e.status, e.message, e.data); err != nil {
+			t.Fatal(err)
+		}
+
+		if rr.Code != e.status {
+			t.Errorf("%s: expected status %d, but got %d", e.name, e.status, rr.Code)
+		}
+		if rr.Body.String() != e.expected {
+			t.Errorf("%s: expected %s, but got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+}
+
+var envelopeXMLTests = []struct {
+	name     string
+	names    *EnvelopeNames
+	status   int
+	data     any
+	expected string
+}{
+	{name: "default", status: http.StatusOK, data: 42, expected: `<XMLResponse><error>false</error><message>ok</message><data>42</data></XMLResponse>`},
+	{name: "renamed", names: legacyEnvelope, status: http.StatusOK, data: 42, expected: `<response><success>true</success><msg>ok</msg><payload>42</payload></response>`},
+	{name: "renamed error", names: legacyEnvelope, status: http.StatusBadRequest, expected: `<response><success>false</success><msg>ok</msg></response>`},
+}
+
+func TestTools_WriteEnvelopeXML(t *testing.T) {
+	for _, e := range envelopeXMLTests {
+		testTools := Tools{EnvelopeNames: e.names}
+
+		rr := httptest.NewRecorder()
+		if err := testTools.WriteEnvelopeXML(rr, e.status, "ok", e.data); err != nil {
+			t.Fatal(err)
+		}
+
+		if rr.Body.String() != xml.Header+e.expected {
+			t.Errorf("%s: expected %s, but got %s", e.name, e.expected, rr.Body.String())
+		}
+	}
+}
+
+func TestTools_ErrorEnvelopeNames(t *testing.T) {
+	testTools := Tools{EnvelopeNames: legacyEnvelope}
+	err := errors.New("something went wrong")
+
+	rr := httptest.NewRecorder()
+	_ = testTools.ErrorJSON(rr, err)
+	if expected := `{"success":false,"msg":"something went wrong"}`; rr.Body.String() != expected {
+		t.Errorf("ErrorJSON: expected %s, but got %s", expected, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	_ = testTools.ErrorXML(rr, err)
+	if expected := `<response><success>false</success><msg>something went wrong</msg></response>`; rr.Body.String() != xml.Header+expected {
+		t.Errorf("ErrorXML: expected %s, but got %s", expected, rr.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(WithRequestID(context.Background(), "abc123"))
+
+	rr = httptest.NewRecorder()
+	_ = testTools.ErrorJSONCtx(rr, req, err)
+	if expected := `{"success":false,"msg":"something went wrong","request_id":"abc123"}`; rr.Body.String() != expected {
+		t.Errorf("ErrorJSONCtx: expected %s, but got %s", expected, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	_ = testTools.ErrorXMLCtx(rr, req, err)
+	if expected := `<response><success>false</success><msg>something went wrong</msg><request_id>abc123</request_id></response>`; rr.Body.String() != xml.Header+expected {
+		t.Errorf("ErrorXMLCtx: expected %s, but got %s", expected, rr.Body.String())
+	}
+}
+
+func TestTools_WriteJSONNamed(t *testing.T) {
+	var testTools Tools
+
+	rr := httptest.NewRecorder()
+	err := testTools.WriteJSONNamed(rr, http.StatusOK, "", "users", []string{"ann", "bob"}, http.Header{"X-Total": {"2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"error":false,"message":"","users":["ann","bob"]}`; rr.Body.String() != expected {
+		t.Errorf("expected %s, but got %s", expected, rr.Body.String())
+	}
+	if rr.Header().Get("X-Total") != "2" || rr.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("wrong headers: %v", rr.Header())
+	}
+
+	// The flag and message follow EnvelopeNames.
+	legacy := Tools{EnvelopeNames: legacyEnvelope}
+	rr = httptest.NewRecorder()
+	_ = legacy.WriteJSONNamed(rr, http.StatusOK, "found", "orders", []int{1})
+	if expected := `{"success":true,"msg":"found","orders":[1]}`; rr.Body.String() != expected {
+		t.Errorf("expected %s, but got %s", expected, rr.Body.String())
+	}
+
+	for _, key := range []string{"", "error", "message", "\xff"} {
+		rr = httptest.NewRecorder()
+		if err := testTools.WriteJSONNamed(rr, http.StatusOK, "", key, nil); err == nil || rr.Body.Len() != 0 {
+			t.Errorf("expected an error, and nothing sent, for the key %q", key)
+		}
+	}
+}
+
+func TestTools_WriteJSONNamedConcurrent(t *testing.T) {
+	var testTools Tools
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		key := "users"
+		if i%2 == 1 {
+			key = "orders"
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			rr := httptest.NewRecorder()
+			if err := testTools.WriteJSONNamed(rr, http.StatusOK, "", key, []int{1, 2}); err != nil {
+				t.Error(err)
+				return
+			}
+			if expected := `{"error":false,"message":"","` + key + `":[1,2]}`; rr.Body.String() != expected {
+				t.Errorf("expected %s, but got %s", expected, rr.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+}
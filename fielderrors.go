@@ -0,0 +1,121 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FieldError is a single problem in a FieldErrors: with a form field, a CSV row, an uploaded file,
+// and so on.
+type FieldError struct {
+	Field   string // the field the problem is with, if any
+	Index   int    // the position of the item the problem is with (e.g. a CSV row), counting from 1, or zero
+	Code    string // a short, stable code for the problem, such as required or invalid, for clients to act on
+	Message string // the message for people; if empty, Err's message is used
+	Err     error  // the underlying error, if there is one
+}
+
+// Error satisfies the error interface.
+func (e FieldError) Error() string {
+	switch {
+	case e.Field != "" && e.Index > 0:
+		return fmt.Sprintf("%s #%d: %s", e.Field, e.Index, e.message())
+	case e.Field != "":
+		return fmt.Sprintf("%s: %s", e.Field, e.message())
+	case e.Index > 0:
+		return fmt.Sprintf("#%d: %s", e.Index, e.message())
+	default:
+		return e.message()
+	}
+}
+
+// Unwrap returns the underlying error.
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// message returns Message, or, if it isn't set, the message of Err.
+func (e FieldError) message() string {
+	if e.Message == "" && e.Err != nil {
+		return e.Err.Error()
+	}
+	return e.Message
+}
+
+// MarshalJSON writes the error as an object with field, index, code and message members, leaving
+// out the field and index if they aren't set.
+func (e FieldError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Field   string `json:"field,omitempty"`
+		Index   int    `json:"index,omitempty"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}{e.Field, e.Index, e.Code, e.message()})
+}
+
+// FieldErrors is a list of problems, in the order they were found, which is itself an error. It is
+// returned (usually wrapped) by ReadForm, ReadBody and ReadMultipartForm, inside a *FormError; by
+// UploadFiles and friends when a batch of files fails; and by CSVReport.FieldErrors. Get it back with
+// errors.As:
+//
+//	var fieldErrs toolbox.FieldErrors
+//	if errors.As(err, &fieldErrs) {
+//		...
+//	}
+//
+// ErrorJSON does that for itself, and sends the list to the client as the "errors" member of the
+// payload. It marshals to JSON as an array of FieldError objects, so it can also be sent with WriteJSON.
+type FieldErrors []FieldError
+
+// Add appends a problem with field, with a code and message.
+func (fe *FieldErrors) Add(field, code, message string) {
+	*fe = append(*fe, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Merge appends the problems in other, keeping their order.
+func (fe *FieldErrors) Merge(other FieldErrors) {
+	*fe = append(*fe, other...)
+}
+
+// Any reports whether there are any problems.
+func (fe FieldErrors) Any() bool {
+	return len(fe) > 0
+}
+
+// Err returns fe as an error, or nil if there are no problems, so that a function collecting problems
+// can end with return fieldErrs.Err().
+func (fe FieldErrors) Err() error {
+	if !fe.Any() {
+		return nil
+	}
+	return fe
+}
+
+// Error satisfies the error interface, joining the message for each problem with "; ".
+func (fe FieldErrors) Error() string {
+	msgs := make([]string, len(fe))
+	for i, e := range fe {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the underlying errors, so that errors.Is and errors.As can look inside them.
+func (fe FieldErrors) Unwrap() []error {
+	var errs []error
+	for _, e := range fe {
+		if e.Err != nil {
+			errs = append(errs, e.Err)
+		}
+	}
+	return errs
+}
+
+// MarshalJSON writes the list as an array, which is empty, rather than null, if there are no problems.
+func (fe FieldErrors) MarshalJSON() ([]byte, error) {
+	if fe == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]FieldError(fe))
+}
@@ -0,0 +1,163 @@
+package toolbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFieldErrors(t *testing.T) {
+	var fieldErrs FieldErrors
+	if fieldErrs.Any() || fieldErrs.Err() != nil {
+		t.Error("expected no problems in an empty FieldErrors")
+	}
+
+	cause := errors.New("not a number")
+	fieldErrs.Add("title", "required", "is required")
+	fieldErrs.Merge(FieldErrors{{Field: "views", Code: "invalid", Err: cause}, {Index: 3, Code: "invalid", Message: "bad row"}})
+
+	if !fieldErrs.Any() || len(fieldErrs) != 3 {
+		t.Fatalf("expected 3 problems, but got %v", fieldErrs)
+	}
+
+	if got := fieldErrs.Error(); got != "title: is required; views: not a number; #3: bad row" {
+		t.Errorf("wrong message: %q", got)
+	}
+
+	wrapped := fmt.Errorf("saving post: %w", fieldErrs.Err())
+	var got FieldErrors
+	if !errors.As(wrapped, &got) || len(got) != 3 {
+		t.Errorf("expected errors.As to find the FieldErrors, but got %v", got)
+	}
+	if !errors.Is(wrapped, cause) {
+		t.Error("expected errors.Is to find the error for views")
+	}
+
+	out, err := json.Marshal(fieldErrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"field":"title","code":"required","message":"is required"},` +
+		`{"field":"views","code":"invalid","message":"not a number"},{"index":3,"code":"invalid","message":"bad row"}]`
+	if string(out) != expected {
+		t.Errorf("wrong JSON: %s", out)
+	}
+
+	out, _ = json.Marshal(FieldErrors(nil))
+	if string(out) != "[]" {
+		t.Errorf("expected an empty array, but got %s", out)
+	}
+}
+
+func TestTools_ErrorJSONFieldErrors(t *testing.T) {
+	var testTools Tools
+
+	// The first problems come from a form...
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("views=lots"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var post struct {
+		Title string `form:"title,required"`
+		Views int    `form:"views"`
+	}
+	formErr := testTools.ReadForm(httptest.NewRecorder(), req, &post)
+
+	var fieldErrs FieldErrors
+	if !errors.As(formErr, &fieldErrs) {
+		t.Fatalf("expected FieldErrors from ReadForm, but got %v", formErr)
+	}
+
+	// ...and the rest from rows of a CSV file.
+	report, err := testTools.ReadCSVStream(strings.NewReader("name,age\nalice,old\nbob\n"), func(row int, record map[string]string) error {
+		if _, err := strconv.Atoi(record["age"]); err != nil {
+			return FieldErrors{{Field: "age", Code: "invalid", Message: "must be a number"}}
+		}
+		return nil
+	}, WithCSVContinueOnError())
+	if err != nil {
+		t.Fatal(err)
+	}
+	fieldErrs.Merge(report.FieldErrors())
+
+	rr := httptest.NewRecorder()
+	if err := testTools.ErrorJSON(rr, fmt.Errorf("import failed: %w", fieldErrs), http.StatusUnprocessableEntity); err != nil {
+		t.Fatal(err)
+	}
+
+	var body struct {
+		Error  bool             `json:"error"`
+		Errors []map[string]any `json:"errors"`
+	}
+	raw := rr.Body.Bytes()
+	if err := json.Unmarshal(raw, &body); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		field string
+		index float64
+		code  string
+	}{
+		{"title", 0, "required"},
+		{"views", 0, "invalid"},
+		{"age", 1, "invalid"},
+		{"", 2, "ragged_row"},
+	}
+	if rr.Code != http.StatusUnprocessableEntity || !body.Error || len(body.Errors) != len(expected) {
+		t.Fatalf("wrong response: %d %s", rr.Code, raw)
+	}
+	for i, e := range expected {
+		got := body.Errors[i]
+		field, _ := got["field"].(string)
+		index, _ := got["index"].(float64)
+		if field != e.field || index != e.index || got["code"] != e.code || got["message"] == "" {
+			t.Errorf("error %d: expected %v, but got %v", i, e, got)
+		}
+	}
+
+	// Errors without FieldErrors are sent as before.
+	rr = httptest.NewRecorder()
+	_ = testTools.ErrorJSON(rr, errors.New("plain"))
+	if bytes.Contains(rr.Body.Bytes(), []byte(`"errors"`)) {
+		t.Errorf("expected no errors member, but got %s", rr.Body)
+	}
+
+	// The list is suppressed along with the message of an internal error.
+	testTools.SuppressInternalErrors = true
+	rr = httptest.NewRecorder()
+	_ = testTools.ErrorJSON(rr, fieldErrs, http.StatusInternalServerError)
+	if bytes.Contains(rr.Body.Bytes(), []byte(`"errors"`)) {
+		t.Errorf("expected the errors to be suppressed, but got %s", rr.Body)
+	}
+}
+
+func TestTools_UploadFilesFieldErrors(t *testing.T) {
+	req, err := NewMultipartRequestFromReaders("/", []MultipartFile{
+		{FieldName: "photo", FileName: "notes.txt", Content: strings.NewReader("hello, world")},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testTools := Tools{AllowedFileTypes: []string{"image/png"}}
+	_, _, err = testTools.UploadFilesPartial(req, t.TempDir(), UploadOptions{ContinueOnError: true})
+
+	var fieldErrs FieldErrors
+	if !errors.As(err, &fieldErrs) || len(fieldErrs) != 1 {
+		t.Fatalf("expected FieldErrors, but got %v", err)
+	}
+	if fieldErrs[0].Field != "photo" || fieldErrs[0].Code != string(UploadTypeNotAllowed) {
+		t.Errorf("wrong field error: %+v", fieldErrs[0])
+	}
+
+	var uploadErr UploadError
+	if !errors.As(err, &uploadErr) || uploadErr.FileName != "notes.txt" || !errors.Is(err, ErrUploadTypeNotAllowed) {
+		t.Errorf("expected the UploadError to be reachable, but got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package toolbox
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// defaultFileNameLength is the length of the random part of names made by RandomFileName.
+const defaultFileNameLength = 25
+
+// maxExtensionLength is the longest part of a file extension (between dots) that RandomFileName keeps.
+const maxExtensionLength = 16
+
+// FileNameStrategy chooses how RandomFileName (and so UploadFiles) names files.
+type FileNameStrategy string
+
+const (
+	// FileNameRandom names files with a random string of FileNameLength characters. This is the default.
+	FileNameRandom FileNameStrategy = "random"
+	// FileNameUUID names files with a random (version 4) UUID.
+	FileNameUUID FileNameStrategy = "uuid"
+	// FileNameTimestamp names files with the current UTC time, followed by a random string of
+	// FileNameLength characters, so that they sort by the time they were created.
+	FileNameTimestamp FileNameStrategy = "timestamp"
+)
+
+// RandomFileName returns a new, random name for a file called originalName, using the strategy in
+// FileNameStrategy. The extension of originalName is kept, lowercased and stripped of anything other
+// than letters and digits, so photo.JPG becomes something like 4fTq...x2.jpg. Compound extensions
+// for tar archives, such as .tar.gz, are kept whole. This is how UploadFiles names files, unless told
+// otherwise.
+func (t *Tools) RandomFileName(originalName string) string {
+	length := t.FileNameLength
+	if length <= 0 {
+		length = defaultFileNameLength
+	}
+
+	var name string
+	switch t.FileNameStrategy {
+	case FileNameUUID:
+		name = newUUID()
+	case FileNameTimestamp:
+		name = time.Now().UTC().Format("20060102-150405") + "-" + t.RandomString(length)
+	default:
+		name = t.RandomString(length)
+	}
+
+	return name + fileExtension(originalName)
+}
+
+// baseFileName returns the last element of name, or fallback if that isn't a usable file name. Only
+// what would name a directory, rather than a file, in it, such as ., .. or an empty name, is replaced.
+func baseFileName(name, fallback string) string {
+	base := filepath.Base(name)
+	switch base {
+	case ".", "..", string(filepath.Separator):
+		return fallback
+	}
+	return base
+}
+
+// fileExtension returns the sanitized, lowercased extension of name, including the dot, or an empty
+// string if it doesn't have one.
+func fileExtension(name string) string {
+	ext := sanitizeExtension(filepath.Ext(name))
+	if ext == "" {
+		return ""
+	}
+
+	// Keep archive.tar.gz as .tar.gz, rather than just .gz.
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	if strings.EqualFold(filepath.Ext(stem), ".tar") {
+		ext = ".tar" + ext
+	}
+
+	return ext
+}
+
+// sanitizeExtension lowercases ext, and removes everything but letters and digits after the dot.
+func sanitizeExtension(ext string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimPrefix(ext, ".")) {
+		if ('a' <= r && r <= 'z') || ('0' <= r && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+
+	if b.Len() == 0 || b.Len() > maxExtensionLength {
+		return ""
+	}
+	return "." + b.String()
+}
+
+// newUUID returns a random, version 4 UUID, as described in RFC 4122.
+func newUUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// defaultMaxFileNameLength is the longest name, in bytes, UploadFiles saves a file with, unless
+// MaxFileNameLength is set. It is the limit of most file systems.
+const defaultMaxFileNameLength = 255
+
+// fitFileName returns name, shortened if need be to at most max bytes by truncating the part before
+// the extension, without splitting a multi-byte character. Since two long names can be the same once
+// truncated, a name that had to be truncated is also made unique in dir, by adding -1, -2, and so on.
+func fitFileName(dir, name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+
+	ext := filepath.Ext(name)
+	if len(ext) >= max {
+		// The extension alone is too long, so there's nothing to keep it for.
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+
+	candidate := truncateUTF8(base, max-len(ext)) + ext
+	for i := 1; fileExists(filepath.Join(dir, candidate)); i++ {
+		suffix := fmt.Sprintf("-%d", i)
+		candidate = truncateUTF8(base, max-len(ext)-len(suffix)) + suffix + ext
+	}
+
+	return candidate
+}
+
+// truncateUTF8 returns the longest prefix of s that is at most n bytes, and doesn't end part way
+// through a UTF-8 encoded character.
+func truncateUTF8(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
+
+// fileExists reports whether anything exists at path.
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
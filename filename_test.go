@@ -0,0 +1,120 @@
+package toolbox
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var fileExtensionTests = []struct {
+	name     string
+	expected string
+}{
+	{name: "photo.JPG", expected: ".jpg"},
+	{name: "archive.tar.gz", expected: ".tar.gz"},
+	{name: "Archive.TAR.BZ2", expected: ".tar.bz2"},
+	{name: "report.final.pdf", expected: ".pdf"},
+	{name: "no-extension", expected: ""},
+	{name: "weird.p<h>p", expected: ".php"},
+	{name: "trailing.", expected: ""},
+	{name: "../../etc/passwd", expected: ""},
+}
+
+func TestFileExtension(t *testing.T) {
+	for _, e := range fileExtensionTests {
+		if got := fileExtension(e.name); got != e.expected {
+			t.Errorf("%s: expected %q but got %q", e.name, e.expected, got)
+		}
+	}
+}
+
+var fitFileNameTests = []struct {
+	name     string
+	fileName string
+	max      int
+	existing []string
+	expected string
+}{
+	{name: "short enough", fileName: "photo.jpg", max: 255, expected: "photo.jpg"},
+	{name: "truncated", fileName: "abcdefghij.txt", max: 9, expected: "abcde.txt"},
+	{name: "multibyte", fileName: "日本語.txt", max: 11, expected: "日本.txt"},
+	{name: "multibyte boundary", fileName: "日本語.txt", max: 12, expected: "日本.txt"},
+	{name: "long extension", fileName: "a.abcdefghij", max: 5, expected: "a.abc"},
+	{name: "collision", fileName: "abcdefghij.txt", max: 9, existing: []string{"abcde.txt"}, expected: "abc-1.txt"},
+	{name: "collisions", fileName: "abcdefghij.txt", max: 9, existing: []string{"abcde.txt", "abc-1.txt"}, expected: "abc-2.txt"},
+	{name: "no collision check if not truncated", fileName: "abc.txt", max: 9, existing: []string{"abc.txt"}, expected: "abc.txt"},
+}
+
+func TestFitFileName(t *testing.T) {
+	for _, e := range fitFileNameTests {
+		dir := t.TempDir()
+		for _, name := range e.existing {
+			if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got := fitFileName(dir, e.fileName, e.max); got != e.expected {
+			t.Errorf("%s: expected %q, but got %q", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestTools_RandomFileName(t *testing.T) {
+	var testTools Tools
+
+	name := testTools.RandomFileName("photo.JPG")
+	if len(name) != 29 || !strings.HasSuffix(name, ".jpg") {
+		t.Errorf("expected 25 random characters and .jpg, but got %s", name)
+	}
+
+	testTools.FileNameLength = 10
+	if name := testTools.RandomFileName("archive.tar.gz"); len(name) != 17 || !strings.HasSuffix(name, ".tar.gz") {
+		t.Errorf("expected 10 random characters and .tar.gz, but got %s", name)
+	}
+
+	testTools.FileNameStrategy = FileNameTimestamp
+	if name := testTools.RandomFileName("a.png"); !regexp.MustCompile(`^\d{8}-\d{6}-.{10}\.png$`).MatchString(name) {
+		t.Errorf("expected a timestamped name, but got %s", name)
+	}
+}
+
+func TestTools_RandomFileNameUUID(t *testing.T) {
+	testTools := Tools{FileNameStrategy: FileNameUUID}
+
+	name := testTools.RandomFileName("photo.JPG")
+	id, ext, _ := strings.Cut(name, ".")
+	if ext != "jpg" {
+		t.Errorf("wrong extension in %s", name)
+	}
+
+	parts := strings.Split(id, "-")
+	if len(parts) != 5 || len(parts[0]) != 8 || len(parts[1]) != 4 || len(parts[2]) != 4 || len(parts[3]) != 4 || len(parts[4]) != 12 {
+		t.Fatalf("not a UUID: %s", id)
+	}
+
+	b, err := hex.DecodeString(strings.Join(parts, ""))
+	if err != nil {
+		t.Fatalf("not a UUID: %s", id)
+	}
+	if b[6]>>4 != 4 || b[8]>>6 != 2 {
+		t.Errorf("expected a version 4 UUID, but got %s", id)
+	}
+}
+
+func TestTools_UploadFilesUsesRandomFileName(t *testing.T) {
+	testTools := Tools{FileNameStrategy: FileNameUUID}
+
+	request, _ := NewMultipartRequest("/", map[string]string{"file": "./testdata/img.png"}, nil)
+	files, err := testTools.UploadFiles(request, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files[0].NewFileName) != 40 || !strings.HasSuffix(files[0].NewFileName, ".png") {
+		t.Errorf("expected a UUID file name, but got %s", files[0].NewFileName)
+	}
+}
@@ -0,0 +1,248 @@
+package toolbox
+
+import (
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// uploadedFileType is the reflect.Type of *UploadedFile, which marks a field that receives an upload.
+var uploadedFileType = reflect.TypeOf((*UploadedFile)(nil))
+
+// FormFieldError is a problem with a single field decoded by ReadMultipartForm. It is a FieldError,
+// and is kept so that code written before FieldErrors still builds.
+type FormFieldError = FieldError
+
+// FormError is returned by ReadMultipartForm, ReadForm and ReadBody, listing every form field that
+// could not be decoded, in the order of the struct's fields.
+type FormError struct {
+	Fields FieldErrors
+}
+
+// Error satisfies the error interface.
+func (e *FormError) Error() string {
+	return e.Fields.Error()
+}
+
+// Unwrap returns Fields, so that errors.As can get the FieldErrors, and errors.Is and errors.As can
+// look inside the error for each field.
+func (e *FormError) Unwrap() error {
+	return e.Fields
+}
+
+// ReadMultipartForm decodes a multipart form into the struct pointed to by dst. Each field is read
+// from the form field named in its form tag, or from the field name if there is no tag; fields tagged
+// form:"-" are skipped. Fields of type *UploadedFile or []*UploadedFile receive the files sent in that
+// form field, which are checked and saved to uploadDir exactly as UploadFiles would save them, with a
+// random name. For example:
+//
+//	type Post struct {
+//		Title       string                  `form:"title,required"`
+//		Views       int                     `form:"views"`
+//		Cover       *toolbox.UploadedFile   `form:"cover,required"`
+//		Attachments []*toolbox.UploadedFile `form:"attachments"`
+//	}
+//
+// Text fields may be any type LoadEnvConfig supports; a []string field gets every value sent for it.
+// Fields of type time.Time or *time.Time are parsed with ParseTimeFlexible, or with the layout in a
+// layout tag, such as layout:"2006-01-02", if there is one.
+// Fields with the required option must be present in the form. Instead of stopping at the first
+// problem, every field is decoded, and the returned *FormError lists each one that failed; in that
+// case, any files that were saved are removed again.
+func (t *Tools) ReadMultipartForm(r *http.Request, dst any, uploadDir string) error {
+	rv, err := formDestination("ReadMultipartForm", dst)
+	if err != nil {
+		return err
+	}
+
+	opts := t.uploadOptions(r.Context(), UploadOptions{})
+
+	uploadDir, err = t.uploadPath(uploadDir)
+	if err != nil {
+		return err
+	}
+
+	err = t.CreateDirIfNotExist(uploadDir)
+	if err != nil {
+		return err
+	}
+
+	err = r.ParseMultipartForm(int64(opts.MaxFileSize))
+	if err != nil {
+		return formError(err)
+	}
+
+	d := formDecoder{t: t, r: r, form: r.MultipartForm, uploadDir: uploadDir, opts: opts}
+	d.decodeStruct(rv.Elem())
+
+	if len(d.errs) > 0 {
+		for _, f := range d.saved {
+			_ = os.Remove(filepath.Join(uploadDir, f.NewFileName))
+		}
+		return &FormError{Fields: d.errs}
+	}
+
+	return nil
+}
+
+// formDecoder holds the state of a single call to ReadMultipartForm.
+type formDecoder struct {
+	t         *Tools
+	r         *http.Request
+	form      *multipart.Form
+	uploadDir string
+	opts      UploadOptions
+	saved     []*UploadedFile
+	errs      FieldErrors
+}
+
+// decodeStruct sets the fields of the struct rv.
+func (d *formDecoder) decodeStruct(rv reflect.Value) {
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("form")
+		if tag == "-" {
+			continue
+		}
+
+		fv := rv.Field(i)
+
+		if field.Anonymous && tag == "" && fv.Kind() == reflect.Struct {
+			d.decodeStruct(fv)
+			continue
+		}
+
+		name, tagOpts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		required := hasTagOption(tagOpts, "required")
+
+		switch {
+		case fv.Type() == uploadedFileType || (fv.Kind() == reflect.Slice && fv.Type().Elem() == uploadedFileType):
+			d.decodeFiles(fv, name, required)
+		default:
+			d.decodeValue(fv, name, required, field.Tag.Get("layout"))
+		}
+	}
+}
+
+// decodeValue sets fv from the text values sent in the form field name. Times are parsed with layout,
+// if it isn't empty, or else with ParseTimeFlexible.
+func (d *formDecoder) decodeValue(fv reflect.Value, name string, required bool, layout string) {
+	values := d.form.Value[name]
+	if len(values) == 0 {
+		if required {
+			d.fail(name, "required", errors.New("is required"))
+		}
+		return
+	}
+
+	if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.String {
+		fv.Set(reflect.ValueOf(append([]string(nil), values...)).Convert(fv.Type()))
+		return
+	}
+
+	if fv.Type() == timeType || fv.Type() == reflect.PointerTo(timeType) {
+		tm, err := d.parseTime(values[0], layout)
+		if err != nil {
+			d.fail(name, "invalid", err)
+			return
+		}
+		if fv.Kind() == reflect.Pointer {
+			fv.Set(reflect.ValueOf(&tm))
+		} else {
+			fv.Set(reflect.ValueOf(tm))
+		}
+		return
+	}
+
+	err := setFieldFromString(fv, values[0])
+	if err != nil {
+		d.fail(name, "invalid", err)
+	}
+}
+
+// parseTime parses s with layout, or with ParseTimeFlexible if layout is empty.
+func (d *formDecoder) parseTime(s, layout string) (time.Time, error) {
+	if layout == "" {
+		return d.t.ParseTimeFlexible(s)
+	}
+
+	tm, err := time.Parse(layout, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected the layout %s", s, layout)
+	}
+	return tm, nil
+}
+
+// decodeFiles saves the files sent in the form field name, and stores them in fv.
+func (d *formDecoder) decodeFiles(fv reflect.Value, name string, required bool) {
+	headers := d.form.File[name]
+	if len(headers) == 0 {
+		if required {
+			d.fail(name, "required", errors.New("a file is required"))
+		}
+		return
+	}
+
+	if fv.Kind() != reflect.Slice && len(headers) > 1 {
+		d.fail(name, "too_many_files", errors.New("only one file is allowed"))
+		return
+	}
+
+	var files []*UploadedFile
+	for _, hdr := range headers {
+		file, err := d.saveFile(hdr)
+		if err != nil {
+			d.fail(name, string(uploadErrorReason(err)), err)
+			return
+		}
+		file.FieldName = name
+		files = append(files, file)
+	}
+
+	if fv.Kind() == reflect.Slice {
+		fv.Set(reflect.ValueOf(files))
+	} else {
+		fv.Set(reflect.ValueOf(files[0]))
+	}
+}
+
+// saveFile checks and saves a single uploaded file.
+func (d *formDecoder) saveFile(hdr *multipart.FileHeader) (*UploadedFile, error) {
+	if hdr.Size > int64(d.opts.MaxFileSize) {
+		return nil, &FileTooBigError{Limit: int64(d.opts.MaxFileSize), Size: hdr.Size}
+	}
+
+	infile, err := hdr.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer infile.Close()
+
+	file, err := d.t.saveFile(d.r.Context(), infile, hdr.Filename, d.uploadDir, d.opts)
+	if err != nil {
+		return nil, err
+	}
+
+	d.saved = append(d.saved, file)
+	return file, nil
+}
+
+// fail records a problem with the form field name, with a code for FieldError.
+func (d *formDecoder) fail(name, code string, err error) {
+	d.errs = append(d.errs, FieldError{Field: name, Code: code, Err: err})
+}
@@ -0,0 +1,98 @@
+package toolbox
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+type formPost struct {
+	Title       string          `form:"title,required"`
+	Views       int             `form:"views"`
+	Cover       *UploadedFile   `form:"cover,required"`
+	Attachments []*UploadedFile `form:"attachments"`
+	Ignored     string          `form:"-"`
+}
+
+func TestTools_ReadMultipartForm(t *testing.T) {
+	uploadDir := t.TempDir()
+	png, _ := os.ReadFile("./testdata/img.png")
+	jpg, _ := os.ReadFile("./testdata/tgg.jpg")
+
+	req, err := NewMultipartRequestFromReaders("/", []MultipartFile{
+		{FieldName: "cover", FileName: "cover.png", Content: bytes.NewReader(png)},
+		{FieldName: "attachments", FileName: "one.jpg", Content: bytes.NewReader(jpg)},
+		{FieldName: "attachments", FileName: "two.png", Content: bytes.NewReader(png)},
+	}, map[string]string{"title": "Hello", "views": "42", "Ignored": "no"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testTools := Tools{AllowedFileTypes: []string{"image/png", "image/jpeg"}}
+
+	var post formPost
+	err = testTools.ReadMultipartForm(req, &post, uploadDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if post.Title != "Hello" || post.Views != 42 || post.Ignored != "" {
+		t.Errorf("wrong text fields: %+v", post)
+	}
+	if post.Cover == nil || post.Cover.OriginalFileName != "cover.png" || post.Cover.ContentType != "image/png" {
+		t.Errorf("wrong cover: %+v", post.Cover)
+	}
+	if len(post.Attachments) != 2 || post.Attachments[0].OriginalFileName != "one.jpg" || post.Attachments[1].OriginalFileName != "two.png" {
+		t.Errorf("wrong attachments: %+v", post.Attachments)
+	}
+
+	entries, _ := os.ReadDir(uploadDir)
+	if len(entries) != 3 {
+		t.Errorf("expected 3 saved files, but found %d", len(entries))
+	}
+}
+
+func TestTools_ReadMultipartFormErrors(t *testing.T) {
+	uploadDir := t.TempDir()
+	png, _ := os.ReadFile("./testdata/img.png")
+	jpg, _ := os.ReadFile("./testdata/tgg.jpg")
+
+	// The attachments are fine, but the cover is the wrong type, and views isn't a number.
+	req, err := NewMultipartRequestFromReaders("/", []MultipartFile{
+		{FieldName: "attachments", FileName: "one.png", Content: bytes.NewReader(png)},
+		{FieldName: "cover", FileName: "cover.jpg", Content: bytes.NewReader(jpg)},
+	}, map[string]string{"views": "lots"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testTools := Tools{AllowedFileTypes: []string{"image/png"}}
+
+	var post formPost
+	err = testTools.ReadMultipartForm(req, &post, uploadDir)
+
+	var formErr *FormError
+	if !errors.As(err, &formErr) {
+		t.Fatalf("expected a *FormError, but got %v", err)
+	}
+
+	fields := map[string]string{}
+	for _, f := range formErr.Fields {
+		fields[f.Field] = f.Err.Error()
+	}
+	if len(fields) != 3 || fields["title"] != "is required" || !strings.Contains(fields["views"], "invalid integer") ||
+		!strings.Contains(fields["cover"], "not permitted") {
+		t.Errorf("wrong field errors: %v", err)
+	}
+
+	entries, _ := os.ReadDir(uploadDir)
+	if len(entries) != 0 {
+		t.Errorf("expected saved files to be removed, but found %d", len(entries))
+	}
+
+	if err := testTools.ReadMultipartForm(req, post, uploadDir); err == nil {
+		t.Error("expected an error for a non-pointer destination")
+	}
+}
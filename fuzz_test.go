@@ -0,0 +1,399 @@
+package toolbox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// The fuzz targets below run their seeds, and anything in testdata/fuzz, as part of go test. To
+// search for new failures, run one of them on its own, for as long as you like:
+//
+//	go test -run '^$' -fuzz '^FuzzReadJSON$' -fuzztime 30s .
+//
+// Inputs that fail are saved in testdata/fuzz, and should be checked in once they are fixed.
+
+// fuzzJSONTarget has a bit of everything the optional JSON checks look at.
+type fuzzJSONTarget struct {
+	fuzzJSONEmbedded
+	Foo    string            `json:"foo,required"`
+	Count  int               `json:"count"`
+	Nick   *string           `json:"nick"`
+	Items  []fuzzJSONItem    `json:"items"`
+	ByName map[string]string `json:"by_name"`
+	Any    any               `json:"any"`
+	Raw    json.RawMessage   `json:"raw"`
+}
+
+type fuzzJSONEmbedded struct {
+	ID string `json:"id"`
+}
+
+type fuzzJSONItem struct {
+	SKU string `json:"sku,required"`
+	Qty int    `json:"qty"`
+}
+
+func FuzzReadJSON(f *testing.F) {
+	for _, e := range jsonTests {
+		f.Add([]byte(e.json))
+	}
+	for _, e := range maxJSONDepthTests {
+		f.Add([]byte(e.body))
+	}
+	for _, e := range duplicateJSONKeyTests {
+		f.Add([]byte(e.body))
+	}
+	f.Add([]byte(`{"foo":"a","id":"b","items":[{"sku":"c"},{"qty":null}],"by_name":{"x":"y"},"any":[1,{"a":null}],"raw":{}}`))
+	f.Add([]byte(`{"Foo":null,"ITEMS":[null],"nick":null,"extra":"\ud800"}`))
+
+	strict := Tools{
+		RejectDuplicateJSONKeys: true,
+		RequireJSONFields:       true,
+		ReportAllUnknownFields:  true,
+		DisallowNullFields:      true,
+		MaxJSONDepth:            32,
+		MaxJSONSize:             1 << 16,
+	}
+	var plain Tools
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		// With every check on, anything may be rejected, but nothing may panic.
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		var target fuzzJSONTarget
+		_ = strict.ReadJSON(httptest.NewRecorder(), req, &target)
+
+		// Any single, valid, JSON value can be read into an any.
+		req = httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		var value any
+		err := plain.ReadJSON(httptest.NewRecorder(), req, &value)
+		if json.Valid(body) && err != nil {
+			t.Errorf("valid JSON %q was rejected: %v", body, err)
+		}
+		if err != nil {
+			var readErr *ReadError
+			var tooLarge *BodyTooLargeError
+			if !errors.As(err, &readErr) && !errors.As(err, &tooLarge) {
+				t.Errorf("unexpected type of error for %q: %T %v", body, err, err)
+			}
+		}
+	})
+}
+
+// fuzzXMLTarget is a small document, with attributes, nested elements and a list.
+type fuzzXMLTarget struct {
+	To    string   `xml:"to"`
+	From  string   `xml:"from"`
+	Lang  string   `xml:"lang,attr"`
+	Tags  []string `xml:"tags>tag"`
+	Inner struct {
+		Value int `xml:",chardata"`
+	} `xml:"inner"`
+}
+
+func FuzzReadXML(f *testing.F) {
+	for _, e := range xmlTests {
+		f.Add([]byte(e.xml))
+	}
+	f.Add([]byte("\ufeff  <note lang=\"en\"><tags><tag>a</tag><tag>b</tag></tags><inner>7</inner></note>"))
+	f.Add([]byte(`<!DOCTYPE note [<!ENTITY a "aaaa">]><note><to>&a;&a;</to></note>`))
+
+	testTools := Tools{MaxXMLSize: 1 << 16}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		var target fuzzXMLTarget
+		_ = testTools.ReadXML(httptest.NewRecorder(), req, &target)
+	})
+}
+
+// slugPattern is what every slug Slugify returns looks like, with the default settings.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+func FuzzSlugify(f *testing.F) {
+	for _, e := range slugTests {
+		f.Add(e.s)
+	}
+	for _, e := range slugTransliterationTests {
+		f.Add(e.s)
+	}
+	for _, e := range slugLeadingDigitTests {
+		f.Add(e.s)
+	}
+
+	var testTools Tools
+	noDigit := Tools{SlugNoLeadingDigit: true, SlugRequireLetter: true}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		slug, err := testTools.Slugify(s)
+		if err != nil {
+			if slug != "" {
+				t.Errorf("expected no slug with an error for %q, but got %q", s, slug)
+			}
+			return
+		}
+
+		if !slugPattern.MatchString(slug) {
+			t.Errorf("Slugify(%q) = %q, which isn't a slug", s, slug)
+		}
+		if again, err := testTools.Slugify(slug); err != nil || again != slug {
+			t.Errorf("expected slug %q to be unchanged, but got %q, %v", slug, again, err)
+		}
+
+		slug, err = noDigit.Slugify(s)
+		if err == nil && (!slugPattern.MatchString(slug) || slug[0] >= '0' && slug[0] <= '9') {
+			t.Errorf("Slugify(%q) with SlugNoLeadingDigit = %q", s, slug)
+		}
+	})
+}
+
+// FuzzTrimSQLSemicolon fuzzes the package's only SQL parser, the one that decides whether a trailing
+// semicolon is part of the statement.
+func FuzzTrimSQLSemicolon(f *testing.F) {
+	for _, e := range trimSQLSemicolonTests {
+		f.Add(e.query)
+	}
+	f.Add("-- ")
+	f.Add("select 1 -- ;\n;")
+	f.Add("select '';'';")
+
+	f.Fuzz(func(t *testing.T, query string) {
+		trimmed := TrimSQLSemicolon(query)
+
+		// At most a semicolon, and the whitespace around it, is removed from the end.
+		ws := " \t\r\n"
+		end := strings.TrimRight(query, ws)
+		if trimmed != end && trimmed != strings.TrimRight(strings.TrimSuffix(end, ";"), ws) {
+			t.Errorf("TrimSQLSemicolon(%q) = %q", query, trimmed)
+		}
+		if trimmed != end && !sqlEndsOutsideQuotes(end[:len(end)-1]) {
+			t.Errorf("TrimSQLSemicolon(%q) removed a quoted semicolon", query)
+		}
+	})
+}
+
+// addMultipartSeed adds the Content-Type and body of a multipart request holding files and fields to
+// the seeds of f.
+func addMultipartSeed(f *testing.F, files []MultipartFile, fields map[string]string) {
+	f.Helper()
+
+	req, err := NewMultipartRequestFromReaders("/", files, fields)
+	if err != nil {
+		f.Fatal(err)
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(req.Header.Get("Content-Type"), body)
+}
+
+// FuzzUploadFiles sends arbitrary multipart bodies through the streaming upload code, which sniffs
+// and saves each part as it arrives, both with random names and with the names sent.
+func FuzzUploadFiles(f *testing.F) {
+	png, err := os.ReadFile("./testdata/img.png")
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	addMultipartSeed(f, []MultipartFile{{FieldName: "file", FileName: "img.png", Content: bytes.NewReader(png)}}, nil)
+	addMultipartSeed(f, []MultipartFile{
+		{FieldName: "a", FileName: "one.txt", Content: strings.NewReader("hello")},
+		{FieldName: "b", FileName: "../two.txt", Content: strings.NewReader("")},
+	}, map[string]string{"title": "Hello"})
+	addMultipartSeed(f, []MultipartFile{{FieldName: "file", FileName: "big.txt", Content: strings.NewReader(strings.Repeat("x", 2048))}}, nil)
+	f.Add(`multipart/form-data; boundary=x`, []byte("--x\r\nContent-Disposition: form-data; name=\"f\"; filename=\"a.txt\"\r\n\r\nhi\r\n--x--"))
+	f.Add(`multipart/form-data; boundary="a b"`, []byte("--a b\r\n\r\n--a b--\r\n"))
+	f.Add(`multipart/mixed`, []byte("--x\r\n"))
+
+	testTools := Tools{AllowedFileTypes: []string{"image/png", "text/plain"}, MaxFileSize: 1024}
+
+	f.Fuzz(func(t *testing.T, contentType string, body []byte) {
+		for _, keepName := range []bool{false, true} {
+			opts := UploadOptions{MaxFiles: 5, KeepOriginalName: keepName}
+			dir := t.TempDir()
+
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+
+			files, err := testTools.UploadFilesWithOptions(req, dir, opts)
+
+			entries, readErr := os.ReadDir(dir)
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+			if err != nil {
+				// A failed upload leaves nothing behind.
+				if len(entries) > 0 {
+					t.Errorf("%d files left in the upload directory after %v", len(entries), err)
+				}
+				continue
+			}
+
+			if len(files) > opts.MaxFiles {
+				t.Errorf("expected at most %d files, but got %d", opts.MaxFiles, len(files))
+			}
+			for _, file := range files {
+				if filepath.Dir(file.SavedPath) != dir {
+					t.Errorf("file saved outside the upload directory: %s", file.SavedPath)
+				}
+				if file.FileSize > int64(testTools.MaxFileSize) {
+					t.Errorf("file of %d bytes saved, with a limit of %d", file.FileSize, testTools.MaxFileSize)
+				}
+				info, err := os.Stat(file.SavedPath)
+				if err != nil || info.Size() != file.FileSize {
+					t.Errorf("saved file %s doesn't match its size of %d: %v", file.NewFileName, file.FileSize, err)
+				}
+			}
+		}
+	})
+}
+
+func FuzzReadCSVStream(f *testing.F) {
+	for _, e := range csvStreamTests {
+		f.Add(e.content)
+	}
+	f.Add("\ufeff a , b \n1,2\n")
+	f.Add("a,a\n1,2\n")
+	f.Add("a,\n1,2\n")
+	f.Add("a,b\n\"1\n2\",3\n\"\n")
+
+	var testTools Tools
+
+	f.Fuzz(func(t *testing.T, content string) {
+		accepted, skipped, lastRow := 0, 0, 0
+		fn := func(rowNumber int, record map[string]string) error {
+			if rowNumber <= lastRow {
+				t.Errorf("row %d passed after row %d", rowNumber, lastRow)
+			}
+			lastRow = rowNumber
+
+			switch rowNumber % 3 {
+			case 0:
+				skipped++
+				return SkipRow
+			case 1:
+				accepted++
+				return nil
+			default:
+				return errors.New("rejected")
+			}
+		}
+
+		report, _ := testTools.ReadCSVStream(strings.NewReader(content), fn, WithCSVContinueOnError(), WithCSVMaxErrors(3))
+
+		if report.RowsProcessed != accepted || report.RowsSkipped != skipped {
+			t.Errorf("report %+v doesn't match %d accepted and %d skipped rows", report, accepted, skipped)
+		}
+		if len(report.Errors) > 3 || report.ErrorsTruncated != (report.RowsFailed > len(report.Errors)) {
+			t.Errorf("report has %d of %d errors, truncated %v", len(report.Errors), report.RowsFailed, report.ErrorsTruncated)
+		}
+		_ = report.FieldErrors()
+	})
+}
+
+func FuzzDataURI(f *testing.F) {
+	for _, e := range decodeDataURITests {
+		f.Add(e.uri)
+	}
+	f.Add("data:text/plain;name=../../a.txt;base64,aGVsbG8=")
+	f.Add("data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\n")))
+	f.Add("data:;base64,%ZZ")
+	f.Add("data:text/plain;charset=\"utf-8\",%e2%82%ac")
+
+	testTools := Tools{AllowedFileTypes: []string{"image/png", "text/plain"}, MaxFileSize: 1024}
+
+	f.Fuzz(func(t *testing.T, uri string) {
+		contentType, data, err := testTools.DecodeDataURI(uri)
+		if err == nil && contentType == "" {
+			t.Errorf("DecodeDataURI(%q) returned no content type", uri)
+		}
+
+		dir := t.TempDir()
+		file, saveErr := testTools.SaveDataURI(uri, dir, false)
+		if saveErr != nil {
+			return
+		}
+
+		if err != nil {
+			t.Fatalf("SaveDataURI saved %q, which DecodeDataURI rejects: %v", uri, err)
+		}
+		if filepath.Dir(file.SavedPath) != dir {
+			t.Errorf("file saved outside the upload directory: %s", file.SavedPath)
+		}
+		saved, err := os.ReadFile(file.SavedPath)
+		if err != nil || !bytes.Equal(saved, data) {
+			t.Errorf("saved file doesn't hold the decoded data of %q: %v", uri, err)
+		}
+	})
+}
+
+func FuzzReadXMLMap(f *testing.F) {
+	for _, e := range readXMLMapTests {
+		f.Add([]byte(e.xml))
+	}
+	f.Add([]byte(`<a x="1" x="2"><b/><b>t</b><c:d xmlns:c="u">v</c:d></a>`))
+	f.Add([]byte(`<a><![CDATA[x]]><!-- c --><?p i?></a><b/>`))
+
+	testTools := Tools{MaxXMLSize: 1 << 16}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		m, err := testTools.ReadXMLMap(httptest.NewRecorder(), req)
+		if err == nil && len(m) != 1 {
+			t.Errorf("expected a single root element from %q, but got %v", body, m)
+		}
+	})
+}
+
+func FuzzApplyJSONPatch(f *testing.F) {
+	for _, e := range jsonPatchTests {
+		f.Add([]byte(e.original), []byte(e.patch))
+	}
+	f.Add([]byte(`{"a":[1,2]}`), []byte(`[{"op":"move","from":"/a/0","path":"/a/-"}]`))
+	f.Add([]byte(`{"a~b":{"c/d":1}}`), []byte(`[{"op":"copy","from":"/a~0b/c~1d","path":"/x"},{"op":"test","path":"/x","value":1}]`))
+	f.Add([]byte(`[]`), []byte(`[{"op":"add","path":"","value":{"a":1}}]`))
+
+	f.Fuzz(func(t *testing.T, original, patch []byte) {
+		patched, err := ApplyJSONPatch(original, patch)
+		if err == nil && !json.Valid(patched) {
+			t.Errorf("patching %q with %q gave invalid JSON %q", original, patch, patched)
+		}
+	})
+}
+
+func FuzzReadNDJSON(f *testing.F) {
+	for _, e := range readNDJSONTests {
+		f.Add([]byte(e.body))
+	}
+	f.Add([]byte("{\"id\":1,\"kind\":\"a\"} {\"id\":2,\"kind\":\"b\"}\n"))
+	f.Add([]byte("\ufeff{\"id\":1,\"kind\":\"a\"}\r\n[1]\n"))
+
+	testTools := Tools{MaxJSONSize: 1 << 16}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		calls, lastLine := 0, 0
+		n, _ := ReadNDJSON(&testTools, httptest.NewRecorder(), req, func(line int, record ndjsonEvent) error {
+			if line <= lastLine {
+				t.Errorf("line %d passed after line %d", line, lastLine)
+			}
+			lastLine = line
+			calls++
+			return nil
+		})
+
+		if n != calls {
+			t.Errorf("ReadNDJSON returned %d records, but passed %d", n, calls)
+		}
+	})
+}
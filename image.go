@@ -0,0 +1,303 @@
+package toolbox
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxImagePixels is the largest image (width times height) we'll decode when normalizing uploads,
+// so that a small file claiming enormous dimensions can't exhaust memory.
+const maxImagePixels = 50_000_000
+
+// ImageNormalization describes how uploaded images are decoded and re-encoded, which throws away
+// anything in the file that isn't the image itself, and gives stored images a consistent format.
+type ImageNormalization struct {
+	Format           string // png or jpeg; if empty, JPEGs stay JPEGs, and everything else becomes PNG
+	JPEGQuality      int    // quality for JPEG output, from 1 to 100 (defaults to 75)
+	MaxImageWidth    int    // if set, wider images are scaled down to fit, keeping their aspect ratio
+	MaxImageHeight   int    // if set, taller images are scaled down to fit, keeping their aspect ratio
+	AllowAnimatedGIF bool   // if set to true, animated GIFs are saved untouched, instead of being rejected
+}
+
+// imageDimensions returns the width and height of the png, jpeg or gif image saved at path, whose
+// first bytes (as read for sniffing) are head. The header of most images fits in head, so the file
+// is only read if it doesn't, as with JPEGs with a lot of metadata. Anything else, and images that
+// can't be decoded, give zeros.
+func imageDimensions(contentType string, head []byte, path string) (int, int) {
+	switch contentType {
+	case "image/png", "image/jpeg", "image/gif":
+	default:
+		return 0, 0
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(head))
+	if err != nil {
+		f, err := os.Open(path)
+		if err != nil {
+			return 0, 0
+		}
+		defer f.Close()
+
+		cfg, _, err = image.DecodeConfig(bufio.NewReader(f))
+		if err != nil {
+			return 0, 0
+		}
+	}
+
+	return cfg.Width, cfg.Height
+}
+
+// normalizeImage decodes the image at path, which was sniffed as contentType, scales it down if
+// needed, and re-encodes it as directed by n, replacing the file. If that changes its extension, and
+// the new name is taken, -1, -2, and so on are added to it, as for availableFileName. The NewFileName,
+// ContentType and FileSize of file are updated to match. Files that can't be decoded are rejected.
+func (t *Tools) normalizeImage(path, contentType string, n *ImageNormalization, file *UploadedFile) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return errors.New("the uploaded image could not be decoded")
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return fmt.Errorf("the uploaded image is too large, and must have fewer than %d pixels", maxImagePixels)
+	}
+
+	// maxImagePixels only limits the size of a GIF's screen, not how many frames it has, so find out
+	// whether it is animated without decoding any of them.
+	if contentType == "image/gif" {
+		frames, err := gifFrameCount(data)
+		if err != nil {
+			return errors.New("the uploaded image could not be decoded")
+		}
+		if frames > 1 {
+			if n.AllowAnimatedGIF {
+				return nil
+			}
+			return errors.New("animated GIFs are not permitted")
+		}
+	}
+
+	var img image.Image
+	if contentType == "image/gif" {
+		// This decodes only the first frame, which is the only one.
+		img, err = gif.Decode(bytes.NewReader(data))
+	} else {
+		img, _, err = image.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return errors.New("the uploaded image could not be decoded")
+	}
+
+	format := strings.ToLower(n.Format)
+	if format == "" {
+		format = "png"
+		if contentType == "image/jpeg" {
+			format = "jpeg"
+		}
+	}
+
+	img = fitImage(img, n.MaxImageWidth, n.MaxImageHeight)
+	file.Width, file.Height = img.Bounds().Dx(), img.Bounds().Dy()
+
+	var buf bytes.Buffer
+	var ext string
+	switch format {
+	case "png":
+		ext, file.ContentType = ".png", "image/png"
+		err = png.Encode(&buf, img)
+	case "jpeg", "jpg":
+		quality := n.JPEGQuality
+		if quality == 0 {
+			quality = jpeg.DefaultQuality
+		}
+		ext, file.ContentType = ".jpg", "image/jpeg"
+		err = jpeg.Encode(&buf, flatten(img), &jpeg.Options{Quality: quality})
+	default:
+		return fmt.Errorf("unsupported image format %q", n.Format)
+	}
+	if err != nil {
+		return err
+	}
+
+	// A new extension means a new name, which mustn't replace a file already in the directory.
+	dir := filepath.Dir(path)
+	newName := strings.TrimSuffix(file.NewFileName, filepath.Ext(file.NewFileName)) + ext
+	if newName != filepath.Base(path) {
+		newName = availableFileName(dir, newName)
+	}
+	newPath := filepath.Join(dir, newName)
+
+	err = t.writeFileAtomic(newPath, buf.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+	if newPath != path {
+		_ = os.Remove(path)
+	}
+
+	file.NewFileName = newName
+	file.FileSize = int64(buf.Len())
+
+	return nil
+}
+
+// gifFrameCount returns how many frames the GIF in data has, up to 2, by walking its blocks, skipping
+// the image data rather than decoding it.
+func gifFrameCount(data []byte) (int, error) {
+	errBadGIF := errors.New("not a valid GIF")
+
+	// The header, and the logical screen descriptor, which may be followed by a global color table.
+	if len(data) < 13 || !bytes.HasPrefix(data, []byte("GIF8")) {
+		return 0, errBadGIF
+	}
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+
+	// skipSubBlocks moves pos past a run of data sub-blocks, and the empty one ending it.
+	skipSubBlocks := func() bool {
+		for pos < len(data) {
+			size := int(data[pos])
+			pos += 1 + size
+			if size == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	frames := 0
+	for pos < len(data) && frames < 2 {
+		switch data[pos] {
+		case 0x21: // an extension, with its label, then sub-blocks
+			pos += 2
+			if !skipSubBlocks() {
+				return 0, errBadGIF
+			}
+		case 0x2c: // an image descriptor, maybe a local color table, the LZW code size, then sub-blocks
+			if pos+10 > len(data) {
+				return 0, errBadGIF
+			}
+			packed := data[pos+9]
+			pos += 10
+			if packed&0x80 != 0 {
+				pos += 3 << (packed&0x07 + 1)
+			}
+			pos++
+			if !skipSubBlocks() {
+				return 0, errBadGIF
+			}
+			frames++
+		case 0x3b: // the trailer
+			return frames, nil
+		default:
+			return 0, errBadGIF
+		}
+	}
+
+	if frames == 0 {
+		return 0, errBadGIF
+	}
+	return frames, nil
+}
+
+// fitImage scales img down, if needed, so that it is no wider than maxWidth and no taller than
+// maxHeight, keeping its aspect ratio. A limit of zero means no limit.
+func fitImage(img image.Image, maxWidth, maxHeight int) image.Image {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+
+	scale := 1.0
+	if maxWidth > 0 && width > maxWidth {
+		scale = float64(maxWidth) / float64(width)
+	}
+	if maxHeight > 0 && height > maxHeight {
+		if s := float64(maxHeight) / float64(height); s < scale {
+			scale = s
+		}
+	}
+	if scale == 1 {
+		return img
+	}
+
+	newWidth := int(float64(width)*scale + 0.5)
+	newHeight := int(float64(height)*scale + 0.5)
+	if newWidth < 1 {
+		newWidth = 1
+	}
+	if newHeight < 1 {
+		newHeight = 1
+	}
+
+	return downscale(img, newWidth, newHeight)
+}
+
+// downscale shrinks src to width by height, averaging the source pixels that make up each
+// destination pixel.
+func downscale(src image.Image, width, height int) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := b.Min.Y + (y+1)*b.Dy()/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := b.Min.X + (x+1)*b.Dx()/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, bl, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					count++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / count),
+				G: uint16(g / count),
+				B: uint16(bl / count),
+				A: uint16(a / count),
+			})
+		}
+	}
+
+	return dst
+}
+
+// flatten draws img over a white background, since JPEG has no transparency, and transparent
+// pixels would otherwise come out black.
+func flatten(img image.Image) image.Image {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return img
+	}
+
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+
+	return dst
+}
@@ -0,0 +1,250 @@
+package toolbox
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// pngBytes returns a width by height PNG.
+func pngBytes(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// animatedGIFBytes returns a GIF with two frames.
+func animatedGIFBytes(t *testing.T) []byte {
+	t.Helper()
+
+	palette := color.Palette{color.Black, color.White}
+	frame := func() *image.Paletted { return image.NewPaletted(image.Rect(0, 0, 10, 10), palette) }
+
+	var buf bytes.Buffer
+	err := gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{frame(), frame()}, Delay: []int{10, 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// uploadImage uploads content as a file named name, normalizing it with n.
+func uploadImage(t *testing.T, uploadDir, name string, content []byte, n ImageNormalization) (*UploadedFile, error) {
+	t.Helper()
+
+	req, err := NewMultipartRequestFromReaders("/", []MultipartFile{{FieldName: "file", FileName: name, Content: bytes.NewReader(content)}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testTools Tools
+	files, err := testTools.UploadFilesWithOptions(req, uploadDir, UploadOptions{NormalizeImages: &n})
+	if err != nil {
+		return nil, err
+	}
+	return files[0], nil
+}
+
+func TestTools_NormalizeImagesToJPEG(t *testing.T) {
+	uploadDir := t.TempDir()
+	content, _ := os.ReadFile("./testdata/img.png")
+
+	file, err := uploadImage(t, uploadDir, "img.png", content, ImageNormalization{Format: "jpeg", JPEGQuality: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(file.NewFileName, ".jpg") || file.ContentType != "image/jpeg" || file.OriginalFileName != "img.png" {
+		t.Errorf("wrong file details: %+v", file)
+	}
+
+	saved, err := os.ReadFile(filepath.Join(uploadDir, file.NewFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(saved)) != file.FileSize {
+		t.Errorf("FileSize is %d, but the file has %d bytes", file.FileSize, len(saved))
+	}
+
+	if _, format, err := image.DecodeConfig(bytes.NewReader(saved)); err != nil || format != "jpeg" {
+		t.Errorf("expected a JPEG, but got %s (%v)", format, err)
+	}
+
+	entries, _ := os.ReadDir(uploadDir)
+	if len(entries) != 1 {
+		t.Errorf("expected only the normalized file to remain, but found %d files", len(entries))
+	}
+}
+
+func TestTools_NormalizeImagesNameTaken(t *testing.T) {
+	uploadDir := t.TempDir()
+	existing := filepath.Join(uploadDir, "photo.jpg")
+	if err := os.WriteFile(existing, []byte("someone else's photo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := os.ReadFile("./testdata/img.png")
+	req, err := NewMultipartRequestFromReaders("/", []MultipartFile{{FieldName: "file", FileName: "photo.png", Content: bytes.NewReader(content)}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testTools Tools
+	files, err := testTools.UploadFilesWithOptions(req, uploadDir, UploadOptions{KeepOriginalName: true, NormalizeImages: &ImageNormalization{Format: "jpeg"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := files[0]
+	if file.NewFileName != "photo-1.jpg" || file.SavedPath != filepath.Join(uploadDir, "photo-1.jpg") {
+		t.Errorf("expected the file to be saved as photo-1.jpg, but got %s (%s)", file.NewFileName, file.SavedPath)
+	}
+	if saved, _ := os.ReadFile(existing); string(saved) != "someone else's photo" {
+		t.Error("expected the existing photo.jpg to be left alone")
+	}
+	if _, err := os.Stat(filepath.Join(uploadDir, "photo.png")); !os.IsNotExist(err) {
+		t.Error("expected the original photo.png to be removed")
+	}
+}
+
+func TestTools_NormalizeImagesDownscale(t *testing.T) {
+	uploadDir := t.TempDir()
+
+	file, err := uploadImage(t, uploadDir, "big.png", pngBytes(t, 400, 200), ImageNormalization{MaxImageWidth: 100, MaxImageHeight: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, _ := os.Open(filepath.Join(uploadDir, file.NewFileName))
+	defer f.Close()
+
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "png" || cfg.Width != 100 || cfg.Height != 50 {
+		t.Errorf("expected a 100x50 png, but got a %dx%d %s", cfg.Width, cfg.Height, format)
+	}
+	if file.Width != 100 || file.Height != 50 {
+		t.Errorf("expected the dimensions of the stored image, 100x50, but got %dx%d", file.Width, file.Height)
+	}
+}
+
+func TestGIFFrameCount(t *testing.T) {
+	var single bytes.Buffer
+	if err := gif.Encode(&single, image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.Black, color.White}), nil); err != nil {
+		t.Fatal(err)
+	}
+	animated := animatedGIFBytes(t)
+
+	var tests = []struct {
+		name          string
+		content       []byte
+		frames        int
+		errorExpected bool
+	}{
+		{name: "single frame", content: single.Bytes(), frames: 1},
+		{name: "animated", content: animated, frames: 2},
+		{name: "truncated", content: animated[:40], errorExpected: true},
+		{name: "no frames", content: append(single.Bytes()[:13:13], 0x3b), errorExpected: true},
+		{name: "not a gif", content: []byte("GIF89a but really not"), errorExpected: true},
+	}
+
+	for _, e := range tests {
+		frames, err := gifFrameCount(e.content)
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: expected an error, but got %d frames", e.name, frames)
+			}
+			continue
+		}
+		if err != nil || frames != e.frames {
+			t.Errorf("%s: expected %d frames, but got %d, %v", e.name, e.frames, frames, err)
+		}
+	}
+}
+
+func TestTools_NormalizeImagesLargeAnimatedGIF(t *testing.T) {
+	// Two 2500x2500 frames compress to almost nothing, but would take 12MB to decode; an animated
+	// GIF is passed through, or rejected, without decoding any of them.
+	palette := color.Palette{color.Black, color.White}
+	frame := image.NewPaletted(image.Rect(0, 0, 2500, 2500), palette)
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{frame, frame}, Delay: []int{10, 10}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, allow := range []bool{true, false} {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := uploadImage(t, t.TempDir(), "big.gif", buf.Bytes(), ImageNormalization{AllowAnimatedGIF: allow})
+		runtime.ReadMemStats(&after)
+
+		if allow != (err == nil) {
+			t.Errorf("allowed %v: unexpected result: %v", allow, err)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 4<<20 {
+			t.Errorf("allowed %v: expected the frames not to be decoded, but %d bytes were allocated", allow, allocated)
+		}
+	}
+}
+
+func TestTools_NormalizeImagesRejected(t *testing.T) {
+	corrupt := append([]byte("\x89PNG\x0D\x0A\x1A\x0A"), bytes.Repeat([]byte("garbage"), 100)...)
+
+	var tests = []struct {
+		name          string
+		content       []byte
+		n             ImageNormalization
+		errorExpected string
+	}{
+		{name: "corrupt", content: corrupt, errorExpected: "could not be decoded"},
+		{name: "animated gif", content: animatedGIFBytes(t), errorExpected: "animated GIFs are not permitted"},
+		{name: "animated gif allowed", content: animatedGIFBytes(t), n: ImageNormalization{AllowAnimatedGIF: true}},
+	}
+
+	for _, e := range tests {
+		uploadDir := t.TempDir()
+
+		file, err := uploadImage(t, uploadDir, "image.gif", e.content, e.n)
+		if e.errorExpected == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", e.name, err)
+				continue
+			}
+
+			saved, _ := os.ReadFile(filepath.Join(uploadDir, file.NewFileName))
+			if !bytes.Equal(saved, e.content) {
+				t.Errorf("%s: expected the file to be saved untouched", e.name)
+			}
+			continue
+		}
+
+		if err == nil || !strings.Contains(err.Error(), e.errorExpected) {
+			t.Errorf("%s: expected error containing %q, but got %v", e.name, e.errorExpected, err)
+		}
+
+		entries, _ := os.ReadDir(uploadDir)
+		if len(entries) != 0 {
+			t.Errorf("%s: expected nothing to be left on disk, but found %d files", e.name, len(entries))
+		}
+	}
+}
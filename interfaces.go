@@ -0,0 +1,127 @@
+package toolbox
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"time"
+)
+
+// JSONReadWriter reads JSON requests and writes JSON responses.
+type JSONReadWriter interface {
+	ReadJSON(w http.ResponseWriter, r *http.Request, data interface{}) error
+	ReadJSONContext(ctx context.Context, w http.ResponseWriter, r *http.Request, data any) error
+	ReadJSONWithLimit(w http.ResponseWriter, r *http.Request, data any, maxBytes int) error
+	ReadJSONRaw(w http.ResponseWriter, r *http.Request, data any) ([]byte, error)
+	WriteJSON(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error
+	ErrorJSON(w http.ResponseWriter, err error, status ...int) error
+	ErrorJSONCtx(w http.ResponseWriter, r *http.Request, err error, status ...int) error
+	ErrorJSONf(w http.ResponseWriter, status int, format string, args ...any) error
+	EncodeJSON(w io.Writer, data any) (int, error)
+	ReadJSONPatch(w http.ResponseWriter, r *http.Request) ([]PatchOp, error)
+	ReadMergePatch(w http.ResponseWriter, r *http.Request, original any) ([]byte, error)
+	WriteJSONWhenReady(w http.ResponseWriter, r *http.Request, status int, produce func(ctx context.Context) (any, error), heartbeat time.Duration) error
+	EncodeJSONContext(ctx context.Context, w io.Writer, data any) (int, error)
+	WriteEnvelope(w http.ResponseWriter, status int, message string, data any) error
+	WriteJSONNamed(w http.ResponseWriter, status int, message string, key string, data any, headers ...http.Header) error
+	WriteNDJSON(w http.ResponseWriter, status int, items <-chan any, headers ...http.Header) error
+}
+
+// XMLReadWriter reads XML requests and writes XML responses.
+type XMLReadWriter interface {
+	ReadXML(w http.ResponseWriter, r *http.Request, data interface{}) error
+	ReadXMLMap(w http.ResponseWriter, r *http.Request) (map[string]any, error)
+	WriteXML(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error
+	ErrorXML(w http.ResponseWriter, err error, status ...int) error
+	ErrorXMLCtx(w http.ResponseWriter, r *http.Request, err error, status ...int) error
+	ErrorXMLf(w http.ResponseWriter, status int, format string, args ...any) error
+	EncodeXML(w io.Writer, data any) (int, error)
+	WriteEnvelopeXML(w http.ResponseWriter, status int, message string, data any) error
+}
+
+// Responder writes plain text, HTML, and file responses.
+type Responder interface {
+	WriteString(w http.ResponseWriter, status int, body string, headers ...http.Header) error
+	WriteHTML(w http.ResponseWriter, status int, body string, headers ...http.Header) error
+	DownloadStaticFile(w http.ResponseWriter, r *http.Request, p, file, displayName string)
+	DownloadFromReader(w http.ResponseWriter, r *http.Request, content io.ReadSeeker, modTime time.Time, displayName, contentType string)
+	DownloadFSFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, name, displayName string) error
+	DownloadFromReaderAt(w http.ResponseWriter, r *http.Request, content io.ReaderAt, size int64, modTime time.Time, displayName, contentType string, opts ...DownloadOption) error
+	DownloadJSON(w http.ResponseWriter, data any, filename string, indent bool) error
+	DownloadNDJSON(w http.ResponseWriter, items <-chan any, filename string) error
+	StaticDir(dir string) http.Handler
+}
+
+// FileUploader saves files sent by clients, or fetched from elsewhere.
+type FileUploader interface {
+	UploadFiles(r *http.Request, uploadDir string, rename ...bool) ([]*UploadedFile, error)
+	UploadFilesWithOptions(r *http.Request, uploadDir string, opts UploadOptions) ([]*UploadedFile, error)
+	UploadFilesPartial(r *http.Request, uploadDir string, opts UploadOptions) ([]*UploadedFile, []UploadError, error)
+	UploadOneFile(r *http.Request, uploadDir string, rename ...bool) (*UploadedFile, error)
+	ReadMultipartJSON(w http.ResponseWriter, r *http.Request, jsonFieldName string, dst any, uploadDir string) ([]*UploadedFile, error)
+	ReadMultipartForm(r *http.Request, dst any, uploadDir string) error
+	ReadForm(w http.ResponseWriter, r *http.Request, dst any) error
+	ReadBody(w http.ResponseWriter, r *http.Request, dst any) error
+	FetchRemoteFile(ctx context.Context, uri, uploadDir string, rename bool) (*UploadedFile, error)
+	SaveDataURI(dataURI string, uploadDir string, rename bool) (*UploadedFile, error)
+	UploadFilesQuarantined(r *http.Request, quarantineDir string) ([]*UploadedFile, error)
+	ListQuarantined(quarantineDir string) ([]*UploadedFile, error)
+	PromoteUpload(file *UploadedFile, destDir string) error
+	RejectUpload(file *UploadedFile) error
+	TrashFile(file *UploadedFile, trashDir string) error
+	RestoreFile(trashDir, name string) (*UploadedFile, error)
+	EmptyTrash(trashDir string, olderThan time.Duration) (int, error)
+	ProgressHandler(tracker *ProgressTracker) http.Handler
+}
+
+// RemoteCaller sends JSON to remote services.
+type RemoteCaller interface {
+	PushJSONToRemote(uri string, data interface{}, client ...*http.Client) (*http.Response, int, error)
+	PushJSONToMany(ctx context.Context, uris []string, data any, opts ...RemoteOption) []RemoteResult
+	PushReaderToRemote(ctx context.Context, uri string, body io.Reader, contentType string, opts ...RemoteOption) (int, []byte, error)
+	PushJSONStreamToRemote(ctx context.Context, uri string, data any, opts ...RemoteOption) (int, []byte, error)
+}
+
+// Toolboxer is everything *Tools can do. Accept it, or one of the smaller interfaces it is made
+// of, in your handlers instead of *Tools, and you can swap in a test double such as
+// toolboxtest.MockTools.
+type Toolboxer interface {
+	JSONReadWriter
+	XMLReadWriter
+	Responder
+	FileUploader
+	RemoteCaller
+
+	RequestID(next http.Handler) http.Handler
+	DebugRequestLogger(next http.Handler) http.Handler
+	Timeout(next http.Handler, d time.Duration) http.Handler
+	RandomString(n int) string
+	RandomStringFast(n int) string
+	RandomStringFastCharset(n int, charset string) string
+	RandomFileName(originalName string) string
+	Slugify(s string) (string, error)
+	CreateDirIfNotExist(path string) error
+	CreateDirReport(path string, mode os.FileMode) (bool, error)
+	TempFile(pattern string) (*os.File, func(), error)
+	CleanupTempFiles(olderThan time.Duration) (int, error)
+	ReadJSONFile(path string, dst any) error
+	WriteJSONFile(path string, data any, perm os.FileMode, indent bool) error
+	UpdateJSONFile(path string, dst any, update func() error, perm os.FileMode) error
+	FileChecksum(path string, algo string) (string, error)
+	VerifyFileChecksum(path, expected, algo string) error
+	EncodeFileToDataURI(path string) (string, error)
+	EncodeReaderToDataURI(r io.Reader, contentType string) (string, error)
+	DecodeDataURI(s string) (string, []byte, error)
+	IsValidEmail(s string) bool
+	NormalizeEmail(s string) (string, error)
+	ParseTimeFlexible(s string) (time.Time, error)
+	ParseTimeIn(s string, loc *time.Location) (time.Time, error)
+	LoadEnvConfig(dst any, prefix string) error
+	ReadCSVStream(r io.Reader, fn func(rowNumber int, record map[string]string) error, opts ...CSVOption) (CSVReport, error)
+	VerifyWebhookSignature(r *http.Request, secret []byte, opts WebhookOptions) ([]byte, error)
+}
+
+// Make sure *Tools keeps satisfying the interfaces.
+var _ Toolboxer = (*Tools)(nil)
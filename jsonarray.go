@@ -0,0 +1,174 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// defaultMaxJSONArrayElements is the most elements ReadJSONArray accepts, unless MaxArrayElements or
+// WithMaxElements is used.
+const defaultMaxJSONArrayElements = 10000
+
+// ElementError describes an element of a JSON array that ReadJSONArray could not decode.
+type ElementError struct {
+	Index   int    `json:"index"`   // the position of the element in the array, counting from 0
+	Message string `json:"message"` // what was wrong with it
+}
+
+// Error satisfies the error interface.
+func (e ElementError) Error() string {
+	return fmt.Sprintf("element %d: %s", e.Index, e.Message)
+}
+
+// JSONArrayOption changes how ReadJSONArray reads an array.
+type JSONArrayOption func(*jsonArrayOptions)
+
+type jsonArrayOptions struct {
+	lenient     bool
+	maxElements int
+}
+
+// WithLenientElements makes ReadJSONArray skip elements that can't be decoded into the element type,
+// reporting them as ElementErrors, rather than failing at the first one. Malformed JSON still fails,
+// since nothing after it can be read.
+func WithLenientElements() JSONArrayOption {
+	return func(o *jsonArrayOptions) {
+		o.lenient = true
+	}
+}
+
+// WithMaxElements sets the most elements the array may have, overriding MaxArrayElements (and the
+// default of 10,000).
+func WithMaxElements(n int) JSONArrayOption {
+	return func(o *jsonArrayOptions) {
+		o.maxElements = n
+	}
+}
+
+// ReadJSONArray reads a request body holding a JSON array, decoding one element at a time into a T,
+// with the same Content-Type check, MaxJSONSize limit and AllowUnknownFields setting as ReadJSON. It
+// is a function, not a method, since methods can't have type parameters:
+//
+//	items, bad, err := toolbox.ReadJSONArray[Item](&tools, w, r, toolbox.WithLenientElements())
+//
+// By default, the first element that can't be decoded (for example, because it has a bad date) stops
+// the read, and is returned as an ElementError as well as in the error. With WithLenientElements, the
+// good elements are returned along with an ElementError for each bad one, and the error is only
+// set for problems with the body as a whole: it's not an array, it's malformed, or it's too big. An
+// element that is malformed JSON always stops the read, with an error naming its index, since
+// nothing after it can be read. As with ReadJSON, nothing may follow the closing bracket.
+func ReadJSONArray[T any](t *Tools, w http.ResponseWriter, r *http.Request, opts ...JSONArrayOption) ([]T, []ElementError, error) {
+	o := jsonArrayOptions{maxElements: defaultMaxJSONArrayElements}
+	if t.MaxArrayElements > 0 {
+		o.maxElements = t.MaxArrayElements
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" && !t.isJSONMediaType(contentType) {
+		return nil, nil, t.jsonMediaTypeError(contentType)
+	}
+
+	maxBytes := t.maxJSONSize(r.Context())
+	if declaredTooLarge(w, r, maxBytes) {
+		return nil, nil, &BodyTooLargeError{Limit: maxBytes}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	dec := json.NewDecoder(r.Body)
+	if !t.AllowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, nil, classifyJSONError(err, maxBytes)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, nil, errors.New("body must be a JSON array")
+	}
+
+	var items []T
+	var failures []ElementError
+
+	for i := 0; dec.More(); i++ {
+		if o.maxElements > 0 && i >= o.maxElements {
+			return nil, nil, fmt.Errorf("body must not have more than %d elements", o.maxElements)
+		}
+
+		var item T
+		err := dec.Decode(&item)
+		if err == nil {
+			items = append(items, item)
+			continue
+		}
+
+		// If the body is too big, or the element isn't even valid JSON, we can't go on.
+		if isMaxBytesError(err) {
+			return nil, nil, classifyJSONError(err, maxBytes)
+		}
+		var syntaxError *json.SyntaxError
+		if errors.As(err, &syntaxError) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil, fmt.Errorf("element %d: %w", i, classifyJSONError(err, maxBytes))
+		}
+
+		failure := ElementError{Index: i, Message: elementErrorMessage(err)}
+		failures = append(failures, failure)
+		if !o.lenient {
+			return nil, failures, failure
+		}
+	}
+
+	// Read the closing bracket, and make sure nothing follows it.
+	_, err = dec.Token()
+	if err != nil {
+		return nil, nil, classifyJSONError(err, maxBytes)
+	}
+
+	err = dec.Decode(&struct{}{})
+	if err != io.EOF {
+		return nil, nil, errMultipleJSONValues()
+	}
+
+	return items, failures, nil
+}
+
+// elementErrorMessage describes why a single array element could not be decoded.
+func elementErrorMessage(err error) string {
+	var unmarshalTypeError *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &unmarshalTypeError):
+		if unmarshalTypeError.Field == "" {
+			return fmt.Sprintf("must be a JSON %s", jsonKind(unmarshalTypeError.Type.Kind().String()))
+		}
+		return fmt.Sprintf("incorrect JSON type for field %q", unmarshalTypeError.Field)
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return "unknown key " + strings.TrimPrefix(err.Error(), "json: unknown field ")
+
+	default:
+		return err.Error()
+	}
+}
+
+// jsonKind names the JSON type that a Go kind is decoded from.
+func jsonKind(kind string) string {
+	switch kind {
+	case "struct", "map":
+		return "object"
+	case "slice", "array":
+		return "array"
+	case "string":
+		return "string"
+	case "bool":
+		return "boolean"
+	default:
+		return "number"
+	}
+}
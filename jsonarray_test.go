@@ -0,0 +1,148 @@
+package toolbox
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type jsonArrayItem struct {
+	Name string    `json:"name"`
+	When time.Time `json:"when"`
+}
+
+var readJSONArrayTests = []struct {
+	name          string
+	body          string
+	lenient       bool
+	maxElements   int
+	expectedNames []string
+	expectedBad   []ElementError
+	errorExpected bool
+}{
+	{name: "clean", body: `[{"name":"a","when":"2024-01-02T03:04:05Z"},{"name":"b","when":"2024-01-02T03:04:05Z"}]`, expectedNames: []string{"a", "b"}},
+	{name: "empty array", body: `[]`},
+	{name: "bad middle strict", body: `[{"name":"a"},{"name":"b","when":"yesterday"},{"name":"c"}]`, expectedBad: []ElementError{{Index: 1}}, errorExpected: true},
+	{name: "bad middle lenient", body: `[{"name":"a"},{"name":"b","when":"yesterday"},{"name":"c"}]`, lenient: true, expectedNames: []string{"a", "c"}, expectedBad: []ElementError{{Index: 1}}},
+	{name: "wrong type lenient", body: `[{"name":1},"x",{"name":"c"}]`, lenient: true, expectedNames: []string{"c"}, expectedBad: []ElementError{{Index: 0, Message: `incorrect JSON type for field "name"`}, {Index: 1, Message: "must be a JSON object"}}},
+	{name: "unknown field", body: `[{"name":"a","other":1}]`, lenient: true, expectedBad: []ElementError{{Index: 0, Message: `unknown key "other"`}}},
+	{name: "malformed lenient", body: `[{"name":"a"},{"name":}]`, lenient: true, errorExpected: true},
+	{name: "not an array", body: `{"name":"a"}`, errorExpected: true},
+	{name: "empty body", body: ``, errorExpected: true},
+	{name: "too many elements", body: `[{},{},{}]`, maxElements: 2, errorExpected: true},
+	{name: "trailing value", body: `[] {}`, errorExpected: true},
+	{name: "unterminated", body: `[{"name":"a"}`, errorExpected: true},
+}
+
+func TestReadJSONArray(t *testing.T) {
+	for _, e := range readJSONArrayTests {
+		var tools Tools
+
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(e.body))
+		req.Header.Set("Content-Type", "application/json")
+
+		var opts []JSONArrayOption
+		if e.lenient {
+			opts = append(opts, WithLenientElements())
+		}
+		if e.maxElements > 0 {
+			opts = append(opts, WithMaxElements(e.maxElements))
+		}
+
+		items, bad, err := ReadJSONArray[jsonArrayItem](&tools, httptest.NewRecorder(), req, opts...)
+		if e.errorExpected && err == nil {
+			t.Errorf("%s: error expected, but none received", e.name)
+		}
+		if !e.errorExpected && err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+		}
+
+		if len(items) != len(e.expectedNames) {
+			t.Errorf("%s: expected %d items, but got %d", e.name, len(e.expectedNames), len(items))
+		} else {
+			for i, name := range e.expectedNames {
+				if items[i].Name != name {
+					t.Errorf("%s: expected item %d to be %s, but got %s", e.name, i, name, items[i].Name)
+				}
+			}
+		}
+
+		if len(bad) != len(e.expectedBad) {
+			t.Errorf("%s: expected %d element errors, but got %d: %v", e.name, len(e.expectedBad), len(bad), bad)
+			continue
+		}
+		for i, want := range e.expectedBad {
+			if bad[i].Index != want.Index {
+				t.Errorf("%s: expected element error at index %d, but got %d", e.name, want.Index, bad[i].Index)
+			}
+			if want.Message != "" && bad[i].Message != want.Message {
+				t.Errorf("%s: expected message %q, but got %q", e.name, want.Message, bad[i].Message)
+			}
+		}
+	}
+}
+
+func TestReadJSONArrayStrictError(t *testing.T) {
+	var tools Tools
+
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`[{"name":"a"},{"name":"b"},{"name":"c","when":"soon"}]`))
+
+	_, _, err := ReadJSONArray[jsonArrayItem](&tools, httptest.NewRecorder(), req)
+	if err == nil || !strings.HasPrefix(err.Error(), "element 2: ") {
+		t.Errorf("expected an error naming element 2, but got %v", err)
+	}
+}
+
+func TestReadJSONArrayMalformedElement(t *testing.T) {
+	var tools Tools
+
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`[{"name":"a"},{"name":"b"},{"name":}]`))
+
+	_, _, err := ReadJSONArray[jsonArrayItem](&tools, httptest.NewRecorder(), req, WithLenientElements())
+	if err == nil || !strings.HasPrefix(err.Error(), "element 2: ") || !errors.Is(err, ErrBadlyFormedJSON) {
+		t.Errorf("expected a badly-formed JSON error naming element 2, but got %v", err)
+	}
+}
+
+func TestReadJSONArrayMaxArrayElements(t *testing.T) {
+	tools := Tools{MaxArrayElements: 2}
+
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`[{},{},{}]`))
+	_, _, err := ReadJSONArray[jsonArrayItem](&tools, httptest.NewRecorder(), req)
+	if err == nil || err.Error() != "body must not have more than 2 elements" {
+		t.Errorf("expected MaxArrayElements to apply, but got %v", err)
+	}
+
+	// WithMaxElements takes precedence.
+	req, _ = http.NewRequest("POST", "/", strings.NewReader(`[{},{},{}]`))
+	items, _, err := ReadJSONArray[jsonArrayItem](&tools, httptest.NewRecorder(), req, WithMaxElements(3))
+	if err != nil || len(items) != 3 {
+		t.Errorf("expected WithMaxElements to override MaxArrayElements, but got %d items and %v", len(items), err)
+	}
+}
+
+func TestReadJSONArrayTooLarge(t *testing.T) {
+	tools := Tools{MaxJSONSize: 20}
+
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`[{"name":"a"},{"name":"b"},{"name":"c"}]`))
+
+	_, _, err := ReadJSONArray[jsonArrayItem](&tools, httptest.NewRecorder(), req, WithLenientElements())
+	if err == nil || err.Error() != "body must not be larger than 20 bytes" {
+		t.Errorf("expected a size error, but got %v", err)
+	}
+}
+
+func TestReadJSONArrayContentType(t *testing.T) {
+	var tools Tools
+
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`[]`))
+	req.Header.Set("Content-Type", "text/plain")
+
+	_, _, err := ReadJSONArray[jsonArrayItem](&tools, httptest.NewRecorder(), req)
+	if err == nil {
+		t.Error("expected an error for the wrong Content-Type, but got none")
+	}
+}
@@ -0,0 +1,72 @@
+package toolbox
+
+import (
+	"fmt"
+	"io"
+)
+
+// defaultMaxJSONDepth is the MaxJSONDepth set by New.
+const defaultMaxJSONDepth = 64
+
+// limitJSONDepth returns body, checked as it is read for arrays and objects nested more deeply
+// than MaxJSONDepth, or body itself if MaxJSONDepth isn't set.
+func (t *Tools) limitJSONDepth(body io.Reader) io.Reader {
+	if t.MaxJSONDepth <= 0 {
+		return body
+	}
+	return &depthLimitReader{r: body, max: t.MaxJSONDepth}
+}
+
+// depthLimitReader follows the nesting of the JSON read through it, and fails with a *ReadError
+// of kind ErrJSONTooDeep as soon as it goes past max, before the decoder has seen the value that
+// is too deep, let alone allocated anything for it: the bytes before the bracket that goes too
+// deep are returned, and the error from the next read, since a decoder may take an error that
+// comes with data for the end of the body. Brackets inside strings don't count. Anything else
+// wrong with the JSON is left for the decoder to find.
+type depthLimitReader struct {
+	r        io.Reader
+	max      int
+	depth    int
+	offset   int64
+	inString bool
+	escaped  bool
+	err      error // the error to return from now on, once the limit has been passed
+}
+
+func (d *depthLimitReader) Read(p []byte) (int, error) {
+	if d.err != nil {
+		return 0, d.err
+	}
+
+	n, err := d.r.Read(p)
+
+	for i, c := range p[:n] {
+		switch {
+		case d.escaped:
+			d.escaped = false
+		case d.inString:
+			switch c {
+			case '\\':
+				d.escaped = true
+			case '"':
+				d.inString = false
+			}
+		case c == '"':
+			d.inString = true
+		case c == '[' || c == '{':
+			d.depth++
+			if d.depth > d.max {
+				offset := d.offset + int64(i)
+				d.err = &ReadError{Kind: ErrJSONTooDeep, Offset: offset,
+					message: fmt.Sprintf("body must not have JSON nested more than %d deep (at character %d)", d.max, offset+1)}
+				d.offset = offset
+				return i, nil
+			}
+		case c == ']' || c == '}':
+			d.depth--
+		}
+	}
+	d.offset += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,73 @@
+package toolbox
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func nestedJSON(depth int) string {
+	return strings.Repeat("[", depth) + strings.Repeat("]", depth)
+}
+
+var maxJSONDepthTests = []struct {
+	name     string
+	maxDepth int
+	body     string
+	tooDeep  bool
+}{
+	{name: "at the limit", maxDepth: 64, body: `{"data":` + nestedJSON(63) + `}`},
+	{name: "one too deep", maxDepth: 64, body: `{"data":` + nestedJSON(64) + `}`, tooDeep: true},
+	{name: "objects", maxDepth: 3, body: `{"data":{"a":{"b":{}}}}`, tooDeep: true},
+	{name: "brackets in strings", maxDepth: 2, body: `{"data":["[[[{{{\"[[[", "]]]"]}`},
+	{name: "wide but shallow", maxDepth: 3, body: `{"data":[[],[],[],[]]}`},
+	{name: "no limit", maxDepth: 0, body: `{"data":` + nestedJSON(500) + `}`},
+}
+
+func TestTools_ReadJSONMaxJSONDepth(t *testing.T) {
+	for _, e := range maxJSONDepthTests {
+		testTools := Tools{MaxJSONDepth: e.maxDepth}
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.body))
+
+		var data struct {
+			Data any `json:"data"`
+		}
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &data)
+
+		if e.tooDeep != errors.Is(err, ErrJSONTooDeep) {
+			t.Errorf("%s: expected too deep to be %v, but got %v", e.name, e.tooDeep, err)
+		}
+		if !e.tooDeep && err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+		}
+	}
+}
+
+func TestTools_ReadJSONMaxJSONDepthDefault(t *testing.T) {
+	testTools := New()
+	if testTools.MaxJSONDepth != 64 {
+		t.Fatalf("expected New to set MaxJSONDepth to 64, but got %d", testTools.MaxJSONDepth)
+	}
+
+	// Ten thousand levels are refused as soon as the 65th is read.
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(nestedJSON(10000)))
+	var data any
+
+	start := time.Now()
+	err := testTools.ReadJSON(httptest.NewRecorder(), req, &data)
+	elapsed := time.Since(start)
+
+	var readErr *ReadError
+	if !errors.As(err, &readErr) || readErr.Kind != ErrJSONTooDeep || readErr.Offset != 64 {
+		t.Fatalf("expected ErrJSONTooDeep at offset 64, but got %v", err)
+	}
+	if err.Error() != "body must not have JSON nested more than 64 deep (at character 65)" {
+		t.Errorf("wrong message: %q", err)
+	}
+	if data != nil || elapsed > time.Second {
+		t.Errorf("expected nothing decoded, quickly, but got %v in %s", data, elapsed)
+	}
+}
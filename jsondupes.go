@@ -0,0 +1,129 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DuplicateJSONKeyError is returned by ReadJSON, when RejectDuplicateJSONKeys is set, for a body
+// containing an object with the same key more than once, such as {"amount":1,"amount":9999}. Since
+// encoding/json matches keys to struct fields without regard to case, keys that differ only in case,
+// such as {"amount":1,"Amount":9999}, count as the same key.
+type DuplicateJSONKeyError struct {
+	Key   string // the duplicated key, as it is the second time
+	First string // the key as it is the first time, which differs from Key, if at all, only in case
+	Path  string // where the second occurrence is, as a JSON Pointer (RFC 6901), e.g. /items/0/amount
+}
+
+// Error satisfies the error interface.
+func (e *DuplicateJSONKeyError) Error() string {
+	if e.First != e.Key {
+		return fmt.Sprintf("body contains the keys %q and %q, which differ only in case (at %s)", e.First, e.Key, e.Path)
+	}
+	return fmt.Sprintf("body contains the key %q more than once (at %s)", e.Key, e.Path)
+}
+
+// jsonScanFrame is an object or array that checkDuplicateJSONKeys is inside.
+type jsonScanFrame struct {
+	path      string
+	keys      map[string]string // the keys seen so far, by their folded form, or nil for an array
+	key       string            // the key of the value being read, in an object
+	expectKey bool              // true if the next token in an object is a key, or the closing brace
+	index     int               // the index of the next value, in an array
+}
+
+// checkDuplicateJSONKeys returns a *DuplicateJSONKeyError if any object in the first JSON value in
+// body, however deeply nested, has the same key more than once, ignoring case. Badly-formed JSON is
+// left for the decoder to report, so nil is returned for it. body should come through limitJSONDepth,
+// whose error is returned as it is.
+func checkDuplicateJSONKeys(body io.Reader) error {
+	dec := json.NewDecoder(body)
+	dec.UseNumber()
+
+	// Keep our own stack, rather than recursing, so that deeply nested bodies can't exhaust it.
+	var stack []*jsonScanFrame
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if errors.Is(err, ErrJSONTooDeep) {
+				return err
+			}
+			return nil
+		}
+
+		var top *jsonScanFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		if top != nil && top.keys != nil && top.expectKey {
+			if tok == json.Delim('}') {
+				stack = stack[:len(stack)-1]
+			} else {
+				key, _ := tok.(string)
+				folded := foldJSONKey(key)
+				if first, ok := top.keys[folded]; ok {
+					return &DuplicateJSONKeyError{Key: key, First: first, Path: top.path + "/" + escapeJSONPointer(key)}
+				}
+				top.keys[folded] = key
+				top.key = key
+				top.expectKey = false
+				continue
+			}
+		} else if tok == json.Delim(']') {
+			stack = stack[:len(stack)-1]
+		} else {
+			var path string
+			switch {
+			case top == nil:
+			case top.keys != nil:
+				path = top.path + "/" + escapeJSONPointer(top.key)
+				top.expectKey = true
+			default:
+				path = top.path + "/" + strconv.Itoa(top.index)
+				top.index++
+			}
+
+			switch tok {
+			case json.Delim('{'):
+				stack = append(stack, &jsonScanFrame{path: path, keys: make(map[string]string), expectKey: true})
+			case json.Delim('['):
+				stack = append(stack, &jsonScanFrame{path: path})
+			}
+		}
+
+		if len(stack) == 0 {
+			return nil
+		}
+	}
+}
+
+// escapeJSONPointer escapes a key for use in a JSON Pointer.
+func escapeJSONPointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+// foldJSONKey returns key folded the way encoding/json folds keys to match them to struct fields, so
+// that foldJSONKey(a) == foldJSONKey(b) exactly when the decoder would treat a and b as the same.
+func foldJSONKey(key string) string {
+	var b strings.Builder
+	b.Grow(len(key))
+	for _, r := range key {
+		// Use the smallest rune in r's fold set, so that, for example, K, k and the Kelvin sign agree.
+		for {
+			r2 := unicode.SimpleFold(r)
+			if r2 <= r {
+				r = r2
+				break
+			}
+			r = r2
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
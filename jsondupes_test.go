@@ -0,0 +1,122 @@
+package toolbox
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var duplicateJSONKeyTests = []struct {
+	name         string
+	body         string
+	expectedKey  string
+	expectedPath string
+}{
+	{name: "top level", body: `{"amount": 1, "amount": 9999}`, expectedKey: "amount", expectedPath: "/amount"},
+	{name: "nested", body: `{"order": {"id": 1, "lines": [{"sku": "a"}, {"sku": "b", "qty": 1, "qty": 2}]}}`, expectedKey: "qty", expectedPath: "/order/lines/1/qty"},
+	{name: "escaped", body: `{"a/b": {"c~d": 1, "c~d": 2}}`, expectedKey: "c~d", expectedPath: "/a~1b/c~0d"},
+	{name: "after a nested object", body: `{"a": {"b": 1}, "c": [1, {"d": 2}], "a": 3}`, expectedKey: "a", expectedPath: "/a"},
+	{name: "siblings", body: `{"items": [{"id": 1, "name": "x"}, {"id": 2, "name": "y"}], "meta": {"id": 3}}`},
+	{name: "key as a value", body: `{"a": "a", "b": ["a", "a"]}`},
+	{name: "differing in case", body: `{"amount": 1, "Amount": 9999}`, expectedKey: "Amount", expectedPath: "/Amount"},
+	{name: "kelvin sign", body: `{"items": [{"kind": 1, "\u212aIND": 2}]}`, expectedKey: "\u212aIND", expectedPath: "/items/0/\u212aIND"},
+	{name: "array at the top", body: `[{"id": 1}, {"id": 2, "id": 3}]`, expectedKey: "id", expectedPath: "/1/id"},
+}
+
+func TestTools_ReadJSONDuplicateKeys(t *testing.T) {
+	for _, e := range duplicateJSONKeyTests {
+		testTools := Tools{RejectDuplicateJSONKeys: true, AllowUnknownFields: true}
+
+		var data any
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.body))
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &data)
+
+		var dupErr *DuplicateJSONKeyError
+		if e.expectedKey == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", e.name, err)
+			}
+			continue
+		}
+		if !errors.As(err, &dupErr) {
+			t.Errorf("%s: expected a DuplicateJSONKeyError, but got %v", e.name, err)
+			continue
+		}
+		if dupErr.Key != e.expectedKey || dupErr.Path != e.expectedPath {
+			t.Errorf("%s: expected %s at %s, but got %s at %s", e.name, e.expectedKey, e.expectedPath, dupErr.Key, dupErr.Path)
+		}
+		if data != nil {
+			t.Errorf("%s: nothing should be decoded, but got %v", e.name, data)
+		}
+	}
+}
+
+func TestTools_ReadJSONDuplicateKeysCase(t *testing.T) {
+	testTools := Tools{RejectDuplicateJSONKeys: true}
+
+	var data struct {
+		Amount int `json:"amount"`
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"amount": 1, "Amount": 9999}`))
+	err := testTools.ReadJSON(httptest.NewRecorder(), req, &data)
+
+	var dupErr *DuplicateJSONKeyError
+	if !errors.As(err, &dupErr) || dupErr.First != "amount" || dupErr.Key != "Amount" {
+		t.Fatalf("expected amount and Amount to be reported, but got %v", err)
+	}
+	if err.Error() != `body contains the keys "amount" and "Amount", which differ only in case (at /Amount)` {
+		t.Errorf("wrong error message: %s", err)
+	}
+	if data.Amount != 0 {
+		t.Errorf("nothing should be decoded, but got %d", data.Amount)
+	}
+}
+
+func TestTools_ReadJSONDuplicateKeysOff(t *testing.T) {
+	var testTools Tools
+
+	var data struct {
+		Amount int `json:"amount"`
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"amount": 1, "amount": 9999}`))
+	if err := testTools.ReadJSON(httptest.NewRecorder(), req, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Amount != 9999 {
+		t.Errorf("expected the last value to win, as encoding/json does, but got %d", data.Amount)
+	}
+}
+
+func TestTools_ReadJSONDuplicateKeysOtherErrors(t *testing.T) {
+	testTools := Tools{RejectDuplicateJSONKeys: true, MaxJSONSize: 20}
+
+	var data any
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"a": "`+strings.Repeat("x", 50)+`"}`))
+	if err := testTools.ReadJSON(httptest.NewRecorder(), req, &data); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("expected ErrBodyTooLarge, but got %v", err)
+	}
+
+	// Badly-formed JSON is reported as usual.
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"a": }`))
+	if err := testTools.ReadJSON(httptest.NewRecorder(), req, &data); err == nil || !strings.Contains(err.Error(), "badly-formed JSON") {
+		t.Errorf("expected a badly-formed JSON error, but got %v", err)
+	}
+
+	// The scan stops at MaxJSONDepth, so a body that is too deep is reported as such, whatever lies
+	// beyond the limit.
+	testTools.MaxJSONSize = 0
+	testTools.MaxJSONDepth = 64
+	body := strings.Repeat("[", 100) + `{"a": 1, "a": 2}` + strings.Repeat("]", 100)
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if err := testTools.ReadJSON(httptest.NewRecorder(), req, &data); !errors.Is(err, ErrJSONTooDeep) {
+		t.Errorf("expected ErrJSONTooDeep, but got %v", err)
+	}
+
+	// So are extra values, even if they have duplicates.
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"a": 1} {"b": 1, "b": 2}`))
+	if err := testTools.ReadJSON(httptest.NewRecorder(), req, &data); err == nil || !strings.Contains(err.Error(), "single JSON value") {
+		t.Errorf("expected an error for more than one value, but got %v", err)
+	}
+}
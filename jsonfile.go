@@ -0,0 +1,115 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ReadJSONFile reads the JSON file at path into dst, which must be a pointer. Unknown fields are
+// rejected unless AllowUnknownFields is true, and errors are described in the same way as ReadJSON,
+// prefixed with the path of the file.
+func (t *Tools) ReadJSONFile(path string, dst any) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+
+	if !t.AllowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	err = dec.Decode(dst)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, classifyJSONError(err, 0))
+	}
+
+	err = dec.Decode(&struct{}{})
+	if err != io.EOF {
+		return fmt.Errorf("%s: %w", path, errMultipleJSONValues())
+	}
+
+	return nil
+}
+
+// WriteJSONFile writes data as JSON to the file at path, creating any missing parent directories. If
+// indent is true, the JSON is indented with two spaces. The file is written atomically: the JSON is
+// written to a temporary file in the same directory, which is then renamed over path, so readers
+// see either the old contents or the new contents, and never a partial write. If path is a symlink,
+// the file it points to is replaced, and the link is left in place.
+func (t *Tools) WriteJSONFile(path string, data any, perm os.FileMode, indent bool) error {
+	var out []byte
+	var err error
+	if indent {
+		out, err = json.MarshalIndent(data, "", "  ")
+	} else {
+		out, err = json.Marshal(data)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Write through symlinks, rather than replacing the link with a regular file.
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	return t.writeFileAtomic(path, out, perm)
+}
+
+// writeFileAtomic writes data to a temporary file next to path, and renames it to path.
+func (t *Tools) writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+
+	err := t.CreateDirIfNotExist(dir)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	// Make sure the temp file does not outlive a failure.
+	ok := false
+	defer func() {
+		if !ok {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		return err
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		return err
+	}
+
+	err = tmp.Chmod(perm)
+	if err != nil {
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("unable to replace %s: %w", path, err)
+	}
+	ok = true
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package toolbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type jsonFileConfig struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestTools_WriteJSONFile(t *testing.T) {
+	var testTools Tools
+
+	path := filepath.Join(t.TempDir(), "nested", "config.json")
+
+	err := testTools.WriteJSONFile(path, jsonFileConfig{Name: "foo", Count: 3}, 0600, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("wrong permissions; expected 0600 but got %o", info.Mode().Perm())
+	}
+
+	var cfg jsonFileConfig
+	err = testTools.ReadJSONFile(path, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Name != "foo" || cfg.Count != 3 {
+		t.Errorf("wrong values read back: %+v", cfg)
+	}
+}
+
+func TestTools_WriteJSONFileFailure(t *testing.T) {
+	var testTools Tools
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+
+	err := testTools.WriteJSONFile(path, jsonFileConfig{Name: "original"}, 0644, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a value that can't be marshalled must not touch the file
+	err = testTools.WriteJSONFile(path, make(chan int), 0644, false)
+	if err == nil {
+		t.Error("expected marshal error, but none received")
+	}
+
+	// a rename that fails (the destination is a non-empty directory) must not leave temp files behind
+	target := filepath.Join(dir, "dir.json")
+	_ = os.MkdirAll(filepath.Join(target, "child"), 0755)
+	err = testTools.WriteJSONFile(target, jsonFileConfig{Name: "new"}, 0644, false)
+	if err == nil {
+		t.Error("expected rename error, but none received")
+	}
+
+	var cfg jsonFileConfig
+	_ = testTools.ReadJSONFile(path, &cfg)
+	if cfg.Name != "original" {
+		t.Errorf("original file was modified: %+v", cfg)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("temp file left behind: %s", entry.Name())
+		}
+	}
+}
+
+func TestTools_WriteJSONFileSymlink(t *testing.T) {
+	var testTools Tools
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, "real.json")
+	link := filepath.Join(dir, "link.json")
+
+	_ = os.WriteFile(target, []byte(`{}`), 0644)
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	err := testTools.WriteJSONFile(link, jsonFileConfig{Name: "linked"}, 0644, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, _ := os.Lstat(link)
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Error("symlink was replaced by a regular file")
+	}
+
+	var cfg jsonFileConfig
+	_ = testTools.ReadJSONFile(target, &cfg)
+	if cfg.Name != "linked" {
+		t.Errorf("symlink target not updated: %+v", cfg)
+	}
+}
+
+func TestTools_ReadJSONFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	_ = os.WriteFile(path, []byte(`{"name": "foo", "extra": true}`), 0644)
+
+	var testTools Tools
+	var cfg jsonFileConfig
+
+	err := testTools.ReadJSONFile(path, &cfg)
+	if err == nil || !strings.Contains(err.Error(), path) || !strings.Contains(err.Error(), "unknown key") {
+		t.Errorf("expected unknown key error including the path, but got %v", err)
+	}
+
+	testTools.AllowUnknownFields = true
+	err = testTools.ReadJSONFile(path, &cfg)
+	if err != nil {
+		t.Errorf("unexpected error with AllowUnknownFields: %v", err)
+	}
+
+	err = testTools.ReadJSONFile(filepath.Join(dir, "missing.json"), &cfg)
+	if err == nil {
+		t.Error("expected error for missing file, but none received")
+	}
+}
@@ -0,0 +1,84 @@
+package toolbox
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+// fallbackLockRetry is how long lockWithFile waits before trying again to create a lock file that
+// another caller holds.
+const fallbackLockRetry = 5 * time.Millisecond
+
+// UpdateJSONFile performs a read-modify-write of the JSON file at path, safely, even with other
+// goroutines and processes doing the same. It takes an exclusive lock, reads the file into dst
+// (which must be a pointer, and is reset to its zero value first), calls update, and, if update
+// returns nil, writes dst back with WriteJSONFile, so the file is replaced atomically. A missing or
+// empty file leaves dst at its zero value. If update returns an error, the file is left alone, and
+// the error is returned. The lock is released however UpdateJSONFile returns, including when update
+// panics.
+//
+// The lock is an advisory lock (flock on Unix, LockFileEx on Windows) on a file named path+".lock",
+// next to the JSON file; where those aren't available, the lock file itself, created exclusively,
+// is the lock. It only protects against callers that also use UpdateJSONFile.
+func (t *Tools) UpdateJSONFile(path string, dst any, update func() error, perm os.FileMode) error {
+	err := checkDestination("UpdateJSONFile", dst)
+	if err != nil {
+		return err
+	}
+
+	err = t.CreateDirIfNotExist(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return fmt.Errorf("unable to lock %s: %w", path, err)
+	}
+	defer unlock()
+
+	v := reflect.ValueOf(dst).Elem()
+	v.Set(reflect.Zero(v.Type()))
+
+	info, err := os.Stat(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	case info.Size() > 0:
+		err = t.ReadJSONFile(path, dst)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = update()
+	if err != nil {
+		return err
+	}
+
+	return t.WriteJSONFile(path, dst, perm, false)
+}
+
+// lockWithFile takes a lock by creating lockPath, which must not already exist, waiting for as long
+// as it takes for whoever holds the lock to remove it. It is the lock used where no advisory locking
+// is available; a lock file left behind by a crashed process has to be removed by hand.
+func lockWithFile(lockPath string) (func(), error) {
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			_ = f.Close()
+			return func() { _ = os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+
+		time.Sleep(fallbackLockRetry)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !(darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || windows)
+
+package toolbox
+
+// lockFile takes a lock with lockWithFile, as there is no advisory locking to use here.
+func lockFile(lockPath string) (func(), error) {
+	return lockWithFile(lockPath)
+}
@@ -0,0 +1,165 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTools_UpdateJSONFile(t *testing.T) {
+	var testTools Tools
+
+	path := filepath.Join(t.TempDir(), "state", "counter.json")
+
+	// Read the file continuously while it is being updated; it must always be valid JSON.
+	done := make(chan struct{})
+	readerErr := make(chan error, 1)
+	go func() {
+		defer close(readerErr)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			data, err := os.ReadFile(path)
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				readerErr <- err
+				return
+			}
+			if !json.Valid(data) {
+				readerErr <- errors.New("invalid JSON: " + string(data))
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var cfg jsonFileConfig
+			err := testTools.UpdateJSONFile(path, &cfg, func() error {
+				cfg.Count++
+				return nil
+			}, 0600)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	close(done)
+	if err := <-readerErr; err != nil {
+		t.Error(err)
+	}
+
+	var cfg jsonFileConfig
+	err := testTools.ReadJSONFile(path, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Count != 50 {
+		t.Errorf("expected a count of 50, but got %d", cfg.Count)
+	}
+}
+
+func TestTools_UpdateJSONFileFailures(t *testing.T) {
+	var testTools Tools
+
+	path := filepath.Join(t.TempDir(), "counter.json")
+
+	// A missing file starts from the zero value, even if dst had something in it.
+	cfg := jsonFileConfig{Name: "stale", Count: 10}
+	err := testTools.UpdateJSONFile(path, &cfg, func() error {
+		if cfg.Name != "" || cfg.Count != 0 {
+			t.Errorf("expected the zero value for a missing file, but got %+v", cfg)
+		}
+		cfg.Count = 1
+		return nil
+	}, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An error from update leaves the file alone.
+	errNope := errors.New("nope")
+	err = testTools.UpdateJSONFile(path, &cfg, func() error {
+		cfg.Count = 100
+		return errNope
+	}, 0600)
+	if !errors.Is(err, errNope) {
+		t.Errorf("expected the error from update, but got %v", err)
+	}
+
+	// A panic in update releases the lock, or the update after it would never finish.
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the panic to be passed on")
+			}
+		}()
+		_ = testTools.UpdateJSONFile(path, &cfg, func() error { panic("boom") }, 0600)
+	}()
+
+	err = testTools.UpdateJSONFile(path, &cfg, func() error {
+		cfg.Count++
+		return nil
+	}, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Count != 2 {
+		t.Errorf("expected a count of 2, but got %d", cfg.Count)
+	}
+
+	var invalid *InvalidDestinationError
+	if err := testTools.UpdateJSONFile(path, cfg, func() error { return nil }, 0600); !errors.As(err, &invalid) {
+		t.Errorf("expected an *InvalidDestinationError, but got %v", err)
+	}
+}
+
+func TestLockWithFile(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "counter.json.lock")
+
+	var wg sync.WaitGroup
+	var held, count atomic.Int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			unlock, err := lockWithFile(lockPath)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer unlock()
+
+			if held.Add(1) != 1 {
+				t.Error("the lock was held twice")
+			}
+			count.Add(1)
+			held.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	if count.Load() != 20 {
+		t.Errorf("expected 20 locks, but got %d", count.Load())
+	}
+	if _, err := os.Stat(lockPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the lock file to be removed, but got %v", err)
+	}
+}
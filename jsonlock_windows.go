@@ -0,0 +1,41 @@
+//go:build windows
+
+package toolbox
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modkernel32      = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = modkernel32.NewProc("LockFileEx")
+	procUnlockFileEx = modkernel32.NewProc("UnlockFileEx")
+)
+
+// lockfileExclusiveLock is LOCKFILE_EXCLUSIVE_LOCK, for LockFileEx.
+const lockfileExclusiveLock = 0x2
+
+// lockFile takes an exclusive LockFileEx lock on lockPath, creating it if need be, and returns a
+// function that releases it.
+func lockFile(lockPath string) (func(), error) {
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lock the first byte, which is enough, as everyone locks the same one.
+	var overlapped syscall.Overlapped
+	r1, _, e1 := syscall.SyscallN(procLockFileEx.Addr(), f.Fd(), lockfileExclusiveLock, 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if r1 == 0 {
+		_ = f.Close()
+		return nil, e1
+	}
+
+	return func() {
+		var overlapped syscall.Overlapped
+		_, _, _ = syscall.SyscallN(procUnlockFileEx.Addr(), f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+		_ = f.Close()
+	}, nil
+}
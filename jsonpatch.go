@@ -0,0 +1,486 @@
+package toolbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrJSONPatchTestFailed is returned, wrapped, when a test operation in a JSON Patch doesn't match
+// the document. Handlers usually respond to it with 409 Conflict.
+var ErrJSONPatchTestFailed = errors.New("test operation failed")
+
+// errPatchPathNotFound is returned when an operation refers to a location that doesn't exist.
+var errPatchPathNotFound = errors.New("path does not exist")
+
+// PatchOp is a single operation in an RFC 6902 JSON Patch document.
+type PatchOp struct {
+	Op    string          `json:"op"`              // add, remove, replace, move, copy or test
+	Path  string          `json:"path"`            // a JSON Pointer to the location to change
+	From  string          `json:"from,omitempty"`  // for move and copy, a JSON Pointer to the source location
+	Value json.RawMessage `json:"value,omitempty"` // for add, replace and test, the value; nil if it was not sent
+}
+
+// ReadJSONPatch reads an RFC 6902 JSON Patch document from the request body, which must have a
+// Content-Type of application/json-patch+json or application/json, if one is set. The body is
+// limited to MaxJSONSize, like ReadJSON, and each operation is checked to be well formed, so that
+// the ops can be handed straight to ApplyPatchOps.
+func (t *Tools) ReadJSONPatch(w http.ResponseWriter, r *http.Request) ([]PatchOp, error) {
+	if r.Header.Get("Content-Type") != "" && !hasMediaType(r.Header.Get("Content-Type"), "application/json-patch+json", "application/json") {
+		return nil, errors.New("the Content-Type header is not application/json-patch+json")
+	}
+
+	maxBytes := t.maxJSONSize(r.Context())
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	// Members that aren't part of an operation must be ignored, so AllowUnknownFields doesn't apply.
+	dec := json.NewDecoder(r.Body)
+
+	var ops []PatchOp
+	err := dec.Decode(&ops)
+	if err != nil {
+		var unmarshalTypeError *json.UnmarshalTypeError
+		if errors.As(err, &unmarshalTypeError) && unmarshalTypeError.Field == "" {
+			return nil, errors.New("body must be a JSON array of patch operations")
+		}
+		return nil, classifyJSONError(err, maxBytes)
+	}
+
+	err = dec.Decode(&struct{}{})
+	if err != io.EOF {
+		return nil, errMultipleJSONValues()
+	}
+
+	err = validatePatchOps(ops)
+	if err != nil {
+		return nil, err
+	}
+
+	return ops, nil
+}
+
+// ApplyJSONPatch applies the RFC 6902 JSON Patch document patch to the JSON document original, and
+// returns the patched document. See ApplyPatchOps.
+func ApplyJSONPatch(original []byte, patch []byte) ([]byte, error) {
+	var ops []PatchOp
+	err := json.Unmarshal(patch, &ops)
+	if err != nil {
+		return nil, errors.New("the patch must be a JSON array of patch operations")
+	}
+
+	return ApplyPatchOps(original, ops)
+}
+
+// ApplyPatchOps applies ops, in order, to the JSON document original, and returns the patched
+// document. The patch is applied as a whole or not at all: if any operation fails, an error naming
+// it is returned, and nothing else. A failed test operation returns an error wrapping
+// ErrJSONPatchTestFailed. Object keys in the result are sorted.
+func ApplyPatchOps(original []byte, ops []PatchOp) ([]byte, error) {
+	err := validatePatchOps(ops)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := decodeJSONValue(original)
+	if err != nil {
+		return nil, errors.New("the original document is not valid JSON")
+	}
+
+	for i, op := range ops {
+		doc, err = applyPatchOp(doc, op)
+		if err != nil {
+			return nil, fmt.Errorf("json patch operation %d (%s %s): %w", i+1, op.Op, op.Path, err)
+		}
+	}
+
+	return encodeJSONValue(doc)
+}
+
+// validatePatchOps checks that every operation has the members it needs.
+func validatePatchOps(ops []PatchOp) error {
+	for i, op := range ops {
+		err := validatePatchOp(op)
+		if err != nil {
+			return fmt.Errorf("json patch operation %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func validatePatchOp(op PatchOp) error {
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return fmt.Errorf("%s needs a value", op.Op)
+		}
+	case "move", "copy":
+		if _, err := parseJSONPointer(op.From); err != nil {
+			return fmt.Errorf("invalid from: %w", err)
+		}
+	case "remove":
+	case "":
+		return errors.New("missing op")
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+
+	if _, err := parseJSONPointer(op.Path); err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+
+	return nil
+}
+
+// applyPatchOp applies a single operation to doc, and returns the new document.
+func applyPatchOp(doc any, op PatchOp) (any, error) {
+	path, _ := parseJSONPointer(op.Path)
+
+	switch op.Op {
+	case "add":
+		value, err := decodeJSONValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, path, value)
+
+	case "remove":
+		if len(path) == 0 {
+			return nil, errors.New("the whole document cannot be removed")
+		}
+		doc, _, err := patchRemove(doc, path)
+		return doc, err
+
+	case "replace":
+		value, err := decodeJSONValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		doc, _, err = patchRemove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, path, value)
+
+	case "move":
+		from, _ := parseJSONPointer(op.From)
+		if len(from) < len(path) && isPointerPrefix(from, path) {
+			return nil, errors.New("a value cannot be moved into one of its own children")
+		}
+		if len(from) == 0 {
+			return nil, errors.New("the whole document cannot be moved")
+		}
+		doc, value, err := patchRemove(doc, from)
+		if err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		return patchAdd(doc, path, value)
+
+	case "copy":
+		from, _ := parseJSONPointer(op.From)
+		value, err := patchGet(doc, from)
+		if err != nil {
+			return nil, fmt.Errorf("from: %w", err)
+		}
+		return patchAdd(doc, path, copyJSONValue(value))
+
+	case "test":
+		expected, err := decodeJSONValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		actual, err := patchGet(doc, path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrJSONPatchTestFailed, err)
+		}
+		if !jsonValuesEqual(actual, expected) {
+			return nil, ErrJSONPatchTestFailed
+		}
+		return doc, nil
+	}
+
+	return nil, fmt.Errorf("unknown op %q", op.Op)
+}
+
+// patchGet returns the value at path in doc.
+func patchGet(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, errPatchPathNotFound
+			}
+			doc = value
+		case []any:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, errPatchPathNotFound
+		}
+	}
+	return doc, nil
+}
+
+// patchAdd adds value at path in doc, inserting it if the parent is an array, and returns the new
+// document.
+func patchAdd(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	token := path[0]
+
+	switch node := doc.(type) {
+	case map[string]any:
+		if len(path) == 1 {
+			node[token] = value
+			return node, nil
+		}
+		child, ok := node[token]
+		if !ok {
+			return nil, errPatchPathNotFound
+		}
+		child, err := patchAdd(child, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = child
+		return node, nil
+
+	case []any:
+		if len(path) == 1 {
+			i := len(node)
+			if token != "-" {
+				var err error
+				i, err = arrayIndex(token, len(node), true)
+				if err != nil {
+					return nil, err
+				}
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+		i, err := arrayIndex(token, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := patchAdd(node[i], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+	}
+
+	return nil, errPatchPathNotFound
+}
+
+// patchRemove removes the value at path in doc, which must exist, and returns the new document and
+// the value that was removed.
+func patchRemove(doc any, path []string) (any, any, error) {
+	token := path[0]
+
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[token]
+		if !ok {
+			return nil, nil, errPatchPathNotFound
+		}
+		if len(path) == 1 {
+			delete(node, token)
+			return node, child, nil
+		}
+		child, removed, err := patchRemove(child, path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		node[token] = child
+		return node, removed, nil
+
+	case []any:
+		i, err := arrayIndex(token, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(path) == 1 {
+			removed := node[i]
+			return append(node[:i], node[i+1:]...), removed, nil
+		}
+		child, removed, err := patchRemove(node[i], path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		node[i] = child
+		return node, removed, nil
+	}
+
+	return nil, nil, errPatchPathNotFound
+}
+
+// arrayIndex parses token as an index into an array of length n. If allowEnd is true, n itself is
+// allowed, for adding to the end of the array.
+func arrayIndex(token string, n int, allowEnd bool) (int, error) {
+	if token == "-" {
+		return 0, errors.New(`the "-" index can only be used to add to an array`)
+	}
+
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i > n || (i == n && !allowEnd) {
+		return 0, fmt.Errorf("array index %s is out of bounds", token)
+	}
+
+	return i, nil
+}
+
+// parseJSONPointer splits an RFC 6901 JSON Pointer into its unescaped reference tokens. The empty
+// pointer refers to the whole document, and returns no tokens.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("JSON pointer %q must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, fmt.Errorf("JSON pointer %q has an invalid ~ escape", pointer)
+			}
+		}
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+// isPointerPrefix reports whether the tokens of prefix are the first tokens of path.
+func isPointerPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeJSONValue decodes a single JSON value, keeping numbers exactly as they were written.
+func decodeJSONValue(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	err := dec.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+
+	if dec.Decode(&struct{}{}) != io.EOF {
+		return nil, errors.New("more than one JSON value")
+	}
+
+	return v, nil
+}
+
+// encodeJSONValue encodes a document built by decodeJSONValue, without escaping HTML characters,
+// which the original document didn't need either.
+func encodeJSONValue(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	err := enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// copyJSONValue returns a deep copy of a value built by decodeJSONValue.
+func copyJSONValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			m[key] = copyJSONValue(value)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, value := range v {
+			s[i] = copyJSONValue(value)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// jsonValuesEqual reports whether two values built by decodeJSONValue are equal, as RFC 6902 defines
+// it for the test operation: numbers are compared by value, and objects regardless of key order.
+func jsonValuesEqual(a, b any) bool {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for key, value := range a {
+			other, ok := b[key]
+			if !ok || !jsonValuesEqual(value, other) {
+				return false
+			}
+		}
+		return true
+
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonValuesEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if a == b {
+			return true
+		}
+		x, okA := new(big.Rat).SetString(string(a))
+		y, okB := new(big.Rat).SetString(string(b))
+		return okA && okB && x.Cmp(y) == 0
+
+	default:
+		return a == b
+	}
+}
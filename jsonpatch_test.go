@@ -0,0 +1,141 @@
+package toolbox
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var jsonPatchTests = []struct {
+	name          string
+	original      string
+	patch         string
+	expected      string
+	errorExpected string
+}{
+	{name: "add member", original: `{"a":1}`, patch: `[{"op":"add","path":"/b","value":[1,2]}]`, expected: `{"a":1,"b":[1,2]}`},
+	{name: "add replaces member", original: `{"a":1}`, patch: `[{"op":"add","path":"/a","value":null}]`, expected: `{"a":null}`},
+	{name: "add array insert", original: `{"a":[1,3]}`, patch: `[{"op":"add","path":"/a/1","value":2}]`, expected: `{"a":[1,2,3]}`},
+	{name: "add array end", original: `{"a":[1]}`, patch: `[{"op":"add","path":"/a/-","value":2}]`, expected: `{"a":[1,2]}`},
+	{name: "add array at length", original: `{"a":[1]}`, patch: `[{"op":"add","path":"/a/1","value":2}]`, expected: `{"a":[1,2]}`},
+	{name: "add array out of bounds", original: `{"a":[1]}`, patch: `[{"op":"add","path":"/a/3","value":2}]`, errorExpected: "out of bounds"},
+	{name: "add leading zero", original: `{"a":[1]}`, patch: `[{"op":"add","path":"/a/01","value":2}]`, errorExpected: "invalid array index"},
+	{name: "add missing parent", original: `{}`, patch: `[{"op":"add","path":"/a/b","value":1}]`, errorExpected: "path does not exist"},
+	{name: "add whole document", original: `{"a":1}`, patch: `[{"op":"add","path":"","value":[true]}]`, expected: `[true]`},
+	{name: "remove member", original: `{"a":1,"b":2}`, patch: `[{"op":"remove","path":"/a"}]`, expected: `{"b":2}`},
+	{name: "remove array element", original: `[1,2,3]`, patch: `[{"op":"remove","path":"/1"}]`, expected: `[1,3]`},
+	{name: "remove missing", original: `{}`, patch: `[{"op":"remove","path":"/a"}]`, errorExpected: "path does not exist"},
+	{name: "remove out of bounds", original: `[1]`, patch: `[{"op":"remove","path":"/1"}]`, errorExpected: "out of bounds"},
+	{name: "remove dash", original: `[1]`, patch: `[{"op":"remove","path":"/-"}]`, errorExpected: `"-" index`},
+	{name: "replace", original: `{"a":{"b":1}}`, patch: `[{"op":"replace","path":"/a/b","value":"x"}]`, expected: `{"a":{"b":"x"}}`},
+	{name: "replace array element", original: `[1,2]`, patch: `[{"op":"replace","path":"/0","value":9}]`, expected: `[9,2]`},
+	{name: "replace missing", original: `{}`, patch: `[{"op":"replace","path":"/a","value":1}]`, errorExpected: "path does not exist"},
+	{name: "move", original: `{"a":{"b":1},"c":[]}`, patch: `[{"op":"move","from":"/a/b","path":"/c/-"}]`, expected: `{"a":{},"c":[1]}`},
+	{name: "move into child", original: `{"a":{"b":1}}`, patch: `[{"op":"move","from":"/a","path":"/a/b"}]`, errorExpected: "own children"},
+	{name: "copy", original: `{"a":{"b":1}}`, patch: `[{"op":"copy","from":"/a","path":"/c"},{"op":"replace","path":"/c/b","value":2}]`, expected: `{"a":{"b":1},"c":{"b":2}}`},
+	{name: "test passes", original: `{"a":[1,{"b":2.0}]}`, patch: `[{"op":"test","path":"/a","value":[1,{"b":2}]}]`, expected: `{"a":[1,{"b":2.0}]}`},
+	{name: "test fails", original: `{"a":"x"}`, patch: `[{"op":"test","path":"/a","value":"y"}]`, errorExpected: "test operation failed"},
+	{name: "test missing", original: `{}`, patch: `[{"op":"test","path":"/a","value":null}]`, errorExpected: "test operation failed"},
+	{name: "escaped pointer", original: `{"a/b":1,"m~n":2}`, patch: `[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`, expected: `{"a/b":3}`},
+	{name: "bad escape", original: `{}`, patch: `[{"op":"add","path":"/a~2","value":1}]`, errorExpected: "invalid ~ escape"},
+	{name: "no leading slash", original: `{}`, patch: `[{"op":"add","path":"a","value":1}]`, errorExpected: "must start with /"},
+	{name: "missing value", original: `{}`, patch: `[{"op":"add","path":"/a"}]`, errorExpected: "add needs a value"},
+	{name: "unknown op", original: `{}`, patch: `[{"op":"frobnicate","path":"/a"}]`, errorExpected: `unknown op "frobnicate"`},
+	{name: "html untouched", original: `{"a":"<b>"}`, patch: `[]`, expected: `{"a":"<b>"}`},
+	{name: "patch not an array", original: `{}`, patch: `{"op":"add"}`, errorExpected: "must be a JSON array"},
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	for _, e := range jsonPatchTests {
+		out, err := ApplyJSONPatch([]byte(e.original), []byte(e.patch))
+
+		if e.errorExpected != "" {
+			if err == nil || !strings.Contains(err.Error(), e.errorExpected) {
+				t.Errorf("%s: expected error containing %q, but got %v", e.name, e.errorExpected, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		if string(out) != e.expected {
+			t.Errorf("%s: expected %s, but got %s", e.name, e.expected, out)
+		}
+	}
+}
+
+func TestApplyJSONPatchAtomic(t *testing.T) {
+	original := []byte(`{"a":[1,2],"b":"x"}`)
+	patch := []byte(`[
+		{"op":"remove","path":"/a/0"},
+		{"op":"replace","path":"/b","value":"y"},
+		{"op":"test","path":"/b","value":"z"}
+	]`)
+
+	out, err := ApplyJSONPatch(original, patch)
+	if !errors.Is(err, ErrJSONPatchTestFailed) {
+		t.Fatalf("expected ErrJSONPatchTestFailed, but got %v", err)
+	}
+	if !strings.Contains(err.Error(), "operation 3") {
+		t.Errorf("expected the error to name the failed operation, but got %v", err)
+	}
+	if out != nil {
+		t.Errorf("expected no output, but got %s", out)
+	}
+	if string(original) != `{"a":[1,2],"b":"x"}` {
+		t.Errorf("original was changed: %s", original)
+	}
+}
+
+var readJSONPatchTests = []struct {
+	name          string
+	contentType   string
+	body          string
+	maxSize       int
+	ops           int
+	errorExpected string
+}{
+	{name: "valid", contentType: "application/json-patch+json", body: `[{"op":"remove","path":"/a","extra":true}]`, ops: 1},
+	{name: "plain json", contentType: "application/json; charset=utf-8", body: `[]`},
+	{name: "wrong content type", contentType: "application/merge-patch+json", body: `[]`, errorExpected: "not application/json-patch+json"},
+	{name: "too large", body: `[{"op":"remove","path":"/aaaaaaaaaa"}]`, maxSize: 10, errorExpected: "must not be larger than 10 bytes"},
+	{name: "not an array", body: `{"op":"remove"}`, errorExpected: "must be a JSON array"},
+	{name: "missing op", body: `[{"path":"/a"}]`, errorExpected: "json patch operation 1: missing op"},
+	{name: "missing from", body: `[{"op":"remove","path":"/a"},{"op":"move","from":"a","path":"/b"}]`, errorExpected: "json patch operation 2: invalid from"},
+	{name: "two values", body: `[] []`, errorExpected: "single JSON value"},
+}
+
+func TestTools_ReadJSONPatch(t *testing.T) {
+	for _, e := range readJSONPatchTests {
+		testTools := Tools{MaxJSONSize: e.maxSize}
+
+		req, _ := http.NewRequest(http.MethodPatch, "/", bytes.NewReader([]byte(e.body)))
+		if e.contentType != "" {
+			req.Header.Set("Content-Type", e.contentType)
+		}
+
+		ops, err := testTools.ReadJSONPatch(httptest.NewRecorder(), req)
+
+		if e.errorExpected != "" {
+			if err == nil || !strings.Contains(err.Error(), e.errorExpected) {
+				t.Errorf("%s: expected error containing %q, but got %v", e.name, e.errorExpected, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		if len(ops) != e.ops {
+			t.Errorf("%s: expected %d ops, but got %d", e.name, e.ops, len(ops))
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package toolbox
+
+import (
+	"context"
+	"net/http"
+)
+
+// The context keys for the per-request limits.
+type (
+	maxFileSizeKey      struct{}
+	maxJSONSizeKey      struct{}
+	maxXMLSizeKey       struct{}
+	allowedFileTypesKey struct{}
+)
+
+// WithMaxFileSize returns a copy of ctx that overrides MaxFileSize with n bytes for any upload read
+// from a request carrying it. It lets middleware that knows about the user decide the limit, with a
+// single Tools for the whole application:
+//
+//	if isAdmin(r) {
+//		r = r.WithContext(toolbox.WithMaxFileSize(r.Context(), 1<<30))
+//	}
+//
+// For every limit that can be set on the context, the order of precedence is: the UploadOptions
+// given to the call, if any, then the request context, then the Tools field, and then the default.
+func WithMaxFileSize(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, maxFileSizeKey{}, n)
+}
+
+// WithMaxJSONSize returns a copy of ctx that overrides MaxJSONSize with n bytes for any JSON read
+// from a request carrying it.
+func WithMaxJSONSize(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, maxJSONSizeKey{}, n)
+}
+
+// WithMaxXMLSize returns a copy of ctx that overrides MaxXMLSize with n bytes for any XML read from
+// a request carrying it.
+func WithMaxXMLSize(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, maxXMLSizeKey{}, n)
+}
+
+// WithAllowedFileTypes returns a copy of ctx that overrides AllowedFileTypes for any upload read
+// from a request carrying it.
+func WithAllowedFileTypes(ctx context.Context, types []string) context.Context {
+	return context.WithValue(ctx, allowedFileTypesKey{}, types)
+}
+
+// contextLimit returns the positive limit stored in ctx under key, or zero.
+func contextLimit(ctx context.Context, key any) int {
+	n, _ := ctx.Value(key).(int)
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// firstLimit returns the first of limits that is set, or the default.
+func firstLimit(limits ...int) int {
+	for _, n := range limits {
+		if n != 0 {
+			return n
+		}
+	}
+	return defaultMaxUpload
+}
+
+// maxJSONSize returns the largest JSON body we'll read for a request with context ctx.
+func (t *Tools) maxJSONSize(ctx context.Context) int {
+	return firstLimit(contextLimit(ctx, maxJSONSizeKey{}), t.MaxJSONSize)
+}
+
+// maxXMLSize returns the largest XML body we'll read for a request with context ctx.
+func (t *Tools) maxXMLSize(ctx context.Context) int {
+	return firstLimit(contextLimit(ctx, maxXMLSizeKey{}), t.MaxXMLSize)
+}
+
+// maxFormSize returns the limit on form bodies read by ReadForm and ReadBody: MaxFormSize, or the
+// default.
+func (t *Tools) maxFormSize() int {
+	if t.MaxFormSize > 0 {
+		return t.MaxFormSize
+	}
+	return defaultMaxUpload
+}
+
+// declaredTooLarge reports whether r says, with its Content-Length, that its body is larger than
+// maxBytes, so that it can be rejected without reading any of it. If so, and w isn't nil, the
+// connection is closed after the response, so that the server doesn't read the body just to throw it
+// away. Bodies of unknown length (chunked, for example) are left to an http.MaxBytesReader.
+func declaredTooLarge(w http.ResponseWriter, r *http.Request, maxBytes int) bool {
+	if r.ContentLength <= int64(maxBytes) {
+		return false
+	}
+
+	if w != nil {
+		w.Header().Set("Connection", "close")
+	}
+	return true
+}
@@ -0,0 +1,244 @@
+package toolbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTools_ReadJSONContextLimit(t *testing.T) {
+	var testTools Tools
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Foo string `json:"foo"`
+		}
+		err := testTools.ReadJSON(w, r, &payload)
+		if err != nil {
+			_ = testTools.ErrorJSON(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	// The middleware only limits requests that ask for it.
+	limit := func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("small") != "" {
+				r = r.WithContext(WithMaxJSONSize(r.Context(), 1024))
+			}
+			next(w, r)
+		}
+	}
+	h := limit(handler)
+
+	body := `{"foo":"` + strings.Repeat("a", 2048) + `"}`
+
+	rr := httptest.NewRecorder()
+	h(rr, httptest.NewRequest(http.MethodPost, "/?small=1", strings.NewReader(body)))
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "larger than 1024 bytes") {
+		t.Errorf("expected the context limit to reject the body, but got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	h(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("expected the next request to fall back to the default limit, but got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestTools_ReadJSONContextLimitOverridesTools(t *testing.T) {
+	testTools := Tools{MaxJSONSize: 10}
+
+	body := `{"foo":"` + strings.Repeat("a", 100) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req = req.WithContext(WithMaxJSONSize(req.Context(), 1024))
+
+	var payload map[string]string
+	err := testTools.ReadJSON(httptest.NewRecorder(), req, &payload)
+	if err != nil {
+		t.Errorf("expected the context to raise MaxJSONSize, but got %v", err)
+	}
+}
+
+func TestTools_ReadJSONWithLimit(t *testing.T) {
+	testTools := Tools{MaxJSONSize: 64}
+
+	small := `{"foo":"` + strings.Repeat("a", 100) + `"}`
+	large := `{"foo":"` + strings.Repeat("a", 2000) + `"}`
+
+	// The same Tools is used at once with different limits, none of which change it.
+	var wg sync.WaitGroup
+	errs := make([]error, 20)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var payload map[string]string
+			if i%2 == 0 {
+				req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(large))
+				errs[i] = testTools.ReadJSONWithLimit(httptest.NewRecorder(), req, &payload, 4096)
+			} else {
+				req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(small))
+				errs[i] = testTools.ReadJSONWithLimit(httptest.NewRecorder(), req, &payload, 100)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if i%2 == 0 && err != nil {
+			t.Errorf("%d: expected the larger limit to accept the body, but got %v", i, err)
+		}
+		if i%2 == 1 && (err == nil || err.Error() != "body must not be larger than 100 bytes") {
+			t.Errorf("%d: expected the error to give the limit for the call, but got %v", i, err)
+		}
+	}
+	if testTools.MaxJSONSize != 64 {
+		t.Errorf("expected MaxJSONSize to be left alone, but it is %d", testTools.MaxJSONSize)
+	}
+
+	// It also takes precedence over the context, and is ignored if it isn't positive.
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(large))
+	req = req.WithContext(WithMaxJSONSize(req.Context(), 10))
+	var payload map[string]string
+	if err := testTools.ReadJSONWithLimit(httptest.NewRecorder(), req, &payload, 4096); err != nil {
+		t.Errorf("expected the limit for the call to override the context, but got %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(small))
+	if err := testTools.ReadJSONWithLimit(httptest.NewRecorder(), req, &payload, 0); err == nil || err.Error() != "body must not be larger than 64 bytes" {
+		t.Errorf("expected MaxJSONSize to apply, but got %v", err)
+	}
+}
+func TestTools_ReadXMLContextLimit(t *testing.T) {
+	var testTools Tools
+
+	body := `<note><to>` + strings.Repeat("a", 100) + `</to></note>`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req = req.WithContext(WithMaxXMLSize(req.Context(), 50))
+
+	var payload struct {
+		To string `xml:"to"`
+	}
+	err := testTools.ReadXML(httptest.NewRecorder(), req, &payload)
+	if err == nil {
+		t.Error("expected the context limit to reject the body, but it was read")
+	}
+}
+
+var uploadContextLimitTests = []struct {
+	name          string
+	maxFileSize   int
+	allowedTypes  []string
+	errorExpected bool
+}{
+	{name: "no overrides"},
+	{name: "too big for the context", maxFileSize: 1024, errorExpected: true},
+	{name: "type allowed by the context", allowedTypes: []string{"image/png"}},
+	{name: "type not allowed by the context", allowedTypes: []string{"image/gif"}, errorExpected: true},
+}
+
+func TestTools_UploadFilesContextLimits(t *testing.T) {
+	for _, e := range uploadContextLimitTests {
+		request, err := NewMultipartRequest("/", map[string]string{"file": "./testdata/img.png"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx := context.Background()
+		if e.maxFileSize > 0 {
+			ctx = WithMaxFileSize(ctx, e.maxFileSize)
+		}
+		if e.allowedTypes != nil {
+			ctx = WithAllowedFileTypes(ctx, e.allowedTypes)
+		}
+		request = request.WithContext(ctx)
+
+		testTools := Tools{AllowedFileTypes: []string{"image/jpeg", "image/png"}}
+		_, err = testTools.UploadFiles(request, t.TempDir())
+		if e.errorExpected && err == nil {
+			t.Errorf("%s: error expected, but none received", e.name)
+		}
+		if !e.errorExpected && err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+		}
+	}
+}
+
+// unreadBody fails the test if it is read.
+type unreadBody struct {
+	t *testing.T
+}
+
+func (b unreadBody) Read(p []byte) (int, error) {
+	b.t.Error("the body was read")
+	return 0, io.EOF
+}
+
+func TestTools_DeclaredContentLengthTooLarge(t *testing.T) {
+	testTools := Tools{MaxJSONSize: 1024, MaxXMLSize: 1024, MaxTotalUploadSize: 1024}
+
+	read := map[string]func(w http.ResponseWriter, r *http.Request) error{
+		"ReadJSON": func(w http.ResponseWriter, r *http.Request) error {
+			var payload struct{}
+			return testTools.ReadJSON(w, r, &payload)
+		},
+		"ReadXML": func(w http.ResponseWriter, r *http.Request) error {
+			var payload struct{}
+			return testTools.ReadXML(w, r, &payload)
+		},
+		"UploadFiles": func(w http.ResponseWriter, r *http.Request) error {
+			r.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+			_, err := testTools.UploadFiles(r, t.TempDir())
+			return err
+		},
+	}
+
+	for name, fn := range read {
+		req := httptest.NewRequest(http.MethodPost, "/", unreadBody{t: t})
+		req.ContentLength = 500 << 20
+		rr := httptest.NewRecorder()
+
+		err := fn(rr, req)
+
+		var tooLarge *BodyTooLargeError
+		if !errors.As(err, &tooLarge) || tooLarge.Limit != 1024 || !errors.Is(err, ErrBodyTooLarge) {
+			t.Errorf("%s: expected a *BodyTooLargeError, but got %v", name, err)
+		}
+		if name == "UploadFiles" {
+			if !errors.Is(err, ErrTotalSizeExceeded) {
+				t.Errorf("%s: expected ErrTotalSizeExceeded, but got %v", name, err)
+			}
+		} else if rr.Header().Get("Connection") != "close" {
+			t.Errorf("%s: expected the connection to be closed", name)
+		}
+	}
+}
+
+func TestTools_UploadFilesMaxTotalUploadSize(t *testing.T) {
+	testTools := Tools{MaxTotalUploadSize: 1024}
+
+	// Without a Content-Length, the limit is found while reading.
+	req, err := NewStreamingMultipartRequest("/", []MultipartFile{
+		{FieldName: "file", FileName: "a.txt", Content: strings.NewReader(strings.Repeat("a", 4096))},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploadDir := t.TempDir()
+	_, err = testTools.UploadFiles(req, uploadDir)
+	if !errors.Is(err, ErrTotalSizeExceeded) {
+		t.Errorf("expected ErrTotalSizeExceeded, but got %v", err)
+	}
+	if entries, _ := os.ReadDir(uploadDir); len(entries) != 0 {
+		t.Errorf("expected nothing to be saved, but found %d files", len(entries))
+	}
+}
@@ -0,0 +1,97 @@
+package toolbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// defaultHeartbeat is how often WriteJSONWhenReady sends a heartbeat, if no interval is given.
+const defaultHeartbeat = 15 * time.Second
+
+// WriteJSONWhenReady is for handlers that take a long time to produce a result, where proxies would
+// otherwise close the idle connection. It sends status and the headers at once, then runs produce,
+// sending (and flushing) a single byte of JSON whitespace (JSONHeartbeatByte) every heartbeat until
+// produce returns, and finally sends the value it returned as JSON, encoded as WriteJSON would. Since
+// the status has already been sent by then, if produce fails, the body is an ErrorJSON style payload
+// instead, and its message is treated as an internal error by SuppressInternalErrors.
+//
+// The context passed to produce is cancelled if the client goes away, in which case the context's
+// error is returned without waiting for produce. An error is also returned, before anything is
+// written, if w does not support flushing.
+func (t *Tools) WriteJSONWhenReady(w http.ResponseWriter, r *http.Request, status int, produce func(ctx context.Context) (any, error), heartbeat time.Duration) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New("streaming is not supported by this response writer")
+	}
+
+	if heartbeat <= 0 {
+		heartbeat = defaultHeartbeat
+	}
+
+	padding := t.JSONHeartbeatByte
+	if padding != ' ' && padding != '\n' && padding != '\r' && padding != '\t' {
+		padding = ' '
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	type result struct {
+		data any
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		data, err := produce(ctx)
+		done <- result{data, err}
+	}()
+
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", t.jsonContentType())
+	}
+	// Stop nginx and similar proxies from buffering the heartbeats.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(status)
+	flusher.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case <-ticker.C:
+			_, err := w.Write([]byte{padding})
+			if err != nil {
+				return err
+			}
+			flusher.Flush()
+
+		case res := <-done:
+			// produce usually returns as soon as the client goes away, so both cases may be ready.
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			out, err := t.marshalJSON(res.data)
+			if res.err == nil && err != nil {
+				res.err = err
+			}
+			if res.err != nil {
+				message := t.errorMessage(res.err, http.StatusInternalServerError, RequestIDFromContext(r.Context()))
+				out, err = t.marshalJSON(t.jsonEnvelope(true, message, nil))
+				if err != nil {
+					return err
+				}
+			}
+
+			_, err = w.Write(out)
+			flusher.Flush()
+			return err
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package toolbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTools_WriteJSONWhenReady(t *testing.T) {
+	testTools := Tools{JSONHeartbeatByte: '\n'}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	err := testTools.WriteJSONWhenReady(rr, req, http.StatusOK, func(ctx context.Context) (any, error) {
+		time.Sleep(100 * time.Millisecond)
+		return map[string]string{"status": "done"}, nil
+	}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := rr.Body.String()
+	heartbeats := len(body) - len(strings.TrimLeft(body, "\n"))
+	if heartbeats < 3 {
+		t.Errorf("expected several heartbeats, but got %d in %q", heartbeats, body)
+	}
+
+	var payload map[string]string
+	err = json.Unmarshal(rr.Body.Bytes(), &payload)
+	if err != nil || payload["status"] != "done" {
+		t.Errorf("final JSON did not parse: %v, %q", err, body)
+	}
+
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/json" || !rr.Flushed {
+		t.Errorf("wrong response: %d %v", rr.Code, rr.Header())
+	}
+}
+
+func TestTools_WriteJSONWhenReadyError(t *testing.T) {
+	var testTools Tools
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	err := testTools.WriteJSONWhenReady(rr, req, http.StatusAccepted, func(ctx context.Context) (any, error) {
+		return nil, errors.New("the report could not be built")
+	}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var payload JSONResponse
+	err = json.Unmarshal(rr.Body.Bytes(), &payload)
+	if err != nil || !payload.Error || payload.Message != "the report could not be built" {
+		t.Errorf("expected an error payload, but got %v, %q", err, rr.Body.String())
+	}
+}
+
+func TestTools_WriteJSONWhenReadyCancelled(t *testing.T) {
+	var testTools Tools
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+
+	stopped := make(chan struct{})
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	err := testTools.WriteJSONWhenReady(httptest.NewRecorder(), req, http.StatusOK, func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		close(stopped)
+		return nil, ctx.Err()
+	}, 5*time.Millisecond)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, but got %v", err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Error("produce was not cancelled")
+	}
+}
+
+func TestTools_WriteJSONWhenReadyNoFlusher(t *testing.T) {
+	var testTools Tools
+
+	w := &failingWriter{header: http.Header{}}
+	called := false
+	err := testTools.WriteJSONWhenReady(w, httptest.NewRequest(http.MethodGet, "/", nil), http.StatusOK, func(ctx context.Context) (any, error) {
+		called = true
+		return nil, nil
+	}, time.Second)
+	if err == nil || called {
+		t.Errorf("expected an error without calling produce, but got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// MergePatch applies the RFC 7386 JSON Merge Patch patch to the JSON document original, and returns
+// the merged document: members of patch objects that are null are removed, objects are merged
+// recursively, and everything else (including arrays) replaces what was there. Object keys in the
+// result are sorted.
+func MergePatch(original, patch []byte) ([]byte, error) {
+	doc, err := decodeJSONValue(original)
+	if err != nil {
+		return nil, errors.New("the original document is not valid JSON")
+	}
+
+	p, err := decodeJSONValue(patch)
+	if err != nil {
+		return nil, errors.New("the merge patch is not valid JSON")
+	}
+
+	return encodeJSONValue(mergePatchValue(doc, p))
+}
+
+// ReadMergePatch reads an RFC 7386 JSON Merge Patch from the request body, which must have a
+// Content-Type of application/merge-patch+json or application/json, if one is set, and is limited to
+// MaxJSONSize, like ReadJSON. The patch is applied to the JSON encoding of original, and the merged
+// document is returned, for the caller to decode (usually with json.Unmarshal into a fresh copy of
+// original) and validate. Object keys in the result are sorted.
+func (t *Tools) ReadMergePatch(w http.ResponseWriter, r *http.Request, original any) ([]byte, error) {
+	if r.Header.Get("Content-Type") != "" && !hasMediaType(r.Header.Get("Content-Type"), "application/merge-patch+json", "application/json") {
+		return nil, errors.New("the Content-Type header is not application/merge-patch+json")
+	}
+
+	maxBytes := t.maxJSONSize(r.Context())
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	dec := json.NewDecoder(r.Body)
+	dec.UseNumber()
+
+	var patch any
+	err := dec.Decode(&patch)
+	if err != nil {
+		return nil, classifyJSONError(err, maxBytes)
+	}
+
+	err = dec.Decode(&struct{}{})
+	if err != io.EOF {
+		return nil, errMultipleJSONValues()
+	}
+
+	out, err := json.Marshal(original)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := decodeJSONValue(out)
+	if err != nil {
+		return nil, err
+	}
+
+	return encodeJSONValue(mergePatchValue(doc, patch))
+}
+
+// mergePatchValue merges patch into target, as described by RFC 7386, and returns the result.
+func mergePatchValue(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+			continue
+		}
+		t[key] = mergePatchValue(t[key], value)
+	}
+
+	return t
+}
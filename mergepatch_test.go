@@ -0,0 +1,105 @@
+package toolbox
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// mergePatchTests are the examples from Appendix A of RFC 7386, plus a few of our own.
+var mergePatchTests = []struct {
+	original string
+	patch    string
+	expected string
+}{
+	{original: `{"a":"b"}`, patch: `{"a":"c"}`, expected: `{"a":"c"}`},
+	{original: `{"a":"b"}`, patch: `{"b":"c"}`, expected: `{"a":"b","b":"c"}`},
+	{original: `{"a":"b"}`, patch: `{"a":null}`, expected: `{}`},
+	{original: `{"a":"b","b":"c"}`, patch: `{"a":null}`, expected: `{"b":"c"}`},
+	{original: `{"a":["b"]}`, patch: `{"a":"c"}`, expected: `{"a":"c"}`},
+	{original: `{"a":"c"}`, patch: `{"a":["b"]}`, expected: `{"a":["b"]}`},
+	{original: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, expected: `{"a":{"b":"d"}}`},
+	{original: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, expected: `{"a":[1]}`},
+	{original: `["a","b"]`, patch: `["c","d"]`, expected: `["c","d"]`},
+	{original: `{"a":"b"}`, patch: `["c"]`, expected: `["c"]`},
+	{original: `{"a":"foo"}`, patch: `null`, expected: `null`},
+	{original: `{"a":"foo"}`, patch: `"bar"`, expected: `"bar"`},
+	{original: `{"e":null}`, patch: `{"a":1}`, expected: `{"a":1,"e":null}`},
+	{original: `[1,2]`, patch: `{"a":"b","c":null}`, expected: `{"a":"b"}`},
+	{original: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, expected: `{"a":{"bb":{}}}`},
+	{original: `{"a":{"b":{"c":1,"d":2.50}},"e":3}`, patch: `{"a":{"b":{"c":null}}}`, expected: `{"a":{"b":{"d":2.50}},"e":3}`},
+	{original: `{"a":"<b>"}`, patch: `{}`, expected: `{"a":"<b>"}`},
+}
+
+func TestMergePatch(t *testing.T) {
+	for _, e := range mergePatchTests {
+		out, err := MergePatch([]byte(e.original), []byte(e.patch))
+		if err != nil {
+			t.Errorf("%s + %s: unexpected error: %v", e.original, e.patch, err)
+			continue
+		}
+		if string(out) != e.expected {
+			t.Errorf("%s + %s: expected %s, but got %s", e.original, e.patch, e.expected, out)
+		}
+	}
+
+	if _, err := MergePatch([]byte(`{`), []byte(`{}`)); err == nil {
+		t.Error("expected an error for an invalid original")
+	}
+	if _, err := MergePatch([]byte(`{}`), []byte(`{} {}`)); err == nil {
+		t.Error("expected an error for an invalid patch")
+	}
+}
+
+var readMergePatchTests = []struct {
+	name          string
+	contentType   string
+	body          string
+	maxSize       int
+	expected      string
+	errorExpected string
+}{
+	{name: "merge", contentType: "application/merge-patch+json", body: `{"name":"Jane","tags":null}`, expected: `{"age":30,"name":"Jane"}`},
+	{name: "plain json", contentType: "application/json", body: `{"age":31}`, expected: `{"age":31,"name":"John","tags":["a"]}`},
+	{name: "wrong content type", contentType: "application/json-patch+json", body: `{}`, errorExpected: "not application/merge-patch+json"},
+	{name: "too large", body: `{"name":"Jonathan"}`, maxSize: 10, errorExpected: "must not be larger than 10 bytes"},
+	{name: "bad json", body: `{"name":`, errorExpected: "badly-formed JSON"},
+	{name: "two values", body: `{} {}`, errorExpected: "single JSON value"},
+}
+
+func TestTools_ReadMergePatch(t *testing.T) {
+	type person struct {
+		Name string   `json:"name"`
+		Age  int      `json:"age"`
+		Tags []string `json:"tags,omitempty"`
+	}
+
+	for _, e := range readMergePatchTests {
+		testTools := Tools{MaxJSONSize: e.maxSize}
+
+		req, _ := http.NewRequest(http.MethodPatch, "/", bytes.NewReader([]byte(e.body)))
+		if e.contentType != "" {
+			req.Header.Set("Content-Type", e.contentType)
+		}
+
+		out, err := testTools.ReadMergePatch(httptest.NewRecorder(), req, person{Name: "John", Age: 30, Tags: []string{"a"}})
+
+		if e.errorExpected != "" {
+			if err == nil || !strings.Contains(err.Error(), e.errorExpected) {
+				t.Errorf("%s: expected error containing %q, but got %v", e.name, e.errorExpected, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		if string(out) != e.expected {
+			t.Errorf("%s: expected %s, but got %s", e.name, e.expected, out)
+		}
+	}
+}
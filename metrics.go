@@ -0,0 +1,70 @@
+package toolbox
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ResponseMetricsHook is called once for every response sent by WriteJSON ("json"), WriteXML
+// ("xml"), ErrorJSON and ErrorJSONCtx ("json_error"), ErrorXML and ErrorXMLCtx ("xml_error"), and
+// WriteNDJSON ("ndjson"), with the status code, the number of body bytes actually written, which is
+// less than the size of the payload if the write failed, and how long encoding and writing took. It
+// is not called if the data can't be encoded, since no response is sent.
+type ResponseMetricsHook func(kind string, status int, bytes int, duration time.Duration)
+
+// reportResponse calls ResponseMetrics, if it is set, for a response of kind that started at start,
+// and logs it, if LogOperations is on.
+func (t *Tools) reportResponse(kind string, status, bytes int, start time.Time) {
+	if t.ResponseMetrics != nil {
+		t.ResponseMetrics(kind, status, bytes, time.Since(start))
+	}
+	if t.logsOperations() {
+		t.logOperation(context.Background(), slog.LevelInfo, responseOps[kind], int64(bytes), time.Since(start), status, nil)
+	}
+}
+
+// DecodeMetricsHook is called once for every call to ReadJSON ("json"), ReadXML ("xml") or ReadNDJSON
+// ("ndjson"), with the number of bytes read from the request body, which may be less than its size if
+// decoding failed early, the error returned, if any, and how long reading and decoding took. It is
+// never given the decoded data.
+type DecodeMetricsHook func(kind string, bytesRead int64, err error, duration time.Duration)
+
+// countingReader wraps a request body, counting the bytes read from it, for DecodeMetrics.
+type countingReader struct {
+	io.ReadCloser
+	read int64
+}
+
+// Read counts the bytes read, and passes them on.
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.ReadCloser.Read(b)
+	c.read += int64(n)
+	return n, err
+}
+
+// trackDecode replaces r.Body with a countingReader, if DecodeMetrics is set or LogOperations is on,
+// and returns a function that reports the result of decoding it as kind to DecodeMetrics, and the
+// log, returning err unchanged.
+func (t *Tools) trackDecode(r *http.Request, kind string) func(err error) error {
+	logs := t.logsOperations()
+	if t.DecodeMetrics == nil && !logs {
+		return func(err error) error { return err }
+	}
+
+	body := &countingReader{ReadCloser: r.Body}
+	r.Body = body
+	start := time.Now()
+
+	return func(err error) error {
+		if t.DecodeMetrics != nil {
+			t.DecodeMetrics(kind, body.read, err, time.Since(start))
+		}
+		if logs {
+			t.logOperation(r.Context(), slog.LevelInfo, decodeOps[kind], body.read, time.Since(start), 0, err)
+		}
+		return err
+	}
+}
@@ -0,0 +1,201 @@
+package toolbox
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// metricsRecord is one call to ResponseMetrics.
+type metricsRecord struct {
+	kind   string
+	status int
+	bytes  int
+}
+
+// partialWriter is an http.ResponseWriter that accepts the first limit bytes, then fails.
+type partialWriter struct {
+	*httptest.ResponseRecorder
+	limit int
+}
+
+func (p *partialWriter) Write(b []byte) (int, error) {
+	if len(b) > p.limit {
+		n, _ := p.ResponseRecorder.Write(b[:p.limit])
+		return n, errors.New("connection reset")
+	}
+	return p.ResponseRecorder.Write(b)
+}
+
+var responseMetricsTests = []struct {
+	name   string
+	kind   string
+	status int
+	write  func(t *Tools, w http.ResponseWriter) error
+}{
+	{name: "json", kind: "json", status: http.StatusOK, write: func(t *Tools, w http.ResponseWriter) error {
+		return t.WriteJSON(w, http.StatusOK, map[string]string{"foo": "bar"})
+	}},
+	{name: "xml", kind: "xml", status: http.StatusCreated, write: func(t *Tools, w http.ResponseWriter) error {
+		return t.WriteXML(w, http.StatusCreated, XMLResponse{Message: "created"})
+	}},
+	{name: "json error", kind: "json_error", status: http.StatusBadRequest, write: func(t *Tools, w http.ResponseWriter) error {
+		return t.ErrorJSON(w, errors.New("bad"))
+	}},
+	{name: "xml error", kind: "xml_error", status: http.StatusNotFound, write: func(t *Tools, w http.ResponseWriter) error {
+		return t.ErrorXML(w, errors.New("missing"), http.StatusNotFound)
+	}},
+	{name: "json error with request id", kind: "json_error", status: http.StatusBadRequest, write: func(t *Tools, w http.ResponseWriter) error {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r = r.WithContext(WithRequestID(r.Context(), "abc123"))
+		return t.ErrorJSONCtx(w, r, errors.New("bad"))
+	}},
+}
+
+func TestTools_ResponseMetrics(t *testing.T) {
+	for _, e := range responseMetricsTests {
+		for _, failing := range []bool{false, true} {
+			var records []metricsRecord
+			testTools := Tools{ResponseMetrics: func(kind string, status int, bytes int, duration time.Duration) {
+				records = append(records, metricsRecord{kind, status, bytes})
+			}}
+
+			rr := httptest.NewRecorder()
+			var w http.ResponseWriter = rr
+			if failing {
+				w = &partialWriter{ResponseRecorder: rr, limit: 5}
+			}
+
+			if err := e.write(&testTools, w); err != nil {
+				t.Errorf("%s: unexpected error: %s", e.name, err)
+			}
+
+			if len(records) != 1 {
+				t.Errorf("%s (failing: %t): expected one call to ResponseMetrics, but got %d", e.name, failing, len(records))
+				continue
+			}
+			if records[0].kind != e.kind || records[0].status != e.status {
+				t.Errorf("%s (failing: %t): expected %s and %d, but got %s and %d", e.name, failing, e.kind, e.status, records[0].kind, records[0].status)
+			}
+			if records[0].bytes != rr.Body.Len() {
+				t.Errorf("%s (failing: %t): expected %d bytes, but got %d", e.name, failing, rr.Body.Len(), records[0].bytes)
+			}
+			if failing && records[0].bytes != 5 {
+				t.Errorf("%s: expected 5 bytes from the failed write, but got %d", e.name, records[0].bytes)
+			}
+		}
+	}
+}
+
+func TestTools_ResponseMetricsNotCalled(t *testing.T) {
+	var calls int
+	testTools := Tools{ResponseMetrics: func(string, int, int, time.Duration) { calls++ }}
+
+	// Nothing is sent when the data can't be encoded.
+	if err := testTools.WriteJSON(httptest.NewRecorder(), http.StatusOK, make(chan int)); err == nil {
+		t.Error("expected an error for data that can't be encoded")
+	}
+	if calls != 0 {
+		t.Errorf("expected no calls to ResponseMetrics, but got %d", calls)
+	}
+
+	// Without a hook, responses are written as usual.
+	var noHook Tools
+	rr := httptest.NewRecorder()
+	if err := noHook.WriteJSON(rr, http.StatusOK, "ok"); err != nil || rr.Body.String() != `"ok"` {
+		t.Errorf("unexpected response without a hook: %q, %v", rr.Body.String(), err)
+	}
+}
+
+var decodeMetricsTests = []struct {
+	name        string
+	xml         bool
+	contentType string
+	body        string
+	maxSize     int
+	expectError bool
+}{
+	{name: "json", body: `{"foo": "bar"}`},
+	{name: "json too large", body: `{"foo": "` + strings.Repeat("a", 100) + `"}`, maxSize: 20, expectError: true},
+	{name: "json badly formed", body: `{"foo": }`, expectError: true},
+	{name: "json wrong content type", contentType: "text/plain", body: `{"foo": "bar"}`, expectError: true},
+	{name: "xml", xml: true, body: `<data><foo>bar</foo></data>`},
+	{name: "xml too large", xml: true, body: `<data><foo>` + strings.Repeat("a", 100) + `</foo></data>`, maxSize: 20, expectError: true},
+}
+
+func TestTools_DecodeMetrics(t *testing.T) {
+	for _, e := range decodeMetricsTests {
+		type call struct {
+			kind      string
+			bytesRead int64
+			err       error
+		}
+		var calls []call
+
+		testTools := Tools{
+			MaxJSONSize: e.maxSize,
+			MaxXMLSize:  e.maxSize,
+			DecodeMetrics: func(kind string, bytesRead int64, err error, duration time.Duration) {
+				calls = append(calls, call{kind, bytesRead, err})
+			},
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.body))
+		if e.contentType != "" {
+			req.Header.Set("Content-Type", e.contentType)
+		}
+		if e.maxSize > 0 {
+			// Send the body without a length, as a chunked body is, so the limit is found by reading.
+			req.ContentLength = -1
+		}
+
+		var err error
+		kind := "json"
+		if e.xml {
+			kind = "xml"
+			var data struct {
+				Foo string `xml:"foo"`
+			}
+			err = testTools.ReadXML(httptest.NewRecorder(), req, &data)
+		} else {
+			var data struct {
+				Foo string `json:"foo"`
+			}
+			err = testTools.ReadJSON(httptest.NewRecorder(), req, &data)
+		}
+
+		if len(calls) != 1 {
+			t.Errorf("%s: expected one call to DecodeMetrics, but got %d", e.name, len(calls))
+			continue
+		}
+		got := calls[0]
+		if got.kind != kind {
+			t.Errorf("%s: expected kind %s, but got %s", e.name, kind, got.kind)
+		}
+		if got.err != err || (err != nil) != e.expectError {
+			t.Errorf("%s: expected the hook to get the error returned (%v), but got %v", e.name, err, got.err)
+		}
+
+		switch {
+		case e.contentType != "":
+			if got.bytesRead != 0 {
+				t.Errorf("%s: expected nothing to be read, but got %d bytes", e.name, got.bytesRead)
+			}
+		case e.maxSize > 0:
+			// Reading stops just past the limit, well short of the whole body.
+			if got.bytesRead <= int64(e.maxSize) || got.bytesRead >= int64(len(e.body)) {
+				t.Errorf("%s: expected just over %d bytes to be read, but got %d", e.name, e.maxSize, got.bytesRead)
+			}
+			if !errors.Is(err, ErrBodyTooLarge) {
+				t.Errorf("%s: expected ErrBodyTooLarge, but got %v", e.name, err)
+			}
+		default:
+			if got.bytesRead != int64(len(e.body)) {
+				t.Errorf("%s: expected %d bytes to be read, but got %d", e.name, len(e.body), got.bytesRead)
+			}
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !(darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || windows)
+
+package toolbox
+
+// isCrossDeviceError reports that no error from os.Rename is because the paths are on different
+// file systems, as there is no way to tell here, so that MoveFile never falls back to copying.
+func isCrossDeviceError(err error) bool {
+	return false
+}
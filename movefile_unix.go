@@ -0,0 +1,14 @@
+//go:build darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd
+
+package toolbox
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isCrossDeviceError reports whether err, from os.Rename, is because the paths are on different
+// file systems.
+func isCrossDeviceError(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package toolbox
+
+import (
+	"errors"
+	"syscall"
+)
+
+// errorNotSameDevice is ERROR_NOT_SAME_DEVICE, which MoveFileEx fails with for paths on different
+// volumes.
+const errorNotSameDevice syscall.Errno = 17
+
+// isCrossDeviceError reports whether err, from os.Rename, is because the paths are on different
+// volumes.
+func isCrossDeviceError(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
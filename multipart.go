@@ -0,0 +1,114 @@
+package toolbox
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// MultipartFile is a file to include in a multipart request built by NewMultipartRequestFromReaders
+// or NewStreamingMultipartRequest.
+type MultipartFile struct {
+	FieldName string    // the form field name, e.g. "file"
+	FileName  string    // the file name sent to the server
+	Content   io.Reader // the file contents
+}
+
+// NewMultipartRequest returns a POST request to target with a multipart/form-data body holding the
+// files and fields specified. The keys of files are form field names, and the values are paths to
+// the files to send. This is handy for testing handlers that call UploadFiles, and for calling
+// remote services which accept uploads.
+func NewMultipartRequest(target string, files map[string]string, fields map[string]string) (*http.Request, error) {
+	var parts []MultipartFile
+
+	for _, field := range sortedKeys(files) {
+		f, err := os.Open(files[field])
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		parts = append(parts, MultipartFile{FieldName: field, FileName: filepath.Base(files[field]), Content: f})
+	}
+
+	return NewMultipartRequestFromReaders(target, parts, fields)
+}
+
+// NewMultipartRequestFromReaders is like NewMultipartRequest, but reads the files from the supplied
+// readers. The whole body is built in memory; for very large files, use NewStreamingMultipartRequest.
+func NewMultipartRequestFromReaders(target string, files []MultipartFile, fields map[string]string) (*http.Request, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	err := writeMultipart(writer, files, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", target, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	return req, nil
+}
+
+// NewStreamingMultipartRequest is like NewMultipartRequestFromReaders, but the body is written through
+// a pipe as it is read, so the files are never held in memory. An error reading one of the files is
+// returned when the request body is read.
+func NewStreamingMultipartRequest(target string, files []MultipartFile, fields map[string]string) (*http.Request, error) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+
+	req, err := http.NewRequest("POST", target, pr)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	go func() {
+		pw.CloseWithError(writeMultipart(writer, files, fields))
+	}()
+
+	return req, nil
+}
+
+// writeMultipart writes fields and files to writer, and closes it.
+func writeMultipart(writer *multipart.Writer, files []MultipartFile, fields map[string]string) error {
+	for _, key := range sortedKeys(fields) {
+		err := writer.WriteField(key, fields[key])
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, file := range files {
+		part, err := writer.CreateFormFile(file.FieldName, file.FileName)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(part, file.Content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// sortedKeys returns the keys of m in sorted order, so that generated bodies and error lists are
+// deterministic.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,70 @@
+package toolbox
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+)
+
+// checkMultipartRequest parses req, and checks that it holds the expected file and fields.
+func checkMultipartRequest(t *testing.T, req *http.Request, field, fileName string, content []byte) {
+	t.Helper()
+
+	err := req.ParseMultipartForm(defaultMaxUpload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.FormValue("title") != "hello" || req.FormValue("tag") != "world" {
+		t.Errorf("wrong form values: %v", req.MultipartForm.Value)
+	}
+
+	headers := req.MultipartForm.File[field]
+	if len(headers) != 1 {
+		t.Fatalf("expected 1 file in field %s, but got %d", field, len(headers))
+	}
+
+	if headers[0].Filename != fileName {
+		t.Errorf("wrong file name; expected %s but got %s", fileName, headers[0].Filename)
+	}
+
+	f, _ := headers[0].Open()
+	defer f.Close()
+	got, _ := io.ReadAll(f)
+	if !bytes.Equal(got, content) {
+		t.Error("file content does not match")
+	}
+}
+
+func TestNewMultipartRequest(t *testing.T) {
+	req, err := NewMultipartRequest("/upload", map[string]string{"file": "./testdata/img.png"}, map[string]string{"title": "hello", "tag": "world"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Method != "POST" || req.URL.Path != "/upload" {
+		t.Errorf("wrong method or path: %s %s", req.Method, req.URL.Path)
+	}
+
+	content, _ := os.ReadFile("./testdata/img.png")
+	checkMultipartRequest(t, req, "file", "img.png", content)
+
+	_, err = NewMultipartRequest("/upload", map[string]string{"file": "./testdata/missing.png"}, nil)
+	if err == nil {
+		t.Error("expected error for missing file, but none received")
+	}
+}
+
+func TestNewStreamingMultipartRequest(t *testing.T) {
+	files := []MultipartFile{{FieldName: "doc", FileName: "notes.txt", Content: strings.NewReader("some notes")}}
+
+	req, err := NewStreamingMultipartRequest("/upload", files, map[string]string{"title": "hello", "tag": "world"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkMultipartRequest(t, req, "doc", "notes.txt", []byte("some notes"))
+}
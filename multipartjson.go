@@ -0,0 +1,92 @@
+package toolbox
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// ReadMultipartJSON handles the common "JSON metadata plus attachments" request: a multipart body
+// with a part named jsonFieldName holding JSON, and any number of file parts. The JSON part is
+// decoded into dst exactly as ReadJSON would decode a request body (MaxJSONSize, AllowUnknownFields,
+// and the same friendly errors), and every file part is checked and saved to uploadDir exactly as
+// UploadFiles would save it, with a random name. The body is streamed, rather than buffered with
+// ParseMultipartForm. Other non-file fields are ignored.
+//
+// If the JSON part is missing or invalid, or any file fails, the files saved so far are removed, and
+// an error is returned, so that the caller never has to deal with half a request.
+func (t *Tools) ReadMultipartJSON(w http.ResponseWriter, r *http.Request, jsonFieldName string, dst any, uploadDir string) ([]*UploadedFile, error) {
+	uploadDir, err := t.uploadPath(uploadDir)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, formError(err)
+	}
+
+	err = t.CreateDirIfNotExist(uploadDir)
+	if err != nil {
+		return nil, err
+	}
+
+	maxBytes := t.maxJSONSize(r.Context())
+
+	uploadOpts := t.uploadOptions(r.Context(), UploadOptions{})
+
+	var uploadedFiles []*UploadedFile
+	cleanup := func() {
+		for _, f := range uploadedFiles {
+			_ = os.Remove(filepath.Join(uploadDir, f.NewFileName))
+		}
+	}
+
+	foundJSON := false
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cleanup()
+			return nil, formError(err)
+		}
+
+		switch {
+		case part.FormName() == jsonFieldName:
+			if foundJSON {
+				err = fmt.Errorf("the %s part must only appear once", jsonFieldName)
+				break
+			}
+			foundJSON = true
+			err = t.decodeJSON(http.MaxBytesReader(w, part, int64(maxBytes)), dst, maxBytes)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", jsonFieldName, err)
+			}
+
+		case part.FileName() != "":
+			var uploadedFile *UploadedFile
+			uploadedFile, err = t.saveFile(r.Context(), part, part.FileName(), uploadDir, uploadOpts)
+			if err == nil {
+				uploadedFile.FieldName = part.FormName()
+				uploadedFiles = append(uploadedFiles, uploadedFile)
+			}
+		}
+
+		_ = part.Close()
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+	}
+
+	if !foundJSON {
+		cleanup()
+		return nil, fmt.Errorf("the multipart request has no %s part", jsonFieldName)
+	}
+
+	return uploadedFiles, nil
+}
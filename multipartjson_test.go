@@ -0,0 +1,94 @@
+package toolbox
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type postMetadata struct {
+	Title string   `json:"title"`
+	Tags  []string `json:"tags"`
+}
+
+var readMultipartJSONTests = []struct {
+	name          string
+	fields        map[string]string
+	files         []string
+	allowedTypes  []string
+	errorExpected string
+}{
+	{name: "metadata and two files", fields: map[string]string{"metadata": `{"title": "hello", "tags": ["a", "b"]}`}, files: []string{"./testdata/img.png", "./testdata/tgg.jpg"}},
+	{name: "metadata only", fields: map[string]string{"metadata": `{"title": "hello"}`}},
+	{name: "other fields ignored", fields: map[string]string{"metadata": `{"title": "hello"}`, "extra": "x"}, files: []string{"./testdata/img.png"}},
+	{name: "malformed json", fields: map[string]string{"metadata": `{"title": "hello",}`}, files: []string{"./testdata/img.png"}, errorExpected: "metadata: body contains badly-formed JSON"},
+	{name: "unknown field", fields: map[string]string{"metadata": `{"title": "hello", "author": "me"}`}, files: []string{"./testdata/img.png"}, errorExpected: `metadata: body contains unknown key "author"`},
+	{name: "missing metadata", fields: map[string]string{"title": "hello"}, files: []string{"./testdata/img.png"}, errorExpected: "the multipart request has no metadata part"},
+	{name: "file type not allowed", fields: map[string]string{"metadata": `{"title": "hello"}`}, files: []string{"./testdata/img.png", "./testdata/tgg.jpg"}, allowedTypes: []string{"image/png"}, errorExpected: "the uploaded file type is not permitted"},
+}
+
+func TestTools_ReadMultipartJSON(t *testing.T) {
+	for _, e := range readMultipartJSONTests {
+		uploadDir := t.TempDir()
+
+		var parts []MultipartFile
+		for _, f := range e.files {
+			content, err := os.ReadFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parts = append(parts, MultipartFile{FieldName: "file", FileName: filepath.Base(f), Content: bytes.NewReader(content)})
+		}
+
+		req, err := NewMultipartRequestFromReaders("/", parts, e.fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testTools := Tools{AllowedFileTypes: e.allowedTypes}
+
+		var meta postMetadata
+		uploaded, err := testTools.ReadMultipartJSON(httptest.NewRecorder(), req, "metadata", &meta, uploadDir)
+
+		if e.errorExpected != "" {
+			if err == nil {
+				t.Errorf("%s: expected error, but none received", e.name)
+			} else if !strings.Contains(err.Error(), e.errorExpected) {
+				t.Errorf("%s: expected error containing %q, but got %q", e.name, e.errorExpected, err.Error())
+			}
+
+			// Nothing saved before the failure should be left behind.
+			entries, _ := os.ReadDir(uploadDir)
+			if len(entries) != 0 {
+				t.Errorf("%s: expected upload directory to be empty, but found %d files", e.name, len(entries))
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		if meta.Title != "hello" {
+			t.Errorf("%s: metadata not decoded: %+v", e.name, meta)
+		}
+
+		if len(uploaded) != len(e.files) {
+			t.Errorf("%s: expected %d files, but got %d", e.name, len(e.files), len(uploaded))
+			continue
+		}
+
+		for i, f := range uploaded {
+			if f.OriginalFileName != filepath.Base(e.files[i]) {
+				t.Errorf("%s: wrong original file name %s", e.name, f.OriginalFileName)
+			}
+			if _, err := os.Stat(filepath.Join(uploadDir, f.NewFileName)); err != nil {
+				t.Errorf("%s: expected file to exist: %s", e.name, err.Error())
+			}
+		}
+	}
+}
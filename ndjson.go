@@ -0,0 +1,308 @@
+package toolbox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// defaultNDJSONFlushItems and defaultNDJSONFlushInterval are how often WriteNDJSON flushes, unless
+// NDJSONFlushItems or NDJSONFlushInterval is set.
+const (
+	defaultNDJSONFlushItems    = 100
+	defaultNDJSONFlushInterval = time.Second
+)
+
+// ndjsonMediaTypes are the media types ReadNDJSON accepts.
+var ndjsonMediaTypes = []string{"application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines"}
+
+// NDJSONLineError is a problem with a single line read by ReadNDJSON: a record that can't be decoded,
+// or fails validation, or an error returned by the callback for it.
+type NDJSONLineError struct {
+	Line int   // the line the record is on, counting from 1
+	Err  error // what went wrong
+}
+
+// Error satisfies the error interface.
+func (e *NDJSONLineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *NDJSONLineError) Unwrap() error {
+	return e.Err
+}
+
+// ReadNDJSON reads a request body of newline-delimited JSON, such as a bulk upload of events,
+// decoding one line at a time into a T and passing it to fn along with its line number, so that
+// the body is never held in memory as a whole. It returns the number of records fn accepted. It is
+// a function, not a method, since methods can't have type parameters:
+//
+//	n, err := toolbox.ReadNDJSON(&tools, w, r, func(line int, event Event) error {
+//		return store.Add(event)
+//	})
+//
+// The body may be sent as application/x-ndjson, application/ndjson, application/jsonl or
+// application/x-jsonlines, or without a Content-Type. MaxJSONSize limits the body as a whole, not
+// each line, so it usually needs raising for bulk uploads (with WithMaxJSONSize, per request). Each
+// line must hold a single JSON value; blank lines are skipped, and an empty body has no records.
+// AllowUnknownFields applies to each record, and so does Validate, if T has one.
+//
+// The first line that can't be decoded, or fails validation, stops the read, as does the first
+// error from fn; either way, the error is a *NDJSONLineError naming the line, which wraps the
+// *ReadError, *ValidationError or error from fn. Records before it have already been passed to fn.
+func ReadNDJSON[T any](t *Tools, w http.ResponseWriter, r *http.Request, fn func(line int, record T) error) (int, error) {
+	done := t.trackDecode(r, "ndjson")
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" && !hasMediaType(contentType, ndjsonMediaTypes...) {
+		return 0, done(&UnsupportedMediaTypeError{ContentType: contentType, Accepted: ndjsonMediaTypes})
+	}
+
+	maxBytes := t.maxJSONSize(r.Context())
+	if declaredTooLarge(w, r, maxBytes) {
+		return 0, done(&BodyTooLargeError{Limit: maxBytes})
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	body := bufio.NewReaderSize(r.Body, 64*1024)
+
+	records := 0
+	for line := 1; ; line++ {
+		// The last line needn't end with a newline, but it must not have been cut short by an error.
+		text, err := body.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			if isMaxBytesError(err) {
+				return records, done(&BodyTooLargeError{Limit: maxBytes})
+			}
+			return records, done(err)
+		}
+		atEnd := err == io.EOF
+
+		if text = bytes.TrimSpace(text); len(text) > 0 {
+			var record T
+			if err := t.decodeNDJSONLine(text, &record, maxBytes); err != nil {
+				return records, done(&NDJSONLineError{Line: line, Err: err})
+			}
+			if err := fn(line, record); err != nil {
+				return records, done(&NDJSONLineError{Line: line, Err: err})
+			}
+			records++
+		}
+
+		if atEnd {
+			return records, done(nil)
+		}
+	}
+}
+
+// decodeNDJSONLine decodes a single line read by ReadNDJSON into data, and validates it.
+func (t *Tools) decodeNDJSONLine(line []byte, data any, maxBytes int) error {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	if !t.AllowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	if err := dec.Decode(data); err != nil {
+		return classifyJSONError(err, maxBytes)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return &ReadError{Kind: ErrMultipleJSONValues, message: "line must only contain a single JSON value"}
+	}
+
+	return t.validate(data)
+}
+
+// WriteNDJSON sends items as newline-delimited JSON (application/x-ndjson): one compact JSON
+// document per line, encoded as WriteJSON would (with JSONMarshalHook and JSONDisableHTMLEscape,
+// but never indented), in the order they arrive. The response is flushed after every
+// NDJSONFlushItems items and at least every NDJSONFlushInterval, so that clients can process
+// it as it is produced, and it ends when items is closed.
+//
+// The status and headers are sent with the first line (or when items is closed, if it sends
+// nothing), so if the first item can't be encoded, nothing has been written and the caller can
+// still send an error response. If a later item can't be encoded, or the client goes away, the
+// error is returned at once, and the response ends after the lines already written; WriteNDJSON
+// stops receiving from items, so whatever sends on it must be able to stop too, for example by
+// watching the request's context.
+func (t *Tools) WriteNDJSON(w http.ResponseWriter, status int, items <-chan any, headers ...http.Header) error {
+	return t.newNDJSONWriter(w, status, headers...).stream(items)
+}
+
+// WriteNDJSONSlice is WriteNDJSON for items that are already in memory. It is a function, not a
+// method, since methods can't have type parameters:
+//
+//	err := toolbox.WriteNDJSONSlice(&tools, w, http.StatusOK, events)
+func WriteNDJSONSlice[T any](t *Tools, w http.ResponseWriter, status int, items []T, headers ...http.Header) error {
+	nw := t.newNDJSONWriter(w, status, headers...)
+
+	for _, item := range items {
+		if err := nw.write(item); err != nil {
+			return nw.fail(err)
+		}
+	}
+
+	return nw.close()
+}
+
+// DownloadNDJSON sends items to the client as a downloadable newline-delimited JSON file named
+// filename, as WriteNDJSON would, for "export as file" buttons. As with DownloadJSON, OnDownload
+// is given a nil request, and isn't called if nothing was sent.
+func (t *Tools) DownloadNDJSON(w http.ResponseWriter, items <-chan any, filename string) error {
+	w, done := t.trackDownload(w, nil, filename)
+
+	nw := t.newNDJSONWriter(w, http.StatusOK, http.Header{
+		"Content-Disposition": {contentDisposition("attachment", filename)},
+	})
+	err := nw.stream(items)
+	if nw.started {
+		done()
+	}
+
+	return err
+}
+
+// ndjsonWriter writes the lines of a WriteNDJSON response, and decides when to flush them.
+type ndjsonWriter struct {
+	t       *Tools
+	w       http.ResponseWriter
+	status  int
+	headers []http.Header
+	buf     bytes.Buffer
+	enc     *json.Encoder
+
+	flushItems int
+	interval   time.Duration
+
+	start     time.Time
+	started   bool
+	pending   int // lines written since the last flush
+	lastFlush time.Time
+	written   int
+}
+
+// newNDJSONWriter returns an ndjsonWriter for a WriteNDJSON response to w.
+func (t *Tools) newNDJSONWriter(w http.ResponseWriter, status int, headers ...http.Header) *ndjsonWriter {
+	nw := &ndjsonWriter{
+		t:          t,
+		w:          w,
+		status:     status,
+		headers:    headers,
+		flushItems: defaultNDJSONFlushItems,
+		interval:   defaultNDJSONFlushInterval,
+		start:      time.Now(),
+	}
+	if t.NDJSONFlushItems > 0 {
+		nw.flushItems = t.NDJSONFlushItems
+	}
+	if t.NDJSONFlushInterval > 0 {
+		nw.interval = t.NDJSONFlushInterval
+	}
+	nw.enc = json.NewEncoder(&nw.buf)
+	nw.enc.SetEscapeHTML(!t.JSONDisableHTMLEscape)
+
+	return nw
+}
+
+// stream writes each item received from items, until it is closed.
+func (nw *ndjsonWriter) stream(items <-chan any) error {
+	ticker := time.NewTicker(nw.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case item, ok := <-items:
+			if !ok {
+				return nw.close()
+			}
+			if err := nw.write(item); err != nil {
+				return nw.fail(err)
+			}
+		case <-ticker.C:
+			nw.flush()
+		}
+	}
+}
+
+// write encodes item as a line of the response, sending the headers first if they haven't been.
+func (nw *ndjsonWriter) write(item any) error {
+	if nw.t.JSONMarshalHook != nil {
+		var err error
+		item, err = nw.t.JSONMarshalHook(item)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Encode into buf before writing anything, so that an item that can't be encoded leaves no
+	// partial line.
+	nw.buf.Reset()
+	if err := nw.enc.Encode(item); err != nil {
+		return err
+	}
+
+	nw.writeHeader()
+	n, err := nw.w.Write(nw.buf.Bytes())
+	nw.written += n
+	if err != nil {
+		return err
+	}
+
+	nw.pending++
+	if nw.pending >= nw.flushItems || time.Since(nw.lastFlush) >= nw.interval {
+		nw.flush()
+	}
+
+	return nil
+}
+
+// writeHeader sends the status and headers, once.
+func (nw *ndjsonWriter) writeHeader() {
+	if nw.started {
+		return
+	}
+	nw.started = true
+
+	setHeaders(nw.w, "application/x-ndjson", nw.headers)
+	// Stop nginx and similar proxies from buffering the stream.
+	nw.w.Header().Set("X-Accel-Buffering", "no")
+	nw.w.WriteHeader(nw.status)
+	nw.lastFlush = time.Now()
+}
+
+// flush sends the lines written so far to the client, if there are any. Writers that can't flush
+// send them when the handler returns instead.
+func (nw *ndjsonWriter) flush() {
+	if nw.pending == 0 {
+		return
+	}
+
+	_ = http.NewResponseController(nw.w).Flush()
+	nw.pending = 0
+	nw.lastFlush = time.Now()
+}
+
+// close ends a response that was written in full.
+func (nw *ndjsonWriter) close() error {
+	nw.writeHeader()
+	nw.flush()
+	nw.report()
+	return nil
+}
+
+// fail ends a response that stopped with err, and returns err.
+func (nw *ndjsonWriter) fail(err error) error {
+	if nw.started {
+		nw.flush()
+		nw.report()
+	}
+	return err
+}
+
+// report tells ResponseMetrics about the response, as kind "ndjson".
+func (nw *ndjsonWriter) report() {
+	nw.t.reportResponse("ndjson", nw.status, nw.written, nw.start)
+}
@@ -0,0 +1,287 @@
+package toolbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flushCounter is an httptest.ResponseRecorder that counts its flushes, and how many lines each sent.
+type flushCounter struct {
+	*httptest.ResponseRecorder
+	flushes []int
+}
+
+func (f *flushCounter) Flush() {
+	f.flushes = append(f.flushes, bytes.Count(f.Body.Bytes(), []byte("\n")))
+	f.ResponseRecorder.Flush()
+}
+
+type exportRow struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestTools_WriteNDJSON(t *testing.T) {
+	const count = 3000
+
+	items := make(chan any)
+	go func() {
+		defer close(items)
+		for i := 0; i < count; i++ {
+			items <- exportRow{ID: i, Name: "<row>"}
+		}
+	}()
+
+	testTools := Tools{NDJSONFlushItems: 500}
+	rr := &flushCounter{ResponseRecorder: httptest.NewRecorder()}
+	err := testTools.WriteNDJSON(rr, http.StatusOK, items, http.Header{"X-Export": {"rows"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/x-ndjson" || rr.Header().Get("X-Export") != "rows" {
+		t.Errorf("wrong status or headers: %d %v", rr.Code, rr.Header())
+	}
+
+	lines := bytes.Split(bytes.TrimSuffix(rr.Body.Bytes(), []byte("\n")), []byte("\n"))
+	if len(lines) != count {
+		t.Fatalf("expected %d lines, but got %d", count, len(lines))
+	}
+	for i, line := range lines {
+		var row exportRow
+		if err := json.Unmarshal(line, &row); err != nil || row.ID != i || row.Name != "<row>" {
+			t.Fatalf("line %d: got %q, %v", i, line, err)
+		}
+	}
+	if !bytes.Contains(lines[0], []byte(`\u003crow\u003e`)) {
+		t.Errorf("expected HTML to be escaped, as WriteJSON does, but got %s", lines[0])
+	}
+
+	if len(rr.flushes) != count/500 || rr.flushes[0] != 500 {
+		t.Errorf("expected a flush every 500 lines, but got %v", rr.flushes)
+	}
+}
+
+func TestTools_WriteNDJSONFlushInterval(t *testing.T) {
+	items := make(chan any)
+	testTools := Tools{NDJSONFlushInterval: 20 * time.Millisecond}
+	rr := &flushCounter{ResponseRecorder: httptest.NewRecorder()}
+
+	done := make(chan error)
+	go func() {
+		done <- testTools.WriteNDJSON(rr, http.StatusOK, items)
+	}()
+
+	// Sent quickly, the first two lines are flushed together, by the ticker; the third, sent after a
+	// pause, is flushed at once.
+	items <- 1
+	items <- 2
+	time.Sleep(50 * time.Millisecond)
+	items <- 3
+	time.Sleep(50 * time.Millisecond)
+	close(items)
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if rr.Body.String() != "1\n2\n3\n" {
+		t.Errorf("wrong body: %q", rr.Body)
+	}
+	if len(rr.flushes) != 2 || rr.flushes[1] != 3 {
+		t.Errorf("expected two flushes, but got %v", rr.flushes)
+	}
+}
+
+func TestTools_WriteNDJSONEncodeError(t *testing.T) {
+	var testTools Tools
+
+	// Nothing is written if the first item fails...
+	rr := httptest.NewRecorder()
+	items := make(chan any, 2)
+	items <- func() {}
+	if err := testTools.WriteNDJSON(rr, http.StatusOK, items); err == nil {
+		t.Error("expected an error for an item that can't be encoded")
+	}
+	if len(rr.Header()) != 0 || rr.Body.Len() != 0 {
+		t.Errorf("expected nothing to be written, but got %v %q", rr.Header(), rr.Body)
+	}
+
+	// ...and a later one ends the response after the lines before it.
+	rr = httptest.NewRecorder()
+	err := WriteNDJSONSlice(&testTools, rr, http.StatusOK, []any{"a", "b", make(chan int), "c"})
+	if err == nil {
+		t.Error("expected an error for an item that can't be encoded")
+	}
+	if rr.Body.String() != "\"a\"\n\"b\"\n" {
+		t.Errorf("expected the lines before the error, but got %q", rr.Body)
+	}
+}
+
+func TestTools_DownloadNDJSON(t *testing.T) {
+	var sent int
+	testTools := Tools{OnDownload: func(_ *http.Request, _ string, bytesSent int64, _ int, _ time.Duration) {
+		sent = int(bytesSent)
+	}}
+
+	items := make(chan any, 2)
+	items <- exportRow{ID: 1}
+	items <- exportRow{ID: 2}
+	close(items)
+
+	rr := httptest.NewRecorder()
+	if err := testTools.DownloadNDJSON(rr, items, "export.ndjson"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := rr.Header().Get("Content-Disposition"); got != `attachment; filename="export.ndjson"` {
+		t.Errorf("wrong Content-Disposition: %q", got)
+	}
+	if rr.Body.String() != "{\"id\":1,\"name\":\"\"}\n{\"id\":2,\"name\":\"\"}\n" || sent != rr.Body.Len() {
+		t.Errorf("wrong body, or OnDownload was told %d bytes: %q", sent, rr.Body)
+	}
+
+	// An empty export is still a file.
+	rr = httptest.NewRecorder()
+	empty := make(chan any)
+	close(empty)
+	if err := testTools.DownloadNDJSON(rr, empty, "export.ndjson"); err != nil || rr.Code != http.StatusOK || rr.Body.Len() != 0 {
+		t.Errorf("expected an empty 200 response, but got %d %q, %v", rr.Code, rr.Body, err)
+	}
+}
+
+type ndjsonEvent struct {
+	ID   int    `json:"id"`
+	Kind string `json:"kind"`
+}
+
+func (e ndjsonEvent) Validate() error {
+	if e.Kind == "" {
+		return errors.New("kind is required")
+	}
+	return nil
+}
+
+var readNDJSONTests = []struct {
+	name        string
+	body        string
+	contentType string
+	stopAt      int
+	records     int
+	errLine     int
+	target      error
+}{
+	{name: "good", body: "{\"id\":1,\"kind\":\"a\"}\n\n{\"id\":2,\"kind\":\"b\"}\r\n  \n{\"id\":3,\"kind\":\"c\"}", contentType: "application/x-ndjson", records: 3},
+	{name: "no content type", body: "{\"id\":1,\"kind\":\"a\"}\n", records: 1},
+	{name: "empty", body: "", records: 0},
+	{name: "malformed", body: "{\"id\":1,\"kind\":\"a\"}\n{\"id\":2,\n{\"id\":3,\"kind\":\"c\"}\n", records: 1, errLine: 2, target: ErrBadlyFormedJSON},
+	{name: "wrong type", body: "{\"id\":\"one\",\"kind\":\"a\"}\n", errLine: 1, target: ErrWrongType},
+	{name: "unknown field", body: "{\"id\":1,\"kind\":\"a\"}\n{\"id\":2,\"kind\":\"b\",\"x\":1}\n", records: 1, errLine: 2, target: ErrUnknownField},
+	{name: "two values on a line", body: "{\"id\":1,\"kind\":\"a\"} {\"id\":2,\"kind\":\"b\"}\n", errLine: 1, target: ErrMultipleJSONValues},
+	{name: "invalid", body: "{\"id\":1,\"kind\":\"a\"}\n{\"id\":2}\n", records: 1, errLine: 2, target: ErrValidation},
+	{name: "callback error", body: "{\"id\":1,\"kind\":\"a\"}\n{\"id\":2,\"kind\":\"b\"}\n{\"id\":3,\"kind\":\"c\"}\n", stopAt: 2, records: 1, errLine: 2, target: errStopImport},
+	{name: "too large", body: strings.Repeat("{\"id\":1,\"kind\":\"a\"}\n", 100), records: 51, target: ErrBodyTooLarge},
+	{name: "wrong content type", body: "{}", contentType: "text/csv", target: ErrUnsupportedMediaType},
+}
+
+var errStopImport = errors.New("stop")
+
+func TestReadNDJSON(t *testing.T) {
+	testTools := Tools{MaxJSONSize: 1024}
+
+	for _, e := range readNDJSONTests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.body))
+		req.ContentLength = -1
+		if e.contentType != "" {
+			req.Header.Set("Content-Type", e.contentType)
+		}
+
+		var lines []int
+		n, err := ReadNDJSON(&testTools, httptest.NewRecorder(), req, func(line int, event ndjsonEvent) error {
+			if line == e.stopAt {
+				return errStopImport
+			}
+			lines = append(lines, line)
+			return nil
+		})
+
+		if n != e.records || len(lines) != e.records {
+			t.Errorf("%s: expected %d records, but got %d (%v)", e.name, e.records, n, lines)
+		}
+		if e.target == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", e.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, e.target) {
+			t.Errorf("%s: expected %v, but got %v", e.name, e.target, err)
+		}
+
+		var lineErr *NDJSONLineError
+		if e.errLine > 0 && (!errors.As(err, &lineErr) || lineErr.Line != e.errLine) {
+			t.Errorf("%s: expected an error for line %d, but got %v", e.name, e.errLine, err)
+		}
+	}
+}
+
+// ndjsonStream generates a body of lines newline-delimited JSON records, without holding it in memory.
+type ndjsonStream struct {
+	lines, next int
+	pending     []byte
+}
+
+func (s *ndjsonStream) Read(p []byte) (int, error) {
+	if len(s.pending) == 0 {
+		if s.next == s.lines {
+			return 0, io.EOF
+		}
+		s.next++
+		s.pending = fmt.Appendf(s.pending[:0], "{\"id\":%d,\"kind\":%q}\n", s.next, strings.Repeat("x", 300))
+	}
+
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+func TestReadNDJSONMemory(t *testing.T) {
+	const lines = 100000
+
+	// The body is over 30MB, which ReadNDJSON should never hold at once.
+	req := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(&ndjsonStream{lines: lines}))
+	req = req.WithContext(WithMaxJSONSize(req.Context(), 64*1024*1024))
+
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	baseline := stats.HeapAlloc
+
+	var testTools Tools
+	var peak uint64
+	n, err := ReadNDJSON(&testTools, httptest.NewRecorder(), req, func(line int, event ndjsonEvent) error {
+		if event.ID != line {
+			return fmt.Errorf("expected id %d, but got %d", line, event.ID)
+		}
+		if line%10000 == 0 {
+			runtime.ReadMemStats(&stats)
+			peak = max(peak, stats.HeapAlloc)
+		}
+		return nil
+	})
+	if err != nil || n != lines {
+		t.Fatalf("expected %d records, but got %d, %v", lines, n, err)
+	}
+
+	if growth := int64(peak) - int64(baseline); growth > 16*1024*1024 {
+		t.Errorf("expected memory use to stay flat, but the heap grew by %d bytes", growth)
+	}
+}
@@ -0,0 +1,93 @@
+package toolbox
+
+import (
+	"reflect"
+	"time"
+)
+
+// maxNormalizeDepth is how deeply NormalizeTimes follows pointers and containers, which stops it
+// from going round a cycle for ever; encoding/json reports the cycle instead.
+const maxNormalizeDepth = 1000
+
+// NormalizeTimes returns a copy of v with every time.Time in it, at any depth, in UTC, so that
+// timestamps are always encoded as RFC 3339 times ending in Z. It goes into pointers, structs
+// (exported fields only), slices, arrays, maps and interfaces; v itself is not changed. Use it as a
+// JSONMarshalHook with:
+//
+//	tools.JSONMarshalHook = func(v any) (any, error) { return toolbox.NormalizeTimes(v), nil }
+func NormalizeTimes(v any) any {
+	if v == nil {
+		return nil
+	}
+	return normalizeTimes(reflect.ValueOf(v), 0).Interface()
+}
+
+// normalizeTimes returns a copy of rv with its times in UTC, or rv itself, if it can't hold any.
+func normalizeTimes(rv reflect.Value, depth int) reflect.Value {
+	if depth > maxNormalizeDepth {
+		return rv
+	}
+
+	if rv.Type() == timeType {
+		return reflect.ValueOf(rv.Interface().(time.Time).UTC())
+	}
+
+	switch rv.Kind() {
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return rv
+		}
+		p := reflect.New(rv.Type().Elem())
+		p.Elem().Set(normalizeTimes(rv.Elem(), depth+1))
+		return p
+
+	case reflect.Interface:
+		if rv.IsNil() {
+			return rv
+		}
+		v := reflect.New(rv.Type()).Elem()
+		v.Set(normalizeTimes(rv.Elem(), depth+1))
+		return v
+
+	case reflect.Struct:
+		v := reflect.New(rv.Type()).Elem()
+		v.Set(rv)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				v.Field(i).Set(normalizeTimes(rv.Field(i), depth+1))
+			}
+		}
+		return v
+
+	case reflect.Slice:
+		if rv.IsNil() || rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv
+		}
+		v := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			v.Index(i).Set(normalizeTimes(rv.Index(i), depth+1))
+		}
+		return v
+
+	case reflect.Array:
+		v := reflect.New(rv.Type()).Elem()
+		for i := 0; i < rv.Len(); i++ {
+			v.Index(i).Set(normalizeTimes(rv.Index(i), depth+1))
+		}
+		return v
+
+	case reflect.Map:
+		if rv.IsNil() {
+			return rv
+		}
+		v := reflect.MakeMapWithSize(rv.Type(), rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			v.SetMapIndex(iter.Key(), normalizeTimes(iter.Value(), depth+1))
+		}
+		return v
+
+	default:
+		return rv
+	}
+}
@@ -0,0 +1,93 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTools_JSONMarshalHook(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+	}
+
+	testTools := Tools{JSONMarshalHook: func(v any) (any, error) {
+		if u, ok := v.(user); ok {
+			u.Name = strings.ToUpper(u.Name)
+			return u, nil
+		}
+		if r, ok := v.(JSONResponse); ok {
+			r.Message = strings.ToUpper(r.Message)
+			return r, nil
+		}
+		return v, nil
+	}}
+
+	rr := httptest.NewRecorder()
+	if err := testTools.WriteJSON(rr, http.StatusOK, user{Name: "jane"}); err != nil {
+		t.Fatal(err)
+	}
+	if rr.Body.String() != `{"name":"JANE"}` {
+		t.Errorf("expected the hook to change the payload, but got %s", rr.Body)
+	}
+
+	rr = httptest.NewRecorder()
+	_ = testTools.ErrorJSON(rr, errors.New("not found"), http.StatusNotFound)
+	if !strings.Contains(rr.Body.String(), `"message":"NOT FOUND"`) {
+		t.Errorf("expected the hook to change the error payload, but got %s", rr.Body)
+	}
+
+	// A failing hook means nothing is written.
+	testTools.JSONMarshalHook = func(v any) (any, error) { return nil, errors.New("no") }
+	rr = httptest.NewRecorder()
+	if err := testTools.WriteJSON(rr, http.StatusOK, user{Name: "jane"}); err == nil || rr.Body.Len() != 0 {
+		t.Errorf("expected an error and no body, but got %v and %s", err, rr.Body)
+	}
+}
+
+func TestNormalizeTimes(t *testing.T) {
+	type event struct {
+		Name    string               `json:"name"`
+		At      time.Time            `json:"at"`
+		Ends    *time.Time           `json:"ends"`
+		History []time.Time          `json:"history"`
+		ByZone  map[string]time.Time `json:"by_zone"`
+		Extra   any                  `json:"extra"`
+	}
+
+	zone := time.FixedZone("EST", -5*60*60)
+	local := time.Date(2024, 3, 1, 9, 30, 0, 0, zone)
+	ends := local
+	e := &event{Name: "launch", At: local, Ends: &ends, History: []time.Time{local}, ByZone: map[string]time.Time{"ny": local}, Extra: local}
+
+	testTools := Tools{JSONMarshalHook: func(v any) (any, error) { return NormalizeTimes(v), nil }}
+
+	rr := httptest.NewRecorder()
+	if err := testTools.WriteJSON(rr, http.StatusOK, e); err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, ts := range []any{got["at"], got["ends"], got["history"].([]any)[0], got["by_zone"].(map[string]any)["ny"], got["extra"]} {
+		s, _ := ts.(string)
+		if !strings.HasSuffix(s, "Z") || s != "2024-03-01T14:30:00Z" {
+			t.Errorf("expected a UTC timestamp, but got %v", ts)
+		}
+	}
+
+	// The value given is left alone.
+	if e.At.Location() != zone || e.Ends.Location() != zone || e.History[0].Location() != zone {
+		t.Error("expected the original value not to be changed")
+	}
+
+	if NormalizeTimes(nil) != nil {
+		t.Error("expected nil to stay nil")
+	}
+}
@@ -0,0 +1,113 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrNullField matches, with errors.Is, the *NullFieldsError returned by ReadJSON when
+// DisallowNullFields is set and the body sends null for a field that can't hold it.
+var ErrNullField = errors.New("null JSON field")
+
+// NullFieldsError lists the fields a JSON body sent as null, when DisallowNullFields is set. Since
+// encoding/json leaves such a field at its zero value, a handler can't tell null from a value that
+// wasn't sent, so only fields that can keep the difference are allowed to be null: pointers,
+// interfaces, and types with their own UnmarshalJSON. Since it unwraps to FieldErrors, with the code
+// null, ErrorJSON sends the list to the client.
+type NullFieldsError struct {
+	// Fields are the null fields, by their paths in the JSON, such as email, author.email or
+	// items[2].sku; the keys of each object are in alphabetical order.
+	Fields []string
+}
+
+// Error satisfies the error interface.
+func (e *NullFieldsError) Error() string {
+	return "body contains null for fields: " + strings.Join(e.Fields, ", ")
+}
+
+// Is makes errors.Is(err, ErrNullField) true.
+func (e *NullFieldsError) Is(target error) bool {
+	return target == ErrNullField
+}
+
+// Unwrap returns the null fields as FieldErrors.
+func (e *NullFieldsError) Unwrap() error {
+	fieldErrs := make(FieldErrors, len(e.Fields))
+	for i, field := range e.Fields {
+		fieldErrs[i] = FieldError{Field: field, Code: "null", Message: "must not be null"}
+	}
+	return fieldErrs
+}
+
+// checkNullJSONFields returns a *NullFieldsError if body, which has already been decoded into data,
+// sends null, at any depth, for a field of data's type that can't hold it.
+func checkNullJSONFields(body []byte, data any) error {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return err
+	}
+
+	var nulls []string
+	findNullJSONFields(reflect.TypeOf(data), doc, "", &nulls)
+	if len(nulls) > 0 {
+		return &NullFieldsError{Fields: nulls}
+	}
+	return nil
+}
+
+// findNullJSONFields adds to nulls the path of each field in value, the decoded JSON for a value of
+// type rt at path, that is null but can't hold null, looking inside structs, slices, arrays and maps.
+func findNullJSONFields(rt reflect.Type, value any, path string, nulls *[]string) {
+	for rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	if reflect.PointerTo(rt).Implements(jsonUnmarshalerType) {
+		return
+	}
+
+	switch rt.Kind() {
+	case reflect.Struct:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return
+		}
+		fields := knownJSONFields(rt)
+		for _, key := range sortedKeys(obj) {
+			ft, ok := lookupJSONField(fields, key)
+			if !ok {
+				// Unknown keys are for AllowUnknownFields to deal with.
+				continue
+			}
+
+			keyPath := joinJSONPath(path, key)
+			if obj[key] == nil {
+				if !nullable(ft) {
+					*nulls = append(*nulls, keyPath)
+				}
+				continue
+			}
+			findNullJSONFields(ft, obj[key], keyPath, nulls)
+		}
+
+	case reflect.Slice, reflect.Array:
+		items, _ := value.([]any)
+		for i, item := range items {
+			findNullJSONFields(rt.Elem(), item, fmt.Sprintf("%s[%d]", path, i), nulls)
+		}
+
+	case reflect.Map:
+		obj, _ := value.(map[string]any)
+		for _, key := range sortedKeys(obj) {
+			findNullJSONFields(rt.Elem(), obj[key], joinJSONPath(path, key), nulls)
+		}
+	}
+}
+
+// nullable reports whether a field of type ft can tell null apart from a value that wasn't sent.
+func nullable(ft reflect.Type) bool {
+	return ft.Kind() == reflect.Pointer || ft.Kind() == reflect.Interface || ft.Implements(jsonUnmarshalerType) ||
+		reflect.PointerTo(ft).Implements(jsonUnmarshalerType)
+}
@@ -0,0 +1,107 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type nullAddress struct {
+	City string  `json:"city"`
+	Zip  *string `json:"zip"`
+}
+
+type nullAudit struct {
+	CreatedBy string `json:"created_by"`
+}
+
+type nullUser struct {
+	nullAudit
+	Email   string          `json:"email"`
+	Age     int             `json:"age"`
+	Nick    *string         `json:"nick"`
+	Extra   any             `json:"extra"`
+	Raw     json.RawMessage `json:"raw"`
+	Born    time.Time       `json:"born"`
+	Tags    []string        `json:"tags"`
+	Address nullAddress     `json:"address"`
+	Homes   []nullAddress   `json:"homes"`
+}
+
+var nullFieldsTests = []struct {
+	name  string
+	body  string
+	nulls []string
+}{
+	{name: "no nulls", body: `{"email":"a@example.com","age":3,"address":{"city":"x"}}`},
+	{name: "nullable fields", body: `{"nick":null,"extra":null,"raw":null,"born":null,"address":{"zip":null}}`},
+	{name: "top level", body: `{"email":null,"age":null,"nick":null}`, nulls: []string{"age", "email"}},
+	{name: "slice field", body: `{"tags":null}`, nulls: []string{"tags"}},
+	{name: "nested", body: `{"address":{"city":null,"zip":null}}`, nulls: []string{"address.city"}},
+	{name: "slice of structs", body: `{"homes":[{"city":"a"},{"city":null}]}`, nulls: []string{"homes[1].city"}},
+	{name: "embedded", body: `{"created_by":null}`, nulls: []string{"created_by"}},
+	{name: "case insensitive keys", body: `{"EMAIL":null}`, nulls: []string{"EMAIL"}},
+	{name: "unknown keys", body: `{"other":null}`},
+}
+
+func TestTools_ReadJSONDisallowNullFields(t *testing.T) {
+	testTools := Tools{DisallowNullFields: true, AllowUnknownFields: true}
+
+	for _, e := range nullFieldsTests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.body))
+		var user nullUser
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &user)
+
+		if len(e.nulls) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", e.name, err)
+			}
+			continue
+		}
+
+		var nullErr *NullFieldsError
+		if !errors.Is(err, ErrNullField) || !errors.As(err, &nullErr) {
+			t.Errorf("%s: expected a *NullFieldsError, but got %v", e.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(nullErr.Fields, e.nulls) {
+			t.Errorf("%s: expected %v to be null, but got %v", e.name, e.nulls, nullErr.Fields)
+		}
+	}
+}
+
+func TestTools_ReadJSONDisallowNullFieldsErrorJSON(t *testing.T) {
+	testTools := Tools{DisallowNullFields: true}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"email":null,"address":{"city":null}}`))
+	var user nullUser
+	err := testTools.ReadJSON(httptest.NewRecorder(), req, &user)
+	if err == nil || err.Error() != "body contains null for fields: address.city, email" {
+		t.Fatalf("wrong error: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	_ = testTools.ErrorJSON(rr, err, http.StatusUnprocessableEntity)
+
+	var body struct {
+		Errors []FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Errors) != 2 || body.Errors[1].Field != "email" || body.Errors[1].Code != "null" {
+		t.Errorf("expected the null fields in the response, but got %s", rr.Body)
+	}
+
+	// Without the flag, null is accepted, as encoding/json accepts it.
+	testTools.DisallowNullFields = false
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"email":null}`))
+	if err := testTools.ReadJSON(httptest.NewRecorder(), req, &user); err != nil {
+		t.Errorf("expected null to be accepted, but got %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package toolbox
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// decodeOps and responseOps name the operations that trackDecode and reportResponse see by kind, for
+// LogOperations.
+var (
+	decodeOps   = map[string]string{"json": "ReadJSON", "xml": "ReadXML", "ndjson": "ReadNDJSON"}
+	responseOps = map[string]string{"json": "WriteJSON", "xml": "WriteXML", "json_error": "ErrorJSON", "xml_error": "ErrorXML", "ndjson": "WriteNDJSON"}
+)
+
+// logsOperations reports whether LogOperations is on, and there is a Logger to log to.
+func (t *Tools) logsOperations() bool {
+	return t.LogOperations && t.Logger != nil
+}
+
+// logOperation logs a summary of one call to op, for LogOperations, with the same attributes every
+// time, so that they can be aggregated: op, bytes (read, written, saved or sent), duration and,
+// where there is one, status and error, followed by extra.
+func (t *Tools) logOperation(ctx context.Context, level slog.Level, op string, bytes int64, duration time.Duration, status int, err error, extra ...slog.Attr) {
+	if !t.Logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := make([]slog.Attr, 0, 5+len(extra))
+	attrs = append(attrs, slog.String("op", op), slog.Int64("bytes", bytes), slog.Duration("duration", duration))
+	if status != 0 {
+		attrs = append(attrs, slog.Int("status", status))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	attrs = append(attrs, extra...)
+
+	t.Logger.LogAttrs(ctx, level, op, attrs...)
+}
@@ -0,0 +1,141 @@
+package toolbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// operationLogs returns Tools with LogOperations on, and a function returning each line logged
+// so far, decoded.
+func operationLogs(level slog.Level) (*Tools, func() []map[string]any) {
+	var buf bytes.Buffer
+	testTools := &Tools{
+		LogOperations: true,
+		Logger:        slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: level})),
+	}
+
+	return testTools, func() []map[string]any {
+		var lines []map[string]any
+		for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+			var entry map[string]any
+			if err := json.Unmarshal(line, &entry); err == nil {
+				lines = append(lines, entry)
+			}
+		}
+		return lines
+	}
+}
+
+func TestTools_LogOperationsReadJSON(t *testing.T) {
+	testTools, logged := operationLogs(slog.LevelInfo)
+
+	body := `{"foo": "bar"}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	var data struct {
+		Foo string `json:"foo"`
+	}
+	if err := testTools.ReadJSON(httptest.NewRecorder(), req, &data); err != nil {
+		t.Fatal(err)
+	}
+	_ = testTools.WriteJSON(httptest.NewRecorder(), http.StatusCreated, data)
+
+	// A bad body is logged with its error.
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"foo":`))
+	_ = testTools.ReadJSON(httptest.NewRecorder(), req, &data)
+
+	lines := logged()
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, but got %v", lines)
+	}
+
+	read, wrote, failed := lines[0], lines[1], lines[2]
+	if read["level"] != "INFO" || read["op"] != "ReadJSON" || read["bytes"] != float64(len(body)) || read["duration"].(float64) <= 0 {
+		t.Errorf("wrong line for ReadJSON: %v", read)
+	}
+	if _, ok := read["status"]; ok {
+		t.Errorf("expected no status for a read, but got %v", read)
+	}
+	if wrote["op"] != "WriteJSON" || wrote["status"] != float64(http.StatusCreated) || wrote["bytes"] != float64(len(`{"foo":"bar"}`)) {
+		t.Errorf("wrong line for WriteJSON: %v", wrote)
+	}
+	if failed["op"] != "ReadJSON" || !strings.Contains(failed["error"].(string), "badly-formed") {
+		t.Errorf("wrong line for a failed read: %v", failed)
+	}
+}
+
+func TestTools_LogOperationsUpload(t *testing.T) {
+	testTools, logged := operationLogs(slog.LevelDebug)
+
+	png, _ := os.ReadFile("./testdata/img.png")
+	req, err := NewMultipartRequestFromReaders("/", []MultipartFile{
+		{FieldName: "first", FileName: "first.png", Content: bytes.NewReader(png)},
+		{FieldName: "second", FileName: "second.png", Content: bytes.NewReader(png)},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := testTools.UploadFiles(req, t.TempDir())
+	if err != nil || len(files) != 2 {
+		t.Fatalf("expected 2 files, but got %d, %v", len(files), err)
+	}
+
+	lines := logged()
+	if len(lines) != 3 {
+		t.Fatalf("expected a line for each file and one for the upload, but got %v", lines)
+	}
+
+	for i, field := range []string{"first", "second"} {
+		if f := lines[i]; f["level"] != "DEBUG" || f["op"] != "UploadFiles" || f["field"] != field || f["bytes"] != float64(len(png)) {
+			t.Errorf("wrong line for file %d: %v", i, f)
+		}
+	}
+	if total := lines[2]; total["level"] != "INFO" || total["op"] != "UploadFiles" || total["files"] != float64(2) ||
+		total["bytes"] != float64(2*len(png)) || total["duration"].(float64) <= 0 {
+		t.Errorf("wrong line for the upload: %v", total)
+	}
+}
+
+func TestTools_LogOperationsRemote(t *testing.T) {
+	testTools, logged := operationLogs(slog.LevelInfo)
+	client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("")), Header: make(http.Header)}, nil
+	})}
+
+	_, _, err := testTools.PushJSONToRemote("http://example.com/hook", map[string]string{"a": "b"}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := logged()
+	if len(lines) != 1 || lines[0]["op"] != "PushJSONToRemote" || lines[0]["status"] != float64(http.StatusOK) ||
+		lines[0]["bytes"] != float64(len(`{"a":"b"}`)) || lines[0]["url"] != "http://example.com/hook" {
+		t.Errorf("wrong line for PushJSONToRemote: %v", lines)
+	}
+}
+
+func TestTools_LogOperationsOff(t *testing.T) {
+	// With the flag off, a Logger alone logs nothing, and the body isn't even wrapped.
+	testTools, logged := operationLogs(slog.LevelDebug)
+	testTools.LogOperations = false
+
+	body := io.NopCloser(strings.NewReader(`{}`))
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Body = body
+	done := testTools.trackDecode(req, "json")
+	if req.Body != body {
+		t.Error("expected the body to be left alone")
+	}
+	_ = done(nil)
+
+	if lines := logged(); len(lines) != 0 {
+		t.Errorf("expected nothing to be logged, but got %v", lines)
+	}
+}
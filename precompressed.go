@@ -0,0 +1,173 @@
+package toolbox
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// precompressedVariants are the suffixes of the precompressed files ServePrecompressed looks for,
+// and their content codings, in order of preference.
+var precompressedVariants = []struct {
+	suffix   string
+	encoding string
+}{
+	{suffix: ".br", encoding: "br"},
+	{suffix: ".gz", encoding: "gzip"},
+}
+
+// StaticDir returns a handler that serves the files in dir, like http.FileServer, but without
+// directory listings, and with any precompressed variants of them if ServePrecompressed is set.
+// Request paths are kept inside dir with SecureJoin. Use http.StripPrefix to mount it below a path:
+//
+//	mux.Handle("/assets/", http.StripPrefix("/assets/", tools.StaticDir("./public")))
+func (t *Tools) StaticDir(dir string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w, done := t.trackDownload(w, r, path.Clean("/"+r.URL.Path))
+		defer done()
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		fp, err := SecureJoin(dir, strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		info, err := os.Stat(fp)
+		if err != nil || info.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+
+		t.serveStatic(w, r, fp, false)
+	})
+}
+
+// serveStatic serves the file at fp with http.ServeFile or, if ServePrecompressed is set and the
+// client accepts it, a precompressed variant of it, with the Content-Type of fp itself. If digest is
+// true, and DownloadDigestAlgorithm is set, a Digest header for the file actually sent is included.
+func (t *Tools) serveStatic(w http.ResponseWriter, r *http.Request, fp string, digest bool) {
+	served, encoding, modTime := fp, "", time.Time{}
+	if t.ServePrecompressed {
+		// The response depends on Accept-Encoding whether or not we find a variant this time.
+		w.Header().Add("Vary", "Accept-Encoding")
+		served, encoding, modTime = precompressedVariant(r, fp)
+	}
+
+	// If requested, publish a checksum of the file, so the client can verify the download.
+	if digest && t.DownloadDigestAlgorithm != "" {
+		if d, err := digestHeader(served, t.DownloadDigestAlgorithm); err == nil {
+			w.Header().Set("Digest", d)
+		}
+	}
+
+	if encoding == "" {
+		http.ServeFile(w, r, fp)
+		return
+	}
+
+	f, err := os.Open(served)
+	if err != nil {
+		http.ServeFile(w, r, fp)
+		return
+	}
+	defer f.Close()
+
+	// ServeContent would sniff the compressed bytes, so work out the type from the original, unless
+	// the caller has set one.
+	if w.Header().Get("Content-Type") == "" {
+		contentType := mime.TypeByExtension(filepath.Ext(fp))
+		if contentType == "" {
+			contentType = sniffFile(fp)
+		}
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Content-Encoding", encoding)
+
+	http.ServeContent(w, r, fp, modTime, f)
+}
+
+// precompressedVariant returns the path of a variant of the file at fp, with one of the suffixes in
+// precompressedVariants, that the client accepts, along with its content coding and the modification
+// time of fp. A variant older than fp is ignored, since it is probably stale. Range requests always
+// get fp itself, so that the byte offsets refer to the file the client asked for. If there is no
+// suitable variant, fp is returned with an empty content coding.
+func precompressedVariant(r *http.Request, fp string) (string, string, time.Time) {
+	accept := r.Header.Get("Accept-Encoding")
+	if accept == "" || r.Header.Get("Range") != "" {
+		return fp, "", time.Time{}
+	}
+
+	info, err := os.Stat(fp)
+	if err != nil || !info.Mode().IsRegular() {
+		return fp, "", time.Time{}
+	}
+
+	for _, v := range precompressedVariants {
+		if !acceptsEncoding(accept, v.encoding) {
+			continue
+		}
+
+		variant, err := os.Stat(fp + v.suffix)
+		if err == nil && variant.Mode().IsRegular() && !variant.ModTime().Before(info.ModTime()) {
+			return fp + v.suffix, v.encoding, info.ModTime()
+		}
+	}
+
+	return fp, "", time.Time{}
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header value header allows the content coding
+// encoding, either by name or with "*", and without a q value of zero.
+func acceptsEncoding(header, encoding string) bool {
+	wildcard := false
+
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.TrimSpace(name)
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(param, "=")
+			if strings.TrimSpace(key) == "q" {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = f
+				}
+			}
+		}
+
+		switch {
+		case strings.EqualFold(name, encoding):
+			return q > 0
+		case name == "*":
+			wildcard = q > 0
+		}
+	}
+
+	return wildcard
+}
+
+// sniffFile returns the content type of the file at fp, from its first 512 bytes.
+func sniffFile(fp string) string {
+	f, err := os.Open(fp)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, _ := io.ReadFull(f, buf)
+
+	return http.DetectContentType(buf[:n])
+}
@@ -0,0 +1,159 @@
+package toolbox
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const precompressedCSS = "body { color: red; }\n"
+
+// writePrecompressed creates foo.css in dir, with gzip and brotli variants whose contents are
+// easy to tell apart.
+func writePrecompressed(t *testing.T, dir string, withBrotli bool) []byte {
+	t.Helper()
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, _ = zw.Write([]byte(precompressedCSS))
+	_ = zw.Close()
+
+	files := map[string][]byte{"foo.css": []byte(precompressedCSS), "foo.css.gz": gz.Bytes()}
+	if withBrotli {
+		files["foo.css.br"] = []byte("not really brotli")
+	}
+	// Give every file the same modification time, so that the variants are never older than foo.css.
+	now := time.Now()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filepath.Join(dir, name), now, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return gz.Bytes()
+}
+
+var precompressedTests = []struct {
+	name             string
+	acceptEncoding   string
+	rangeHeader      string
+	withBrotli       bool
+	staleVariants    bool
+	disabled         bool
+	expectedEncoding string
+}{
+	{name: "gzip accepted", acceptEncoding: "gzip, deflate", expectedEncoding: "gzip"},
+	{name: "nothing accepted"},
+	{name: "gzip refused", acceptEncoding: "gzip;q=0, deflate"},
+	{name: "wildcard", acceptEncoding: "*", expectedEncoding: "gzip"},
+	{name: "brotli preferred", acceptEncoding: "gzip, br", withBrotli: true, expectedEncoding: "br"},
+	{name: "brotli missing", acceptEncoding: "br"},
+	{name: "stale variant", acceptEncoding: "gzip", staleVariants: true},
+	{name: "range request", acceptEncoding: "gzip", rangeHeader: "bytes=0-3"},
+	{name: "not enabled", acceptEncoding: "gzip", disabled: true},
+}
+
+func TestTools_StaticDirPrecompressed(t *testing.T) {
+	for _, e := range precompressedTests {
+		dir := t.TempDir()
+		gz := writePrecompressed(t, dir, e.withBrotli)
+		if e.staleVariants {
+			old := time.Now().Add(-time.Hour)
+			_ = os.Chtimes(filepath.Join(dir, "foo.css.gz"), old, old)
+		}
+
+		testTools := Tools{ServePrecompressed: !e.disabled}
+
+		req := httptest.NewRequest(http.MethodGet, "/foo.css", nil)
+		if e.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", e.acceptEncoding)
+		}
+		if e.rangeHeader != "" {
+			req.Header.Set("Range", e.rangeHeader)
+		}
+		rr := httptest.NewRecorder()
+		testTools.StaticDir(dir).ServeHTTP(rr, req)
+
+		if got := rr.Header().Get("Content-Encoding"); got != e.expectedEncoding {
+			t.Errorf("%s: expected Content-Encoding %q, but got %q", e.name, e.expectedEncoding, got)
+		}
+		if got := rr.Header().Get("Content-Type"); got != "text/css; charset=utf-8" {
+			t.Errorf("%s: expected the type of the uncompressed file, but got %q", e.name, got)
+		}
+		if got := rr.Header().Get("Vary"); (got == "Accept-Encoding") == e.disabled {
+			t.Errorf("%s: unexpected Vary header %q", e.name, got)
+		}
+
+		var expected []byte
+		switch {
+		case e.rangeHeader != "":
+			expected = []byte(precompressedCSS[:4])
+		case e.expectedEncoding == "gzip":
+			expected = gz
+		case e.expectedEncoding == "br":
+			expected = []byte("not really brotli")
+		default:
+			expected = []byte(precompressedCSS)
+		}
+		if !bytes.Equal(rr.Body.Bytes(), expected) {
+			t.Errorf("%s: expected body %q, but got %q", e.name, expected, rr.Body.Bytes())
+		}
+	}
+}
+
+func TestTools_StaticDirNotFound(t *testing.T) {
+	dir := t.TempDir()
+	writePrecompressed(t, dir, false)
+
+	var testTools Tools
+	for p, status := range map[string]int{"/missing.css": http.StatusNotFound, "/": http.StatusNotFound, "/../foo.css": http.StatusBadRequest} {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL.Path = p
+		testTools.StaticDir(dir).ServeHTTP(rr, req)
+		if rr.Code != status {
+			t.Errorf("%s: expected %d, but got %d", p, status, rr.Code)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	testTools.StaticDir(dir).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/foo.css", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for POST, but got %d", rr.Code)
+	}
+}
+
+func TestTools_DownloadStaticFilePrecompressed(t *testing.T) {
+	dir := t.TempDir()
+	gz := writePrecompressed(t, dir, false)
+
+	testTools := Tools{ServePrecompressed: true, DownloadDigestAlgorithm: "sha256"}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	testTools.DownloadStaticFile(rr, req, dir, "foo.css", "styles.css")
+
+	if rr.Header().Get("Content-Encoding") != "gzip" || !bytes.Equal(rr.Body.Bytes(), gz) {
+		t.Errorf("expected the gzip variant, but got %q", rr.Header().Get("Content-Encoding"))
+	}
+	if rr.Header().Get("Content-Type") != "text/css; charset=utf-8" {
+		t.Errorf("expected the type of the uncompressed file, but got %q", rr.Header().Get("Content-Type"))
+	}
+
+	want, err := digestHeader(filepath.Join(dir, "foo.css.gz"), "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.Header().Get("Digest") != want {
+		t.Errorf("expected the digest of the file sent, %s, but got %s", want, rr.Header().Get("Digest"))
+	}
+}
@@ -0,0 +1,15 @@
+//go:build darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd
+
+package toolbox
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive reports whether a process with the ID pid is running, by sending it the null signal;
+// a process we aren't allowed to signal is still running.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,31 @@
+//go:build windows
+
+package toolbox
+
+import (
+	"errors"
+	"syscall"
+)
+
+const (
+	// processQueryLimitedInformation is PROCESS_QUERY_LIMITED_INFORMATION, for OpenProcess.
+	processQueryLimitedInformation = 0x1000
+	// stillActive is the exit code GetExitCodeProcess gives for a process that hasn't exited.
+	stillActive = 259
+)
+
+// processAlive reports whether a process with the ID pid is running. A process we aren't allowed to
+// open is still running.
+func processAlive(pid int) bool {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return errors.Is(err, syscall.ERROR_ACCESS_DENIED)
+	}
+	defer syscall.CloseHandle(h)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
@@ -0,0 +1,36 @@
+//go:build linux
+
+package toolbox
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// processStartTime returns the time the process with the ID pid started, in clock ticks since boot,
+// from field 22 of /proc/<pid>/stat. Two processes that were given the same ID one after another
+// have different start times.
+func processStartTime(pid int) (int64, bool) {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return 0, false
+	}
+
+	// The command name, field 2, is in parentheses and may contain spaces, so fields are counted
+	// from the last closing parenthesis, which is followed by field 3.
+	i := strings.LastIndexByte(string(stat), ')')
+	if i < 0 {
+		return 0, false
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 20 {
+		return 0, false
+	}
+
+	start, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return start, true
+}
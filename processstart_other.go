@@ -0,0 +1,8 @@
+//go:build !(linux || windows)
+
+package toolbox
+
+// processStartTime reports that the start time of a process can't be found here.
+func processStartTime(pid int) (int64, bool) {
+	return 0, false
+}
@@ -0,0 +1,22 @@
+//go:build windows
+
+package toolbox
+
+import "syscall"
+
+// processStartTime returns the creation time of the process with the ID pid, in 100-nanosecond
+// intervals since 1601. Two processes that were given the same ID one after another have different
+// creation times.
+func processStartTime(pid int) (int64, bool) {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err != nil {
+		return 0, false
+	}
+	defer syscall.CloseHandle(h)
+
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return 0, false
+	}
+	return int64(creation.HighDateTime)<<32 | int64(creation.LowDateTime), true
+}
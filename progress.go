@@ -0,0 +1,170 @@
+package toolbox
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultProgressTTL is how long a ProgressTracker keeps an upload after it was last updated, unless
+// NewProgressTracker is given a TTL.
+const defaultProgressTTL = 10 * time.Minute
+
+// ProgressFunc is told how an upload is going, through UploadOptions.Progress: received is the number
+// of bytes of the request body read so far, and total is its size, or -1 if the client didn't say. It
+// is called as the body is read, and once more, with done set, when the upload ends, whether or not
+// it succeeded.
+type ProgressFunc func(received, total int64, done bool)
+
+// progressReader reports each read of a request body to a ProgressFunc.
+type progressReader struct {
+	io.ReadCloser
+	fn       ProgressFunc
+	received int64
+	total    int64
+}
+
+// newProgressReader returns a progressReader for r, which is nil, and does nothing, if fn is nil.
+func newProgressReader(r *http.Request, fn ProgressFunc) *progressReader {
+	if fn == nil {
+		return nil
+	}
+
+	total := r.ContentLength
+	if total <= 0 {
+		total = -1
+	}
+	return &progressReader{fn: fn, total: total}
+}
+
+// wrap returns body, which will be reported as it is read.
+func (p *progressReader) wrap(body io.ReadCloser) io.ReadCloser {
+	if p == nil {
+		return body
+	}
+
+	p.ReadCloser = body
+	p.fn(0, p.total, false)
+	return p
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.received += int64(n)
+		p.fn(p.received, p.total, false)
+	}
+	return n, err
+}
+
+// finish reports that the upload has ended.
+func (p *progressReader) finish() {
+	if p != nil {
+		p.fn(p.received, p.total, true)
+	}
+}
+
+// UploadProgress is how far an upload tracked by a ProgressTracker has got.
+type UploadProgress struct {
+	Received int64 `json:"received"` // bytes of the request body read so far
+	Total    int64 `json:"total"`    // the size of the request body, or -1 if it isn't known
+	Done     bool  `json:"done"`     // whether the upload has ended, successfully or not
+}
+
+// ProgressTracker keeps the progress of uploads in memory, by a token the client chooses (or is
+// given) for each one, so that the browser can poll for it with ProgressHandler while the upload is
+// going on. Uploads are forgotten once they haven't been updated for the TTL, finished or not. It is
+// safe for concurrent use, but lives in a single process, so polls must reach the server doing the
+// upload.
+type ProgressTracker struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	uploads map[string]trackedUpload
+}
+
+// trackedUpload is an entry in a ProgressTracker.
+type trackedUpload struct {
+	progress UploadProgress
+	updated  time.Time
+}
+
+// NewProgressTracker returns a ProgressTracker which forgets uploads ttl after they were last
+// updated, or after 10 minutes if ttl isn't positive.
+func NewProgressTracker(ttl time.Duration) *ProgressTracker {
+	if ttl <= 0 {
+		ttl = defaultProgressTTL
+	}
+	return &ProgressTracker{ttl: ttl, uploads: make(map[string]trackedUpload)}
+}
+
+// Update records the progress of the upload with token.
+func (p *ProgressTracker) Update(token string, progress UploadProgress) {
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Expired uploads are cleared out whenever a new one starts, which keeps the map from growing
+	// without needing a goroutine to do it.
+	if _, ok := p.uploads[token]; !ok {
+		for t, u := range p.uploads {
+			if now.Sub(u.updated) >= p.ttl {
+				delete(p.uploads, t)
+			}
+		}
+	}
+
+	p.uploads[token] = trackedUpload{progress: progress, updated: now}
+}
+
+// Get returns the progress of the upload with token, and false if there is no such upload, or it
+// has expired.
+func (p *ProgressTracker) Get(token string) (UploadProgress, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	u, ok := p.uploads[token]
+	if !ok || time.Since(u.updated) >= p.ttl {
+		return UploadProgress{}, false
+	}
+	return u.progress, true
+}
+
+// TrackUploadProgress returns a ProgressFunc, for UploadOptions.Progress, which records the progress
+// of an upload in tracker under token. The upload is recorded at once, with nothing received, so that
+// polls that arrive before the body is read don't find it missing:
+//
+//	token := r.URL.Query().Get("token")
+//	files, err := tools.UploadFilesWithOptions(r, "", toolbox.UploadOptions{
+//		Progress: toolbox.TrackUploadProgress(tracker, token),
+//	})
+func TrackUploadProgress(tracker *ProgressTracker, token string) ProgressFunc {
+	tracker.Update(token, UploadProgress{Total: -1})
+
+	return func(received, total int64, done bool) {
+		tracker.Update(token, UploadProgress{Received: received, Total: total, Done: done})
+	}
+}
+
+// ProgressHandler returns a handler that sends the progress of the upload named by the token query
+// parameter, from tracker, as JSON: {"received":N,"total":M,"done":false}. Unknown (or expired)
+// tokens get a 404 ErrorJSON response.
+func (t *Tools) ProgressHandler(tracker *ProgressTracker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			_ = t.ErrorJSON(w, errors.New("method not allowed"), http.StatusMethodNotAllowed)
+			return
+		}
+
+		progress, ok := tracker.Get(r.URL.Query().Get("token"))
+		if !ok {
+			_ = t.ErrorJSON(w, errors.New("unknown upload token"), http.StatusNotFound)
+			return
+		}
+
+		_ = t.WriteJSON(w, http.StatusOK, progress, http.Header{"Cache-Control": {"no-store"}})
+	})
+}
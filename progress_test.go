@@ -0,0 +1,118 @@
+package toolbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// trickleBody sends its content a few bytes at a time, with a pause before each read.
+type trickleBody struct {
+	r io.Reader
+}
+
+func (b *trickleBody) Read(p []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+	if len(p) > 8192 {
+		p = p[:8192]
+	}
+	return b.r.Read(p)
+}
+
+func (b *trickleBody) Close() error {
+	return nil
+}
+
+func TestTools_ProgressHandler(t *testing.T) {
+	png, _ := os.ReadFile("./testdata/img.png")
+	req, err := NewMultipartRequestFromReaders("/", []MultipartFile{
+		{FieldName: "file", FileName: "img.png", Content: bytes.NewReader(png)},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(req.Body)
+	req.Body = &trickleBody{r: bytes.NewReader(body)}
+
+	var testTools Tools
+	tracker := NewProgressTracker(time.Minute)
+	handler := testTools.ProgressHandler(tracker)
+
+	poll := func(token string) (UploadProgress, int) {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/progress?token="+token, nil))
+		var progress UploadProgress
+		_ = json.Unmarshal(rr.Body.Bytes(), &progress)
+		return progress, rr.Code
+	}
+
+	if _, code := poll("nope"); code != http.StatusNotFound {
+		t.Errorf("expected a 404 for an unknown token, but got %d", code)
+	}
+
+	uploaded := make(chan error, 1)
+	opts := UploadOptions{Progress: TrackUploadProgress(tracker, "abc")}
+	go func() {
+		_, err := testTools.UploadFilesWithOptions(req, t.TempDir(), opts)
+		uploaded <- err
+	}()
+
+	var last UploadProgress
+	var polls int
+	for !last.Done {
+		progress, code := poll("abc")
+		if code != http.StatusOK {
+			t.Fatalf("expected 200 while uploading, but got %d", code)
+		}
+		if progress.Received < last.Received {
+			t.Fatalf("progress went backwards, from %d to %d", last.Received, progress.Received)
+		}
+		last = progress
+		polls++
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	if err := <-uploaded; err != nil {
+		t.Fatal(err)
+	}
+	if last.Received != int64(len(body)) || last.Total != int64(len(body)) {
+		t.Errorf("expected %d of %d bytes at the end, but got %+v", len(body), len(body), last)
+	}
+	if polls < 3 {
+		t.Errorf("expected to see the upload in progress, but it took %d polls", polls)
+	}
+}
+
+func TestProgressTrackerEviction(t *testing.T) {
+	tracker := NewProgressTracker(20 * time.Millisecond)
+
+	progress := TrackUploadProgress(tracker, "old")
+	if p, ok := tracker.Get("old"); !ok || p.Done || p.Total != -1 {
+		t.Errorf("expected a new upload to be recorded at once, but got %+v, %v", p, ok)
+	}
+
+	// An upload that fails part way through is still marked done.
+	progress(10, 100, false)
+	progress(10, 100, true)
+	if p, _ := tracker.Get("old"); !p.Done || p.Received != 10 {
+		t.Errorf("expected the upload to be done, but got %+v", p)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := tracker.Get("old"); ok {
+		t.Error("expected the upload to have expired")
+	}
+
+	TrackUploadProgress(tracker, "new")
+	tracker.mu.Lock()
+	n := len(tracker.uploads)
+	tracker.mu.Unlock()
+	if n != 1 {
+		t.Errorf("expected the expired upload to be evicted, but there are %d", n)
+	}
+}
@@ -0,0 +1,164 @@
+package toolbox
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// quarantineSidecarSuffix is added to the name of a quarantined file to get the name of the file
+// holding its metadata.
+const quarantineSidecarSuffix = ".quarantine.json"
+
+// quarantineRecord is the metadata kept next to a quarantined file, so that ListQuarantined can
+// reconstruct its UploadedFile.
+type quarantineRecord struct {
+	NewFileName      string `json:"new_file_name"`
+	OriginalFileName string `json:"original_file_name"`
+	FieldName        string `json:"field_name,omitempty"`
+	FileSize         int64  `json:"file_size"`
+	ContentType      string `json:"content_type"`
+	Width            int    `json:"width,omitempty"`
+	Height           int    `json:"height,omitempty"`
+}
+
+// UploadFilesQuarantined is UploadFiles, with the same validation, for uploads that must be reviewed
+// before they are published: the files are saved, with random names, to quarantineDir (which should
+// not be served to anyone), and returned with Quarantined set. Each file is given a small JSON
+// sidecar holding its metadata, so that ListQuarantined can find it again later, for example from a
+// moderation queue. Once reviewed, a file is published with PromoteUpload, or removed with RejectUpload.
+func (t *Tools) UploadFilesQuarantined(r *http.Request, quarantineDir string) ([]*UploadedFile, error) {
+	files, err := t.UploadFiles(r, quarantineDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, f := range files {
+		f.Quarantined = true
+
+		err = t.WriteJSONFile(f.SavedPath+quarantineSidecarSuffix, quarantineRecord{
+			NewFileName:      f.NewFileName,
+			OriginalFileName: f.OriginalFileName,
+			FieldName:        f.FieldName,
+			FileSize:         f.FileSize,
+			ContentType:      f.ContentType,
+			Width:            f.Width,
+			Height:           f.Height,
+		}, 0644, false)
+		if err != nil {
+			// Don't leave files behind that nobody can find to review.
+			for _, g := range files {
+				_ = os.Remove(g.SavedPath)
+			}
+			for _, g := range files[:i] {
+				_ = os.Remove(g.SavedPath + quarantineSidecarSuffix)
+			}
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// ListQuarantined returns the files saved to quarantineDir by UploadFilesQuarantined that have been
+// neither promoted nor rejected, in order of their names. A sidecar whose file has gone is ignored.
+func (t *Tools) ListQuarantined(quarantineDir string) ([]*UploadedFile, error) {
+	quarantineDir, err := t.uploadPath(quarantineDir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(quarantineDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*UploadedFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), quarantineSidecarSuffix) {
+			continue
+		}
+
+		var record quarantineRecord
+		err = t.ReadJSONFile(filepath.Join(quarantineDir, entry.Name()), &record)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+
+		savedPath := filepath.Join(quarantineDir, strings.TrimSuffix(entry.Name(), quarantineSidecarSuffix))
+		if _, err := os.Stat(savedPath); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		files = append(files, &UploadedFile{
+			NewFileName:      record.NewFileName,
+			OriginalFileName: record.OriginalFileName,
+			FieldName:        record.FieldName,
+			FileSize:         record.FileSize,
+			ContentType:      record.ContentType,
+			SavedPath:        savedPath,
+			Quarantined:      true,
+			Width:            record.Width,
+			Height:           record.Height,
+		})
+	}
+
+	return files, nil
+}
+
+// PromoteUpload moves a quarantined file into destDir, keeping its name, with MoveFile, and removes
+// its sidecar. The file's SavedPath is updated, and Quarantined cleared. It is an error for destDir to
+// already have a file with the same name.
+func (t *Tools) PromoteUpload(file *UploadedFile, destDir string) error {
+	if !file.Quarantined {
+		return fmt.Errorf("%s is not quarantined", file.NewFileName)
+	}
+
+	destDir, err := t.uploadPath(destDir)
+	if err != nil {
+		return err
+	}
+
+	err = t.CreateDirIfNotExist(destDir)
+	if err != nil {
+		return err
+	}
+
+	dst := filepath.Join(destDir, filepath.Base(file.SavedPath))
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("cannot promote %s: %w", file.NewFileName, fs.ErrExist)
+	}
+
+	err = MoveFile(file.SavedPath, dst)
+	if err != nil {
+		return err
+	}
+	_ = os.Remove(file.SavedPath + quarantineSidecarSuffix)
+
+	file.SavedPath = dst
+	file.Quarantined = false
+
+	return nil
+}
+
+// RejectUpload deletes a quarantined file, and its sidecar. The file's SavedPath is cleared.
+func (t *Tools) RejectUpload(file *UploadedFile) error {
+	if !file.Quarantined {
+		return fmt.Errorf("%s is not quarantined", file.NewFileName)
+	}
+
+	err := os.Remove(file.SavedPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	_ = os.Remove(file.SavedPath + quarantineSidecarSuffix)
+
+	file.SavedPath = ""
+	file.Quarantined = false
+
+	return nil
+}
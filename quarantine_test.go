@@ -0,0 +1,136 @@
+package toolbox
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestTools_QuarantineWorkflow(t *testing.T) {
+	quarantineDir := filepath.Join(t.TempDir(), "quarantine")
+	publicDir := filepath.Join(t.TempDir(), "public")
+
+	request, err := NewMultipartRequest("/", map[string]string{"a": "./testdata/img.png", "b": "./testdata/tgg.jpg"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testTools Tools
+	uploaded, err := testTools.UploadFilesQuarantined(request, quarantineDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploaded) != 2 {
+		t.Fatalf("expected 2 files, but got %d", len(uploaded))
+	}
+	for _, f := range uploaded {
+		if !f.Quarantined || filepath.Dir(f.SavedPath) != quarantineDir {
+			t.Errorf("expected %s to be quarantined in %s, but got %v at %s", f.OriginalFileName, quarantineDir, f.Quarantined, f.SavedPath)
+		}
+	}
+
+	listed, err := testTools.ListQuarantined(quarantineDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 2 {
+		t.Fatalf("expected 2 quarantined files, but got %d", len(listed))
+	}
+
+	byName := make(map[string]*UploadedFile)
+	for _, f := range uploaded {
+		byName[f.OriginalFileName] = f
+	}
+	for _, f := range listed {
+		want := byName[f.OriginalFileName]
+		if want == nil || *f != *want {
+			t.Errorf("expected the listed metadata to match the upload, but got %+v", f)
+		}
+	}
+
+	png, jpg := byName["img.png"], byName["tgg.jpg"]
+
+	err = testTools.PromoteUpload(png, publicDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if png.Quarantined || png.SavedPath != filepath.Join(publicDir, png.NewFileName) {
+		t.Errorf("expected the promoted file to be in %s, but got %v at %s", publicDir, png.Quarantined, png.SavedPath)
+	}
+
+	err = testTools.RejectUpload(jpg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jpg.Quarantined || jpg.SavedPath != "" {
+		t.Errorf("expected the rejected file to be gone, but got %v at %s", jpg.Quarantined, jpg.SavedPath)
+	}
+
+	if names := dirNames(t, quarantineDir); len(names) != 0 {
+		t.Errorf("expected the quarantine directory to be empty, but it has %v", names)
+	}
+	if names := dirNames(t, publicDir); len(names) != 1 || names[0] != png.NewFileName {
+		t.Errorf("expected the public directory to hold only %s, but it has %v", png.NewFileName, names)
+	}
+
+	listed, err = testTools.ListQuarantined(quarantineDir)
+	if err != nil || len(listed) != 0 {
+		t.Errorf("expected nothing left in quarantine, but got %v, %v", listed, err)
+	}
+
+	// A file can only be promoted or rejected once.
+	if err := testTools.PromoteUpload(png, publicDir); err == nil {
+		t.Error("expected an error promoting a file that is not quarantined, but got none")
+	}
+	if err := testTools.RejectUpload(jpg); err == nil {
+		t.Error("expected an error rejecting a file that is not quarantined, but got none")
+	}
+}
+
+func TestTools_PromoteUploadExisting(t *testing.T) {
+	quarantineDir := t.TempDir()
+	publicDir := t.TempDir()
+
+	request, err := NewMultipartRequest("/", map[string]string{"file": "./testdata/img.png"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testTools Tools
+	uploaded, err := testTools.UploadFilesQuarantined(request, quarantineDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(filepath.Join(publicDir, uploaded[0].NewFileName), []byte("x"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = testTools.PromoteUpload(uploaded[0], publicDir)
+	if err == nil {
+		t.Error("expected an error promoting over an existing file, but got none")
+	}
+	if !uploaded[0].Quarantined {
+		t.Error("expected the file to still be quarantined")
+	}
+}
+
+// dirNames returns the sorted names of the entries in dir.
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,189 @@
+package toolbox
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeType is the reflect.Type of time.Time, which is treated as a scalar rather than a struct.
+var timeType = reflect.TypeOf(time.Time{})
+
+// EncodeQuery converts a struct (or pointer to a struct) into url.Values, using the url struct tag
+// to name each parameter, e.g.
+//
+//	type SearchOptions struct {
+//		Query   string    `url:"q"`
+//		Page    int       `url:"page,omitempty"`
+//		Tags    []string  `url:"tag"`
+//		Fields  []string  `url:"fields,comma"`
+//		Since   time.Time `url:"since,omitempty" layout:"2006-01-02"`
+//		Private *bool     `url:"private"`
+//	}
+//
+// Fields without a tag use the field name, and fields tagged "-" are skipped. Strings, booleans,
+// numbers, time.Time (formatted as RFC 3339 unless a layout tag is given), pointers to these, and
+// slices of these are supported. Slices become repeated parameters, or a single comma separated
+// parameter with the comma option; empty slices are skipped. Nil pointers are always skipped, and
+// zero values are skipped with the omitempty option. Embedded structs are flattened.
+func EncodeQuery(v any) (url.Values, error) {
+	values := make(url.Values)
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return values, nil
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("EncodeQuery: expected a struct, but got %T", v)
+	}
+
+	err := encodeQueryStruct(values, rv)
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// encodeQueryStruct adds the fields of the struct rv to values.
+func encodeQueryStruct(values url.Values, rv reflect.Value) error {
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag := field.Tag.Get("url")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		omitEmpty := hasTagOption(opts, "omitempty")
+		comma := hasTagOption(opts, "comma")
+
+		fv := rv.Field(i)
+
+		if field.Anonymous && tag == "" && fv.Kind() == reflect.Struct {
+			if err := encodeQueryStruct(values, fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		if fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+
+		if fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array {
+			var items []string
+			for j := 0; j < fv.Len(); j++ {
+				s, err := formatQueryValue(fv.Index(j), field)
+				if err != nil {
+					return err
+				}
+				items = append(items, s)
+			}
+
+			if comma && len(items) > 0 {
+				values.Set(name, strings.Join(items, ","))
+			} else if !comma {
+				values[name] = append(values[name], items...)
+			}
+			continue
+		}
+
+		s, err := formatQueryValue(fv, field)
+		if err != nil {
+			return err
+		}
+		values.Set(name, s)
+	}
+
+	return nil
+}
+
+// formatQueryValue formats a single scalar value for a query string.
+func formatQueryValue(v reflect.Value, field reflect.StructField) (string, error) {
+	if v.Type() == timeType {
+		layout := field.Tag.Get("layout")
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return v.Interface().(time.Time).Format(layout), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	}
+
+	return "", fmt.Errorf("EncodeQuery: field %s has unsupported type %s", field.Name, v.Type())
+}
+
+// hasTagOption reports whether the comma separated list of struct tag options contains option.
+func hasTagOption(opts, option string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// WithQueryParams adds query parameters to the request URL. The params may be url.Values, a
+// map[string]string, or a struct, which is converted with EncodeQuery.
+func WithQueryParams(params any) RemoteOption {
+	return func(o *remoteOptions) {
+		var values url.Values
+		switch p := params.(type) {
+		case url.Values:
+			values = p
+		case map[string]string:
+			values = make(url.Values)
+			for k, v := range p {
+				values.Set(k, v)
+			}
+		default:
+			var err error
+			values, err = EncodeQuery(params)
+			if err != nil {
+				o.err = errors.Join(o.err, err)
+				return
+			}
+		}
+
+		for k, v := range values {
+			o.query[k] = append(o.query[k], v...)
+		}
+	}
+}
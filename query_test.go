@@ -0,0 +1,118 @@
+package toolbox
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type queryOptions struct {
+	Query    string    `url:"q"`
+	Page     int       `url:"page,omitempty"`
+	Limit    uint8     `url:"limit"`
+	Ratio    float64   `url:"ratio,omitempty"`
+	Active   bool      `url:"active"`
+	Tags     []string  `url:"tag"`
+	Fields   []string  `url:"fields,comma"`
+	Since    time.Time `url:"since,omitempty"`
+	Day      time.Time `url:"day,omitempty" layout:"2006-01-02"`
+	Private  *bool     `url:"private"`
+	Ignored  string    `url:"-"`
+	NoTag    string
+	internal string
+}
+
+var encodeQueryTests = []struct {
+	name     string
+	input    any
+	expected string
+}{
+	{
+		name: "all kinds",
+		input: queryOptions{
+			Query:    "go lang",
+			Page:     2,
+			Limit:    10,
+			Ratio:    0.5,
+			Active:   true,
+			Tags:     []string{"a", "b"},
+			Fields:   []string{"id", "name"},
+			Since:    time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+			Day:      time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+			Ignored:  "x",
+			NoTag:    "y",
+			internal: "z",
+		},
+		expected: "NoTag=y&active=true&day=2024-01-02&fields=id%2Cname&limit=10&page=2&q=go+lang&ratio=0.5&since=2024-01-02T15%3A04%3A05Z&tag=a&tag=b",
+	},
+	{
+		name:     "omitempty and nil pointer",
+		input:    &queryOptions{Query: "x"},
+		expected: "NoTag=&active=false&limit=0&q=x",
+	},
+}
+
+func TestEncodeQuery(t *testing.T) {
+	for _, e := range encodeQueryTests {
+		values, err := EncodeQuery(e.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		if values.Encode() != e.expected {
+			t.Errorf("%s: wrong query string; expected\n%s\nbut got\n%s", e.name, e.expected, values.Encode())
+		}
+	}
+
+	private := true
+	values, _ := EncodeQuery(queryOptions{Private: &private})
+	if values.Get("private") != "true" {
+		t.Error("pointer value not encoded")
+	}
+}
+
+func TestEncodeQueryErrors(t *testing.T) {
+	_, err := EncodeQuery("not a struct")
+	if err == nil {
+		t.Error("expected error for non-struct, but none received")
+	}
+
+	_, err = EncodeQuery(struct {
+		Bad map[string]string `url:"bad"`
+	}{Bad: map[string]string{"a": "b"}})
+	if err == nil || !bytes.Contains([]byte(err.Error()), []byte("field Bad")) {
+		t.Errorf("expected error naming the field, but got %v", err)
+	}
+}
+
+func TestWithQueryParams(t *testing.T) {
+	var rawQuery string
+	client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		rawQuery = req.URL.RawQuery
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString("")), Header: make(http.Header)}, nil
+	})}
+
+	var testTools Tools
+	results := testTools.PushJSONToMany(context.Background(), []string{"http://example.com/?a=1"}, "x",
+		WithHTTPClient(client), WithQueryParams(struct {
+			Page int `url:"page"`
+		}{Page: 3}))
+
+	if results[0].Err != nil {
+		t.Fatal(results[0].Err)
+	}
+
+	if rawQuery != "a=1&page=3" {
+		t.Errorf("wrong query string sent: %s", rawQuery)
+	}
+
+	results = testTools.PushJSONToMany(context.Background(), []string{"http://example.com/"}, "x",
+		WithHTTPClient(client), WithQueryParams(42))
+	if results[0].Err == nil {
+		t.Error("expected error for invalid query params, but none received")
+	}
+}
@@ -0,0 +1,89 @@
+package toolbox
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTools_RandomStringAlphabet(t *testing.T) {
+	var testTools Tools
+
+	var tests = []struct {
+		name    string
+		fn      func(n int) string
+		charset string
+	}{
+		{name: "secure", fn: testTools.RandomString, charset: randomStringSource},
+		{name: "fast", fn: testTools.RandomStringFast, charset: randomStringSource},
+		{name: "fast charset", fn: func(n int) string { return testTools.RandomStringFastCharset(n, "abcé") }, charset: "abcé"},
+		{name: "fast empty charset", fn: func(n int) string { return testTools.RandomStringFastCharset(n, "") }, charset: randomStringSource},
+	}
+
+	for _, e := range tests {
+		if s := e.fn(0); s != "" {
+			t.Errorf("%s: expected an empty string for n=0, but got %q", e.name, s)
+		}
+
+		s := e.fn(10000)
+		if n := len([]rune(s)); n != 10000 {
+			t.Errorf("%s: expected 10000 characters, but got %d", e.name, n)
+		}
+
+		// Each character should turn up roughly as often as the others; with 10000 draws, being off
+		// by half is vanishingly unlikely unless the selection is biased.
+		counts := map[rune]int{}
+		for _, r := range s {
+			if !strings.ContainsRune(e.charset, r) {
+				t.Fatalf("%s: unexpected character %q", e.name, r)
+			}
+			counts[r]++
+		}
+
+		expected := 10000 / len([]rune(e.charset))
+		for _, r := range e.charset {
+			if counts[r] < expected/2 || counts[r] > expected*3/2 {
+				t.Errorf("%s: %q appeared %d times, expected about %d", e.name, r, counts[r], expected)
+			}
+		}
+	}
+}
+
+func TestTools_RandomStringFastConcurrent(t *testing.T) {
+	var testTools Tools
+
+	var wg sync.WaitGroup
+	results := make([]string, 32)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				results[i] = testTools.RandomStringFast(32)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	for _, s := range results {
+		if len(s) != 32 || seen[s] {
+			t.Errorf("bad or repeated result %q", s)
+		}
+		seen[s] = true
+	}
+}
+
+func BenchmarkTools_RandomString(b *testing.B) {
+	var testTools Tools
+	for i := 0; i < b.N; i++ {
+		_ = testTools.RandomString(32)
+	}
+}
+
+func BenchmarkTools_RandomStringFast(b *testing.B) {
+	var testTools Tools
+	for i := 0; i < b.N; i++ {
+		_ = testTools.RandomStringFast(32)
+	}
+}
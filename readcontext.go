@@ -0,0 +1,162 @@
+package toolbox
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ReadJSONContext is ReadJSON, but stops reading the body as soon as ctx is done, returning ctx's
+// error (context.Canceled, or context.DeadlineExceeded), rather than waiting for a slow client, or
+// one that has gone, to send the rest. If JSONReadTimeout, or BodyReadTimeout, is set, the body must
+// also arrive within that time, or a *ReadTimeoutError is returned.
+// ReadJSON calls it with the request's context, which is cancelled when the client disconnects.
+func (t *Tools) ReadJSONContext(ctx context.Context, w http.ResponseWriter, r *http.Request, data any) error {
+	return t.readJSON(ctx, w, r, data, readJSONOptions{})
+}
+
+// readDeadliner is implemented by the http.ResponseWriter of a server connection, on which a read
+// deadline can be set, as it is for http.ResponseController.
+type readDeadliner interface {
+	SetReadDeadline(deadline time.Time) error
+}
+
+// newContextReader returns rc, wrapped so that reading it stops as soon as ctx is done, unless ctx
+// can never be done, along with a function to call once rc has been read. If w, which may be nil,
+// can set a read deadline on the connection rc comes from, that is how a blocked Read is stopped,
+// with a deadlineReader; otherwise, a contextReader does the reads in a goroutine of its own.
+func newContextReader(ctx context.Context, w http.ResponseWriter, rc io.ReadCloser) (io.ReadCloser, func()) {
+	if ctx.Done() == nil {
+		return rc, func() {}
+	}
+
+	if d := findReadDeadliner(w); d != nil {
+		// Once rc has been read, the request's context is cancelled when the handler returns, and by
+		// then the connection may be reading the next request, so the deadline mustn't be set.
+		stop := context.AfterFunc(ctx, func() { _ = d.SetReadDeadline(time.Now()) })
+		return &deadlineReader{ctx: ctx, rc: rc}, func() { stop() }
+	}
+
+	return &contextReader{ctx: ctx, rc: rc, requests: make(chan []byte), results: make(chan contextReadResult, 1)}, func() {}
+}
+
+// findReadDeadliner returns the readDeadliner w is, or wraps, if there is one, looking through
+// ResponseWriters with an Unwrap method, as http.ResponseController does.
+func findReadDeadliner(w http.ResponseWriter) readDeadliner {
+	for w != nil {
+		if d, ok := w.(readDeadliner); ok {
+			return d
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = u.Unwrap()
+	}
+	return nil
+}
+
+// deadlineReader is an io.ReadCloser for a body read from a server connection, whose read deadline
+// is set, by newContextReader, as soon as ctx is done, so that a blocked Read fails straight away.
+// It returns ctx's error in place of the one that gives.
+type deadlineReader struct {
+	ctx context.Context
+	rc  io.ReadCloser
+}
+
+// Read satisfies the io.Reader interface.
+func (d *deadlineReader) Read(p []byte) (int, error) {
+	if d.ctx.Err() != nil {
+		return 0, readContextError(d.ctx)
+	}
+
+	n, err := d.rc.Read(p)
+	if err != nil && d.ctx.Err() != nil {
+		return n, readContextError(d.ctx)
+	}
+	return n, err
+}
+
+// Close closes the underlying reader.
+func (d *deadlineReader) Close() error {
+	return d.rc.Close()
+}
+
+// contextReader is an io.ReadCloser that returns ctx's error as soon as ctx is done, even while a
+// Read of the underlying reader is blocked. The reads are done by a goroutine, started by the first
+// Read, into a buffer of the contextReader's own, so that a Read abandoned when ctx is done can't
+// write to the caller's buffer later. The goroutine finishes once the underlying reader returns an
+// error, such as io.EOF, or ctx is done; an abandoned Read finishes when the underlying reader is
+// closed, or sends more.
+type contextReader struct {
+	ctx      context.Context
+	rc       io.ReadCloser
+	buf      []byte
+	reading  bool                   // true while the goroutine is running
+	requests chan []byte            // buffers for the goroutine to read into
+	results  chan contextReadResult // the result of each read
+}
+
+// contextReadResult is the result of a single Read of the underlying reader.
+type contextReadResult struct {
+	n   int
+	err error
+}
+
+// Read satisfies the io.Reader interface.
+func (c *contextReader) Read(p []byte) (int, error) {
+	if c.ctx.Err() != nil {
+		return 0, readContextError(c.ctx)
+	}
+
+	// The goroutine is waiting for a request, if it is running, so the buffer is ours to replace.
+	if cap(c.buf) < len(p) {
+		c.buf = make([]byte, len(p))
+	}
+	buf := c.buf[:len(p)]
+
+	if !c.reading {
+		c.reading = true
+		go c.readLoop()
+	}
+
+	select {
+	case c.requests <- buf:
+	case <-c.ctx.Done():
+		return 0, readContextError(c.ctx)
+	}
+
+	select {
+	case res := <-c.results:
+		if res.err != nil {
+			c.reading = false
+		}
+		copy(p, buf[:res.n])
+		return res.n, res.err
+	case <-c.ctx.Done():
+		// The buffer still belongs to the abandoned Read, but ctx stays done, so it is never used again.
+		return 0, readContextError(c.ctx)
+	}
+}
+
+// readLoop reads into each buffer it is sent, until a read fails, or ctx is done.
+func (c *contextReader) readLoop() {
+	for {
+		select {
+		case buf := <-c.requests:
+			n, err := c.rc.Read(buf)
+			c.results <- contextReadResult{n: n, err: err}
+			if err != nil {
+				return
+			}
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// Close closes the underlying reader.
+func (c *contextReader) Close() error {
+	return c.rc.Close()
+}
@@ -0,0 +1,171 @@
+package toolbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowBody sends the start of a JSON object, and then nothing more until it is closed.
+type slowBody struct {
+	sent   bool
+	closed chan struct{}
+}
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	if !b.sent {
+		b.sent = true
+		return copy(p, `{"foo": "b`), nil
+	}
+	<-b.closed
+	return 0, io.ErrClosedPipe
+}
+
+func (b *slowBody) Close() error {
+	close(b.closed)
+	return nil
+}
+
+func TestTools_ReadJSONContext(t *testing.T) {
+	var decoded struct {
+		Foo string `json:"foo"`
+	}
+
+	// A context that is already cancelled stops the read before it starts.
+	var testTools Tools
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	body := &slowBody{closed: make(chan struct{})}
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	err := testTools.ReadJSONContext(ctx, httptest.NewRecorder(), req, &decoded)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, but got %v", err)
+	}
+	_ = body.Close()
+
+	// Cancelling while the body is stalled stops the read straight away.
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	body = &slowBody{closed: make(chan struct{})}
+	req = httptest.NewRequest(http.MethodPost, "/", body)
+	start := time.Now()
+	err = testTools.ReadJSONContext(ctx, httptest.NewRecorder(), req, &decoded)
+	if !errors.Is(err, context.Canceled) || time.Since(start) > time.Second {
+		t.Errorf("expected context.Canceled promptly, but got %v after %s", err, time.Since(start))
+	}
+	_ = body.Close()
+
+	// JSONReadTimeout puts a deadline on reads by ReadJSON too.
+	testTools.JSONReadTimeout = 20 * time.Millisecond
+	body = &slowBody{closed: make(chan struct{})}
+	req = httptest.NewRequest(http.MethodPost, "/", body)
+	start = time.Now()
+	err = testTools.ReadJSON(httptest.NewRecorder(), req, &decoded)
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+		t.Errorf("expected context.DeadlineExceeded promptly, but got %v after %s", err, time.Since(start))
+	}
+	_ = body.Close()
+
+	// A body that arrives in time is read as usual.
+	req = httptest.NewRequest(http.MethodPost, "/", io.NopCloser(&slowReader{data: []byte(`{"foo": "bar"}`)}))
+	err = testTools.ReadJSON(httptest.NewRecorder(), req, &decoded)
+	if err != nil || decoded.Foo != "bar" {
+		t.Errorf("expected to read bar, but got %q and %v", decoded.Foo, err)
+	}
+}
+
+// slowReader returns its data a byte at a time.
+type slowReader struct {
+	data []byte
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	p[0] = r.data[0]
+	r.data = r.data[1:]
+	return 1, nil
+}
+
+func TestContextReaderOneGoroutine(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Reading a body a byte at a time doesn't start a goroutine, or allocate, for every Read.
+	data := []byte(strings.Repeat("a", 1000))
+	allocs := testing.AllocsPerRun(10, func() {
+		body, _ := newContextReader(ctx, nil, io.NopCloser(&slowReader{data: data}))
+		n, err := io.CopyBuffer(io.Discard, struct{ io.Reader }{body}, make([]byte, 1))
+		if n != 1000 || err != nil {
+			t.Fatalf("expected to read 1000 bytes, but got %d, %v", n, err)
+		}
+	})
+	if allocs > 20 {
+		t.Errorf("expected a few allocations, but got %v", allocs)
+	}
+}
+
+func TestTools_ReadJSONContextServer(t *testing.T) {
+	// On a server connection, a stalled body is stopped with a read deadline, and a body read in
+	// time leaves the connection to be used again.
+	testTools := Tools{BodyReadTimeout: 50 * time.Millisecond}
+	errs := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var decoded struct {
+			Foo string `json:"foo"`
+		}
+		start := time.Now()
+		err := testTools.ReadJSON(w, r, &decoded)
+		if err == nil && decoded.Foo != "bar" {
+			err = errors.New("wrong value: " + decoded.Foo)
+		}
+		if err == nil && time.Since(start) > time.Second {
+			err = errors.New("the read took too long")
+		}
+		errs <- err
+	}))
+	defer server.Close()
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go func() { _, _ = pw.Write([]byte(`{"foo": "b`)) }()
+
+	start := time.Now()
+	resp, err := http.Post(server.URL, "application/json", pr)
+	if err == nil {
+		resp.Body.Close()
+	}
+	if err := <-errs; !errors.Is(err, ErrReadTimeout) || time.Since(start) > time.Second {
+		t.Errorf("expected a *ReadTimeoutError promptly, but got %v after %s", err, time.Since(start))
+	}
+
+	var reused []bool
+	trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) { reused = append(reused, info.Reused) }}
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodPost, server.URL, strings.NewReader(`{"foo": "bar"}`))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if err := <-errs; err != nil {
+			t.Errorf("request %d: unexpected error: %v", i, err)
+		}
+	}
+	if len(reused) != 2 || !reused[1] {
+		t.Errorf("expected the connection to be used again, but got %v", reused)
+	}
+}
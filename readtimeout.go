@@ -0,0 +1,50 @@
+package toolbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrReadTimeout matches, with errors.Is, the *ReadTimeoutError returned by ReadJSON and ReadXML
+// when a body takes longer than BodyReadTimeout (or, for ReadJSON, JSONReadTimeout) to arrive, so
+// that a handler can respond with 408 Request Timeout.
+var ErrReadTimeout = errors.New("body took too long to read")
+
+// ReadTimeoutError is returned when a request body isn't read within Timeout, usually because the
+// client is sending it very slowly, or has stopped. It also matches context.DeadlineExceeded, which
+// was returned before there was a type of its own for it.
+type ReadTimeoutError struct {
+	Timeout time.Duration // the time allowed for reading the body
+}
+
+// Error satisfies the error interface.
+func (e *ReadTimeoutError) Error() string {
+	return fmt.Sprintf("body must be sent within %s", e.Timeout)
+}
+
+// Is makes errors.Is(err, ErrReadTimeout) and errors.Is(err, context.DeadlineExceeded) true.
+func (e *ReadTimeoutError) Is(target error) bool {
+	return target == ErrReadTimeout || target == context.DeadlineExceeded
+}
+
+// withReadTimeout returns a copy of ctx that is done once d has passed, with a *ReadTimeoutError as
+// its cause, for the reader from newContextReader to return. If d isn't positive, ctx is returned as
+// it is.
+func withReadTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, d, &ReadTimeoutError{Timeout: d})
+}
+
+// readContextError returns the error a read with ctx fails with, once ctx is done: the
+// *ReadTimeoutError, if it ran out of time set by withReadTimeout, or else ctx's error.
+func readContextError(ctx context.Context) error {
+	var timeout *ReadTimeoutError
+	if errors.As(context.Cause(ctx), &timeout) {
+		return timeout
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,77 @@
+package toolbox
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTools_BodyReadTimeout(t *testing.T) {
+	type note struct {
+		To string `json:"to" xml:"to"`
+	}
+
+	tests := []struct {
+		name        string
+		tools       Tools
+		body        string
+		contentType string
+		read        func(tools *Tools, r *http.Request) error
+	}{
+		{name: "json", tools: Tools{BodyReadTimeout: 50 * time.Millisecond}, body: `{"to": "John Smith"}`,
+			read: func(tools *Tools, r *http.Request) error { return tools.ReadJSON(httptest.NewRecorder(), r, &note{}) }},
+		{name: "json read timeout", tools: Tools{JSONReadTimeout: 50 * time.Millisecond, BodyReadTimeout: time.Hour}, body: `{"to": "John Smith"}`,
+			read: func(tools *Tools, r *http.Request) error { return tools.ReadJSON(httptest.NewRecorder(), r, &note{}) }},
+		{name: "xml", tools: Tools{BodyReadTimeout: 50 * time.Millisecond}, body: `<note><to>John Smith</to></note>`,
+			read: func(tools *Tools, r *http.Request) error { return tools.ReadXML(httptest.NewRecorder(), r, &note{}) }},
+		{name: "xml map", tools: Tools{BodyReadTimeout: 50 * time.Millisecond}, body: `<note><to>John Smith</to></note>`,
+			read: func(tools *Tools, r *http.Request) error {
+				_, err := tools.ReadXMLMap(httptest.NewRecorder(), r)
+				return err
+			}},
+	}
+
+	for _, e := range tests {
+		// A client that sends the start of the body, then a byte every 20ms, is cut off.
+		req := httptest.NewRequest(http.MethodPost, "/", &dribbleBody{r: strings.NewReader(e.body), fast: 4, interval: 20 * time.Millisecond})
+		start := time.Now()
+		err := e.read(&e.tools, req)
+
+		var timeoutErr *ReadTimeoutError
+		if !errors.Is(err, ErrReadTimeout) || !errors.As(err, &timeoutErr) || timeoutErr.Timeout != 50*time.Millisecond {
+			t.Errorf("%s: expected a *ReadTimeoutError, but got %v", e.name, err)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s: expected the error to match context.DeadlineExceeded too", e.name)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: expected the read to stop promptly, but it took %s", e.name, elapsed)
+		}
+
+		// The same body, sent quickly, is read as usual.
+		req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.body))
+		if err := e.read(&e.tools, req); err != nil {
+			t.Errorf("%s: unexpected error for a fast body: %v", e.name, err)
+		}
+	}
+}
+
+func TestTools_BodyReadTimeoutErrorJSON(t *testing.T) {
+	testTools := Tools{BodyReadTimeout: 20 * time.Millisecond}
+
+	req := httptest.NewRequest(http.MethodPost, "/", &dribbleBody{r: strings.NewReader(`{"to": "x"}`), interval: 50 * time.Millisecond})
+	err := testTools.ReadJSON(httptest.NewRecorder(), req, &struct{}{})
+	if err == nil || err.Error() != "body must be sent within 20ms" {
+		t.Fatalf("wrong error: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	_ = testTools.ErrorJSON(rr, err, http.StatusRequestTimeout)
+	if rr.Code != http.StatusRequestTimeout || !strings.Contains(rr.Body.String(), "body must be sent within 20ms") {
+		t.Errorf("wrong response: %d %s", rr.Code, rr.Body)
+	}
+}
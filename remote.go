@@ -0,0 +1,471 @@
+package toolbox
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+)
+
+// defaultRemoteTimeout is the default timeout for calls to remote services.
+const defaultRemoteTimeout = 30 * time.Second
+
+// maxRemoteRedirects is the maximum number of redirects we'll follow when calling a remote service.
+const maxRemoteRedirects = 10
+
+// remoteClient returns the http.Client used for calls to remote services: RemoteClient if it is set,
+// otherwise a client using RemoteTimeout (or a 30 second default).
+func (t *Tools) remoteClient() *http.Client {
+	if t.RemoteClient != nil {
+		return t.RemoteClient
+	}
+
+	timeout := defaultRemoteTimeout
+	if t.RemoteTimeout != 0 {
+		timeout = t.RemoteTimeout
+	}
+
+	return &http.Client{Timeout: timeout}
+}
+
+// FetchRemoteFile downloads the file at uri and saves it to uploadDir, applying the same validation
+// as UploadFiles: the download is aborted as soon as it exceeds MaxFileSize, and the content type
+// must be in AllowedFileTypes. If rename is true, the file is given a random name; otherwise the
+// last element of the URL path is used, or download, if it isn't a file name. Non-2xx responses and
+// excessive redirects are returned as errors.
+func (t *Tools) FetchRemoteFile(ctx context.Context, uri, uploadDir string, rename bool) (*UploadedFile, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("cannot fetch %s: only http and https URLs are supported", uri)
+	}
+
+	uploadDir, err = t.uploadPath(uploadDir)
+	if err != nil {
+		return nil, err
+	}
+
+	fileName := baseFileName(path.Base(u.Path), "download")
+
+	err = t.CreateDirIfNotExist(uploadDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy the client, so that we can limit redirects without modifying the shared one.
+	client := *t.remoteClient()
+	if client.CheckRedirect == nil {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRemoteRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRemoteRedirects)
+			}
+			return nil
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := t.doRemote("FetchRemoteFile", &client, request)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %w", uri, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, fmt.Errorf("error fetching %s: remote server returned %s", uri, response.Status)
+	}
+
+	opts := t.uploadOptions(ctx, UploadOptions{KeepOriginalName: !rename})
+
+	// Fail early if the server tells us the file is too big.
+	if response.ContentLength > int64(opts.MaxFileSize) {
+		return nil, &FileTooBigError{Limit: int64(opts.MaxFileSize), Size: response.ContentLength}
+	}
+
+	return t.saveFile(ctx, response.Body, fileName, uploadDir, opts)
+}
+
+// remoteSnippetSize is the maximum number of bytes of a remote response body kept in a RemoteResult.
+const remoteSnippetSize = 1024
+
+// defaultRemoteConcurrency is the default number of concurrent requests made by PushJSONToMany.
+const defaultRemoteConcurrency = 10
+
+// ErrPreconditionFailed is returned (wrapped) when a remote service responds with 412 Precondition
+// Failed, usually because the resource has changed since the ETag given to WithIfMatch was read.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// ErrNotModified is returned (wrapped) when a remote service responds with 304 Not Modified, because
+// the resource still has the ETag given to WithIfNoneMatch.
+var ErrNotModified = errors.New("not modified")
+
+// RemoteOption configures a call to a remote service.
+type RemoteOption func(*remoteOptions)
+
+// remoteOptions holds the settings applied by a list of RemoteOption.
+type remoteOptions struct {
+	client      *http.Client
+	headers     http.Header
+	query       url.Values
+	timeout     time.Duration
+	concurrency int
+	attempts    int
+	backoff     Backoff
+	length      int64
+	proxy       *url.URL
+	tlsConfig   *tls.Config
+	rootCAs     *x509.CertPool
+	rootCAsSum  [sha256.Size]byte
+	err         error
+}
+
+// WithHTTPClient sets the http.Client used for the call, instead of RemoteClient.
+func WithHTTPClient(client *http.Client) RemoteOption {
+	return func(o *remoteOptions) {
+		o.client = client
+	}
+}
+
+// WithHeader adds a header to the outgoing request.
+func WithHeader(key, value string) RemoteOption {
+	return func(o *remoteOptions) {
+		o.headers.Add(key, value)
+	}
+}
+
+// WithTimeout sets a timeout for each individual request.
+func WithTimeout(d time.Duration) RemoteOption {
+	return func(o *remoteOptions) {
+		o.timeout = d
+	}
+}
+
+// WithConcurrency sets the maximum number of requests made at the same time by PushJSONToMany.
+func WithConcurrency(n int) RemoteOption {
+	return func(o *remoteOptions) {
+		o.concurrency = n
+	}
+}
+
+// WithRetry makes up to attempts tries at each request, waiting delay between them. A request is
+// retried if it fails to reach the remote service, or the remote service returns a 5xx status.
+func WithRetry(attempts int, delay time.Duration) RemoteOption {
+	return WithBackoff(attempts, ConstantBackoff{Interval: delay})
+}
+
+// WithBackoff is like WithRetry, but the wait between tries is decided by strategy.
+func WithBackoff(attempts int, strategy Backoff) RemoteOption {
+	return func(o *remoteOptions) {
+		o.attempts = attempts
+		o.backoff = strategy
+	}
+}
+
+// WithContentLength sets the length of the body sent by PushReaderToRemote, when the reader can't
+// tell us (because it is not an io.Seeker), so that the request is not sent with chunked encoding.
+func WithContentLength(n int64) RemoteOption {
+	return func(o *remoteOptions) {
+		o.length = n
+	}
+}
+
+// WithIfMatch sends an If-Match header, so that the remote service only applies the request if the
+// resource still has etag; if it doesn't, the error returned is ErrPreconditionFailed.
+func WithIfMatch(etag string) RemoteOption {
+	return func(o *remoteOptions) {
+		o.headers.Set("If-Match", etag)
+	}
+}
+
+// WithIfNoneMatch sends an If-None-Match header, so that the remote service only applies the request
+// (or, for a read, sends the resource) if it no longer has etag; if it does, the error returned is
+// ErrNotModified, or ErrPreconditionFailed, depending on the service.
+func WithIfNoneMatch(etag string) RemoteOption {
+	return func(o *remoteOptions) {
+		o.headers.Set("If-None-Match", etag)
+	}
+}
+
+// buildRemoteOptions applies opts on top of the defaults for t.
+func (t *Tools) buildRemoteOptions(opts []RemoteOption) *remoteOptions {
+	o := &remoteOptions{
+		headers:     make(http.Header),
+		query:       make(url.Values),
+		concurrency: defaultRemoteConcurrency,
+		attempts:    1,
+		length:      -1,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.client == nil {
+		o.client = t.remoteClient()
+	}
+	o.applyTransportOptions()
+	if o.concurrency < 1 {
+		o.concurrency = 1
+	}
+	if o.attempts < 1 {
+		o.attempts = 1
+	}
+
+	return o
+}
+
+// RemoteResult is the outcome of a single request made by PushJSONToMany.
+type RemoteResult struct {
+	URI        string        // the URI the request was sent to
+	StatusCode int           // the response status code, or 0 if no response was received
+	Body       []byte        // up to the first 1 kb of the response body
+	Err        error         // the error, if any
+	Duration   time.Duration // how long the request took, including any retries
+}
+
+// PushJSONToMany posts the same JSON payload to every URI in uris, with at most 10 requests (or the
+// number set by WithConcurrency) in flight at once. The returned results are in the same order as
+// uris, and a failure for one URI does not affect the others. Cancelling ctx abandons any outstanding
+// requests, which are returned with ctx.Err() as their error.
+func (t *Tools) PushJSONToMany(ctx context.Context, uris []string, data any, opts ...RemoteOption) []RemoteResult {
+	results := make([]RemoteResult, len(uris))
+	for i, uri := range uris {
+		results[i].URI = uri
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		for i := range results {
+			results[i].Err = err
+		}
+		return results
+	}
+
+	o := t.buildRemoteOptions(opts)
+	if o.err != nil {
+		for i := range results {
+			results[i].Err = o.err
+		}
+		return results
+	}
+
+	sem := make(chan struct{}, o.concurrency)
+
+	var wg sync.WaitGroup
+	for i := range uris {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(res *RemoteResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			start := time.Now()
+			res.StatusCode, res.Body, res.Err = t.sendRemote(ctx, "PushJSONToMany", "POST", res.URI, bytesBody(jsonData), "application/json", o)
+			res.Duration = time.Since(start)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	return results
+}
+
+// remoteBody returns the body for one attempt at a request, and its length, or -1 if that is not
+// known. It is called again for each retry.
+type remoteBody func() (io.Reader, int64, error)
+
+// bytesBody returns a remoteBody that sends b.
+func bytesBody(b []byte) remoteBody {
+	return func() (io.Reader, int64, error) {
+		return bytes.NewReader(b), int64(len(b)), nil
+	}
+}
+
+// sendRemote sends body to uri with the given method and content type, retrying as configured in o,
+// and returns the status code and up to remoteSnippetSize bytes of the response body. The answers to
+// conditional requests, 412 and 304, are returned as ErrPreconditionFailed and ErrNotModified, and
+// are never retried.
+func (t *Tools) sendRemote(ctx context.Context, op, method, uri string, body remoteBody, contentType string, o *remoteOptions) (int, []byte, error) {
+	var status int
+	var snippet []byte
+
+	err := Retry(ctx, o.attempts, o.backoff, func(ctx context.Context) error {
+		var err error
+		status, snippet, err = t.sendRemoteOnce(ctx, op, method, uri, body, contentType, o)
+		if err != nil {
+			return err
+		}
+		switch {
+		case status == http.StatusPreconditionFailed:
+			return Permanent(fmt.Errorf("remote service at %s returned status %d: %w", uri, status, ErrPreconditionFailed))
+		case status == http.StatusNotModified:
+			return Permanent(fmt.Errorf("remote service at %s returned status %d: %w", uri, status, ErrNotModified))
+		case status >= 500:
+			return fmt.Errorf("remote service at %s returned status %d", uri, status)
+		}
+		return nil
+	})
+
+	return status, snippet, err
+}
+
+// sendRemoteOnce makes a single request for sendRemote.
+func (t *Tools) sendRemoteOnce(ctx context.Context, op, method, uri string, body remoteBody, contentType string, o *remoteOptions) (int, []byte, error) {
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+
+	reader, length, err := body()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, uri, reader)
+	if err != nil {
+		if c, ok := reader.(io.Closer); ok {
+			_ = c.Close()
+		}
+		return 0, nil, err
+	}
+	// A length of -1 makes the request use chunked encoding.
+	request.ContentLength = length
+	if length == 0 {
+		request.Body = http.NoBody
+	}
+	if len(o.query) > 0 {
+		query := request.URL.Query()
+		for key, values := range o.query {
+			query[key] = append(query[key], values...)
+		}
+		request.URL.RawQuery = query.Encode()
+	}
+	for key, values := range o.headers {
+		request.Header[key] = values
+	}
+	request.Header.Set("Content-Type", contentType)
+
+	response, err := t.doRemote(op, o.client, request)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer response.Body.Close()
+
+	snippet, err := io.ReadAll(io.LimitReader(response.Body, remoteSnippetSize))
+	if err != nil {
+		return response.StatusCode, snippet, err
+	}
+
+	return response.StatusCode, snippet, nil
+}
+
+// RemoteRequestHook is called before every call the package makes to a remote service. Sensitive
+// headers have already been masked, and bodyLen is -1 if the length of the body is not known.
+type RemoteRequestHook func(method, url string, headers http.Header, bodyLen int)
+
+// RemoteResponseHook is called after every call the package makes to a remote service, with the
+// status code of the response (zero if there wasn't one), and how long it took to arrive.
+type RemoteResponseHook func(method, url string, status int, duration time.Duration, err error)
+
+// redactedValue replaces the values of sensitive headers before they are given to OnRemoteRequest.
+const redactedValue = "[REDACTED]"
+
+// defaultRedactedHeaders are always masked before headers are given to OnRemoteRequest.
+var defaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie"}
+
+// doRemote sends request using client, calling OnRemoteRequest and OnRemoteResponse (or the debug
+// logging versions of them) around it, and logging it as op if LogOperations is on. Every call the
+// package makes to a remote service goes through here.
+func (t *Tools) doRemote(op string, client *http.Client, request *http.Request) (*http.Response, error) {
+	onRequest, onResponse := t.remoteHooks()
+	uri := request.URL.Redacted()
+
+	if onRequest != nil {
+		onRequest(request.Method, uri, t.redactHeaders(request.Header), int(request.ContentLength))
+	}
+
+	start := time.Now()
+	response, err := client.Do(request)
+
+	if onResponse != nil || t.logsOperations() {
+		status := 0
+		if response != nil {
+			status = response.StatusCode
+		}
+		if onResponse != nil {
+			onResponse(request.Method, uri, status, time.Since(start), err)
+		}
+		if t.logsOperations() {
+			// The size of a chunked body isn't known, so it is logged as -1.
+			t.logOperation(request.Context(), slog.LevelInfo, op, request.ContentLength, time.Since(start), status, err,
+				slog.String("method", request.Method), slog.String("url", uri))
+		}
+	}
+
+	return response, err
+}
+
+// remoteHooks returns OnRemoteRequest and OnRemoteResponse or, if neither is set, Debug is true, and
+// we have a Logger, hooks that log each call at debug level.
+func (t *Tools) remoteHooks() (RemoteRequestHook, RemoteResponseHook) {
+	if t.OnRemoteRequest != nil || t.OnRemoteResponse != nil || !t.Debug || t.Logger == nil {
+		return t.OnRemoteRequest, t.OnRemoteResponse
+	}
+
+	onRequest := func(method, url string, headers http.Header, bodyLen int) {
+		t.Logger.Debug("remote request", "method", method, "url", url, "headers", headers, "body_length", bodyLen)
+	}
+	onResponse := func(method, url string, status int, duration time.Duration, err error) {
+		if err != nil {
+			t.Logger.Debug("remote response", "method", method, "url", url, "duration", duration, "error", err)
+			return
+		}
+		t.Logger.Debug("remote response", "method", method, "url", url, "status", status, "duration", duration)
+	}
+
+	return onRequest, onResponse
+}
+
+// redactHeaders returns a copy of headers, with the values of sensitive headers (the defaults, and
+// any in RemoteRedactHeaders) replaced.
+func (t *Tools) redactHeaders(headers http.Header) http.Header {
+	redacted := headers.Clone()
+	if redacted == nil {
+		redacted = make(http.Header)
+	}
+
+	for _, list := range [][]string{defaultRedactedHeaders, t.RemoteRedactHeaders} {
+		for _, name := range list {
+			key := http.CanonicalHeaderKey(name)
+			if values, ok := redacted[key]; ok {
+				for i := range values {
+					values[i] = redactedValue
+				}
+			}
+		}
+	}
+
+	return redacted
+}
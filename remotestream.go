@@ -0,0 +1,79 @@
+package toolbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// ErrBodyNotRewindable is returned when a request is to be retried, but its body is a reader that
+// can only be read once.
+var ErrBodyNotRewindable = errors.New("the request body can't be rewound, so the request can't be retried; use an io.ReadSeeker")
+
+// PushReaderToRemote posts the already encoded contents of body to uri, streaming it rather than
+// reading it into memory first, and returns the response status code and up to the first 1 kb of
+// the response body. If body is an io.Seeker, or WithContentLength is given, the Content-Length
+// header is set; otherwise the body is sent with chunked encoding. Retries (WithRetry or WithBackoff)
+// rewind body to where it was when PushReaderToRemote was called, so they need an io.Seeker, and
+// ErrBodyNotRewindable is returned, before anything is sent, for any other reader. The body is not
+// closed. For conditional requests (WithIfMatch and WithIfNoneMatch), a 412 or 304 response comes
+// back with ErrPreconditionFailed or ErrNotModified, as well as its status code.
+func (t *Tools) PushReaderToRemote(ctx context.Context, uri string, body io.Reader, contentType string, opts ...RemoteOption) (int, []byte, error) {
+	o := t.buildRemoteOptions(opts)
+	if o.err != nil {
+		return 0, nil, o.err
+	}
+
+	// Files on pipes are io.Seekers too, but fail to seek, so we have to try it to find out.
+	seeker, canSeek := body.(io.Seeker)
+	var start int64
+	if canSeek {
+		var err error
+		start, err = seeker.Seek(0, io.SeekCurrent)
+		canSeek = err == nil
+	}
+
+	if o.attempts > 1 && !canSeek {
+		return 0, nil, ErrBodyNotRewindable
+	}
+
+	length := o.length
+	if canSeek && length < 0 {
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, nil, err
+		}
+		length = end - start
+	}
+
+	return t.sendRemote(ctx, "PushReaderToRemote", "POST", uri, func() (io.Reader, int64, error) {
+		if canSeek {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, 0, err
+			}
+		}
+		// Hide the type of body, so that the request neither closes it nor treats it specially.
+		return struct{ io.Reader }{body}, length, nil
+	}, contentType, o)
+}
+
+// PushJSONStreamToRemote is like PushReaderToRemote, but posts data encoded as JSON. The encoding is
+// written straight into the request body as it is sent, so that a large payload is never held in
+// memory in full; the request uses chunked encoding, as its length isn't known in advance. Since data
+// can simply be encoded again, retries work as usual.
+func (t *Tools) PushJSONStreamToRemote(ctx context.Context, uri string, data any, opts ...RemoteOption) (int, []byte, error) {
+	o := t.buildRemoteOptions(opts)
+	if o.err != nil {
+		return 0, nil, o.err
+	}
+
+	return t.sendRemote(ctx, "PushJSONStreamToRemote", "POST", uri, func() (io.Reader, int64, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			// If the request stops reading early, the pipe is closed, and Encode fails.
+			pw.CloseWithError(json.NewEncoder(pw).Encode(data))
+		}()
+		return pr, -1, nil
+	}, "application/json", o)
+}
@@ -0,0 +1,200 @@
+package toolbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// oneShotReader hides everything but Read, like a network connection or a pipe.
+type oneShotReader struct {
+	io.Reader
+}
+
+var pushReaderToRemoteTests = []struct {
+	name           string
+	body           io.Reader
+	opts           []RemoteOption
+	expectedLength int64
+}{
+	{name: "seekable", body: strings.NewReader("hello, world"), expectedLength: 12},
+	{name: "one shot", body: oneShotReader{strings.NewReader("hello, world")}, expectedLength: -1},
+	{name: "explicit length", body: oneShotReader{strings.NewReader("hello, world")}, opts: []RemoteOption{WithContentLength(12)}, expectedLength: 12},
+}
+
+func TestTools_PushReaderToRemote(t *testing.T) {
+	for _, e := range pushReaderToRemoteTests {
+		var received []byte
+		var length int64
+		var contentType string
+		client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			received, _ = io.ReadAll(req.Body)
+			length = req.ContentLength
+			contentType = req.Header.Get("Content-Type")
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok")), Header: make(http.Header)}, nil
+		})}
+
+		var testTools Tools
+		status, snippet, err := testTools.PushReaderToRemote(context.Background(), "http://example.com/sync", e.body, "text/plain", append(e.opts, WithHTTPClient(client))...)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+		if status != http.StatusOK || string(snippet) != "ok" {
+			t.Errorf("%s: expected 200 ok, but got %d %q", e.name, status, snippet)
+		}
+		if string(received) != "hello, world" {
+			t.Errorf("%s: expected the body to be streamed, but got %q", e.name, received)
+		}
+		if length != e.expectedLength {
+			t.Errorf("%s: expected a content length of %d, but got %d", e.name, e.expectedLength, length)
+		}
+		if contentType != "text/plain" {
+			t.Errorf("%s: expected the content type to be sent, but got %q", e.name, contentType)
+		}
+	}
+}
+
+func TestTools_PushReaderToRemoteRetry(t *testing.T) {
+	var bodies []string
+	client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, string(b))
+		status := http.StatusOK
+		if len(bodies) == 1 {
+			status = http.StatusServiceUnavailable
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("")), Header: make(http.Header)}, nil
+	})}
+
+	var testTools Tools
+
+	// Start part way through, to check that retries rewind to where we started rather than the beginning.
+	body := strings.NewReader("skip:payload")
+	_, _ = body.Seek(5, io.SeekStart)
+
+	status, _, err := testTools.PushReaderToRemote(context.Background(), "http://example.com/sync", body, "text/plain", WithHTTPClient(client), WithRetry(2, time.Millisecond))
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("expected success after a retry, but got %d, %v", status, err)
+	}
+	if len(bodies) != 2 || bodies[0] != "payload" || bodies[1] != "payload" {
+		t.Errorf("expected the body to be sent twice, but got %q", bodies)
+	}
+}
+
+var conditionalRemoteTests = []struct {
+	name        string
+	status      int
+	opt         RemoteOption
+	header      string
+	expectedErr error
+}{
+	{name: "if-match ok", status: http.StatusOK, opt: WithIfMatch(`"v1"`), header: "If-Match"},
+	{name: "if-match changed", status: http.StatusPreconditionFailed, opt: WithIfMatch(`"v1"`), header: "If-Match", expectedErr: ErrPreconditionFailed},
+	{name: "if-none-match unchanged", status: http.StatusNotModified, opt: WithIfNoneMatch(`"v1"`), header: "If-None-Match", expectedErr: ErrNotModified},
+}
+
+func TestTools_PushReaderToRemoteConditional(t *testing.T) {
+	for _, e := range conditionalRemoteTests {
+		var sent string
+		var calls int
+		client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls++
+			sent = req.Header.Get(e.header)
+			return &http.Response{StatusCode: e.status, Body: io.NopCloser(strings.NewReader("")), Header: make(http.Header)}, nil
+		})}
+
+		var testTools Tools
+		status, _, err := testTools.PushReaderToRemote(context.Background(), "http://example.com/doc", strings.NewReader("{}"), "application/json",
+			WithHTTPClient(client), e.opt, WithRetry(3, time.Millisecond))
+
+		if sent != `"v1"` {
+			t.Errorf("%s: expected %s to be sent, but got %q", e.name, e.header, sent)
+		}
+		if status != e.status {
+			t.Errorf("%s: expected status %d, but got %d", e.name, e.status, status)
+		}
+		if e.expectedErr == nil && err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+		}
+		if e.expectedErr != nil && !errors.Is(err, e.expectedErr) {
+			t.Errorf("%s: expected %v, but got %v", e.name, e.expectedErr, err)
+		}
+		if calls != 1 {
+			t.Errorf("%s: expected one call, with no retries, but got %d", e.name, calls)
+		}
+	}
+}
+
+func TestTools_PushReaderToRemoteRetryUnrewindable(t *testing.T) {
+	calls := 0
+	client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("")), Header: make(http.Header)}, nil
+	})}
+
+	var testTools Tools
+	_, _, err := testTools.PushReaderToRemote(context.Background(), "http://example.com/sync", oneShotReader{strings.NewReader("x")}, "text/plain", WithHTTPClient(client), WithRetry(3, time.Millisecond))
+	if !errors.Is(err, ErrBodyNotRewindable) {
+		t.Errorf("expected ErrBodyNotRewindable, but got %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("expected no request to be sent, but %d were", calls)
+	}
+}
+
+func TestTools_PushJSONStreamToRemote(t *testing.T) {
+	var bodies [][]byte
+	var length int64
+	client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, b)
+		length = req.ContentLength
+		status := http.StatusOK
+		if len(bodies) == 1 {
+			status = http.StatusBadGateway
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("")), Header: make(http.Header)}, nil
+	})}
+
+	payload := map[string][]string{"items": {strings.Repeat("a", 100000), "b"}}
+
+	var testTools Tools
+	status, _, err := testTools.PushJSONStreamToRemote(context.Background(), "http://example.com/sync", payload, WithHTTPClient(client), WithRetry(2, time.Millisecond))
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("expected success after a retry, but got %d, %v", status, err)
+	}
+	if length != -1 {
+		t.Errorf("expected an unknown content length, but got %d", length)
+	}
+
+	expected, _ := json.Marshal(payload)
+	for i, b := range bodies {
+		if !bytes.Equal(bytes.TrimSpace(b), expected) {
+			t.Errorf("attempt %d: the streamed JSON was not what was expected", i+1)
+		}
+	}
+}
+
+func TestTools_PushJSONStreamToRemoteEncodeError(t *testing.T) {
+	client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		_, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("")), Header: make(http.Header)}, nil
+	})}
+
+	var testTools Tools
+	_, _, err := testTools.PushJSONStreamToRemote(context.Background(), "http://example.com/sync", map[string]any{"c": make(chan int)}, WithHTTPClient(client))
+	var unsupported *json.UnsupportedTypeError
+	if !errors.As(err, &unsupported) {
+		t.Errorf("expected the encoding error, but got %v", err)
+	}
+}
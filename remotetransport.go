@@ -0,0 +1,168 @@
+package toolbox
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// maxRemoteTransports is the most transports remoteTransports keeps. Past that, the one used least
+// recently is dropped, and its idle connections closed, so that callers who build a new tls.Config
+// for every call don't keep a transport, and its connections, for each of them.
+const maxRemoteTransports = 32
+
+// remoteTransports caches the transports built for WithProxy, WithTLSConfig and WithRootCAs, so
+// that calls to the same destination share connections.
+var remoteTransports = newTransportCache(maxRemoteTransports)
+
+// transportCache is a cache of transports, by remoteTransportKey, that keeps no more than max of
+// them, dropping the one used least recently to make room.
+type transportCache struct {
+	mu      sync.Mutex
+	max     int
+	entries map[remoteTransportKey]*list.Element // elements of order
+	order   *list.List                           // the transports, as *transportCacheEntry, most recently used first
+}
+
+// transportCacheEntry is a transport in a transportCache.
+type transportCacheEntry struct {
+	key       remoteTransportKey
+	transport *http.Transport
+}
+
+// newTransportCache returns an empty transportCache that keeps up to max transports.
+func newTransportCache(max int) *transportCache {
+	return &transportCache{max: max, entries: make(map[remoteTransportKey]*list.Element), order: list.New()}
+}
+
+// get returns the transport for key, calling build to make it if it isn't in the cache.
+func (c *transportCache) get(key remoteTransportKey, build func() *http.Transport) *http.Transport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.order.MoveToFront(e)
+		return e.Value.(*transportCacheEntry).transport
+	}
+
+	transport := build()
+	c.entries[key] = c.order.PushFront(&transportCacheEntry{key: key, transport: transport})
+
+	for c.order.Len() > c.max {
+		oldest := c.order.Remove(c.order.Back()).(*transportCacheEntry)
+		delete(c.entries, oldest.key)
+		// Calls still using it finish as usual; only the connections nobody is using are closed.
+		oldest.transport.CloseIdleConnections()
+	}
+
+	return transport
+}
+
+// remoteTransportKey identifies a transport in remoteTransports.
+type remoteTransportKey struct {
+	base    *http.Transport // the transport the cached one was cloned from
+	proxy   string
+	tls     *tls.Config
+	rootCAs [sha256.Size]byte // the checksum of the PEM given to WithRootCAs, if any
+}
+
+// WithProxy sends the call through the proxy at proxyURL, which may be an http, https, or socks5
+// URL, instead of the proxy (if any) set in the environment. An invalid URL makes the call fail
+// before any request is sent.
+func WithProxy(proxyURL string) RemoteOption {
+	return func(o *remoteOptions) {
+		u, err := url.Parse(proxyURL)
+		if err == nil && u.Host == "" {
+			err = errors.New("missing host")
+		}
+		if err == nil {
+			switch u.Scheme {
+			case "http", "https", "socks5", "socks5h":
+			default:
+				err = fmt.Errorf("unsupported scheme %q", u.Scheme)
+			}
+		}
+		if err != nil {
+			o.err = errors.Join(o.err, fmt.Errorf("invalid proxy URL %q: %w", proxyURL, err))
+			return
+		}
+		o.proxy = u
+	}
+}
+
+// WithTLSConfig makes the call with cfg as its TLS configuration, e.g. for client certificates.
+// Connections are shared between calls with the same proxy and the same cfg (the same pointer, not
+// an equal copy), so reuse cfg rather than building a new one for every call: only a few transports
+// are kept, so a new cfg for every call means a new connection for every call too.
+func WithTLSConfig(cfg *tls.Config) RemoteOption {
+	return func(o *remoteOptions) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithRootCAs makes the call trust only the certificate authorities in pem, one or more PEM
+// encoded certificates, instead of the system's. It can be combined with WithTLSConfig. If pem
+// holds no certificates, the call fails before any request is sent.
+func WithRootCAs(pem []byte) RemoteOption {
+	return func(o *remoteOptions) {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			o.err = errors.Join(o.err, errors.New("no certificates could be parsed from the PEM given to WithRootCAs"))
+			return
+		}
+		o.rootCAs = pool
+		o.rootCAsSum = sha256.Sum256(pem)
+	}
+}
+
+// applyTransportOptions replaces o.client with a copy using a transport with the proxy and TLS
+// settings in o, if there are any. The transport is cloned from the client's own, which must be an
+// *http.Transport (or nil, for http.DefaultTransport), and cached for later calls; neither the
+// client nor its transport is modified.
+func (o *remoteOptions) applyTransportOptions() {
+	if o.proxy == nil && o.tlsConfig == nil && o.rootCAs == nil {
+		return
+	}
+
+	roundTripper := o.client.Transport
+	if roundTripper == nil {
+		roundTripper = http.DefaultTransport
+	}
+	base, ok := roundTripper.(*http.Transport)
+	if !ok {
+		o.err = errors.Join(o.err, fmt.Errorf("cannot set a proxy or TLS configuration on a client with a %T transport", roundTripper))
+		return
+	}
+
+	key := remoteTransportKey{base: base, tls: o.tlsConfig, rootCAs: o.rootCAsSum}
+	if o.proxy != nil {
+		key.proxy = o.proxy.String()
+	}
+
+	transport := remoteTransports.get(key, func() *http.Transport {
+		clone := base.Clone()
+		if o.proxy != nil {
+			clone.Proxy = http.ProxyURL(o.proxy)
+		}
+		if o.tlsConfig != nil {
+			clone.TLSClientConfig = o.tlsConfig.Clone()
+		}
+		if o.rootCAs != nil {
+			if clone.TLSClientConfig == nil {
+				clone.TLSClientConfig = &tls.Config{}
+			}
+			clone.TLSClientConfig.RootCAs = o.rootCAs
+		}
+		return clone
+	})
+
+	client := *o.client
+	client.Transport = transport
+	o.client = &client
+}
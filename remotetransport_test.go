@@ -0,0 +1,186 @@
+package toolbox
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTools_RemoteTransportOptions(t *testing.T) {
+	var testTools Tools
+	cfg := &tls.Config{ServerName: "partner.example.com"}
+
+	o := testTools.buildRemoteOptions([]RemoteOption{WithProxy("socks5://proxy.example.com:1080"), WithTLSConfig(cfg)})
+	if o.err != nil {
+		t.Fatal(o.err)
+	}
+
+	transport, ok := o.client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("expected an *http.Transport, but got %T", o.client.Transport)
+	}
+	if transport == http.DefaultTransport {
+		t.Fatal("expected a new transport, not http.DefaultTransport")
+	}
+
+	proxy, err := transport.Proxy(httptest.NewRequest(http.MethodGet, "https://partner.example.com/", nil))
+	if err != nil || proxy == nil || proxy.String() != "socks5://proxy.example.com:1080" {
+		t.Errorf("wrong proxy: %v, %v", proxy, err)
+	}
+	if transport.TLSClientConfig == nil || transport.TLSClientConfig.ServerName != "partner.example.com" {
+		t.Errorf("expected the TLS configuration to be used, but got %+v", transport.TLSClientConfig)
+	}
+	if o.client.Timeout != defaultRemoteTimeout {
+		t.Errorf("expected the default timeout to be kept, but got %s", o.client.Timeout)
+	}
+
+	// The same options get the same transport.
+	again := testTools.buildRemoteOptions([]RemoteOption{WithProxy("socks5://proxy.example.com:1080"), WithTLSConfig(cfg)})
+	if again.client.Transport != transport {
+		t.Error("expected the transport to be reused")
+	}
+
+	// Other calls, and the shared transport, are not affected.
+	if plain := testTools.buildRemoteOptions(nil); plain.client.Transport != nil {
+		t.Errorf("expected calls without the options to use the default transport, but got %T", plain.client.Transport)
+	}
+	if def := http.DefaultTransport.(*http.Transport); def.TLSClientConfig != nil && def.TLSClientConfig.ServerName != "" {
+		t.Error("http.DefaultTransport was modified")
+	}
+
+	// A client with its own transport is copied, not modified.
+	own := &http.Transport{}
+	client := &http.Client{Transport: own}
+	o = testTools.buildRemoteOptions([]RemoteOption{WithHTTPClient(client), WithProxy("http://proxy.example.com:3128")})
+	if o.err != nil || o.client == client || o.client.Transport == own || client.Transport != own || own.Proxy != nil {
+		t.Error("expected a copy of the client, with a copy of its transport")
+	}
+
+	// Transports that aren't an *http.Transport can't be given a proxy.
+	client = &http.Client{Transport: roundTripperFunc(func(*http.Request) (*http.Response, error) { return nil, nil })}
+	o = testTools.buildRemoteOptions([]RemoteOption{WithHTTPClient(client), WithProxy("http://proxy.example.com:3128")})
+	if o.err == nil {
+		t.Error("expected an error for a custom transport")
+	}
+}
+
+var remoteTransportErrorTests = []struct {
+	name string
+	opt  RemoteOption
+}{
+	{name: "proxy without a host", opt: WithProxy("http://")},
+	{name: "proxy with a bad scheme", opt: WithProxy("ftp://proxy.example.com")},
+	{name: "proxy that doesn't parse", opt: WithProxy("http://proxy.example.com:port")},
+	{name: "bad PEM", opt: WithRootCAs([]byte("not a certificate"))},
+}
+
+func TestTools_RemoteTransportOptionErrors(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+
+	var testTools Tools
+	for _, e := range remoteTransportErrorTests {
+		_, _, err := testTools.PushReaderToRemote(context.Background(), server.URL, strings.NewReader("{}"), "application/json", e.opt)
+		if err == nil {
+			t.Errorf("%s: expected an error", e.name)
+		}
+	}
+
+	if hits.Load() != 0 {
+		t.Errorf("expected no requests to be sent, but %d were", hits.Load())
+	}
+}
+
+func TestTools_RemoteRootCAs(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	// The handshake that is expected to fail would otherwise be logged.
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	var testTools Tools
+
+	// The test server's certificate isn't trusted by default.
+	_, _, err := testTools.PushReaderToRemote(context.Background(), server.URL, strings.NewReader("{}"), "application/json")
+	if err == nil {
+		t.Fatal("expected a certificate error without WithRootCAs")
+	}
+
+	status, _, err := testTools.PushReaderToRemote(context.Background(), server.URL, strings.NewReader("{}"), "application/json", WithRootCAs(caPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusAccepted {
+		t.Errorf("expected status %d, but got %d", http.StatusAccepted, status)
+	}
+
+	o := testTools.buildRemoteOptions([]RemoteOption{WithTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}), WithRootCAs(caPEM)})
+	transport := o.client.Transport.(*http.Transport)
+	if transport.TLSClientConfig.RootCAs == nil || transport.TLSClientConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("expected WithRootCAs to be combined with WithTLSConfig, but got %+v", transport.TLSClientConfig)
+	}
+}
+
+func TestTools_RemoteTransportCacheBounded(t *testing.T) {
+	var testTools Tools
+
+	// A new tls.Config for every call doesn't keep a transport for each of them.
+	first := &tls.Config{ServerName: "first.example.com"}
+	o := testTools.buildRemoteOptions([]RemoteOption{WithTLSConfig(first)})
+	transport := o.client.Transport
+
+	for i := 0; i < 2*maxRemoteTransports; i++ {
+		_ = testTools.buildRemoteOptions([]RemoteOption{WithTLSConfig(&tls.Config{})})
+	}
+
+	remoteTransports.mu.Lock()
+	n := len(remoteTransports.entries)
+	remoteTransports.mu.Unlock()
+	if n > maxRemoteTransports {
+		t.Errorf("expected at most %d transports to be kept, but there are %d", maxRemoteTransports, n)
+	}
+
+	// The config used least recently was dropped, so it gets a new transport.
+	if again := testTools.buildRemoteOptions([]RemoteOption{WithTLSConfig(first)}); again.client.Transport == transport {
+		t.Error("expected the least recently used transport to be dropped")
+	}
+}
+
+func TestTransportCache(t *testing.T) {
+	cache := newTransportCache(2)
+	key := func(name string) remoteTransportKey { return remoteTransportKey{proxy: name} }
+	build := func() *http.Transport { return &http.Transport{} }
+
+	a := cache.get(key("a"), build)
+	b := cache.get(key("b"), build)
+
+	// Using a makes b the least recently used, so it is the one dropped for c.
+	if cache.get(key("a"), build) != a {
+		t.Error("expected a to be cached")
+	}
+	_ = cache.get(key("c"), build)
+
+	if cache.get(key("a"), build) != a {
+		t.Error("expected a to be kept")
+	}
+	if cache.get(key("b"), build) == b {
+		t.Error("expected b to be dropped")
+	}
+	if cache.order.Len() != 2 || len(cache.entries) != 2 {
+		t.Errorf("expected 2 transports, but got %d", cache.order.Len())
+	}
+}
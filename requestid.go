@@ -0,0 +1,133 @@
+package toolbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// defaultRequestIDHeader is the header RequestID reads and writes, unless RequestIDHeader is set.
+const defaultRequestIDHeader = "X-Request-ID"
+
+// defaultRequestIDField is the name of the field ErrorJSONCtx and ErrorXMLCtx add, unless
+// RequestIDField is set.
+const defaultRequestIDField = "request_id"
+
+// maxRequestIDLength is the longest request ID we'll accept from a client.
+const maxRequestIDLength = 128
+
+// requestIDKey is the context key for the request ID.
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx by the RequestID middleware, or an
+// empty string if there isn't one.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID is middleware that gives every request an ID. If the request already has one in the
+// X-Request-ID header (or RequestIDHeader, if set), and it is reasonable, it is kept; otherwise a
+// random one is generated. The ID is sent back in the same response header, and stored in the
+// request context, where it can be read with RequestIDFromContext, and where ErrorJSONCtx and
+// ErrorXMLCtx find it.
+func (t *Tools) RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := t.requestIDHeader()
+
+		id := r.Header.Get(header)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(header, id)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
+
+// ErrorJSONCtx is ErrorJSON, but if r has a request ID, it is added to the payload as
+// "request_id" (or RequestIDField, if set), so that clients can quote it when reporting problems.
+// Without a request ID, the payload is exactly what ErrorJSON sends.
+func (t *Tools) ErrorJSONCtx(w http.ResponseWriter, r *http.Request, err error, status ...int) error {
+	id := RequestIDFromContext(r.Context())
+	if id == "" {
+		return t.ErrorJSON(w, err, status...)
+	}
+
+	statusCode := http.StatusBadRequest
+
+	// If a custom response code is specified, use that instead of bad request.
+	if len(status) > 0 {
+		statusCode = status[0]
+	}
+
+	extra := append([]jsonField{{Key: t.requestIDField(), Value: id}}, t.fieldErrorsMember(err, statusCode)...)
+	payload := t.jsonEnvelope(true, t.errorMessage(err, statusCode, id), nil, extra...)
+
+	return t.writeJSON(w, "json_error", statusCode, payload)
+}
+
+// ErrorXMLCtx is ErrorXML, but if r has a request ID, it is added to the payload as a request_id
+// element (or RequestIDField, if set). Without a request ID, the payload is exactly what ErrorXML sends.
+func (t *Tools) ErrorXMLCtx(w http.ResponseWriter, r *http.Request, err error, status ...int) error {
+	id := RequestIDFromContext(r.Context())
+	if id == "" {
+		return t.ErrorXML(w, err, status...)
+	}
+
+	statusCode := http.StatusBadRequest
+
+	// If a custom response code is specified, use that instead of bad request.
+	if len(status) > 0 {
+		statusCode = status[0]
+	}
+
+	payload := t.xmlEnvelope(true, t.errorMessage(err, statusCode, id), nil, xmlElement{Name: t.requestIDField(), Value: id})
+
+	return t.writeXML(w, "xml_error", statusCode, payload)
+}
+
+// requestIDHeader returns the header used for request IDs.
+func (t *Tools) requestIDHeader() string {
+	if t.RequestIDHeader != "" {
+		return t.RequestIDHeader
+	}
+	return defaultRequestIDHeader
+}
+
+// requestIDField returns the name of the request ID field in error payloads.
+func (t *Tools) requestIDField() string {
+	if t.RequestIDField != "" {
+		return t.RequestIDField
+	}
+	return defaultRequestIDField
+}
+
+// validRequestID reports whether id, sent by a client, is safe to reuse: not empty, not too long,
+// and only printable ASCII, so it can't be used to inject anything into logs or headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < '!' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newRequestID returns a random, 32 character hex string.
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
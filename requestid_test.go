@@ -0,0 +1,110 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTools_RequestID(t *testing.T) {
+	var testTools Tools
+
+	var seen string
+	handler := testTools.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if len(seen) != 32 || rr.Header().Get("X-Request-ID") != seen {
+		t.Errorf("expected a generated ID in the context and header, but got %q and %q", seen, rr.Header().Get("X-Request-ID"))
+	}
+
+	// A sensible incoming ID is kept; a nasty one is replaced.
+	for id, keep := range map[string]bool{"abc-123": true, "bad\nid": false, strings.Repeat("x", 200): false} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-ID", id)
+
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if (seen == id) != keep {
+			t.Errorf("%q: expected kept to be %t, but got ID %q", id, keep, seen)
+		}
+	}
+}
+
+func TestTools_ErrorJSONCtx(t *testing.T) {
+	testTools := Tools{RequestIDHeader: "X-Trace-ID"}
+
+	handler := testTools.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = testTools.ErrorJSONCtx(w, r, errors.New("some error"), http.StatusUnprocessableEntity)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("wrong status code; expected %d but got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+
+	var payload map[string]any
+	err := json.NewDecoder(rr.Body).Decode(&payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if payload["request_id"] != rr.Header().Get("X-Trace-ID") || payload["message"] != "some error" || payload["error"] != true {
+		t.Errorf("wrong payload: %v", payload)
+	}
+
+	// Without an ID, the payload is the same as from ErrorJSON, and the field name can be changed.
+	rr = httptest.NewRecorder()
+	_ = testTools.ErrorJSONCtx(rr, httptest.NewRequest(http.MethodGet, "/", nil), errors.New("some error"))
+	if rr.Body.String() != `{"error":true,"message":"some error"}` {
+		t.Errorf("expected plain ErrorJSON payload, but got %s", rr.Body.String())
+	}
+
+	testTools.RequestIDField = "traceId"
+	rr = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	_ = testTools.ErrorJSONCtx(rr, req.WithContext(WithRequestID(req.Context(), "abc")), errors.New("some error"))
+	if rr.Body.String() != `{"error":true,"message":"some error","traceId":"abc"}` {
+		t.Errorf("wrong payload with custom field: %s", rr.Body.String())
+	}
+}
+
+func TestTools_ErrorXMLCtx(t *testing.T) {
+	var testTools Tools
+
+	handler := testTools.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = testTools.ErrorXMLCtx(w, r, errors.New("some error"))
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var payload struct {
+		Message   string `xml:"message"`
+		RequestID string `xml:"request_id"`
+	}
+	err := xml.NewDecoder(rr.Body).Decode(&payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if payload.RequestID == "" || payload.RequestID != rr.Header().Get("X-Request-ID") || payload.Message != "some error" {
+		t.Errorf("wrong payload: %+v", payload)
+	}
+
+	rr = httptest.NewRecorder()
+	_ = testTools.ErrorXMLCtx(rr, httptest.NewRequest(http.MethodGet, "/", nil), errors.New("some error"))
+	if strings.Contains(rr.Body.String(), "request_id") {
+		t.Errorf("expected no request ID, but got %s", rr.Body.String())
+	}
+}
@@ -0,0 +1,175 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrMissingFields matches, with errors.Is, the *MissingFieldsError returned by ReadJSON when
+// RequireJSONFields is set and the body leaves out a required field.
+var ErrMissingFields = errors.New("missing required JSON fields")
+
+// MissingFieldsError lists the required fields a JSON body left out, or sent as null. A field is
+// required if its json tag has the required option, as in json:"title,required", which
+// encoding/json ignores. Since it unwraps to FieldErrors, with the code required, ErrorJSON sends
+// the list to the client.
+type MissingFieldsError struct {
+	// Fields are the missing fields, by their paths in the JSON, such as title, author.name or
+	// items[2].sku, in the order they appear in the struct.
+	Fields []string
+}
+
+// Error satisfies the error interface.
+func (e *MissingFieldsError) Error() string {
+	return "body is missing required fields: " + strings.Join(e.Fields, ", ")
+}
+
+// Is makes errors.Is(err, ErrMissingFields) true.
+func (e *MissingFieldsError) Is(target error) bool {
+	return target == ErrMissingFields
+}
+
+// Unwrap returns the missing fields as FieldErrors.
+func (e *MissingFieldsError) Unwrap() error {
+	fieldErrs := make(FieldErrors, len(e.Fields))
+	for i, field := range e.Fields {
+		fieldErrs[i] = FieldError{Field: field, Code: "required", Message: "is required"}
+	}
+	return fieldErrs
+}
+
+// hasRequiredJSONFields reports whether a value of type rt, or anything in it, has a field tagged
+// as required, so that ReadJSON only keeps the body to check when there is something to check.
+func hasRequiredJSONFields(rt reflect.Type, seen map[reflect.Type]bool) bool {
+	for rt.Kind() == reflect.Pointer || rt.Kind() == reflect.Slice || rt.Kind() == reflect.Array || rt.Kind() == reflect.Map {
+		rt = rt.Elem()
+	}
+	if rt.Kind() != reflect.Struct || seen[rt] {
+		return false
+	}
+	seen[rt] = true
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("json")
+		if (!field.IsExported() && !field.Anonymous) || tag == "-" {
+			continue
+		}
+
+		_, tagOpts, _ := strings.Cut(tag, ",")
+		if hasTagOption(tagOpts, "required") || hasRequiredJSONFields(field.Type, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRequiredJSONFields returns a *MissingFieldsError if body, which has already been decoded into
+// data, leaves out any of the required fields of data's type, at any depth.
+func checkRequiredJSONFields(body []byte, data any) error {
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return err
+	}
+
+	var missing []string
+	findMissingJSONFields(reflect.TypeOf(data), doc, "", &missing)
+	if len(missing) > 0 {
+		return &MissingFieldsError{Fields: missing}
+	}
+	return nil
+}
+
+// findMissingJSONFields adds to missing the path of each required field left out of value, the
+// decoded JSON for a value of type rt at path, looking inside structs, slices, arrays and maps.
+func findMissingJSONFields(rt reflect.Type, value any, path string, missing *[]string) {
+	for rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+
+	switch rt.Kind() {
+	case reflect.Struct:
+		if obj, ok := value.(map[string]any); ok {
+			findMissingStructFields(rt, obj, path, missing)
+		}
+
+	case reflect.Slice, reflect.Array:
+		items, _ := value.([]any)
+		for i, item := range items {
+			findMissingJSONFields(rt.Elem(), item, fmt.Sprintf("%s[%d]", path, i), missing)
+		}
+
+	case reflect.Map:
+		obj, _ := value.(map[string]any)
+		for _, key := range sortedKeys(obj) {
+			findMissingJSONFields(rt.Elem(), obj[key], joinJSONPath(path, key), missing)
+		}
+	}
+}
+
+// findMissingStructFields is findMissingJSONFields for a struct type rt, decoded as obj.
+func findMissingStructFields(rt reflect.Type, obj map[string]any, path string, missing *[]string) {
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("json")
+		if (!field.IsExported() && !field.Anonymous) || tag == "-" {
+			continue
+		}
+
+		name, tagOpts, _ := strings.Cut(tag, ",")
+
+		// The fields of an embedded struct without a name of its own are promoted into this one.
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				findMissingStructFields(ft, obj, path, missing)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldPath := joinJSONPath(path, name)
+		value, ok := lookupJSONKey(obj, name)
+		if !ok || value == nil {
+			if hasTagOption(tagOpts, "required") {
+				*missing = append(*missing, fieldPath)
+			}
+			continue
+		}
+
+		findMissingJSONFields(field.Type, value, fieldPath, missing)
+	}
+}
+
+// lookupJSONKey returns the member of obj that encoding/json would decode into a field named name:
+// the one with exactly that key, or else one whose key matches it ignoring case.
+func lookupJSONKey(obj map[string]any, name string) (any, bool) {
+	if value, ok := obj[name]; ok {
+		return value, true
+	}
+	for key, value := range obj {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// joinJSONPath returns the path of the member name of the object at path.
+func joinJSONPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,115 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type requiredAuthor struct {
+	Name  string `json:"name,required"`
+	Email string `json:"email"`
+}
+
+type requiredItem struct {
+	SKU string `json:"sku,required"`
+	Qty int    `json:"qty,required"`
+}
+
+type requiredAudit struct {
+	CreatedBy string `json:"created_by,required"`
+}
+
+type requiredPost struct {
+	requiredAudit
+	Title   string                  `json:"title,required"`
+	Summary string                  `json:"summary"`
+	Author  *requiredAuthor         `json:"author,required"`
+	Items   []requiredItem          `json:"items"`
+	ByLang  map[string]requiredItem `json:"by_lang"`
+}
+
+var requiredFieldsTests = []struct {
+	name    string
+	body    string
+	missing []string
+}{
+	{name: "complete", body: `{"created_by":"x","title":"","author":{"name":"a"},"items":[{"sku":"s","qty":0}]}`},
+	{name: "case insensitive keys", body: `{"Created_By":"x","TITLE":"t","author":{"Name":"a"}}`},
+	{name: "everything missing", body: `{}`, missing: []string{"created_by", "title", "author"}},
+	{name: "null", body: `{"created_by":"x","title":null,"author":null}`, missing: []string{"title", "author"}},
+	{name: "nested", body: `{"created_by":"x","title":"t","author":{"email":"e"}}`, missing: []string{"author.name"}},
+	{name: "slice of structs", body: `{"created_by":"x","title":"t","author":{"name":"a"},"items":[{"sku":"a","qty":1},{"qty":2},{}]}`,
+		missing: []string{"items[1].sku", "items[2].sku", "items[2].qty"}},
+	{name: "map of structs", body: `{"created_by":"x","title":"t","author":{"name":"a"},"by_lang":{"fr":{"sku":"b"},"en":{"qty":1}}}`,
+		missing: []string{"by_lang.en.sku", "by_lang.fr.qty"}},
+}
+
+func TestTools_ReadJSONRequiredFields(t *testing.T) {
+	testTools := Tools{RequireJSONFields: true}
+
+	for _, e := range requiredFieldsTests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.body))
+		var post requiredPost
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &post)
+
+		if len(e.missing) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", e.name, err)
+			}
+			continue
+		}
+
+		var missingErr *MissingFieldsError
+		if !errors.Is(err, ErrMissingFields) || !errors.As(err, &missingErr) {
+			t.Errorf("%s: expected a *MissingFieldsError, but got %v", e.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(missingErr.Fields, e.missing) {
+			t.Errorf("%s: expected %v to be missing, but got %v", e.name, e.missing, missingErr.Fields)
+		}
+	}
+}
+
+func TestTools_ReadJSONRequiredFieldsErrorJSON(t *testing.T) {
+	testTools := Tools{RequireJSONFields: true}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"author":{}}`))
+	var post requiredPost
+	err := testTools.ReadJSON(httptest.NewRecorder(), req, &post)
+	if err == nil || err.Error() != "body is missing required fields: created_by, title, author.name" {
+		t.Fatalf("wrong error: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	_ = testTools.ErrorJSON(rr, err, http.StatusUnprocessableEntity)
+
+	var body struct {
+		Errors []FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Errors) != 3 || body.Errors[2].Field != "author.name" || body.Errors[2].Code != "required" {
+		t.Errorf("expected the missing fields in the response, but got %s", rr.Body)
+	}
+}
+
+func TestTools_ReadJSONRequiredFieldsOff(t *testing.T) {
+	// Without the flag, a body missing required fields is read as before.
+	var testTools Tools
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"summary":"s"}`))
+	var post requiredPost
+	if err := testTools.ReadJSON(httptest.NewRecorder(), req, &post); err != nil || post.Summary != "s" {
+		t.Errorf("expected the body to be read, but got %v", err)
+	}
+
+	if hasRequiredJSONFields(reflect.TypeOf(&struct{ A, B string }{}), map[reflect.Type]bool{}) {
+		t.Error("expected a struct without tags to have no required fields")
+	}
+}
@@ -0,0 +1,105 @@
+package toolbox
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ResponseRecorder wraps an http.ResponseWriter, recording the status code sent, the number of body
+// bytes written, and when the response started, for logging and metrics middleware. Flush, Hijack and
+// ReadFrom are passed on to the wrapped writer (or whatever it wraps, through Unwrap), so wrapping a
+// writer doesn't stop handlers from streaming. Like any http.ResponseWriter, it must not be used by
+// more than one goroutine at a time.
+type ResponseRecorder struct {
+	http.ResponseWriter
+	status     int
+	written    int64
+	firstWrite time.Time
+}
+
+// WrapResponseWriter returns a ResponseRecorder wrapping w.
+func WrapResponseWriter(w http.ResponseWriter) *ResponseRecorder {
+	return &ResponseRecorder{ResponseWriter: w}
+}
+
+// Status returns the status code sent. If nothing has been sent yet, that's http.StatusOK, since
+// that's what the server sends for a handler that doesn't set one.
+func (rr *ResponseRecorder) Status() int {
+	if rr.status == 0 {
+		return http.StatusOK
+	}
+	return rr.status
+}
+
+// BytesWritten returns the number of body bytes written so far.
+func (rr *ResponseRecorder) BytesWritten() int64 {
+	return rr.written
+}
+
+// FirstWrite returns when the status and headers were sent, which is the zero time if nothing has
+// been sent yet.
+func (rr *ResponseRecorder) FirstWrite() time.Time {
+	return rr.firstWrite
+}
+
+// WriteHeader records the first final (not 1xx) status code, and passes it on.
+func (rr *ResponseRecorder) WriteHeader(status int) {
+	if rr.status == 0 && status >= 200 {
+		rr.status = status
+		rr.firstWrite = time.Now()
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes written, and passes them on. If the status hasn't been sent, it is 200.
+func (rr *ResponseRecorder) Write(b []byte) (int, error) {
+	rr.sendingBody()
+	n, err := rr.ResponseWriter.Write(b)
+	rr.written += int64(n)
+	return n, err
+}
+
+// ReadFrom copies from src to the wrapped writer, counting the bytes, using the wrapped writer's own
+// ReadFrom, if it has one, so that files can still be sent with sendfile.
+func (rr *ResponseRecorder) ReadFrom(src io.Reader) (int64, error) {
+	rr.sendingBody()
+
+	var n int64
+	var err error
+	if rf, ok := rr.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		n, err = io.Copy(struct{ io.Writer }{rr.ResponseWriter}, src)
+	}
+	rr.written += n
+	return n, err
+}
+
+// Flush sends any buffered data to the client, if the wrapped writer supports it.
+func (rr *ResponseRecorder) Flush() {
+	rr.sendingBody()
+	_ = http.NewResponseController(rr.ResponseWriter).Flush()
+}
+
+// Hijack lets the caller take over the connection, if the wrapped writer supports it; if it doesn't,
+// the error matches http.ErrNotSupported.
+func (rr *ResponseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(rr.ResponseWriter).Hijack()
+}
+
+// Unwrap returns the wrapped http.ResponseWriter, for http.ResponseController.
+func (rr *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
+// sendingBody records a status of 200 if nothing has been sent yet, since sending anything sends
+// the headers with that status.
+func (rr *ResponseRecorder) sendingBody() {
+	if rr.status == 0 {
+		rr.status = http.StatusOK
+		rr.firstWrite = time.Now()
+	}
+}
@@ -0,0 +1,127 @@
+package toolbox
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseRecorder(t *testing.T) {
+	var tests = []struct {
+		name            string
+		handler         func(w http.ResponseWriter)
+		expectedStatus  int
+		expectedWritten int64
+		expectWrite     bool
+	}{
+		{name: "nothing written", handler: func(w http.ResponseWriter) {}, expectedStatus: http.StatusOK},
+		{name: "write without WriteHeader", handler: func(w http.ResponseWriter) {
+			_, _ = w.Write([]byte("hello"))
+			_, _ = w.Write([]byte(" world"))
+		}, expectedStatus: http.StatusOK, expectedWritten: 11, expectWrite: true},
+		{name: "WriteHeader", handler: func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte("made"))
+		}, expectedStatus: http.StatusCreated, expectedWritten: 4, expectWrite: true},
+		{name: "WriteHeader twice", handler: func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusNotFound)
+			w.WriteHeader(http.StatusOK)
+		}, expectedStatus: http.StatusNotFound, expectWrite: true},
+		{name: "informational status first", handler: func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusEarlyHints)
+			w.WriteHeader(http.StatusAccepted)
+		}, expectedStatus: http.StatusAccepted, expectWrite: true},
+		{name: "ReadFrom", handler: func(w http.ResponseWriter) {
+			_, _ = io.Copy(w, strings.NewReader("copied"))
+		}, expectedStatus: http.StatusOK, expectedWritten: 6, expectWrite: true},
+	}
+
+	for _, e := range tests {
+		rec := httptest.NewRecorder()
+		rr := WrapResponseWriter(rec)
+
+		e.handler(rr)
+
+		if rr.Status() != e.expectedStatus {
+			t.Errorf("%s: expected status %d, but got %d", e.name, e.expectedStatus, rr.Status())
+		}
+		if rr.BytesWritten() != e.expectedWritten || int64(rec.Body.Len()) != e.expectedWritten {
+			t.Errorf("%s: expected %d bytes, but counted %d, and %d arrived", e.name, e.expectedWritten, rr.BytesWritten(), rec.Body.Len())
+		}
+		if rr.FirstWrite().IsZero() == e.expectWrite {
+			t.Errorf("%s: wrong first write time: %s", e.name, rr.FirstWrite())
+		}
+		// httptest.ResponseRecorder keeps an informational status, which a real client never sees.
+		if e.expectWrite && rec.Code != e.expectedStatus && rec.Code >= 200 {
+			t.Errorf("%s: expected the client to get %d, but it got %d", e.name, e.expectedStatus, rec.Code)
+		}
+	}
+}
+
+func TestResponseRecorderFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rr := WrapResponseWriter(rec)
+
+	var w http.ResponseWriter = rr
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		t.Fatal("expected ResponseRecorder to be an http.Flusher")
+	}
+	flusher.Flush()
+
+	if !rec.Flushed {
+		t.Error("expected Flush to reach the wrapped writer")
+	}
+	if rr.Status() != http.StatusOK || rr.FirstWrite().IsZero() {
+		t.Error("expected flushing to send the headers, with a status of 200")
+	}
+
+	// Flushing through another wrapper, found with Unwrap, works too.
+	rec = httptest.NewRecorder()
+	WrapResponseWriter(WrapResponseWriter(rec)).Flush()
+	if !rec.Flushed {
+		t.Error("expected Flush to reach a writer wrapped twice")
+	}
+
+	// The streaming helpers work through a ResponseRecorder.
+	sse, err := NewSSEWriter(WrapResponseWriter(httptest.NewRecorder()))
+	if err != nil || sse == nil {
+		t.Errorf("expected NewSSEWriter to accept a ResponseRecorder, but got %v", err)
+	}
+}
+
+func TestResponseRecorderHijack(t *testing.T) {
+	// httptest.ResponseRecorder can't be hijacked.
+	_, _, err := WrapResponseWriter(httptest.NewRecorder()).Hijack()
+	if !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("expected http.ErrNotSupported, but got %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := WrapResponseWriter(w).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		_, _ = buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		_ = buf.Flush()
+	}))
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(bufio.NewReader(response.Body))
+	if err != nil || string(body) != "hijacked" {
+		t.Errorf("expected the hijacked connection's response, but got %q, %v", body, err)
+	}
+}
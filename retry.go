@@ -0,0 +1,146 @@
+package toolbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff decides how long to wait before each retry made by Retry.
+type Backoff interface {
+	// Next returns the delay before the given retry; the first retry is attempt 1.
+	Next(attempt int) time.Duration
+}
+
+// ConstantBackoff waits the same Interval before every retry.
+type ConstantBackoff struct {
+	Interval time.Duration
+}
+
+// Next returns b.Interval.
+func (b ConstantBackoff) Next(int) time.Duration {
+	return b.Interval
+}
+
+// ExponentialBackoff doubles the delay after every retry, starting from Base. If Jitter is between 0
+// and 1, each delay is randomly reduced by up to that fraction, so that many clients retrying at once
+// don't all hit the remote service at the same moment.
+type ExponentialBackoff struct {
+	Base   time.Duration
+	Jitter float64
+}
+
+// Next returns Base * 2^(attempt-1), less a random jitter.
+func (b ExponentialBackoff) Next(attempt int) time.Duration {
+	return applyJitter(exponentialDelay(b.Base, attempt, 0), b.Jitter)
+}
+
+// CappedExponentialBackoff is like ExponentialBackoff, but the delay never exceeds Max.
+type CappedExponentialBackoff struct {
+	Base   time.Duration
+	Max    time.Duration
+	Jitter float64
+}
+
+// Next returns Base * 2^(attempt-1), limited to Max, less a random jitter.
+func (b CappedExponentialBackoff) Next(attempt int) time.Duration {
+	return applyJitter(exponentialDelay(b.Base, attempt, b.Max), b.Jitter)
+}
+
+// exponentialDelay returns base * 2^(attempt-1), capped at max if max is greater than zero, and
+// protected against overflow.
+func exponentialDelay(base time.Duration, attempt int, max time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	d := float64(base) * math.Pow(2, float64(attempt-1))
+	if max > 0 && d > float64(max) {
+		return max
+	}
+	if d >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return time.Duration(d)
+}
+
+// applyJitter randomly reduces d by up to the fraction jitter.
+func applyJitter(d time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return d
+	}
+	if jitter > 1 {
+		jitter = 1
+	}
+
+	return time.Duration(float64(d) * (1 - jitter*rand.Float64()))
+}
+
+// permanentError marks an error as not worth retrying.
+type permanentError struct {
+	err error
+}
+
+func (p *permanentError) Error() string { return p.err.Error() }
+
+func (p *permanentError) Unwrap() error { return p.err }
+
+// Permanent wraps err so that Retry stops immediately, and returns err, instead of trying again.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// retrySleep waits for d, or until ctx is done. It is a variable so that tests can avoid sleeping.
+var retrySleep = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Retry calls fn until it succeeds, it returns an error wrapped with Permanent, or it has been called
+// attempts times, waiting between calls as directed by strategy. It returns nil on success, and
+// otherwise the last error returned by fn (unwrapped, if it was permanent). If ctx is done while
+// waiting to retry, Retry returns ctx.Err(), wrapped with the number of attempts made so far.
+func Retry(ctx context.Context, attempts int, strategy Backoff, fn func(ctx context.Context) error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 && strategy != nil {
+			if sleepErr := retrySleep(ctx, strategy.Next(attempt-1)); sleepErr != nil {
+				return fmt.Errorf("retry cancelled after %d attempts: %w", attempt-1, sleepErr)
+			}
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("retry cancelled after %d attempts: %w", attempt-1, ctxErr)
+		}
+
+		err = fn(ctx)
+		if err == nil {
+			return nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+	}
+
+	return err
+}
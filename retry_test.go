@@ -0,0 +1,156 @@
+package toolbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// fakeSleep replaces retrySleep for the duration of a test, recording the requested delays.
+func fakeSleep(t *testing.T) *[]time.Duration {
+	var delays []time.Duration
+
+	original := retrySleep
+	retrySleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	t.Cleanup(func() { retrySleep = original })
+
+	return &delays
+}
+
+func TestRetry(t *testing.T) {
+	delays := fakeSleep(t)
+
+	calls := 0
+	err := Retry(context.Background(), 5, ExponentialBackoff{Base: 10 * time.Millisecond}, func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("flaky")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 3 {
+		t.Errorf("expected 3 calls, but got %d", calls)
+	}
+
+	expected := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}
+	if len(*delays) != 2 || (*delays)[0] != expected[0] || (*delays)[1] != expected[1] {
+		t.Errorf("wrong delays; expected %v but got %v", expected, *delays)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	fakeSleep(t)
+
+	calls := 0
+	err := Retry(context.Background(), 4, ConstantBackoff{Interval: time.Second}, func(ctx context.Context) error {
+		calls++
+		return errors.New("always fails")
+	})
+
+	if err == nil || err.Error() != "always fails" {
+		t.Errorf("expected the last error, but got %v", err)
+	}
+
+	if calls != 4 {
+		t.Errorf("expected 4 calls, but got %d", calls)
+	}
+}
+
+func TestRetryPermanent(t *testing.T) {
+	fakeSleep(t)
+
+	sentinel := errors.New("bad request")
+
+	calls := 0
+	err := Retry(context.Background(), 5, ConstantBackoff{}, func(ctx context.Context) error {
+		calls++
+		return Permanent(sentinel)
+	})
+
+	if err != sentinel {
+		t.Errorf("expected unwrapped permanent error, but got %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected 1 call, but got %d", calls)
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := 0
+	err := Retry(ctx, 5, ConstantBackoff{Interval: time.Hour}, func(ctx context.Context) error {
+		calls++
+		cancel()
+		return errors.New("fails")
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, but got %v", err)
+	}
+
+	if err.Error() != "retry cancelled after 1 attempts: context canceled" {
+		t.Errorf("wrong error message: %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected 1 call, but got %d", calls)
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	b := ExponentialBackoff{Base: 100 * time.Millisecond, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		d := b.Next(3)
+		if d < 200*time.Millisecond || d > 400*time.Millisecond {
+			t.Fatalf("jittered delay out of bounds: %v", d)
+		}
+	}
+
+	c := CappedExponentialBackoff{Base: time.Second, Max: 5 * time.Second}
+	if d := c.Next(10); d != 5*time.Second {
+		t.Errorf("expected capped delay of 5s, but got %v", d)
+	}
+
+	if d := (ExponentialBackoff{Base: time.Second}).Next(100); d <= 0 {
+		t.Errorf("delay overflowed: %v", d)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	fakeSleep(t)
+
+	calls := 0
+	client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		status := http.StatusServiceUnavailable
+		if calls == 3 {
+			status = http.StatusOK
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewBufferString("")), Header: make(http.Header)}, nil
+	})}
+
+	var testTools Tools
+	results := testTools.PushJSONToMany(context.Background(), []string{"http://example.com/"}, "x",
+		WithHTTPClient(client), WithRetry(3, time.Second))
+
+	if results[0].Err != nil || results[0].StatusCode != http.StatusOK {
+		t.Errorf("expected success on third attempt, but got %d %v", results[0].StatusCode, results[0].Err)
+	}
+
+	if calls != 3 {
+		t.Errorf("expected 3 calls, but got %d", calls)
+	}
+}
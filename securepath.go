@@ -0,0 +1,40 @@
+package toolbox
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ErrUnsafePath is returned, wrapped, when a path that should stay inside a base directory is
+// absolute, or climbs out of it with "..".
+var ErrUnsafePath = errors.New("path is outside the base directory")
+
+// SecureJoin joins name, which may come from user input, onto base, and returns the result, or an
+// error wrapping ErrUnsafePath if name is absolute or would end up outside base once cleaned (for
+// example, "a/../../b"). The check is purely lexical, so symbolic links inside base are not followed.
+func SecureJoin(base, name string) (string, error) {
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" || strings.HasPrefix(name, "/") || strings.HasPrefix(name, `\`) {
+		return "", fmt.Errorf("%w: %q is absolute", ErrUnsafePath, name)
+	}
+
+	joined := filepath.Join(base, name)
+
+	rel, err := filepath.Rel(filepath.Clean(base), joined)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q", ErrUnsafePath, name)
+	}
+
+	return joined, nil
+}
+
+// uploadPath returns the directory uploads to uploadDir should be saved in: uploadDir itself, or,
+// if BaseUploadDir is set, uploadDir confined to it with SecureJoin.
+func (t *Tools) uploadPath(uploadDir string) (string, error) {
+	if t.BaseUploadDir == "" {
+		return uploadDir, nil
+	}
+
+	return SecureJoin(t.BaseUploadDir, uploadDir)
+}
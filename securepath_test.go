@@ -0,0 +1,45 @@
+package toolbox
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+var secureJoinTests = []struct {
+	name     string
+	path     string
+	expected string
+	unsafe   bool
+}{
+	{name: "simple", path: "tenant1/photos", expected: "/data/tenant1/photos"},
+	{name: "cleaned", path: "tenant1/../tenant2/./photos", expected: "/data/tenant2/photos"},
+	{name: "empty", path: "", expected: "/data"},
+	{name: "dots in name", path: "..photos", expected: "/data/..photos"},
+	{name: "absolute", path: "/etc", unsafe: true},
+	{name: "parent", path: "..", unsafe: true},
+	{name: "escaping", path: "../outside", unsafe: true},
+	{name: "escaping later", path: "a/../../b", unsafe: true},
+}
+
+func TestSecureJoin(t *testing.T) {
+	for _, e := range secureJoinTests {
+		joined, err := SecureJoin("/data", e.path)
+
+		if e.unsafe {
+			if !errors.Is(err, ErrUnsafePath) {
+				t.Errorf("%s: expected ErrUnsafePath, but got %q, %v", e.name, joined, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		if joined != filepath.FromSlash(e.expected) {
+			t.Errorf("%s: expected %s, but got %s", e.name, e.expected, joined)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package toolbox
+
+import (
+	"strings"
+	"unicode"
+)
+
+// defaultSlugDigitPrefix is put in front of slugs that start with a digit when SlugNoLeadingDigit is
+// set, unless SlugDigitPrefix is.
+const defaultSlugDigitPrefix = "n"
+
+// defaultSlugTransliterations is used by Slugify to turn common accented and special Latin letters
+// into plain ASCII, rather than dropping them. SlugTransliterations is consulted first.
+var defaultSlugTransliterations = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i", 'ı': "i",
+	'ł': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ř': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ß': "ss", 'ť': "t", 'ţ': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// transliterateForSlug lowercases s, and replaces each rune found in custom, or failing that in
+// defaultSlugTransliterations, with its replacement. Anything the replacements contain that isn't
+// allowed in a slug is removed afterwards by Slugify, like any other character.
+func transliterateForSlug(s string, custom map[rune]string) string {
+	var b strings.Builder
+	for _, r := range s {
+		lower := unicode.ToLower(r)
+
+		if replacement, ok := custom[r]; ok {
+			b.WriteString(strings.ToLower(replacement))
+		} else if replacement, ok := custom[lower]; ok {
+			b.WriteString(strings.ToLower(replacement))
+		} else if replacement, ok := defaultSlugTransliterations[lower]; ok {
+			b.WriteString(replacement)
+		} else {
+			b.WriteRune(lower)
+		}
+	}
+
+	return b.String()
+}
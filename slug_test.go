@@ -0,0 +1,75 @@
+package toolbox
+
+import "testing"
+
+var slugTransliterationTests = []struct {
+	name     string
+	s        string
+	custom   map[rune]string
+	expected string
+}{
+	{name: "default table", s: "Crème Brûlée à la Façon", expected: "creme-brulee-a-la-facon"},
+	{name: "default german", s: "Über Müller Straße", expected: "uber-muller-strasse"},
+	{name: "german rules", s: "Über Müller Straße", custom: map[rune]string{'ü': "ue", 'ö': "oe", 'ä': "ae"}, expected: "ueber-mueller-strasse"},
+	{name: "uppercase key", s: "Über", custom: map[rune]string{'Ü': "UE"}, expected: "ueber"},
+	{name: "turkish rules", s: "Işık Ağacı", custom: map[rune]string{'ı': "i", 'ğ': "g", 'ş': "s"}, expected: "isik-agaci"},
+	{name: "custom wins", s: "café", custom: map[rune]string{'é': "ay"}, expected: "cafay"},
+	{name: "replacement is cleaned", s: "axb", custom: map[rune]string{'x': "-<b>/é"}, expected: "a-b-b"},
+	{name: "no roman characters", s: "こんにちは 世界 ü", expected: "u"},
+}
+
+func TestTools_SlugifyTransliterations(t *testing.T) {
+	for _, e := range slugTransliterationTests {
+		testTools := Tools{SlugTransliterations: e.custom}
+
+		slug, err := testTools.Slugify(e.s)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		if slug != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, slug)
+		}
+	}
+}
+
+var slugLeadingDigitTests = []struct {
+	name          string
+	tools         Tools
+	s             string
+	expected      string
+	errorExpected bool
+}{
+	{name: "default, starts with digits", s: "2024 Annual Report", expected: "2024-annual-report"},
+	{name: "default, all digits", s: "42", expected: "42"},
+	{name: "no leading digit, starts with digits", tools: Tools{SlugNoLeadingDigit: true}, s: "2024 Annual Report", expected: "n2024-annual-report"},
+	{name: "no leading digit, all digits", tools: Tools{SlugNoLeadingDigit: true}, s: "42", expected: "n42"},
+	{name: "no leading digit, starts with a letter", tools: Tools{SlugNoLeadingDigit: true}, s: "Report 2024", expected: "report-2024"},
+	{name: "custom prefix", tools: Tools{SlugNoLeadingDigit: true, SlugDigitPrefix: "id-"}, s: "42", expected: "id-42"},
+	{name: "require letter, starts with digits", tools: Tools{SlugRequireLetter: true}, s: "2024 Annual Report", expected: "2024-annual-report"},
+	{name: "require letter, all digits", tools: Tools{SlugRequireLetter: true}, s: "42", errorExpected: true},
+	{name: "require letter, digits and punctuation", tools: Tools{SlugRequireLetter: true}, s: "4 / 2", errorExpected: true},
+	{name: "both, all digits", tools: Tools{SlugRequireLetter: true, SlugNoLeadingDigit: true}, s: "42", errorExpected: true},
+	{name: "both, starts with digits", tools: Tools{SlugRequireLetter: true, SlugNoLeadingDigit: true}, s: "2024 Annual Report", expected: "n2024-annual-report"},
+}
+
+func TestTools_SlugifyLeadingDigit(t *testing.T) {
+	for _, e := range slugLeadingDigitTests {
+		slug, err := e.tools.Slugify(e.s)
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: error expected, but got %s", e.name, slug)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+		if slug != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, slug)
+		}
+	}
+}
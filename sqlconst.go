@@ -0,0 +1,137 @@
+package toolbox
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// sqlInitialisms are written in capitals in generated identifiers, as Go style asks.
+var sqlInitialisms = map[string]bool{
+	"API": true, "CSV": true, "HTML": true, "HTTP": true, "ID": true, "IP": true,
+	"JSON": true, "SQL": true, "URL": true, "UUID": true, "XML": true,
+}
+
+// SQLConstantsOption changes the file written by GenerateSQLConstants.
+type SQLConstantsOption func(*sqlConstantsOptions)
+
+type sqlConstantsOptions struct {
+	keysVar string
+}
+
+// WithSQLKeysVar adds a var named name to the generated file, listing every key in order.
+func WithSQLKeysVar(name string) SQLConstantsOption {
+	return func(o *sqlConstantsOptions) {
+		o.keysVar = name
+	}
+}
+
+// GenerateSQLConstants writes a gofmt formatted Go source file for package pkg to w, declaring a
+// string constant for each key in queries, so that code can refer to queries by a name the compiler
+// checks. Keys are converted to exported identifiers by splitting them on anything that isn't a
+// letter or digit and capitalizing each part, so "users.get_by_id" becomes UsersGetByID. If two keys
+// would become the same identifier, an error naming both is returned, and nothing is written. It is
+// meant to be run from a small main package, or a go:generate directive; for example:
+//
+//	f, _ := os.Create("queries_gen.go")
+//	err := toolbox.GenerateSQLConstants(f, "store", queries, toolbox.WithSQLKeysVar("AllQueries"))
+func GenerateSQLConstants(w io.Writer, pkg string, queries map[string]string, opts ...SQLConstantsOption) error {
+	var o sqlConstantsOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if !token.IsIdentifier(pkg) {
+		return fmt.Errorf("%q is not a valid package name", pkg)
+	}
+	if o.keysVar != "" && (!token.IsIdentifier(o.keysVar) || !token.IsExported(o.keysVar)) {
+		return fmt.Errorf("%q is not a valid exported identifier", o.keysVar)
+	}
+
+	keys := make([]string, 0, len(queries))
+	for key := range queries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	names := make(map[string]string, len(keys))
+	if o.keysVar != "" {
+		names[o.keysVar] = ""
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by toolbox.GenerateSQLConstants. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	buf.WriteString("// Keys of the SQL queries.\nconst (\n")
+
+	for _, key := range keys {
+		name, err := sqlConstantName(key)
+		if err != nil {
+			return err
+		}
+
+		if other, ok := names[name]; ok {
+			if other == "" {
+				return fmt.Errorf("query key %q becomes %s, which is the name of the keys var", key, name)
+			}
+			return fmt.Errorf("query keys %q and %q both become %s", other, key, name)
+		}
+		names[name] = key
+
+		fmt.Fprintf(&buf, "\t%s = %s\n", name, strconv.Quote(key))
+	}
+	buf.WriteString(")\n")
+
+	if o.keysVar != "" {
+		fmt.Fprintf(&buf, "\n// %s lists the keys of every SQL query.\nvar %s = []string{\n", o.keysVar, o.keysVar)
+		for _, key := range keys {
+			name, _ := sqlConstantName(key)
+			fmt.Fprintf(&buf, "\t%s,\n", name)
+		}
+		buf.WriteString("}\n")
+	}
+
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(out)
+	return err
+}
+
+// sqlConstantName converts a query key to an exported Go identifier.
+func sqlConstantName(key string) (string, error) {
+	parts := strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var b strings.Builder
+	for _, part := range parts {
+		if sqlInitialisms[strings.ToUpper(part)] {
+			b.WriteString(strings.ToUpper(part))
+			continue
+		}
+		r := []rune(part)
+		b.WriteRune(unicode.ToUpper(r[0]))
+		b.WriteString(string(r[1:]))
+	}
+
+	name := b.String()
+	if name == "" {
+		return "", fmt.Errorf("query key %q has no letters or digits to make a name from", key)
+	}
+
+	// Identifiers can't start with a digit, and must start with an upper case letter to be exported.
+	if !token.IsExported(name) {
+		name = "Query" + name
+	}
+
+	return name, nil
+}
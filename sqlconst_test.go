@@ -0,0 +1,103 @@
+package toolbox
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestGenerateSQLConstants(t *testing.T) {
+	queries := map[string]string{
+		"users.get_by_id":  "SELECT * FROM users WHERE id = $1",
+		"users.list":       "SELECT * FROM users",
+		"orders-by-url":    "SELECT * FROM orders WHERE url = $1",
+		"2fa.enable":       "UPDATE users SET two_factor = true WHERE id = $1",
+		"reports/monthly ": "SELECT * FROM monthly_reports",
+	}
+
+	var buf bytes.Buffer
+	err := GenerateSQLConstants(&buf, "store", queries, WithSQLKeysVar("AllQueries"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := parser.ParseFile(token.NewFileSet(), "queries_gen.go", buf.Bytes(), parser.ParseComments)
+	if err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, buf.String())
+	}
+
+	if f.Name.Name != "store" || !ast.IsGenerated(f) {
+		t.Errorf("wrong package, or missing generated comment:\n%s", buf.String())
+	}
+
+	consts := map[string]string{}
+	var keysVar *ast.ValueSpec
+	for _, decl := range f.Decls {
+		gen := decl.(*ast.GenDecl)
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			if gen.Tok == token.VAR {
+				keysVar = vs
+				continue
+			}
+			consts[vs.Names[0].Name] = vs.Values[0].(*ast.BasicLit).Value
+		}
+	}
+
+	expected := map[string]string{
+		"UsersGetByID":   `"users.get_by_id"`,
+		"UsersList":      `"users.list"`,
+		"OrdersByURL":    `"orders-by-url"`,
+		"Query2faEnable": `"2fa.enable"`,
+		"ReportsMonthly": `"reports/monthly "`,
+	}
+	for name, value := range expected {
+		if consts[name] != value {
+			t.Errorf("expected %s = %s, but got %q", name, value, consts[name])
+		}
+	}
+	if len(consts) != len(expected) {
+		t.Errorf("expected %d constants, but got %v", len(expected), consts)
+	}
+
+	if keysVar == nil || keysVar.Names[0].Name != "AllQueries" || len(keysVar.Values[0].(*ast.CompositeLit).Elts) != len(queries) {
+		t.Errorf("missing or wrong keys var:\n%s", buf.String())
+	}
+
+	// Without the option, there is no var.
+	buf.Reset()
+	_ = GenerateSQLConstants(&buf, "store", queries)
+	if strings.Contains(buf.String(), "var ") {
+		t.Errorf("unexpected var:\n%s", buf.String())
+	}
+}
+
+func TestGenerateSQLConstantsErrors(t *testing.T) {
+	var tests = []struct {
+		name          string
+		pkg           string
+		queries       map[string]string
+		opts          []SQLConstantsOption
+		errorExpected string
+	}{
+		{name: "collision", pkg: "store", queries: map[string]string{"users.get": "", "users_get": ""}, errorExpected: `"users.get" and "users_get" both become UsersGet`},
+		{name: "keys var collision", pkg: "store", queries: map[string]string{"all.queries": ""}, opts: []SQLConstantsOption{WithSQLKeysVar("AllQueries")}, errorExpected: "name of the keys var"},
+		{name: "no name", pkg: "store", queries: map[string]string{"...": ""}, errorExpected: "no letters or digits"},
+		{name: "bad package", pkg: "my-store", queries: map[string]string{"a": ""}, errorExpected: "not a valid package name"},
+		{name: "bad var", pkg: "store", queries: map[string]string{"a": ""}, opts: []SQLConstantsOption{WithSQLKeysVar("allQueries")}, errorExpected: "not a valid exported identifier"},
+	}
+
+	for _, e := range tests {
+		var buf bytes.Buffer
+		err := GenerateSQLConstants(&buf, e.pkg, e.queries, e.opts...)
+		if err == nil || !strings.Contains(err.Error(), e.errorExpected) {
+			t.Errorf("%s: expected error containing %q, but got %v", e.name, e.errorExpected, err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("%s: expected nothing to be written", e.name)
+		}
+	}
+}
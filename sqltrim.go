@@ -0,0 +1,61 @@
+package toolbox
+
+import "strings"
+
+// TrimSQLSemicolon removes a single trailing semicolon, and any whitespace around it, from query,
+// for drivers and prepared statement APIs that reject statements ending in ";". A semicolon that is
+// inside a string literal, a quoted identifier, or a comment is never removed, even if it is the last
+// thing in query (which happens when a literal or comment is left open); nor is one followed by a
+// comment. Queries without a trailing semicolon are returned with trailing whitespace removed.
+func TrimSQLSemicolon(query string) string {
+	query = strings.TrimRight(query, " \t\r\n")
+	if !strings.HasSuffix(query, ";") || !sqlEndsOutsideQuotes(query[:len(query)-1]) {
+		return query
+	}
+
+	return strings.TrimRight(query[:len(query)-1], " \t\r\n")
+}
+
+// sqlEndsOutsideQuotes reports whether the end of query is outside any string literal, quoted
+// identifier, or comment, so that a character appended to it would be part of the statement itself.
+func sqlEndsOutsideQuotes(query string) bool {
+	var quote byte // the quote that opened the literal or identifier we're in, if any
+	lineComment, blockComment := false, false
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		switch {
+		case lineComment:
+			if c == '\n' {
+				lineComment = false
+			}
+
+		case blockComment:
+			if c == '*' && i+1 < len(query) && query[i+1] == '/' {
+				blockComment = false
+				i++
+			}
+
+		case quote != 0:
+			// A doubled quote is an escaped quote, which the second iteration through here treats as
+			// reopening the literal, so no special case is needed.
+			if c == quote {
+				quote = 0
+			}
+
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			lineComment = true
+			i++
+
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			blockComment = true
+			i++
+		}
+	}
+
+	return quote == 0 && !lineComment && !blockComment
+}
@@ -0,0 +1,32 @@
+package toolbox
+
+import "testing"
+
+var trimSQLSemicolonTests = []struct {
+	name     string
+	query    string
+	expected string
+}{
+	{name: "trailing semicolon", query: "select * from users;", expected: "select * from users"},
+	{name: "whitespace around it", query: "select 1 \n;\n\n", expected: "select 1"},
+	{name: "no semicolon", query: "select 1\n", expected: "select 1"},
+	{name: "only one removed", query: "select 1;;", expected: "select 1;"},
+	{name: "semicolon in a literal", query: "select * from t where a = 'x;'", expected: "select * from t where a = 'x;'"},
+	{name: "literal then semicolon", query: "select * from t where a = 'x;';", expected: "select * from t where a = 'x;'"},
+	{name: "escaped quote", query: "select 'it''s;';", expected: "select 'it''s;'"},
+	{name: "unterminated literal", query: "select 'abc;", expected: "select 'abc;"},
+	{name: "quoted identifier", query: `select "a;b" from t;`, expected: `select "a;b" from t`},
+	{name: "line comment", query: "select 1 -- the end;", expected: "select 1 -- the end;"},
+	{name: "comment before semicolon", query: "select 1 -- note\n;", expected: "select 1 -- note"},
+	{name: "block comment", query: "select 1 /* stop; */;", expected: "select 1 /* stop; */"},
+	{name: "open block comment", query: "select 1 /* stop;", expected: "select 1 /* stop;"},
+	{name: "semicolon in the middle", query: "begin; select 1; end;", expected: "begin; select 1; end"},
+}
+
+func TestTrimSQLSemicolon(t *testing.T) {
+	for _, e := range trimSQLSemicolonTests {
+		if got := TrimSQLSemicolon(e.query); got != e.expected {
+			t.Errorf("%s: expected %q, but got %q", e.name, e.expected, got)
+		}
+	}
+}
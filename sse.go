@@ -0,0 +1,120 @@
+package toolbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SSEWriter writes Server-Sent Events to a client. It is safe for concurrent use, so a KeepAlive
+// loop can run alongside calls to Send.
+type SSEWriter struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// NewSSEWriter sets the headers for an event stream on w, sends them to the client, and returns an
+// SSEWriter. It returns an error if w does not support flushing, since events could not be delivered
+// as they are sent.
+func NewSSEWriter(w http.ResponseWriter) (*SSEWriter, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("streaming is not supported by this response writer")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stop nginx and similar proxies from buffering the stream.
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &SSEWriter{w: w, flusher: flusher}, nil
+}
+
+// Send writes an event to the client. The event and id are optional, and are omitted when empty.
+// If data is a string or []byte, it is sent as is; anything else is encoded as JSON. Data spanning
+// several lines is sent as one data: line per line of data.
+func (s *SSEWriter) Send(event, id string, data any) error {
+	var payload string
+	switch v := data.(type) {
+	case string:
+		payload = v
+	case []byte:
+		payload = string(v)
+	default:
+		out, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		payload = string(out)
+	}
+
+	var b strings.Builder
+	if id != "" {
+		fmt.Fprintf(&b, "id: %s\n", stripNewlines(id))
+	}
+	if event != "" {
+		fmt.Fprintf(&b, "event: %s\n", stripNewlines(event))
+	}
+
+	// A browser ends a line at a CR, an LF or a CRLF, so all three must start a new data line here;
+	// otherwise a lone CR would let the payload write a field of its own.
+	payload = strings.ReplaceAll(strings.ReplaceAll(payload, "\r\n", "\n"), "\r", "\n")
+	for _, line := range strings.Split(payload, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+
+	return s.write(b.String())
+}
+
+// Comment writes a comment line to the client. Comments are ignored by browsers, which makes them
+// useful for keeping idle connections open.
+func (s *SSEWriter) Comment(comment string) error {
+	return s.write(": " + stripNewlines(comment) + "\n\n")
+}
+
+// KeepAlive sends a comment to the client every interval until ctx is done, or a write fails. It
+// returns the write error, or ctx.Err().
+func (s *SSEWriter) KeepAlive(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := s.Comment("keep-alive"); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// write sends msg to the client and flushes it.
+func (s *SSEWriter) write(msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.w.Write([]byte(msg))
+	if err != nil {
+		return err
+	}
+	s.flusher.Flush()
+
+	return nil
+}
+
+// stripNewlines removes carriage returns and line feeds, which would break a single line field.
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
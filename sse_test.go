@@ -0,0 +1,117 @@
+package toolbox
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSSEWriter(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	sse, err := NewSSEWriter(rr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("wrong content type: %s", rr.Header().Get("Content-Type"))
+	}
+
+	if rr.Header().Get("X-Accel-Buffering") != "no" {
+		t.Error("expected proxy buffering to be disabled")
+	}
+
+	if !rr.Flushed {
+		t.Error("expected headers to be flushed")
+	}
+
+	_ = sse.Send("progress", "1", map[string]int{"received": 10})
+	_ = sse.Send("", "", "line one\nline two")
+	_ = sse.Comment("ping")
+
+	expected := "id: 1\nevent: progress\ndata: {\"received\":10}\n\n" +
+		"data: line one\ndata: line two\n\n" +
+		": ping\n\n"
+
+	if rr.Body.String() != expected {
+		t.Errorf("wrong event stream; expected:\n%q\ngot:\n%q", expected, rr.Body.String())
+	}
+}
+
+func TestSSEWriter_SendLineEnds(t *testing.T) {
+	rr := httptest.NewRecorder()
+	sse, err := NewSSEWriter(rr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every kind of line end starts a new data line, so none of them can inject a field.
+	_ = sse.Send("", "", "a\rdata: injected\r\nb\nc\r")
+
+	expected := "data: a\ndata: data: injected\ndata: b\ndata: c\ndata: \n\n"
+	if rr.Body.String() != expected {
+		t.Errorf("wrong event stream; expected:\n%q\ngot:\n%q", expected, rr.Body.String())
+	}
+	if strings.Contains(rr.Body.String(), "\r") {
+		t.Error("expected no CR in the event stream")
+	}
+}
+
+func TestNewSSEWriterNoFlusher(t *testing.T) {
+	_, err := NewSSEWriter(&failingWriter{})
+	if err == nil {
+		t.Error("expected error for writer without flusher, but none received")
+	}
+}
+
+func TestSSEWriter_KeepAlive(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	sse, err := NewSSEWriter(rr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 35*time.Millisecond)
+	defer cancel()
+
+	err = sse.KeepAlive(ctx, 10*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, but got %v", err)
+	}
+
+	if !strings.Contains(rr.Body.String(), ": keep-alive\n\n") {
+		t.Error("expected at least one keep-alive comment")
+	}
+}
+
+// disconnectedWriter is a flushable response writer whose client has gone away.
+type disconnectedWriter struct {
+	*httptest.ResponseRecorder
+	fail bool
+}
+
+func (d *disconnectedWriter) Write(b []byte) (int, error) {
+	if d.fail {
+		return 0, errors.New("client disconnected")
+	}
+	return d.ResponseRecorder.Write(b)
+}
+
+func TestSSEWriter_SendAfterDisconnect(t *testing.T) {
+	w := &disconnectedWriter{ResponseRecorder: httptest.NewRecorder()}
+
+	sse, err := NewSSEWriter(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.fail = true
+	if err := sse.Send("message", "", "hello"); err == nil {
+		t.Error("expected write error after disconnect, but none received")
+	}
+}
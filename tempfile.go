@@ -0,0 +1,155 @@
+package toolbox
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tempFilePrefix is prepended to the name of every temp file created by TempFile, followed by the ID
+// of the process that created it, a token for that process, and a dash each, so that CleanupTempFiles
+// can identify leftovers without touching anything else in the directory, or anything a running
+// process may still be using.
+const tempFilePrefix = "toolbox-"
+
+// tempFileToken identifies this process in the names of its temp files. The ID alone isn't enough:
+// IDs are reused, and in a container every restart of the program is process 1. Where the start
+// time of a process can be found, it is the token, so that other processes can tell whether the
+// owner of a file is still running; otherwise, it is the time this package was initialised.
+var tempFileToken = processToken(os.Getpid())
+
+// processToken returns the token for the process with the ID pid, as used in temp file names.
+func processToken(pid int) string {
+	start, ok := processStartTime(pid)
+	if !ok {
+		start = time.Now().UnixNano()
+	}
+	return strconv.FormatInt(start, 36)
+}
+
+// TempFile creates a new temporary file in t.TempDir (or os.TempDir if TempDir is not set), opened
+// for reading and writing. The pattern is used as in os.CreateTemp, but any path separators are
+// removed from it first. The name of the file starts with toolbox-, the ID of this process, and a
+// token that tells it apart from other processes given the same ID. The returned cleanup function
+// closes and removes the file, and is safe to call more than once.
+func (t *Tools) TempFile(pattern string) (*os.File, func(), error) {
+	dir := t.tempDir()
+
+	err := t.CreateDirIfNotExist(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.CreateTemp(dir, tempFilePrefix+strconv.Itoa(os.Getpid())+"-"+tempFileToken+"-"+sanitizeTempPattern(pattern))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var once sync.Once
+	cleanup := func() {
+		once.Do(func() {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		})
+	}
+
+	return f, cleanup, nil
+}
+
+// CleanupTempFiles removes files created by TempFile which were last modified more than olderThan ago,
+// and were left behind by a process that is no longer running, typically because it crashed. Files
+// created by this process, or by any other that is still running, may still be in use, so they are
+// left alone, as is everything without the toolbox prefix. A file left by an earlier process with this
+// process's ID, such as a previous run of a program that is always process 1 in a container, is a
+// leftover too, as is, on Linux and Windows, one whose owner's ID has since been given to another
+// process. It returns the number of files removed.
+func (t *Tools) CleanupTempFiles(olderThan time.Duration) (int, error) {
+	dir := t.tempDir()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		pid, token, ok := tempFileOwner(entry.Name())
+		if !ok || ownerRunning(pid, token) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		if info.ModTime().Before(cutoff) {
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err == nil {
+				removed++
+			}
+		}
+	}
+
+	return removed, nil
+}
+
+// ownerRunning reports whether the process with the ID pid and the given token, which created a temp
+// file, may still be running. A file with this process's ID but another token was left by an earlier
+// process with the same ID, such as a previous run of a program that is always process 1. If the start
+// time of a running process can't be found, it is assumed to be the owner.
+func ownerRunning(pid int, token string) bool {
+	if pid == os.Getpid() {
+		return token == tempFileToken
+	}
+	if !processAlive(pid) {
+		return false
+	}
+	if start, ok := processStartTime(pid); ok {
+		return token == strconv.FormatInt(start, 36)
+	}
+	return true
+}
+
+// tempFileOwner returns the ID and token of the process that created the temp file called name, if
+// it is one that TempFile created.
+func tempFileOwner(name string) (int, string, bool) {
+	rest, ok := strings.CutPrefix(name, tempFilePrefix)
+	if !ok {
+		return 0, "", false
+	}
+	digits, rest, ok := strings.Cut(rest, "-")
+	if !ok {
+		return 0, "", false
+	}
+	token, _, ok := strings.Cut(rest, "-")
+	if !ok || token == "" {
+		return 0, "", false
+	}
+
+	pid, err := strconv.Atoi(digits)
+	if err != nil || pid <= 0 {
+		return 0, "", false
+	}
+	return pid, token, true
+}
+
+// tempDir returns the directory used for temp files.
+func (t *Tools) tempDir() string {
+	if t.TempDir != "" {
+		return t.TempDir
+	}
+	return os.TempDir()
+}
+
+// sanitizeTempPattern strips path separators and parent directory references from a temp file pattern.
+func sanitizeTempPattern(pattern string) string {
+	pattern = strings.NewReplacer("/", "", "\\", "", string(os.PathSeparator), "").Replace(pattern)
+	return strings.ReplaceAll(pattern, "..", "")
+}
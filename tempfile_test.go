@@ -0,0 +1,133 @@
+package toolbox
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestTools_TempFile(t *testing.T) {
+	var testTools Tools
+	testTools.TempDir = t.TempDir()
+
+	// a pattern with path separators should still end up in TempDir
+	f, cleanup, err := testTools.TempFile("../../evil/upload-*.tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filepath.Dir(f.Name()) != testTools.TempDir {
+		t.Errorf("temp file created in wrong directory: %s", f.Name())
+	}
+
+	_, err = f.WriteString("hello")
+	if err != nil {
+		t.Error(err)
+	}
+
+	cleanup()
+	cleanup()
+
+	if _, err := os.Stat(f.Name()); !os.IsNotExist(err) {
+		t.Error("expected temp file to be removed by cleanup")
+	}
+}
+
+// exitedProcessID returns the ID of a process that has run and exited.
+func exitedProcessID(t *testing.T) int {
+	t.Helper()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestTools_CleanupTempFiles(t *testing.T) {
+	var testTools Tools
+	testTools.TempDir = t.TempDir()
+
+	// a file of ours, which might still be in use, however old it is
+	ours, cleanup, err := testTools.TempFile("ours-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	// files left behind by a process that has gone, by one that is still running, and a file
+	// without the prefix, which must never be touched
+	exited := strconv.Itoa(exitedProcessID(t))
+	parent := strconv.Itoa(os.Getppid()) + "-" + processToken(os.Getppid())
+	crashed := filepath.Join(testTools.TempDir, "toolbox-"+exited+"-abc-upload-123.tmp")
+	recent := filepath.Join(testTools.TempDir, "toolbox-"+exited+"-abc-upload-456.tmp")
+	running := filepath.Join(testTools.TempDir, "toolbox-"+parent+"-upload-789.tmp")
+	other := filepath.Join(testTools.TempDir, "other.txt")
+
+	// a file left by an earlier process with our ID, as when a container restarts and the program
+	// is process 1 again
+	restarted := filepath.Join(testTools.TempDir, "toolbox-"+strconv.Itoa(os.Getpid())+"-abc-upload-321.tmp")
+
+	for _, name := range []string{crashed, recent, running, other, restarted} {
+		if err := os.WriteFile(name, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	past := time.Now().Add(-2 * time.Hour)
+	for _, name := range []string{ours.Name(), crashed, running, other, restarted} {
+		_ = os.Chtimes(name, past, past)
+	}
+
+	removed, err := testTools.CleanupTempFiles(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 2 {
+		t.Errorf("expected 2 files removed, but got %d", removed)
+	}
+
+	if _, err := os.Stat(crashed); !os.IsNotExist(err) {
+		t.Error("expected old temp file of an exited process to be removed")
+	}
+
+	if _, err := os.Stat(restarted); !os.IsNotExist(err) {
+		t.Error("expected old temp file of an earlier process with our ID to be removed")
+	}
+
+	for _, name := range []string{ours.Name(), recent, running, other} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("%s should not have been removed", filepath.Base(name))
+		}
+	}
+}
+
+func TestTools_CleanupTempFilesReusedID(t *testing.T) {
+	if _, ok := processStartTime(os.Getppid()); !ok {
+		t.Skip("the start time of a process can't be found here")
+	}
+
+	var testTools Tools
+	testTools.TempDir = t.TempDir()
+
+	// a file left by a process whose ID now belongs to our parent, which started later
+	reused := filepath.Join(testTools.TempDir, "toolbox-"+strconv.Itoa(os.Getppid())+"-abc-upload-123.tmp")
+	if err := os.WriteFile(reused, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-2 * time.Hour)
+	_ = os.Chtimes(reused, past, past)
+
+	removed, err := testTools.CleanupTempFiles(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if removed != 1 {
+		t.Errorf("expected 1 file removed, but got %d", removed)
+	}
+}
@@ -0,0 +1,173 @@
+package toolbox
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errRequestTimeout is sent to the client by Timeout when a handler takes too long.
+var errRequestTimeout = errors.New("the request took too long to process")
+
+// Timeout is middleware that gives next d to respond. The request's context gets a deadline of d,
+// and if it passes before next has written anything, the client is sent a 504 Gateway Timeout error,
+// with ErrorJSONCtx, or ErrorXMLCtx for clients that ask for XML before JSON in their Accept header;
+// anything next writes after that is discarded, and its writes fail with http.ErrHandlerTimeout. If
+// next had already started its response, it is left to finish it, with its context cancelled. If
+// the client goes away first, nothing is sent.
+//
+// Unlike http.TimeoutHandler, the response isn't buffered, so next can stream. Flush works as usual,
+// but the writer next is given can't be hijacked.
+func (t *Tools) Timeout(next http.Handler, d time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		tw := &timeoutWriter{rec: WrapResponseWriter(w), header: make(http.Header), ctx: ctx}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+
+		go func() {
+			defer close(done)
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			next.ServeHTTP(tw, r)
+		}()
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			tw.mu.Lock()
+			if !tw.wroteHeader {
+				tw.timedOut = true
+				if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					// The client has gone, so there's nobody to tell.
+					tw.mu.Unlock()
+					return
+				}
+				if prefersXML(r.Header.Get("Accept")) {
+					_ = t.ErrorXMLCtx(tw.rec, r, errRequestTimeout, http.StatusGatewayTimeout)
+				} else {
+					_ = t.ErrorJSONCtx(tw.rec, r, errRequestTimeout, http.StatusGatewayTimeout)
+				}
+				tw.mu.Unlock()
+				return
+			}
+			tw.mu.Unlock()
+
+			// The response has started, so all we can do is wait for next to notice.
+			<-done
+		}
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		default:
+		}
+	})
+}
+
+// timeoutWriter is the http.ResponseWriter Timeout gives handlers. It has a header map of its own,
+// which is copied to the real one when the response starts, and a mutex, so that the handler's
+// response and the timeout response can never both be sent. A response can only be started while
+// ctx, the handler's context, is still live, so a handler that wakes up at the deadline can't get
+// in ahead of the timeout response.
+type timeoutWriter struct {
+	rec         *ResponseRecorder
+	header      http.Header
+	ctx         context.Context
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+// Header returns the handler's header map.
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+// WriteHeader sends the status code and headers, unless the request has timed out.
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.writeHeader(status)
+}
+
+// Write sends b, unless the request has timed out, in which case it fails with http.ErrHandlerTimeout.
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.writeHeader(http.StatusOK)
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+
+	return tw.rec.Write(b)
+}
+
+// Flush sends any buffered data to the client, unless the request has timed out.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.writeHeader(http.StatusOK)
+	if tw.timedOut {
+		return
+	}
+
+	tw.rec.Flush()
+}
+
+// writeHeader copies the handler's headers to the real header map, and sends status, if the
+// response hasn't been started, or timed out; if the handler's context is done, it has timed out.
+// 1xx statuses are sent without starting the response. The caller must hold tw.mu.
+func (tw *timeoutWriter) writeHeader(status int) {
+	if tw.wroteHeader {
+		return
+	}
+	if tw.ctx.Err() != nil {
+		tw.timedOut = true
+	}
+	if tw.timedOut {
+		return
+	}
+
+	dst := tw.rec.Header()
+	for k, v := range tw.header {
+		dst[k] = append([]string(nil), v...)
+	}
+
+	tw.rec.WriteHeader(status)
+	if status >= 200 {
+		tw.wroteHeader = true
+	}
+}
+
+// prefersXML reports whether the Accept header value accept lists an XML media type before any JSON
+// one. Quality values aren't considered.
+func prefersXML(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			return false
+		case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package toolbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTools_TimeoutFastHandler(t *testing.T) {
+	var testTools Tools
+
+	handler := testTools.Timeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Custom", "yes")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("done"))
+	}), time.Second)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rr.Code != http.StatusCreated || rr.Body.String() != "done" || rr.Header().Get("X-Custom") != "yes" {
+		t.Errorf("wrong response: %d %q %v", rr.Code, rr.Body.String(), rr.Header())
+	}
+}
+
+func TestTools_TimeoutSlowHandler(t *testing.T) {
+	var testTools Tools
+
+	for _, accept := range []string{"", "application/xml, application/json"} {
+		writeErr := make(chan error, 1)
+		handler := testTools.Timeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+			w.Header().Set("X-Custom", "too late")
+			_, err := w.Write([]byte("too late"))
+			writeErr <- err
+		}), 20*time.Millisecond)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusGatewayTimeout || rr.Header().Get("X-Custom") != "" {
+			t.Errorf("%q: wrong response: %d %v", accept, rr.Code, rr.Header())
+		}
+
+		if accept == "" {
+			var payload JSONResponse
+			if err := json.Unmarshal(rr.Body.Bytes(), &payload); err != nil || !payload.Error || payload.Message != errRequestTimeout.Error() {
+				t.Errorf("wrong JSON body: %s", rr.Body.String())
+			}
+		} else if !strings.Contains(rr.Body.String(), "<message>"+errRequestTimeout.Error()+"</message>") {
+			t.Errorf("wrong XML body: %s", rr.Body.String())
+		}
+
+		if err := <-writeErr; !errors.Is(err, http.ErrHandlerTimeout) {
+			t.Errorf("%q: expected the late write to fail with http.ErrHandlerTimeout, but got %v", accept, err)
+		}
+	}
+}
+
+func TestTools_TimeoutAfterWriting(t *testing.T) {
+	var testTools Tools
+
+	var ctxErr error
+	handler := testTools.Timeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		<-r.Context().Done()
+		ctxErr = r.Context().Err()
+		_, _ = w.Write([]byte("partial"))
+	}), 20*time.Millisecond)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rr.Code != http.StatusAccepted || rr.Body.String() != "partial" {
+		t.Errorf("wrong response: %d %q", rr.Code, rr.Body.String())
+	}
+	if !errors.Is(ctxErr, context.DeadlineExceeded) {
+		t.Errorf("expected the handler's context to be cancelled, but got %v", ctxErr)
+	}
+}
+
+func TestTools_TimeoutPanic(t *testing.T) {
+	var testTools Tools
+
+	handler := testTools.Timeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), time.Second)
+
+	defer func() {
+		if recover() != "boom" {
+			t.Error("expected the handler's panic to be passed on")
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
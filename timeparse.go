@@ -0,0 +1,95 @@
+package toolbox
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// flexibleTimeLayouts are the layouts tried, in order, by ParseTimeFlexible and ParseTimeIn.
+var flexibleTimeLayouts = []string{
+	time.RFC3339Nano, // also matches RFC 3339 without fractional seconds
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"20060102",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// Bounds for numeric timestamps: roughly September 2001 to November 2286. Numbers outside of
+// these ranges are far more likely to be something other than a Unix timestamp.
+const (
+	minUnixSeconds = 1e9
+	maxUnixSeconds = 1e10
+	minUnixMillis  = 1e12
+	maxUnixMillis  = 1e13
+)
+
+// ParseTimeFlexible parses s using the first matching layout from this list:
+//
+//   - RFC 3339, with or without fractional seconds (2024-01-02T15:04:05Z, 2024-01-02T15:04:05.123+02:00)
+//   - RFC 3339 without a zone (2024-01-02T15:04:05), or with a space instead of the T
+//   - date and minutes (2024-01-02T15:04)
+//   - date only (2024-01-02)
+//   - compact date (20240102)
+//   - RFC 1123, with a numeric or named zone (Tue, 02 Jan 2024 15:04:05 GMT)
+//
+// A string of 10 digits is treated as Unix seconds, and a string of 13 digits as Unix milliseconds,
+// provided the value falls between 2001 and 2286. Values without a zone are treated as UTC; use
+// ParseTimeIn to choose a different location.
+func (t *Tools) ParseTimeFlexible(s string) (time.Time, error) {
+	return t.ParseTimeIn(s, time.UTC)
+}
+
+// ParseTimeIn is like ParseTimeFlexible, but values without a zone, such as 2024-01-02, are interpreted
+// in loc. Values that specify a zone, and Unix timestamps, are unaffected by loc, other than being
+// returned in it.
+func (t *Tools) ParseTimeIn(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, fmt.Errorf("cannot parse empty string as a time")
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	if isAllDigits(s) && len(s) != len("20060102") {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err == nil {
+			switch {
+			case n >= minUnixSeconds && n < maxUnixSeconds:
+				return time.Unix(n, 0).In(loc), nil
+			case n >= minUnixMillis && n < maxUnixMillis:
+				return time.UnixMilli(n).In(loc), nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("cannot parse %q as a time: number is not a plausible Unix timestamp", s)
+	}
+
+	for _, layout := range flexibleTimeLayouts {
+		parsed, err := time.ParseInLocation(layout, s, loc)
+		if err == nil {
+			return parsed, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("cannot parse %q as a time: unrecognized format", s)
+}
+
+// isAllDigits reports whether s is non-empty and consists only of ASCII digits.
+func isAllDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
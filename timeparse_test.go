@@ -0,0 +1,75 @@
+package toolbox
+
+import (
+	"testing"
+	"time"
+)
+
+var parseTimeTests = []struct {
+	name          string
+	s             string
+	expected      time.Time
+	errorExpected bool
+}{
+	{name: "rfc3339", s: "2024-01-02T15:04:05Z", expected: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)},
+	{name: "rfc3339 fractional", s: "2024-01-02T15:04:05.123+02:00", expected: time.Date(2024, 1, 2, 13, 4, 5, 123000000, time.UTC)},
+	{name: "no zone", s: "2024-01-02T15:04:05", expected: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)},
+	{name: "space separator", s: "2024-01-02 15:04:05", expected: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)},
+	{name: "date only", s: "2024-01-02", expected: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	{name: "compact date", s: "20240102", expected: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	{name: "rfc1123", s: "Tue, 02 Jan 2024 15:04:05 GMT", expected: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)},
+	{name: "unix seconds", s: "1704207845", expected: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)},
+	{name: "unix milliseconds", s: "1704207845123", expected: time.Date(2024, 1, 2, 15, 4, 5, 123000000, time.UTC)},
+	{name: "implausible number", s: "123456", errorExpected: true},
+	{name: "ambiguous digits", s: "202401021504", errorExpected: true},
+	{name: "garbage", s: "next tuesday", errorExpected: true},
+	{name: "empty", s: "", errorExpected: true},
+}
+
+func TestTools_ParseTimeFlexible(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range parseTimeTests {
+		parsed, err := testTools.ParseTimeFlexible(e.s)
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: error expected, but none received (got %v)", e.name, parsed)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		if !parsed.Equal(e.expected) {
+			t.Errorf("%s: expected %v but got %v", e.name, e.expected, parsed)
+		}
+	}
+}
+
+func TestTools_ParseTimeIn(t *testing.T) {
+	var testTools Tools
+
+	loc := time.FixedZone("EST", -5*60*60)
+
+	parsed, err := testTools.ParseTimeIn("2024-01-02", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !parsed.Equal(time.Date(2024, 1, 2, 5, 0, 0, 0, time.UTC)) {
+		t.Errorf("date only value not interpreted in location: %v", parsed)
+	}
+
+	// an explicit zone wins over the location
+	parsed, err = testTools.ParseTimeIn("2024-01-02T00:00:00Z", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !parsed.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("explicit zone not honored: %v", parsed)
+	}
+}
@@ -0,0 +1,891 @@
+// Package toolboxtest provides a test double for the toolbox package, so that handlers which accept
+// toolbox.Toolboxer, or one of its smaller interfaces, can be tested without real files, remote
+// services, or response recorders.
+package toolboxtest
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tsawler/toolbox"
+)
+
+// Call is a single recorded call to a MockTools method.
+type Call struct {
+	Method string
+	Args   []any
+}
+
+// MockTools is a toolbox.Toolboxer that records every call made to it. Each method calls the
+// matching Func field, if it is set; otherwise it does nothing, and returns zero values, with Err
+// as the error (middleware, such as RequestID, returns the handler it was given). It is safe for
+// concurrent use, as long as the fields are set up before use.
+type MockTools struct {
+	// Err is returned by every method with an error result that has no Func set.
+	Err error
+
+	ReadJSONFunc                func(w http.ResponseWriter, r *http.Request, data interface{}) error
+	ReadJSONContextFunc         func(ctx context.Context, w http.ResponseWriter, r *http.Request, data any) error
+	ReadJSONWithLimitFunc       func(w http.ResponseWriter, r *http.Request, data any, maxBytes int) error
+	ReadJSONRawFunc             func(w http.ResponseWriter, r *http.Request, data any) ([]byte, error)
+	WriteJSONFunc               func(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error
+	ErrorJSONFunc               func(w http.ResponseWriter, err error, status ...int) error
+	ErrorJSONCtxFunc            func(w http.ResponseWriter, r *http.Request, err error, status ...int) error
+	ErrorJSONfFunc              func(w http.ResponseWriter, status int, format string, args ...any) error
+	EncodeJSONFunc              func(w io.Writer, data any) (int, error)
+	ReadJSONPatchFunc           func(w http.ResponseWriter, r *http.Request) ([]toolbox.PatchOp, error)
+	ReadMergePatchFunc          func(w http.ResponseWriter, r *http.Request, original any) ([]byte, error)
+	WriteJSONWhenReadyFunc      func(w http.ResponseWriter, r *http.Request, status int, produce func(context.Context) (any, error), heartbeat time.Duration) error
+	EncodeJSONContextFunc       func(ctx context.Context, w io.Writer, data any) (int, error)
+	WriteEnvelopeFunc           func(w http.ResponseWriter, status int, message string, data any) error
+	WriteJSONNamedFunc          func(w http.ResponseWriter, status int, message string, key string, data any, headers ...http.Header) error
+	WriteNDJSONFunc             func(w http.ResponseWriter, status int, items <-chan any, headers ...http.Header) error
+	ReadXMLFunc                 func(w http.ResponseWriter, r *http.Request, data interface{}) error
+	ReadXMLMapFunc              func(w http.ResponseWriter, r *http.Request) (map[string]any, error)
+	WriteXMLFunc                func(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error
+	ErrorXMLFunc                func(w http.ResponseWriter, err error, status ...int) error
+	ErrorXMLCtxFunc             func(w http.ResponseWriter, r *http.Request, err error, status ...int) error
+	ErrorXMLfFunc               func(w http.ResponseWriter, status int, format string, args ...any) error
+	EncodeXMLFunc               func(w io.Writer, data any) (int, error)
+	WriteEnvelopeXMLFunc        func(w http.ResponseWriter, status int, message string, data any) error
+	WriteStringFunc             func(w http.ResponseWriter, status int, body string, headers ...http.Header) error
+	WriteHTMLFunc               func(w http.ResponseWriter, status int, body string, headers ...http.Header) error
+	DownloadStaticFileFunc      func(w http.ResponseWriter, r *http.Request, p, file, displayName string)
+	DownloadFromReaderFunc      func(w http.ResponseWriter, r *http.Request, content io.ReadSeeker, modTime time.Time, displayName, contentType string)
+	DownloadFSFileFunc          func(w http.ResponseWriter, r *http.Request, fsys fs.FS, name, displayName string) error
+	StaticDirFunc               func(dir string) http.Handler
+	DownloadFromReaderAtFunc    func(w http.ResponseWriter, r *http.Request, content io.ReaderAt, size int64, modTime time.Time, displayName, contentType string, opts ...toolbox.DownloadOption) error
+	DownloadJSONFunc            func(w http.ResponseWriter, data any, filename string, indent bool) error
+	DownloadNDJSONFunc          func(w http.ResponseWriter, items <-chan any, filename string) error
+	UploadFilesFunc             func(r *http.Request, uploadDir string, rename ...bool) ([]*toolbox.UploadedFile, error)
+	UploadFilesWithOptionsFunc  func(r *http.Request, uploadDir string, opts toolbox.UploadOptions) ([]*toolbox.UploadedFile, error)
+	UploadFilesPartialFunc      func(r *http.Request, uploadDir string, opts toolbox.UploadOptions) ([]*toolbox.UploadedFile, []toolbox.UploadError, error)
+	UploadOneFileFunc           func(r *http.Request, uploadDir string, rename ...bool) (*toolbox.UploadedFile, error)
+	UploadFilesQuarantinedFunc  func(r *http.Request, quarantineDir string) ([]*toolbox.UploadedFile, error)
+	ListQuarantinedFunc         func(quarantineDir string) ([]*toolbox.UploadedFile, error)
+	PromoteUploadFunc           func(file *toolbox.UploadedFile, destDir string) error
+	RejectUploadFunc            func(file *toolbox.UploadedFile) error
+	TrashFileFunc               func(file *toolbox.UploadedFile, trashDir string) error
+	RestoreFileFunc             func(trashDir, name string) (*toolbox.UploadedFile, error)
+	EmptyTrashFunc              func(trashDir string, olderThan time.Duration) (int, error)
+	ProgressHandlerFunc         func(tracker *toolbox.ProgressTracker) http.Handler
+	ReadMultipartJSONFunc       func(w http.ResponseWriter, r *http.Request, jsonFieldName string, dst any, uploadDir string) ([]*toolbox.UploadedFile, error)
+	ReadMultipartFormFunc       func(r *http.Request, dst any, uploadDir string) error
+	ReadFormFunc                func(w http.ResponseWriter, r *http.Request, dst any) error
+	ReadBodyFunc                func(w http.ResponseWriter, r *http.Request, dst any) error
+	FetchRemoteFileFunc         func(ctx context.Context, uri, uploadDir string, rename bool) (*toolbox.UploadedFile, error)
+	SaveDataURIFunc             func(dataURI string, uploadDir string, rename bool) (*toolbox.UploadedFile, error)
+	PushJSONToRemoteFunc        func(uri string, data interface{}, client ...*http.Client) (*http.Response, int, error)
+	PushJSONToManyFunc          func(ctx context.Context, uris []string, data any, opts ...toolbox.RemoteOption) []toolbox.RemoteResult
+	PushReaderToRemoteFunc      func(ctx context.Context, uri string, body io.Reader, contentType string, opts ...toolbox.RemoteOption) (int, []byte, error)
+	PushJSONStreamToRemoteFunc  func(ctx context.Context, uri string, data any, opts ...toolbox.RemoteOption) (int, []byte, error)
+	RequestIDFunc               func(next http.Handler) http.Handler
+	DebugRequestLoggerFunc      func(next http.Handler) http.Handler
+	TimeoutFunc                 func(next http.Handler, d time.Duration) http.Handler
+	RandomStringFunc            func(n int) string
+	RandomStringFastFunc        func(n int) string
+	RandomStringFastCharsetFunc func(n int, charset string) string
+	RandomFileNameFunc          func(originalName string) string
+	SlugifyFunc                 func(s string) (string, error)
+	CreateDirIfNotExistFunc     func(path string) error
+	CreateDirReportFunc         func(path string, mode os.FileMode) (bool, error)
+	TempFileFunc                func(pattern string) (*os.File, func(), error)
+	CleanupTempFilesFunc        func(olderThan time.Duration) (int, error)
+	ReadJSONFileFunc            func(path string, dst any) error
+	WriteJSONFileFunc           func(path string, data any, perm os.FileMode, indent bool) error
+	UpdateJSONFileFunc          func(path string, dst any, update func() error, perm os.FileMode) error
+	FileChecksumFunc            func(path string, algo string) (string, error)
+	VerifyFileChecksumFunc      func(path, expected, algo string) error
+	EncodeFileToDataURIFunc     func(path string) (string, error)
+	EncodeReaderToDataURIFunc   func(r io.Reader, contentType string) (string, error)
+	DecodeDataURIFunc           func(s string) (string, []byte, error)
+	IsValidEmailFunc            func(s string) bool
+	NormalizeEmailFunc          func(s string) (string, error)
+	ParseTimeFlexibleFunc       func(s string) (time.Time, error)
+	ParseTimeInFunc             func(s string, loc *time.Location) (time.Time, error)
+	LoadEnvConfigFunc           func(dst any, prefix string) error
+	ReadCSVStreamFunc           func(r io.Reader, fn func(int, map[string]string) error, opts ...toolbox.CSVOption) (toolbox.CSVReport, error)
+	VerifyWebhookSignatureFunc  func(r *http.Request, secret []byte, opts toolbox.WebhookOptions) ([]byte, error)
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Make sure MockTools keeps up with the toolbox interfaces.
+var _ toolbox.Toolboxer = (*MockTools)(nil)
+
+// record appends a call to the list of calls.
+func (m *MockTools) record(method string, args ...any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, Call{Method: method, Args: args})
+}
+
+// Calls returns every call made so far, in order.
+func (m *MockTools) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// CallCount returns the number of times method was called.
+func (m *MockTools) CallCount(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for _, c := range m.calls {
+		if c.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+// Reset forgets every recorded call.
+func (m *MockTools) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+}
+
+// ReadJSON records the call, and calls ReadJSONFunc if it is set.
+func (m *MockTools) ReadJSON(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	m.record("ReadJSON", w, r, data)
+	if m.ReadJSONFunc != nil {
+		return m.ReadJSONFunc(w, r, data)
+	}
+	return m.Err
+}
+
+// ReadJSONContext records the call, and calls ReadJSONContextFunc if it is set.
+func (m *MockTools) ReadJSONContext(ctx context.Context, w http.ResponseWriter, r *http.Request, data any) error {
+	m.record("ReadJSONContext", ctx, w, r, data)
+	if m.ReadJSONContextFunc != nil {
+		return m.ReadJSONContextFunc(ctx, w, r, data)
+	}
+	return m.Err
+}
+
+// ReadJSONWithLimit records the call, and calls ReadJSONWithLimitFunc if it is set.
+func (m *MockTools) ReadJSONWithLimit(w http.ResponseWriter, r *http.Request, data any, maxBytes int) error {
+	m.record("ReadJSONWithLimit", w, r, data, maxBytes)
+	if m.ReadJSONWithLimitFunc != nil {
+		return m.ReadJSONWithLimitFunc(w, r, data, maxBytes)
+	}
+	return m.Err
+}
+
+// ReadJSONRaw records the call, and calls ReadJSONRawFunc if it is set.
+func (m *MockTools) ReadJSONRaw(w http.ResponseWriter, r *http.Request, data any) ([]byte, error) {
+	m.record("ReadJSONRaw", w, r, data)
+	if m.ReadJSONRawFunc != nil {
+		return m.ReadJSONRawFunc(w, r, data)
+	}
+	return nil, m.Err
+}
+
+// WriteJSON records the call, and calls WriteJSONFunc if it is set.
+func (m *MockTools) WriteJSON(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
+	m.record("WriteJSON", w, status, data, headers)
+	if m.WriteJSONFunc != nil {
+		return m.WriteJSONFunc(w, status, data, headers...)
+	}
+	return m.Err
+}
+
+// ErrorJSON records the call, and calls ErrorJSONFunc if it is set.
+func (m *MockTools) ErrorJSON(w http.ResponseWriter, err error, status ...int) error {
+	m.record("ErrorJSON", w, err, status)
+	if m.ErrorJSONFunc != nil {
+		return m.ErrorJSONFunc(w, err, status...)
+	}
+	return m.Err
+}
+
+// ErrorJSONCtx records the call, and calls ErrorJSONCtxFunc if it is set.
+func (m *MockTools) ErrorJSONCtx(w http.ResponseWriter, r *http.Request, err error, status ...int) error {
+	m.record("ErrorJSONCtx", w, r, err, status)
+	if m.ErrorJSONCtxFunc != nil {
+		return m.ErrorJSONCtxFunc(w, r, err, status...)
+	}
+	return m.Err
+}
+
+// ErrorJSONf records the call, and calls ErrorJSONfFunc if it is set.
+func (m *MockTools) ErrorJSONf(w http.ResponseWriter, status int, format string, args ...any) error {
+	m.record("ErrorJSONf", w, status, format, args)
+	if m.ErrorJSONfFunc != nil {
+		return m.ErrorJSONfFunc(w, status, format, args...)
+	}
+	return m.Err
+}
+
+// EncodeJSON records the call, and calls EncodeJSONFunc if it is set.
+func (m *MockTools) EncodeJSON(w io.Writer, data any) (int, error) {
+	m.record("EncodeJSON", w, data)
+	if m.EncodeJSONFunc != nil {
+		return m.EncodeJSONFunc(w, data)
+	}
+	return 0, m.Err
+}
+
+// ReadJSONPatch records the call, and calls ReadJSONPatchFunc if it is set.
+func (m *MockTools) ReadJSONPatch(w http.ResponseWriter, r *http.Request) ([]toolbox.PatchOp, error) {
+	m.record("ReadJSONPatch", w, r)
+	if m.ReadJSONPatchFunc != nil {
+		return m.ReadJSONPatchFunc(w, r)
+	}
+	return nil, m.Err
+}
+
+// ReadMergePatch records the call, and calls ReadMergePatchFunc if it is set.
+func (m *MockTools) ReadMergePatch(w http.ResponseWriter, r *http.Request, original any) ([]byte, error) {
+	m.record("ReadMergePatch", w, r, original)
+	if m.ReadMergePatchFunc != nil {
+		return m.ReadMergePatchFunc(w, r, original)
+	}
+	return nil, m.Err
+}
+
+// WriteJSONWhenReady records the call, and calls WriteJSONWhenReadyFunc if it is set.
+func (m *MockTools) WriteJSONWhenReady(w http.ResponseWriter, r *http.Request, status int, produce func(context.Context) (any, error), heartbeat time.Duration) error {
+	m.record("WriteJSONWhenReady", w, r, status, produce, heartbeat)
+	if m.WriteJSONWhenReadyFunc != nil {
+		return m.WriteJSONWhenReadyFunc(w, r, status, produce, heartbeat)
+	}
+	return m.Err
+}
+
+// EncodeJSONContext records the call, and calls EncodeJSONContextFunc if it is set.
+func (m *MockTools) EncodeJSONContext(ctx context.Context, w io.Writer, data any) (int, error) {
+	m.record("EncodeJSONContext", ctx, w, data)
+	if m.EncodeJSONContextFunc != nil {
+		return m.EncodeJSONContextFunc(ctx, w, data)
+	}
+	return 0, m.Err
+}
+
+// WriteEnvelope records the call, and calls WriteEnvelopeFunc if it is set.
+func (m *MockTools) WriteEnvelope(w http.ResponseWriter, status int, message string, data any) error {
+	m.record("WriteEnvelope", w, status, message, data)
+	if m.WriteEnvelopeFunc != nil {
+		return m.WriteEnvelopeFunc(w, status, message, data)
+	}
+	return m.Err
+}
+
+// WriteJSONNamed records the call, and calls WriteJSONNamedFunc if it is set.
+func (m *MockTools) WriteJSONNamed(w http.ResponseWriter, status int, message string, key string, data any, headers ...http.Header) error {
+	m.record("WriteJSONNamed", w, status, message, key, data, headers)
+	if m.WriteJSONNamedFunc != nil {
+		return m.WriteJSONNamedFunc(w, status, message, key, data, headers...)
+	}
+	return m.Err
+}
+
+// WriteNDJSON records the call, and calls WriteNDJSONFunc if it is set.
+func (m *MockTools) WriteNDJSON(w http.ResponseWriter, status int, items <-chan any, headers ...http.Header) error {
+	m.record("WriteNDJSON", w, status, items, headers)
+	if m.WriteNDJSONFunc != nil {
+		return m.WriteNDJSONFunc(w, status, items, headers...)
+	}
+	return m.Err
+}
+
+// ReadXML records the call, and calls ReadXMLFunc if it is set.
+func (m *MockTools) ReadXML(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	m.record("ReadXML", w, r, data)
+	if m.ReadXMLFunc != nil {
+		return m.ReadXMLFunc(w, r, data)
+	}
+	return m.Err
+}
+
+// ReadXMLMap records the call, and calls ReadXMLMapFunc if it is set.
+func (m *MockTools) ReadXMLMap(w http.ResponseWriter, r *http.Request) (map[string]any, error) {
+	m.record("ReadXMLMap", w, r)
+	if m.ReadXMLMapFunc != nil {
+		return m.ReadXMLMapFunc(w, r)
+	}
+	return nil, m.Err
+}
+
+// WriteXML records the call, and calls WriteXMLFunc if it is set.
+func (m *MockTools) WriteXML(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
+	m.record("WriteXML", w, status, data, headers)
+	if m.WriteXMLFunc != nil {
+		return m.WriteXMLFunc(w, status, data, headers...)
+	}
+	return m.Err
+}
+
+// ErrorXML records the call, and calls ErrorXMLFunc if it is set.
+func (m *MockTools) ErrorXML(w http.ResponseWriter, err error, status ...int) error {
+	m.record("ErrorXML", w, err, status)
+	if m.ErrorXMLFunc != nil {
+		return m.ErrorXMLFunc(w, err, status...)
+	}
+	return m.Err
+}
+
+// ErrorXMLCtx records the call, and calls ErrorXMLCtxFunc if it is set.
+func (m *MockTools) ErrorXMLCtx(w http.ResponseWriter, r *http.Request, err error, status ...int) error {
+	m.record("ErrorXMLCtx", w, r, err, status)
+	if m.ErrorXMLCtxFunc != nil {
+		return m.ErrorXMLCtxFunc(w, r, err, status...)
+	}
+	return m.Err
+}
+
+// ErrorXMLf records the call, and calls ErrorXMLfFunc if it is set.
+func (m *MockTools) ErrorXMLf(w http.ResponseWriter, status int, format string, args ...any) error {
+	m.record("ErrorXMLf", w, status, format, args)
+	if m.ErrorXMLfFunc != nil {
+		return m.ErrorXMLfFunc(w, status, format, args...)
+	}
+	return m.Err
+}
+
+// EncodeXML records the call, and calls EncodeXMLFunc if it is set.
+func (m *MockTools) EncodeXML(w io.Writer, data any) (int, error) {
+	m.record("EncodeXML", w, data)
+	if m.EncodeXMLFunc != nil {
+		return m.EncodeXMLFunc(w, data)
+	}
+	return 0, m.Err
+}
+
+// WriteEnvelopeXML records the call, and calls WriteEnvelopeXMLFunc if it is set.
+func (m *MockTools) WriteEnvelopeXML(w http.ResponseWriter, status int, message string, data any) error {
+	m.record("WriteEnvelopeXML", w, status, message, data)
+	if m.WriteEnvelopeXMLFunc != nil {
+		return m.WriteEnvelopeXMLFunc(w, status, message, data)
+	}
+	return m.Err
+}
+
+// WriteString records the call, and calls WriteStringFunc if it is set.
+func (m *MockTools) WriteString(w http.ResponseWriter, status int, body string, headers ...http.Header) error {
+	m.record("WriteString", w, status, body, headers)
+	if m.WriteStringFunc != nil {
+		return m.WriteStringFunc(w, status, body, headers...)
+	}
+	return m.Err
+}
+
+// WriteHTML records the call, and calls WriteHTMLFunc if it is set.
+func (m *MockTools) WriteHTML(w http.ResponseWriter, status int, body string, headers ...http.Header) error {
+	m.record("WriteHTML", w, status, body, headers)
+	if m.WriteHTMLFunc != nil {
+		return m.WriteHTMLFunc(w, status, body, headers...)
+	}
+	return m.Err
+}
+
+// DownloadStaticFile records the call, and calls DownloadStaticFileFunc if it is set.
+func (m *MockTools) DownloadStaticFile(w http.ResponseWriter, r *http.Request, p, file, displayName string) {
+	m.record("DownloadStaticFile", w, r, p, file, displayName)
+	if m.DownloadStaticFileFunc != nil {
+		m.DownloadStaticFileFunc(w, r, p, file, displayName)
+	}
+}
+
+// DownloadFromReader records the call, and calls DownloadFromReaderFunc if it is set.
+func (m *MockTools) DownloadFromReader(w http.ResponseWriter, r *http.Request, content io.ReadSeeker, modTime time.Time, displayName, contentType string) {
+	m.record("DownloadFromReader", w, r, content, modTime, displayName, contentType)
+	if m.DownloadFromReaderFunc != nil {
+		m.DownloadFromReaderFunc(w, r, content, modTime, displayName, contentType)
+	}
+}
+
+// DownloadFSFile records the call, and calls DownloadFSFileFunc if it is set.
+func (m *MockTools) DownloadFSFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, name, displayName string) error {
+	m.record("DownloadFSFile", w, r, fsys, name, displayName)
+	if m.DownloadFSFileFunc != nil {
+		return m.DownloadFSFileFunc(w, r, fsys, name, displayName)
+	}
+	return m.Err
+}
+
+// StaticDir records the call, and calls StaticDirFunc if it is set.
+func (m *MockTools) StaticDir(dir string) http.Handler {
+	m.record("StaticDir", dir)
+	if m.StaticDirFunc != nil {
+		return m.StaticDirFunc(dir)
+	}
+	return http.NotFoundHandler()
+}
+
+// DownloadFromReaderAt records the call, and calls DownloadFromReaderAtFunc if it is set.
+func (m *MockTools) DownloadFromReaderAt(w http.ResponseWriter, r *http.Request, content io.ReaderAt, size int64, modTime time.Time, displayName, contentType string, opts ...toolbox.DownloadOption) error {
+	m.record("DownloadFromReaderAt", w, r, content, size, modTime, displayName, contentType, opts)
+	if m.DownloadFromReaderAtFunc != nil {
+		return m.DownloadFromReaderAtFunc(w, r, content, size, modTime, displayName, contentType, opts...)
+	}
+	return m.Err
+}
+
+// DownloadJSON records the call, and calls DownloadJSONFunc if it is set.
+func (m *MockTools) DownloadJSON(w http.ResponseWriter, data any, filename string, indent bool) error {
+	m.record("DownloadJSON", w, data, filename, indent)
+	if m.DownloadJSONFunc != nil {
+		return m.DownloadJSONFunc(w, data, filename, indent)
+	}
+	return m.Err
+}
+
+// DownloadNDJSON records the call, and calls DownloadNDJSONFunc if it is set.
+func (m *MockTools) DownloadNDJSON(w http.ResponseWriter, items <-chan any, filename string) error {
+	m.record("DownloadNDJSON", w, items, filename)
+	if m.DownloadNDJSONFunc != nil {
+		return m.DownloadNDJSONFunc(w, items, filename)
+	}
+	return m.Err
+}
+
+// UploadFiles records the call, and calls UploadFilesFunc if it is set.
+func (m *MockTools) UploadFiles(r *http.Request, uploadDir string, rename ...bool) ([]*toolbox.UploadedFile, error) {
+	m.record("UploadFiles", r, uploadDir, rename)
+	if m.UploadFilesFunc != nil {
+		return m.UploadFilesFunc(r, uploadDir, rename...)
+	}
+	return nil, m.Err
+}
+
+// UploadFilesWithOptions records the call, and calls UploadFilesWithOptionsFunc if it is set.
+func (m *MockTools) UploadFilesWithOptions(r *http.Request, uploadDir string, opts toolbox.UploadOptions) ([]*toolbox.UploadedFile, error) {
+	m.record("UploadFilesWithOptions", r, uploadDir, opts)
+	if m.UploadFilesWithOptionsFunc != nil {
+		return m.UploadFilesWithOptionsFunc(r, uploadDir, opts)
+	}
+	return nil, m.Err
+}
+
+// UploadFilesPartial records the call, and calls UploadFilesPartialFunc if it is set.
+func (m *MockTools) UploadFilesPartial(r *http.Request, uploadDir string, opts toolbox.UploadOptions) ([]*toolbox.UploadedFile, []toolbox.UploadError, error) {
+	m.record("UploadFilesPartial", r, uploadDir, opts)
+	if m.UploadFilesPartialFunc != nil {
+		return m.UploadFilesPartialFunc(r, uploadDir, opts)
+	}
+	return nil, nil, m.Err
+}
+
+// UploadOneFile records the call, and calls UploadOneFileFunc if it is set.
+func (m *MockTools) UploadOneFile(r *http.Request, uploadDir string, rename ...bool) (*toolbox.UploadedFile, error) {
+	m.record("UploadOneFile", r, uploadDir, rename)
+	if m.UploadOneFileFunc != nil {
+		return m.UploadOneFileFunc(r, uploadDir, rename...)
+	}
+	return nil, m.Err
+}
+
+// UploadFilesQuarantined records the call, and calls UploadFilesQuarantinedFunc if it is set.
+func (m *MockTools) UploadFilesQuarantined(r *http.Request, quarantineDir string) ([]*toolbox.UploadedFile, error) {
+	m.record("UploadFilesQuarantined", r, quarantineDir)
+	if m.UploadFilesQuarantinedFunc != nil {
+		return m.UploadFilesQuarantinedFunc(r, quarantineDir)
+	}
+	return nil, m.Err
+}
+
+// ListQuarantined records the call, and calls ListQuarantinedFunc if it is set.
+func (m *MockTools) ListQuarantined(quarantineDir string) ([]*toolbox.UploadedFile, error) {
+	m.record("ListQuarantined", quarantineDir)
+	if m.ListQuarantinedFunc != nil {
+		return m.ListQuarantinedFunc(quarantineDir)
+	}
+	return nil, m.Err
+}
+
+// PromoteUpload records the call, and calls PromoteUploadFunc if it is set.
+func (m *MockTools) PromoteUpload(file *toolbox.UploadedFile, destDir string) error {
+	m.record("PromoteUpload", file, destDir)
+	if m.PromoteUploadFunc != nil {
+		return m.PromoteUploadFunc(file, destDir)
+	}
+	return m.Err
+}
+
+// RejectUpload records the call, and calls RejectUploadFunc if it is set.
+func (m *MockTools) RejectUpload(file *toolbox.UploadedFile) error {
+	m.record("RejectUpload", file)
+	if m.RejectUploadFunc != nil {
+		return m.RejectUploadFunc(file)
+	}
+	return m.Err
+}
+
+// TrashFile records the call, and calls TrashFileFunc if it is set.
+func (m *MockTools) TrashFile(file *toolbox.UploadedFile, trashDir string) error {
+	m.record("TrashFile", file, trashDir)
+	if m.TrashFileFunc != nil {
+		return m.TrashFileFunc(file, trashDir)
+	}
+	return m.Err
+}
+
+// RestoreFile records the call, and calls RestoreFileFunc if it is set.
+func (m *MockTools) RestoreFile(trashDir, name string) (*toolbox.UploadedFile, error) {
+	m.record("RestoreFile", trashDir, name)
+	if m.RestoreFileFunc != nil {
+		return m.RestoreFileFunc(trashDir, name)
+	}
+	return nil, m.Err
+}
+
+// EmptyTrash records the call, and calls EmptyTrashFunc if it is set.
+func (m *MockTools) EmptyTrash(trashDir string, olderThan time.Duration) (int, error) {
+	m.record("EmptyTrash", trashDir, olderThan)
+	if m.EmptyTrashFunc != nil {
+		return m.EmptyTrashFunc(trashDir, olderThan)
+	}
+	return 0, m.Err
+}
+
+// ProgressHandler records the call, and calls ProgressHandlerFunc if it is set.
+func (m *MockTools) ProgressHandler(tracker *toolbox.ProgressTracker) http.Handler {
+	m.record("ProgressHandler", tracker)
+	if m.ProgressHandlerFunc != nil {
+		return m.ProgressHandlerFunc(tracker)
+	}
+	return http.NotFoundHandler()
+}
+
+// ReadMultipartJSON records the call, and calls ReadMultipartJSONFunc if it is set.
+func (m *MockTools) ReadMultipartJSON(w http.ResponseWriter, r *http.Request, jsonFieldName string, dst any, uploadDir string) ([]*toolbox.UploadedFile, error) {
+	m.record("ReadMultipartJSON", w, r, jsonFieldName, dst, uploadDir)
+	if m.ReadMultipartJSONFunc != nil {
+		return m.ReadMultipartJSONFunc(w, r, jsonFieldName, dst, uploadDir)
+	}
+	return nil, m.Err
+}
+
+// ReadMultipartForm records the call, and calls ReadMultipartFormFunc if it is set.
+func (m *MockTools) ReadMultipartForm(r *http.Request, dst any, uploadDir string) error {
+	m.record("ReadMultipartForm", r, dst, uploadDir)
+	if m.ReadMultipartFormFunc != nil {
+		return m.ReadMultipartFormFunc(r, dst, uploadDir)
+	}
+	return m.Err
+}
+
+// ReadForm records the call, and calls ReadFormFunc if it is set.
+func (m *MockTools) ReadForm(w http.ResponseWriter, r *http.Request, dst any) error {
+	m.record("ReadForm", w, r, dst)
+	if m.ReadFormFunc != nil {
+		return m.ReadFormFunc(w, r, dst)
+	}
+	return m.Err
+}
+
+// ReadBody records the call, and calls ReadBodyFunc if it is set.
+func (m *MockTools) ReadBody(w http.ResponseWriter, r *http.Request, dst any) error {
+	m.record("ReadBody", w, r, dst)
+	if m.ReadBodyFunc != nil {
+		return m.ReadBodyFunc(w, r, dst)
+	}
+	return m.Err
+}
+
+// FetchRemoteFile records the call, and calls FetchRemoteFileFunc if it is set.
+func (m *MockTools) FetchRemoteFile(ctx context.Context, uri, uploadDir string, rename bool) (*toolbox.UploadedFile, error) {
+	m.record("FetchRemoteFile", ctx, uri, uploadDir, rename)
+	if m.FetchRemoteFileFunc != nil {
+		return m.FetchRemoteFileFunc(ctx, uri, uploadDir, rename)
+	}
+	return nil, m.Err
+}
+
+// SaveDataURI records the call, and calls SaveDataURIFunc if it is set.
+func (m *MockTools) SaveDataURI(dataURI string, uploadDir string, rename bool) (*toolbox.UploadedFile, error) {
+	m.record("SaveDataURI", dataURI, uploadDir, rename)
+	if m.SaveDataURIFunc != nil {
+		return m.SaveDataURIFunc(dataURI, uploadDir, rename)
+	}
+	return nil, m.Err
+}
+
+// PushJSONToRemote records the call, and calls PushJSONToRemoteFunc if it is set.
+func (m *MockTools) PushJSONToRemote(uri string, data interface{}, client ...*http.Client) (*http.Response, int, error) {
+	m.record("PushJSONToRemote", uri, data, client)
+	if m.PushJSONToRemoteFunc != nil {
+		return m.PushJSONToRemoteFunc(uri, data, client...)
+	}
+	return nil, 0, m.Err
+}
+
+// PushJSONToMany records the call, and calls PushJSONToManyFunc if it is set.
+func (m *MockTools) PushJSONToMany(ctx context.Context, uris []string, data any, opts ...toolbox.RemoteOption) []toolbox.RemoteResult {
+	m.record("PushJSONToMany", ctx, uris, data, opts)
+	if m.PushJSONToManyFunc != nil {
+		return m.PushJSONToManyFunc(ctx, uris, data, opts...)
+	}
+	return nil
+}
+
+// PushReaderToRemote records the call, and calls PushReaderToRemoteFunc if it is set.
+func (m *MockTools) PushReaderToRemote(ctx context.Context, uri string, body io.Reader, contentType string, opts ...toolbox.RemoteOption) (int, []byte, error) {
+	m.record("PushReaderToRemote", ctx, uri, body, contentType, opts)
+	if m.PushReaderToRemoteFunc != nil {
+		return m.PushReaderToRemoteFunc(ctx, uri, body, contentType, opts...)
+	}
+	return 0, nil, m.Err
+}
+
+// PushJSONStreamToRemote records the call, and calls PushJSONStreamToRemoteFunc if it is set.
+func (m *MockTools) PushJSONStreamToRemote(ctx context.Context, uri string, data any, opts ...toolbox.RemoteOption) (int, []byte, error) {
+	m.record("PushJSONStreamToRemote", ctx, uri, data, opts)
+	if m.PushJSONStreamToRemoteFunc != nil {
+		return m.PushJSONStreamToRemoteFunc(ctx, uri, data, opts...)
+	}
+	return 0, nil, m.Err
+}
+
+// RequestID records the call, and calls RequestIDFunc if it is set.
+func (m *MockTools) RequestID(next http.Handler) http.Handler {
+	m.record("RequestID", next)
+	if m.RequestIDFunc != nil {
+		return m.RequestIDFunc(next)
+	}
+	return next
+}
+
+// DebugRequestLogger records the call, and calls DebugRequestLoggerFunc if it is set.
+func (m *MockTools) DebugRequestLogger(next http.Handler) http.Handler {
+	m.record("DebugRequestLogger", next)
+	if m.DebugRequestLoggerFunc != nil {
+		return m.DebugRequestLoggerFunc(next)
+	}
+	return next
+}
+
+// Timeout records the call, and calls TimeoutFunc if it is set.
+func (m *MockTools) Timeout(next http.Handler, d time.Duration) http.Handler {
+	m.record("Timeout", next, d)
+	if m.TimeoutFunc != nil {
+		return m.TimeoutFunc(next, d)
+	}
+	return next
+}
+
+// RandomString records the call, and calls RandomStringFunc if it is set.
+func (m *MockTools) RandomString(n int) string {
+	m.record("RandomString", n)
+	if m.RandomStringFunc != nil {
+		return m.RandomStringFunc(n)
+	}
+	return ""
+}
+
+// RandomStringFast records the call, and calls RandomStringFastFunc if it is set.
+func (m *MockTools) RandomStringFast(n int) string {
+	m.record("RandomStringFast", n)
+	if m.RandomStringFastFunc != nil {
+		return m.RandomStringFastFunc(n)
+	}
+	return ""
+}
+
+// RandomStringFastCharset records the call, and calls RandomStringFastCharsetFunc if it is set.
+func (m *MockTools) RandomStringFastCharset(n int, charset string) string {
+	m.record("RandomStringFastCharset", n, charset)
+	if m.RandomStringFastCharsetFunc != nil {
+		return m.RandomStringFastCharsetFunc(n, charset)
+	}
+	return ""
+}
+
+// RandomFileName records the call, and calls RandomFileNameFunc if it is set.
+func (m *MockTools) RandomFileName(originalName string) string {
+	m.record("RandomFileName", originalName)
+	if m.RandomFileNameFunc != nil {
+		return m.RandomFileNameFunc(originalName)
+	}
+	return ""
+}
+
+// Slugify records the call, and calls SlugifyFunc if it is set.
+func (m *MockTools) Slugify(s string) (string, error) {
+	m.record("Slugify", s)
+	if m.SlugifyFunc != nil {
+		return m.SlugifyFunc(s)
+	}
+	return "", m.Err
+}
+
+// CreateDirIfNotExist records the call, and calls CreateDirIfNotExistFunc if it is set.
+func (m *MockTools) CreateDirIfNotExist(path string) error {
+	m.record("CreateDirIfNotExist", path)
+	if m.CreateDirIfNotExistFunc != nil {
+		return m.CreateDirIfNotExistFunc(path)
+	}
+	return m.Err
+}
+
+// CreateDirReport records the call, and calls CreateDirReportFunc if it is set.
+func (m *MockTools) CreateDirReport(path string, mode os.FileMode) (bool, error) {
+	m.record("CreateDirReport", path, mode)
+	if m.CreateDirReportFunc != nil {
+		return m.CreateDirReportFunc(path, mode)
+	}
+	return false, m.Err
+}
+
+// TempFile records the call, and calls TempFileFunc if it is set.
+func (m *MockTools) TempFile(pattern string) (*os.File, func(), error) {
+	m.record("TempFile", pattern)
+	if m.TempFileFunc != nil {
+		return m.TempFileFunc(pattern)
+	}
+	return nil, nil, m.Err
+}
+
+// CleanupTempFiles records the call, and calls CleanupTempFilesFunc if it is set.
+func (m *MockTools) CleanupTempFiles(olderThan time.Duration) (int, error) {
+	m.record("CleanupTempFiles", olderThan)
+	if m.CleanupTempFilesFunc != nil {
+		return m.CleanupTempFilesFunc(olderThan)
+	}
+	return 0, m.Err
+}
+
+// ReadJSONFile records the call, and calls ReadJSONFileFunc if it is set.
+func (m *MockTools) ReadJSONFile(path string, dst any) error {
+	m.record("ReadJSONFile", path, dst)
+	if m.ReadJSONFileFunc != nil {
+		return m.ReadJSONFileFunc(path, dst)
+	}
+	return m.Err
+}
+
+// WriteJSONFile records the call, and calls WriteJSONFileFunc if it is set.
+func (m *MockTools) WriteJSONFile(path string, data any, perm os.FileMode, indent bool) error {
+	m.record("WriteJSONFile", path, data, perm, indent)
+	if m.WriteJSONFileFunc != nil {
+		return m.WriteJSONFileFunc(path, data, perm, indent)
+	}
+	return m.Err
+}
+
+// UpdateJSONFile records the call, and calls UpdateJSONFileFunc if it is set.
+func (m *MockTools) UpdateJSONFile(path string, dst any, update func() error, perm os.FileMode) error {
+	m.record("UpdateJSONFile", path, dst, update, perm)
+	if m.UpdateJSONFileFunc != nil {
+		return m.UpdateJSONFileFunc(path, dst, update, perm)
+	}
+	return m.Err
+}
+
+// FileChecksum records the call, and calls FileChecksumFunc if it is set.
+func (m *MockTools) FileChecksum(path string, algo string) (string, error) {
+	m.record("FileChecksum", path, algo)
+	if m.FileChecksumFunc != nil {
+		return m.FileChecksumFunc(path, algo)
+	}
+	return "", m.Err
+}
+
+// VerifyFileChecksum records the call, and calls VerifyFileChecksumFunc if it is set.
+func (m *MockTools) VerifyFileChecksum(path, expected, algo string) error {
+	m.record("VerifyFileChecksum", path, expected, algo)
+	if m.VerifyFileChecksumFunc != nil {
+		return m.VerifyFileChecksumFunc(path, expected, algo)
+	}
+	return m.Err
+}
+
+// EncodeFileToDataURI records the call, and calls EncodeFileToDataURIFunc if it is set.
+func (m *MockTools) EncodeFileToDataURI(path string) (string, error) {
+	m.record("EncodeFileToDataURI", path)
+	if m.EncodeFileToDataURIFunc != nil {
+		return m.EncodeFileToDataURIFunc(path)
+	}
+	return "", m.Err
+}
+
+// EncodeReaderToDataURI records the call, and calls EncodeReaderToDataURIFunc if it is set.
+func (m *MockTools) EncodeReaderToDataURI(r io.Reader, contentType string) (string, error) {
+	m.record("EncodeReaderToDataURI", r, contentType)
+	if m.EncodeReaderToDataURIFunc != nil {
+		return m.EncodeReaderToDataURIFunc(r, contentType)
+	}
+	return "", m.Err
+}
+
+// DecodeDataURI records the call, and calls DecodeDataURIFunc if it is set.
+func (m *MockTools) DecodeDataURI(s string) (string, []byte, error) {
+	m.record("DecodeDataURI", s)
+	if m.DecodeDataURIFunc != nil {
+		return m.DecodeDataURIFunc(s)
+	}
+	return "", nil, m.Err
+}
+
+// IsValidEmail records the call, and calls IsValidEmailFunc if it is set.
+func (m *MockTools) IsValidEmail(s string) bool {
+	m.record("IsValidEmail", s)
+	if m.IsValidEmailFunc != nil {
+		return m.IsValidEmailFunc(s)
+	}
+	return false
+}
+
+// NormalizeEmail records the call, and calls NormalizeEmailFunc if it is set.
+func (m *MockTools) NormalizeEmail(s string) (string, error) {
+	m.record("NormalizeEmail", s)
+	if m.NormalizeEmailFunc != nil {
+		return m.NormalizeEmailFunc(s)
+	}
+	return "", m.Err
+}
+
+// ParseTimeFlexible records the call, and calls ParseTimeFlexibleFunc if it is set.
+func (m *MockTools) ParseTimeFlexible(s string) (time.Time, error) {
+	m.record("ParseTimeFlexible", s)
+	if m.ParseTimeFlexibleFunc != nil {
+		return m.ParseTimeFlexibleFunc(s)
+	}
+	return time.Time{}, m.Err
+}
+
+// ParseTimeIn records the call, and calls ParseTimeInFunc if it is set.
+func (m *MockTools) ParseTimeIn(s string, loc *time.Location) (time.Time, error) {
+	m.record("ParseTimeIn", s, loc)
+	if m.ParseTimeInFunc != nil {
+		return m.ParseTimeInFunc(s, loc)
+	}
+	return time.Time{}, m.Err
+}
+
+// LoadEnvConfig records the call, and calls LoadEnvConfigFunc if it is set.
+func (m *MockTools) LoadEnvConfig(dst any, prefix string) error {
+	m.record("LoadEnvConfig", dst, prefix)
+	if m.LoadEnvConfigFunc != nil {
+		return m.LoadEnvConfigFunc(dst, prefix)
+	}
+	return m.Err
+}
+
+// ReadCSVStream records the call, and calls ReadCSVStreamFunc if it is set.
+func (m *MockTools) ReadCSVStream(r io.Reader, fn func(int, map[string]string) error, opts ...toolbox.CSVOption) (toolbox.CSVReport, error) {
+	m.record("ReadCSVStream", r, fn, opts)
+	if m.ReadCSVStreamFunc != nil {
+		return m.ReadCSVStreamFunc(r, fn, opts...)
+	}
+	return toolbox.CSVReport{}, m.Err
+}
+
+// VerifyWebhookSignature records the call, and calls VerifyWebhookSignatureFunc if it is set.
+func (m *MockTools) VerifyWebhookSignature(r *http.Request, secret []byte, opts toolbox.WebhookOptions) ([]byte, error) {
+	m.record("VerifyWebhookSignature", r, secret, opts)
+	if m.VerifyWebhookSignatureFunc != nil {
+		return m.VerifyWebhookSignatureFunc(r, secret, opts)
+	}
+	return nil, m.Err
+}
@@ -0,0 +1,75 @@
+package toolboxtest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tsawler/toolbox"
+)
+
+// createPost is the kind of handler a consumer would write, depending only on the interfaces.
+func createPost(tools interface {
+	toolbox.JSONReadWriter
+	toolbox.FileUploader
+}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Title string `json:"title"`
+		}
+
+		files, err := tools.ReadMultipartJSON(w, r, "metadata", &payload, "./uploads")
+		if err != nil {
+			_ = tools.ErrorJSON(w, err)
+			return
+		}
+
+		_ = tools.WriteJSON(w, http.StatusCreated, len(files))
+	}
+}
+
+func TestMockTools(t *testing.T) {
+	mock := &MockTools{
+		ReadMultipartJSONFunc: func(w http.ResponseWriter, r *http.Request, jsonFieldName string, dst any, uploadDir string) ([]*toolbox.UploadedFile, error) {
+			return []*toolbox.UploadedFile{{NewFileName: "a.png"}, {NewFileName: "b.png"}}, nil
+		},
+	}
+
+	rr := httptest.NewRecorder()
+	createPost(mock).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("")))
+
+	calls := mock.Calls()
+	if len(calls) != 2 || calls[0].Method != "ReadMultipartJSON" || calls[1].Method != "WriteJSON" {
+		t.Fatalf("wrong calls recorded: %+v", calls)
+	}
+
+	if calls[1].Args[1] != http.StatusCreated || calls[1].Args[2] != 2 {
+		t.Errorf("wrong arguments to WriteJSON: %v", calls[1].Args)
+	}
+
+	if mock.CallCount("ErrorJSON") != 0 {
+		t.Error("ErrorJSON should not have been called")
+	}
+}
+
+func TestMockToolsErr(t *testing.T) {
+	mock := &MockTools{Err: errors.New("boom")}
+
+	rr := httptest.NewRecorder()
+	createPost(mock).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("")))
+
+	if mock.CallCount("ErrorJSON") != 1 || mock.CallCount("WriteJSON") != 0 {
+		t.Errorf("expected the error path to be taken, but got %+v", mock.Calls())
+	}
+
+	if err := mock.Calls()[1].Args[1].(error); err.Error() != "boom" {
+		t.Errorf("wrong error passed to ErrorJSON: %v", err)
+	}
+
+	mock.Reset()
+	if len(mock.Calls()) != 0 {
+		t.Error("expected no calls after Reset")
+	}
+}
@@ -1,20 +1,26 @@
 package toolbox
 
 import (
+	"bufio"
 	"bytes"
-	"crypto/rand"
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
+	"log/slog"
+	"mime"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // randomStringSource is the source for generating random strings.
@@ -29,21 +35,118 @@ type Tools struct {
 	MaxJSONSize        int         // maximum size of JSON file we'll process
 	MaxXMLSize         int         // maximum size of XML file we'll process
 	MaxFileSize        int         // maximum size of uploaded files in bytes
-	AllowedFileTypes   []string    // allowed file types for upload (e.g. image/jpeg)
+	AllowedFileTypes   []string    // allowed file types for upload (e.g. image/jpeg, or image/* for any image)
 	AllowUnknownFields bool        // if set to true, allow unknown fields in JSON
 	ErrorLog           *log.Logger // the info log.
 	InfoLog            *log.Logger // the error log.
+	TempDir            string      // directory for temp files (defaults to os.TempDir)
+	MaxDataURISize     int         // maximum size of data we'll encode as a data URI
+
+	// Reading request bodies.
+	AcceptedJSONTypes       []string      // media types ReadJSON accepts besides application/json and +json types such as application/problem+json (e.g. text/json)
+	RejectDuplicateJSONKeys bool          // if set to true, ReadJSON rejects objects with the same key twice (in any case), instead of keeping the last value
+	MaxFormSize             int           // maximum size of form bodies read by ReadForm and ReadBody (defaults to 10MB)
+	JSONReadTimeout         time.Duration // if set, ReadJSON and ReadJSONContext give up on bodies that take longer than this to read
+	BodyReadTimeout         time.Duration // if set, ReadJSON and ReadXML give up on bodies that take longer than this to read, with a *ReadTimeoutError
+	VerifyContentDigest     bool          // if set to true, ReadJSON and UploadFiles check bodies against any Content-MD5 or Digest header sent
+	MaxArrayElements        int           // most elements ReadJSONArray accepts, unless WithMaxElements is used (defaults to 10,000)
+	SkipValidation          bool          // if set to true, ReadJSON doesn't call Validate on values that implement Validator
+	RequireJSONFields       bool          // if set to true, ReadJSON rejects bodies that leave out fields tagged json:",required"
+	MaxJSONDepth            int           // if set, ReadJSON rejects bodies with arrays and objects nested more deeply than this (New sets 64)
+	AllowEmptyBody          bool          // if set to true, ReadJSON leaves the destination alone, and returns nil, for an empty (or all whitespace) body
+	ReportAllUnknownFields  bool          // if set to true, ReadJSON reports every unknown key in a body, in an *UnknownFieldsError, rather than just the first
+	DisallowNullFields      bool          // if set to true, ReadJSON rejects null for fields that aren't pointers, interfaces or json.Unmarshalers, with a *NullFieldsError
+
+	// Logging.
+	Logger        *slog.Logger // structured logger for debug output (optional)
+	Debug         bool         // if set to true, and Logger is set, log debugging information
+	LogOperations bool         // if set to true, and Logger is set, log a summary of every read, write, upload and remote call
+
+	// Debug logging of requests.
+	DebugBodyLimit     int      // how much of each request body DebugRequestLogger logs (defaults to 4096 bytes)
+	DebugRedactHeaders []string // headers masked by DebugRequestLogger, as well as Authorization and Cookie
+	DebugRedactFields  []string // JSON and form fields masked by DebugRequestLogger, as well as password
+
+	// Encoding responses.
+	JSONIndent            string        // if set, JSON output is indented with this string (e.g. two spaces)
+	JSONDisableHTMLEscape bool          // if set to true, don't escape <, > and & in JSON strings
+	XMLIndent             string        // if set, XML output is indented with this string
+	JSONContentType       string        // the Content-Type of JSON responses, such as application/json; charset=utf-8 (defaults to application/json)
+	JSONHeartbeatByte     byte          // sent by WriteJSONWhenReady to keep connections open; must be JSON whitespace (defaults to a space)
+	NDJSONFlushItems      int           // WriteNDJSON flushes after this many lines (defaults to 100)
+	NDJSONFlushInterval   time.Duration // and at least this often, while lines are being written (defaults to a second)
+
+	// JSONMarshalHook, if set, is given every value about to be encoded as JSON, by WriteJSON,
+	// ErrorJSON, the envelope helpers and EncodeJSON, and what it returns is encoded instead, so
+	// that payloads can be changed in one place. If it fails, nothing is written. NormalizeTimes
+	// is one such change.
+	JSONMarshalHook func(v any) (any, error)
+
+	// Request IDs.
+	RequestIDHeader string // header used by the RequestID middleware (defaults to X-Request-ID)
+	RequestIDField  string // name of the request ID field added by ErrorJSONCtx and ErrorXMLCtx (defaults to request_id)
+
+	// Uploads.
+	FileNameStrategy   FileNameStrategy    // how uploaded files are named (defaults to FileNameRandom)
+	FileNameLength     int                 // length of the random part of uploaded file names (defaults to 25)
+	ScanFunc           FileScanner         // if set, every uploaded file must pass this scan before it is saved
+	NormalizeImages    *ImageNormalization // if set, uploaded images are decoded and re-encoded
+	BaseUploadDir      string              // if set, upload directories are relative to, and must stay inside, this directory
+	MaxFileNameLength  int                 // longest name, in bytes, a file is saved with; longer names are truncated (defaults to 255)
+	MaxTotalUploadSize int                 // if set, the largest request body, with all its files, UploadFiles accepts
+	MaxUploadDuration  time.Duration       // if set, how long UploadFiles may spend reading a request body
+	MinUploadRate      int64               // if set, the slowest, in bytes a second over the last few seconds, UploadFiles lets a body be sent
+
+	// UploadCopyBufferSize is the size of the buffer used to write each uploaded file to disk
+	// (defaults to 128KB); larger buffers can be faster on fast disks.
+	UploadCopyBufferSize int
+
+	// Slugs.
+	SlugTransliterations map[rune]string // replacements for runes in slugs (e.g. 'ü': "ue"), used before the built in table
+	SlugNoLeadingDigit   bool            // if true, slugs that would start with a digit are given SlugDigitPrefix, so 42 becomes n42
+	SlugDigitPrefix      string          // the prefix used by SlugNoLeadingDigit (defaults to n)
+	SlugRequireLetter    bool            // if true, Slugify returns an error for slugs without a letter, such as 42
+
+	// Downloads.
+	DownloadDigestAlgorithm string       // if set (sha256, sha1, or md5), download helpers send a Digest header
+	OnDownload              DownloadHook // if set, called after every response sent by the download helpers, e.g. for auditing
+	ServePrecompressed      bool         // if true, DownloadStaticFile and StaticDir send a file's .br or .gz variant, if it has one, to clients that accept it
+
+	// Metrics.
+	ResponseMetrics ResponseMetricsHook // if set, called after every response sent by WriteJSON, WriteXML, ErrorJSON and ErrorXML
+	DecodeMetrics   DecodeMetricsHook   // if set, called after every request body read by ReadJSON and ReadXML
+
+	// Calls to remote services.
+	RemoteClient        *http.Client       // client for calls to remote services (optional)
+	RemoteTimeout       time.Duration      // timeout for calls to remote services when RemoteClient is not set (defaults to 30s)
+	OnRemoteRequest     RemoteRequestHook  // if set, called before every call to a remote service
+	OnRemoteResponse    RemoteResponseHook // if set, called after every call to a remote service
+	RemoteRedactHeaders []string           // headers masked before OnRemoteRequest sees them, as well as Authorization and Cookie
+
+	// Email validation.
+	EmailAllowBareTLD          bool // if set to true, allow email domains without a dot (e.g. user@localhost)
+	EmailRejectConsecutiveDots bool // if set to true, reject email local parts containing ".."
+
+	// Error responses.
+	SuppressInternalErrors bool           // if set to true (and Debug is not), errors with a status of 500 or more are logged, not sent
+	InternalErrorMessage   string         // the message sent instead of a suppressed error (defaults to "internal server error")
+	EnvelopeNames          *EnvelopeNames // if set, renames the members of the envelope sent by WriteEnvelope and the error helpers
 }
 
-// New returns a new toolbox with sensible defaults.
-func New() Tools {
-	return Tools{
-		MaxJSONSize: defaultMaxUpload,
-		MaxXMLSize:  defaultMaxUpload,
-		MaxFileSize: defaultMaxUpload,
-		InfoLog:     log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime),
-		ErrorLog:    log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile),
+// New returns a new toolbox with sensible defaults, changed by opts, if there are any.
+func New(opts ...Option) Tools {
+	t := Tools{
+		MaxJSONSize:  defaultMaxUpload,
+		MaxXMLSize:   defaultMaxUpload,
+		MaxFileSize:  defaultMaxUpload,
+		MaxJSONDepth: defaultMaxJSONDepth,
+		InfoLog:      log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime),
+		ErrorLog:     log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile),
 	}
+	for _, opt := range opts {
+		opt(&t)
+	}
+	return t
 }
 
 // JSONResponse is the type used for sending JSON around.
@@ -61,28 +164,185 @@ type XMLResponse struct {
 }
 
 // ReadJSON tries to read the body of a request and converts it from JSON to a variable. The third parameter, data,
-// is expected to be a pointer, so that we can read data into it.
+// is expected to be a pointer, so that we can read data into it; if it isn't, an *InvalidDestinationError is returned.
+// If data is a Validator, its Validate method is called once the body has been read, unless SkipValidation is set,
+// and an error from it is returned as a *ValidationError. An empty body is an error, of kind ErrEmptyBody, unless
+// AllowEmptyBody is set, in which case data is left as it was, for endpoints where the body is optional. Unknown keys
+// are rejected, unless AllowUnknownFields is set, with a *ReadError for the first one, or, if ReportAllUnknownFields
+// is set, an *UnknownFieldsError listing them all.
 func (t *Tools) ReadJSON(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	return t.ReadJSONContext(r.Context(), w, r, data)
+}
+
+// ReadJSONInto is ReadJSON, for when it's easier to get the decoded value back than to declare it
+// first. It is a function, not a method, since methods can't have type parameters:
+//
+//	post, err := toolbox.ReadJSONInto[Post](&tools, w, r)
+//
+// The body is read exactly as ReadJSON reads it, with the same limits and errors, into a new T; if
+// there is an error, the zero value of T is returned with it.
+func ReadJSONInto[T any](t *Tools, w http.ResponseWriter, r *http.Request) (T, error) {
+	var data T
+	err := t.ReadJSON(w, r, &data)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return data, nil
+}
+
+// ReadJSONWithLimit is ReadJSON, but allows a body of up to maxBytes, instead of the limit set on the
+// request context, or MaxJSONSize, for this call only, so that one endpoint can accept a bulk import
+// while the rest keep a small limit:
+//
+//	err := tools.ReadJSONWithLimit(w, r, &rows, 50<<20)
+//
+// The receiver is not changed, so calls with different limits can share a Tools. If maxBytes isn't
+// positive, the usual limit applies.
+func (t *Tools) ReadJSONWithLimit(w http.ResponseWriter, r *http.Request, data any, maxBytes int) error {
+	return t.readJSON(r.Context(), w, r, data, readJSONOptions{maxBytes: maxBytes})
+}
+
+// ReadJSONRaw is ReadJSON, but also returns the body exactly as it was received, for audit logs, or
+// for checking a webhook's signature. The body is returned even if it couldn't be decoded, in which
+// case the rest of it is read too, so that it is complete. Only as much as MaxJSONSize (or the limit
+// set on the request context) allows is ever kept; a body that is too large is cut off there. If
+// the body wasn't read at all (because of its Content-Type, for example), nil is returned.
+func (t *Tools) ReadJSONRaw(w http.ResponseWriter, r *http.Request, data any) ([]byte, error) {
+	var raw bytes.Buffer
+	err := t.readJSON(r.Context(), w, r, data, readJSONOptions{raw: &raw})
+	if raw.Len() == 0 {
+		return nil, err
+	}
+	return raw.Bytes(), err
+}
+
+// readJSONOptions changes how readJSON reads a body.
+type readJSONOptions struct {
+	maxBytes int           // the limit on the size of the body, if not zero
+	raw      *bytes.Buffer // if set, receives the body as it is read
+}
+
+// readJSON does the work of ReadJSONContext, ReadJSONWithLimit and ReadJSONRaw, reading the body of
+// r only for as long as ctx is live (and within JSONReadTimeout, or BodyReadTimeout, if one is set).
+func (t *Tools) readJSON(ctx context.Context, w http.ResponseWriter, r *http.Request, data any, opts readJSONOptions) error {
+	done := t.trackDecode(r, "json")
+
+	timeout := t.JSONReadTimeout
+	if timeout <= 0 {
+		timeout = t.BodyReadTimeout
+	}
+	ctx, cancel := withReadTimeout(ctx, timeout)
+	defer cancel()
 
-	// Check content-type header; it should be application/json. If it's not specified,
+	if err := checkDestination("ReadJSON", data); err != nil {
+		return done(err)
+	}
+
+	// Check content-type header; it should be application/json (parameters such as charset are
+	// fine), or another JSON type, such as application/merge-patch+json. If it's not specified,
 	// try to decode the body anyway.
-	if r.Header.Get("Content-Type") != "" {
-		contentType := r.Header.Get("Content-Type")
-		if strings.ToLower(contentType) != "application/json" {
-			return errors.New("the Content-Type header is not application/json")
-		}
+	if contentType := r.Header.Get("Content-Type"); contentType != "" && !t.isJSONMediaType(contentType) {
+		return done(t.jsonMediaTypeError(contentType))
 	}
 
-	// Set a sensible default for the maximum payload size.
-	maxBytes := defaultMaxUpload
+	verifyDigest, err := t.verifyBodyDigest(r)
+	if err != nil {
+		return done(err)
+	}
 
-	// If MaxJSONSize is set, use that value instead of default.
-	if t.MaxJSONSize != 0 {
-		maxBytes = t.MaxJSONSize
+	// Limit the payload to the size given in opts, if any, or else to the size set on the request
+	// context, MaxJSONSize, or a sensible default.
+	maxBytes := opts.maxBytes
+	if maxBytes <= 0 {
+		maxBytes = t.maxJSONSize(r.Context())
+	}
+	if declaredTooLarge(w, r, maxBytes) {
+		return done(&BodyTooLargeError{Limit: maxBytes})
 	}
 	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	var stopReading func()
+	r.Body, stopReading = newContextReader(ctx, w, r.Body)
+	defer stopReading()
+
+	if opts.raw != nil {
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(r.Body, opts.raw), r.Body}
+
+		// If decoding stops part way, read the rest (still within the limit), so that the caller has
+		// the whole body.
+		defer func() { _, _ = io.Copy(io.Discard, r.Body) }()
+	}
+
+	// Checking for duplicate keys, unknown keys, missing fields or nulls means reading the body
+	// twice, so only keep it in memory if we must.
+	requireFields := t.RequireJSONFields && hasRequiredJSONFields(reflect.TypeOf(data), map[reflect.Type]bool{})
+	reportUnknown := t.ReportAllUnknownFields && !t.AllowUnknownFields
+	var body []byte
+	if t.RejectDuplicateJSONKeys || requireFields || reportUnknown || t.DisallowNullFields {
+		body, err = io.ReadAll(r.Body)
+		if isMaxBytesError(err) {
+			return done(&BodyTooLargeError{Limit: maxBytes})
+		}
+		if err != nil {
+			return done(err)
+		}
+
+		if t.RejectDuplicateJSONKeys {
+			if err := checkDuplicateJSONKeys(t.limitJSONDepth(bytes.NewReader(body))); err != nil {
+				return done(err)
+			}
+		}
+
+		if reportUnknown {
+			if err := checkUnknownJSONFields(t.limitJSONDepth(bytes.NewReader(body)), data); err != nil {
+				return done(err)
+			}
+		}
 
-	dec := json.NewDecoder(r.Body)
+		err = t.decodeJSON(t.limitJSONDepth(bytes.NewReader(body)), data, maxBytes)
+	} else {
+		err = t.decodeJSON(t.limitJSONDepth(r.Body), data, maxBytes)
+	}
+	empty := t.AllowEmptyBody && errors.Is(err, ErrEmptyBody)
+	if err != nil && !empty {
+		return done(err)
+	}
+
+	err = verifyDigest()
+	if isMaxBytesError(err) {
+		return done(&BodyTooLargeError{Limit: maxBytes})
+	}
+	if err != nil {
+		return done(err)
+	}
+
+	// An empty body leaves data as it was, so there is nothing to check.
+	if empty {
+		return done(nil)
+	}
+
+	if requireFields {
+		if err := checkRequiredJSONFields(body, data); err != nil {
+			return done(err)
+		}
+	}
+
+	if t.DisallowNullFields {
+		if err := checkNullJSONFields(body, data); err != nil {
+			return done(err)
+		}
+	}
+
+	return done(t.validate(data))
+}
+
+// decodeJSON decodes a single JSON value from body into data, honoring AllowUnknownFields. The
+// caller is responsible for limiting the size of body to maxBytes, which is used in error messages.
+func (t *Tools) decodeJSON(body io.Reader, data interface{}, maxBytes int) error {
+	dec := json.NewDecoder(body)
 
 	// Should we allow unknown fields?
 	if !t.AllowUnknownFields {
@@ -93,70 +353,225 @@ func (t *Tools) ReadJSON(w http.ResponseWriter, r *http.Request, data interface{
 	// response.
 	err := dec.Decode(data)
 	if err != nil {
-		var syntaxError *json.SyntaxError
-		var unmarshalTypeError *json.UnmarshalTypeError
-		var invalidUnmarshalError *json.InvalidUnmarshalError
+		return classifyJSONError(err, maxBytes)
+	}
 
-		switch {
-		case errors.As(err, &syntaxError):
-			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+	err = dec.Decode(&struct{}{})
+	if isContextError(err) {
+		return err
+	}
+	if err != io.EOF {
+		return errMultipleJSONValues()
+	}
 
-		case errors.Is(err, io.ErrUnexpectedEOF):
-			return errors.New("body contains badly-formed JSON")
+	return nil
+}
 
-		case errors.As(err, &unmarshalTypeError):
-			return fmt.Errorf("body contains incorrect JSON type for field %q at offset %d", unmarshalTypeError.Field, unmarshalTypeError.Offset)
+// isContextError reports whether err is from a context that is done.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
 
-		case errors.Is(err, io.EOF):
-			return errors.New("body must not be empty")
+// ErrBodyTooLarge matches, with errors.Is, the *BodyTooLargeError returned when a request body is
+// larger than the limit for reading it (MaxJSONSize, or MaxXMLSize), so that a handler can respond
+// with 413 Request Entity Too Large for any of them.
+var ErrBodyTooLarge = errors.New("body too large")
 
-		case strings.HasPrefix(err.Error(), "json: unknown field "):
-			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
-			return fmt.Errorf("body contains unknown key %s", fieldName)
+// BodyTooLargeError is returned, by ReadJSON, ReadXML and the other helpers that read request bodies,
+// when the body is larger than Limit bytes.
+type BodyTooLargeError struct {
+	Limit int // the limit that was exceeded, in bytes
+}
 
-		case err.Error() == "http: request body too large":
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytes)
+// Error satisfies the error interface.
+func (e *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("body must not be larger than %d bytes", e.Limit)
+}
 
-		case errors.As(err, &invalidUnmarshalError):
-			return fmt.Errorf("error unmarshalling json: %s", err.Error())
+// Is makes errors.Is(err, ErrBodyTooLarge) true.
+func (e *BodyTooLargeError) Is(target error) bool {
+	return target == ErrBodyTooLarge
+}
 
-		default:
-			return err
-		}
-	}
+// InvalidDestinationError is returned by ReadJSON and ReadXML when the value to decode into is not a
+// non-nil pointer. Unlike the other errors they return, it is a bug in the caller, not a problem with
+// the request, so it should be treated as an internal error, rather than sent to the client.
+type InvalidDestinationError struct {
+	Func string       // the function that was called, e.g. ReadJSON
+	Type reflect.Type // the type of the value given, or nil for a nil interface
+}
 
-	err = dec.Decode(&struct{}{})
-	if err != io.EOF {
-		return errors.New("body must only contain a single JSON value")
+// Error satisfies the error interface.
+func (e *InvalidDestinationError) Error() string {
+	if e.Type == nil {
+		return fmt.Sprintf("%s: destination must be a non-nil pointer, got nil", e.Func)
 	}
+	if e.Type.Kind() == reflect.Pointer {
+		return fmt.Sprintf("%s: destination must be a non-nil pointer, got nil %s", e.Func, e.Type)
+	}
+	return fmt.Sprintf("%s: destination must be a non-nil pointer, got %s", e.Func, e.Type)
+}
 
+// checkDestination returns an *InvalidDestinationError, for fn, if data is not a non-nil pointer.
+func checkDestination(fn string, data any) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return &InvalidDestinationError{Func: fn, Type: reflect.TypeOf(data)}
+	}
 	return nil
 }
 
+// isMaxBytesError reports whether err is from reading past the limit of an http.MaxBytesReader.
+func isMaxBytesError(err error) bool {
+	var maxBytesError *http.MaxBytesError
+	return errors.As(err, &maxBytesError)
+}
+
+// The kinds of *ReadError, which it matches with errors.Is, so that handlers can choose a status
+// without looking at the message. A body that is too large gets a *BodyTooLargeError, which matches
+// ErrBodyTooLarge, and one of the wrong type an *UnsupportedMediaTypeError.
+var (
+	// ErrBadlyFormedJSON is for a body that isn't valid JSON, including one that ends too soon.
+	ErrBadlyFormedJSON = errors.New("badly-formed JSON")
+
+	// ErrWrongType is for a JSON value of the wrong type for the field it is decoded into.
+	ErrWrongType = errors.New("incorrect JSON type")
+
+	// ErrUnknownField is for a JSON object with a key the destination has no field for, when
+	// AllowUnknownFields is not set.
+	ErrUnknownField = errors.New("unknown JSON field")
+
+	// ErrEmptyBody is for a body with no JSON in it at all.
+	ErrEmptyBody = errors.New("empty body")
+
+	// ErrMultipleJSONValues is for a body with more than one JSON value in it.
+	ErrMultipleJSONValues = errors.New("more than one JSON value")
+
+	// ErrJSONTooDeep is for a body with arrays or objects nested more deeply than MaxJSONDepth.
+	ErrJSONTooDeep = errors.New("JSON nested too deeply")
+)
+
+// ReadError is returned by ReadJSON, and the other helpers that read JSON, when the body can't be
+// decoded. Its message is meant for the client; Kind says what went wrong, for the handler.
+type ReadError struct {
+	Kind   error  // ErrBadlyFormedJSON, ErrWrongType, ErrUnknownField, ErrEmptyBody, ErrMultipleJSONValues, or ErrJSONTooDeep
+	Field  string // the field concerned, for ErrWrongType and ErrUnknownField
+	Offset int64  // how far into the body the problem was found, or zero if not known
+
+	message string
+	err     error // the error from encoding/json, if there was one
+}
+
+// Error satisfies the error interface.
+func (e *ReadError) Error() string {
+	return e.message
+}
+
+// Is makes errors.Is(err, e.Kind) true.
+func (e *ReadError) Is(target error) bool {
+	return target == e.Kind
+}
+
+// Unwrap returns the error from encoding/json, if there was one.
+func (e *ReadError) Unwrap() error {
+	return e.err
+}
+
+// errMultipleJSONValues returns the error for a body with more than one JSON value in it.
+func errMultipleJSONValues() error {
+	return &ReadError{Kind: ErrMultipleJSONValues, message: "body must only contain a single JSON value"}
+}
+
+// classifyJSONError converts an error from decoding JSON into a human-readable *ReadError, or
+// *BodyTooLargeError. The maxBytes parameter is the size limit that was in effect, for use in the
+// error.
+func classifyJSONError(err error, maxBytes int) error {
+	var syntaxError *json.SyntaxError
+	var unmarshalTypeError *json.UnmarshalTypeError
+	var invalidUnmarshalError *json.InvalidUnmarshalError
+
+	switch {
+	case errors.As(err, &syntaxError):
+		return &ReadError{Kind: ErrBadlyFormedJSON, Offset: syntaxError.Offset, err: err,
+			message: fmt.Sprintf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)}
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &ReadError{Kind: ErrBadlyFormedJSON, err: err, message: "body contains badly-formed JSON"}
+
+	case errors.As(err, &unmarshalTypeError):
+		return &ReadError{Kind: ErrWrongType, Field: unmarshalTypeError.Field, Offset: unmarshalTypeError.Offset, err: err,
+			message: fmt.Sprintf("body contains incorrect JSON type for field %q at offset %d", unmarshalTypeError.Field, unmarshalTypeError.Offset)}
+
+	case errors.Is(err, io.EOF):
+		return &ReadError{Kind: ErrEmptyBody, message: "body must not be empty"}
+
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		fieldName := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		return &ReadError{Kind: ErrUnknownField, Field: strings.Trim(fieldName, `"`), err: err,
+			message: fmt.Sprintf("body contains unknown key %s", fieldName)}
+
+	case isMaxBytesError(err):
+		return &BodyTooLargeError{Limit: maxBytes}
+
+	case errors.As(err, &invalidUnmarshalError):
+		return fmt.Errorf("error unmarshalling json: %s", err.Error())
+
+	default:
+		return err
+	}
+}
+
 // WriteJSON takes a response status code and arbitrary data and writes a JSON response to the client.
+// The Content-Type header is set to JSONContentType (application/json by default), unless one is
+// supplied in the optional headers parameter.
 func (t *Tools) WriteJSON(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
-	out, err := json.Marshal(data)
+	return t.writeJSON(w, "json", status, data, headers...)
+}
+
+// writeJSON is WriteJSON, reporting the response to ResponseMetrics as kind.
+func (t *Tools) writeJSON(w http.ResponseWriter, kind string, status int, data interface{}, headers ...http.Header) error {
+	start := time.Now()
+
+	out, err := t.marshalJSON(data)
 	if err != nil {
 		return err
 	}
 
-	// If we have a value as the last parameter in the function call, then we are setting a custom header.
+	// Set the headers, and the content type, unless the caller gave one, and send response.
+	setHeaders(w, t.jsonContentType(), headers)
+	w.WriteHeader(status)
+	n, _ := w.Write(out)
+	t.reportResponse(kind, status, n, start)
+
+	return nil
+}
+
+// jsonContentType returns the Content-Type sent with JSON responses: JSONContentType, or
+// application/json if that isn't set.
+func (t *Tools) jsonContentType() string {
+	if t.JSONContentType != "" {
+		return t.JSONContentType
+	}
+	return "application/json"
+}
+
+// setHeaders copies the custom headers passed to a Write function, if there are any, to w, and sets
+// its Content-Type to contentType, unless they include a Content-Type of their own, which wins.
+func setHeaders(w http.ResponseWriter, contentType string, headers []http.Header) {
 	if len(headers) > 0 {
 		for key, value := range headers[0] {
 			w.Header()[key] = value
 		}
+		if headers[0].Get("Content-Type") != "" {
+			return
+		}
 	}
-
-	// Set the content type and send response.
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	_, _ = w.Write(out)
-
-	return nil
+	w.Header().Set("Content-Type", contentType)
 }
 
 // ErrorJSON takes an error, and optionally a response status code, and generates and sends
-// a JSON error response.
+// a JSON error response. If err holds FieldErrors, such as those from ReadForm, they are sent too,
+// as the errors member (or EnvelopeNames.Errors), in the order they were found.
 func (t *Tools) ErrorJSON(w http.ResponseWriter, err error, status ...int) error {
 	statusCode := http.StatusBadRequest
 
@@ -166,28 +581,59 @@ func (t *Tools) ErrorJSON(w http.ResponseWriter, err error, status ...int) error
 	}
 
 	// Build the JSON payload.
-	var payload JSONResponse
-	payload.Error = true
-	payload.Message = err.Error()
+	payload := t.jsonEnvelope(true, t.errorMessage(err, statusCode, ""), nil, t.fieldErrorsMember(err, statusCode)...)
+
+	return t.writeJSON(w, "json_error", statusCode, payload)
+}
+
+// ErrorJSONf is ErrorJSON, with the error made from format and args, as fmt.Errorf would make it
+// (so %w works too), for call sites that would otherwise build an error just to send it:
+//
+//	return tools.ErrorJSONf(w, http.StatusNotFound, "no order with id %d", id)
+//
+// SuppressInternalErrors applies as it does to ErrorJSON. An empty format is an error, and nothing
+// is sent.
+func (t *Tools) ErrorJSONf(w http.ResponseWriter, status int, format string, args ...any) error {
+	if format == "" {
+		return errors.New("ErrorJSONf: format must not be empty")
+	}
+	return t.ErrorJSON(w, fmt.Errorf(format, args...), status)
+}
+
+// WriteString takes a response status code and a string, and writes it to the client as plain text.
+// The Content-Type header defaults to text/plain; charset=utf-8, but can be overridden by supplying
+// a Content-Type in the optional headers parameter.
+func (t *Tools) WriteString(w http.ResponseWriter, status int, body string, headers ...http.Header) error {
+	return t.writeBody(w, status, "text/plain; charset=utf-8", []byte(body), headers...)
+}
+
+// WriteHTML takes a response status code and a string of HTML, and writes it to the client. The
+// Content-Type header defaults to text/html; charset=utf-8, but can be overridden by supplying
+// a Content-Type in the optional headers parameter.
+func (t *Tools) WriteHTML(w http.ResponseWriter, status int, body string, headers ...http.Header) error {
+	return t.writeBody(w, status, "text/html; charset=utf-8", []byte(body), headers...)
+}
+
+// writeBody sets the headers, as setHeaders does for WriteJSON, so that a Content-Type in headers
+// wins over contentType, and writes the status and body, returning any error from the write.
+func (t *Tools) writeBody(w http.ResponseWriter, status int, contentType string, body []byte, headers ...http.Header) error {
+	setHeaders(w, contentType, headers)
+	w.WriteHeader(status)
 
-	return t.WriteJSON(w, statusCode, payload)
+	_, err := w.Write(body)
+	return err
 }
 
 // RandomString returns a random string of letters of length n, using characters specified in randomStringSource.
+// Every character is equally likely, and chosen with crypto/rand, so the result can be used as a secret.
 func (t *Tools) RandomString(n int) string {
-	s, r := make([]rune, n), []rune(randomStringSource)
-	for i := range s {
-		p, _ := rand.Prime(rand.Reader, len(r))
-		x, y := p.Uint64(), uint64(len(r))
-		s[i] = r[x%y]
-	}
-	return string(s)
+	return randomString(n, randomStringSource, secureIntN)
 }
 
 // PushJSONToRemote posts arbitrary json to some url, and returns the response, the response
 // status code, and error, if any. The final parameter, client, is optional, and will default
-// to the standard http.Client. It exists to make testing possible without an active remote
-// url.
+// to RemoteClient, or a standard http.Client using RemoteTimeout. It exists to make testing
+// possible without an active remote url.
 func (t *Tools) PushJSONToRemote(uri string, data interface{}, client ...*http.Client) (*http.Response, int, error) {
 	// create json we'll send
 	jsonData, err := json.Marshal(data)
@@ -195,7 +641,7 @@ func (t *Tools) PushJSONToRemote(uri string, data interface{}, client ...*http.C
 		return nil, 0, err
 	}
 
-	httpClient := &http.Client{}
+	httpClient := t.remoteClient()
 	if len(client) > 0 {
 		httpClient = client[0]
 	}
@@ -208,7 +654,7 @@ func (t *Tools) PushJSONToRemote(uri string, data interface{}, client ...*http.C
 	request.Header.Set("Content-Type", "application/json")
 
 	// Call the url.
-	response, err := httpClient.Do(request)
+	response, err := t.doRemote("PushJSONToRemote", httpClient, request)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -218,19 +664,32 @@ func (t *Tools) PushJSONToRemote(uri string, data interface{}, client ...*http.C
 }
 
 // DownloadStaticFile downloads a file to the remote user, and tries to force the browser to avoid displaying it in
-// the browser window by setting content-disposition. It also allows specification of the display name.
+// the browser window by setting content-disposition. It also allows specification of the display name. If
+// DownloadDigestAlgorithm is set, a Digest header holding the checksum of the file is also sent. HEAD,
+// Range and conditional requests are handled by http.ServeFile. If ServePrecompressed is set, and
+// the client accepts it, an up to date .br or .gz variant of the file is sent instead.
 func (t *Tools) DownloadStaticFile(w http.ResponseWriter, r *http.Request, p, file, displayName string) {
 	fp := path.Join(p, file)
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", displayName))
 
-	http.ServeFile(w, r, fp)
+	w, done := t.trackDownload(w, r, fp)
+	defer done()
+
+	w.Header().Set("Content-Disposition", contentDisposition("attachment", displayName))
+
+	t.serveStatic(w, r, fp, true)
 }
 
 // UploadedFile is the type used for the uploaded file.
 type UploadedFile struct {
-	NewFileName      string
-	OriginalFileName string
+	NewFileName      string // the name the file was saved with, which may have been truncated to MaxFileNameLength
+	OriginalFileName string // the name sent by the client
 	FileSize         int64
+	ContentType      string
+	SavedPath        string // where the file is now, including the directory
+	FieldName        string // the form field the file was sent in
+	Quarantined      bool   // true if the file is waiting for PromoteUpload or RejectUpload
+	Width            int    // the width of a png, jpeg or gif image, in pixels, or zero for anything else
+	Height           int    // the height of a png, jpeg or gif image, in pixels, or zero for anything else
 }
 
 // UploadOneFile is just a convenience method that calls UploadFiles, but expects only one file to
@@ -252,7 +711,8 @@ func (t *Tools) UploadOneFile(r *http.Request, uploadDir string, rename ...bool)
 // UploadFiles uploads one or more file to a specified directory, and gives the files a random name.
 // It returns a slice containing the newly named files, the original file names, the size of the files,
 // and potentially an error. If the optional last parameter is set to true, then we will not rename
-// the files, but will use the original file names.
+// the files, but will use the original file names. The files are in the order they were sent in the
+// request, and each has the name of the form field it came in.
 func (t *Tools) UploadFiles(r *http.Request, uploadDir string, rename ...bool) ([]*UploadedFile, error) {
 	// check to see if we are renaming the uploadedFiles with the optional last parameter.
 	renameFile := true
@@ -260,176 +720,350 @@ func (t *Tools) UploadFiles(r *http.Request, uploadDir string, rename ...bool) (
 		renameFile = rename[0]
 	}
 
-	var uploadedFiles []*UploadedFile
+	return t.UploadFilesWithOptions(r, uploadDir, UploadOptions{KeepOriginalName: !renameFile})
+}
 
-	// Create the upload directory if it does not exist.
-	err := t.CreateDirIfNotExist(uploadDir)
-	if err != nil {
+// saveFile validates the content of src against the allowed types, extensions and maximum size in
+// opts, which must have been filled in by uploadOptions, and writes it to uploadDir, using either a
+// new name or originalName. The size limit is enforced while copying, so no more than MaxFileSize+1
+// bytes are ever read from src, and a partially written file is removed. If opts has a ScanFunc, the
+// file is only moved into uploadDir once it has passed the scan. If opts has NormalizeImages, images
+// are re-encoded once they are in place.
+func (t *Tools) saveFile(ctx context.Context, src io.Reader, originalName, uploadDir string, opts UploadOptions) (*UploadedFile, error) {
+	var uploadedFile UploadedFile
+
+	if !extensionAllowed(opts.AllowedExtensions, filepath.Ext(originalName)) {
+		return nil, ErrUploadExtensionNotAllowed
+	}
+
+	// Read the first 512 bytes, which is all http.DetectContentType considers.
+	sniffBuf := sniffBufferPool.Get().(*[]byte)
+	defer sniffBufferPool.Put(sniffBuf)
+	buff := *sniffBuf
+	n, err := io.ReadFull(src, buff)
+	if err != nil && err != io.ErrUnexpectedEOF {
 		return nil, err
 	}
+	buff = buff[:n]
 
-	// Sanity check on t.MaxFileSize.
-	if t.MaxFileSize == 0 {
-		t.MaxFileSize = defaultMaxUpload
+	uploadedFile.ContentType = http.DetectContentType(buff)
+	if !fileTypeAllowed(opts.AllowedTypes, uploadedFile.ContentType) {
+		return nil, &FileTypeError{ContentType: uploadedFile.ContentType}
 	}
 
-	// Parse the form, so we have access to the file. Payload is limited to MaxFileSize.
-	err = r.ParseMultipartForm(int64(t.MaxFileSize))
+	switch {
+	case opts.KeepOriginalName:
+		uploadedFile.NewFileName = originalName
+	case opts.RenameFunc != nil:
+		uploadedFile.NewFileName = filepath.Base(opts.RenameFunc(originalName))
+	default:
+		uploadedFile.NewFileName = t.RandomFileName(originalName)
+	}
+	uploadedFile.NewFileName = fitFileName(uploadDir, uploadedFile.NewFileName, opts.MaxFileNameLength)
+	uploadedFile.OriginalFileName = originalName
+
+	dst := filepath.Join(uploadDir, uploadedFile.NewFileName)
+
+	// With a scanner, write to a temporary file first, so that nothing reaches uploadDir until it has
+	// been scanned.
+	var outfile *os.File
+	removeFile := func() { _ = os.Remove(dst) }
+	if opts.ScanFunc != nil {
+		var cleanup func()
+		outfile, cleanup, err = t.TempFile("upload-*" + filepath.Ext(originalName))
+		removeFile = cleanup
+	} else {
+		outfile, err = os.Create(dst)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error parsing form data: %v", err)
-	}
-
-	for _, fHeaders := range r.MultipartForm.File {
-		for _, hdr := range fHeaders {
-			uploadedFiles, err = func(uploadedFiles []*UploadedFile) ([]*UploadedFile, error) {
-				var uploadedFile UploadedFile
-				infile, err := hdr.Open()
-				if err != nil {
-					return nil, err
-				}
-				defer infile.Close()
-
-				if hdr.Size > int64(t.MaxFileSize) {
-					return nil, fmt.Errorf("the uploaded file is too big, and must be less than %d", t.MaxFileSize)
-				}
-
-				buff := make([]byte, 512)
-				_, err = infile.Read(buff)
-				if err != nil {
-					return nil, err
-				}
-
-				allowed := false
-				filetype := http.DetectContentType(buff)
-				if len(t.AllowedFileTypes) > 0 {
-					for _, x := range t.AllowedFileTypes {
-						if strings.EqualFold(filetype, x) {
-							allowed = true
-						}
-					}
-				} else {
-					allowed = true
-				}
-
-				if !allowed {
-					return nil, errors.New("the uploaded file type is not permitted")
-				}
-
-				_, err = infile.Seek(0, 0)
-				if err != nil {
-					fmt.Println(err)
-					return nil, err
-				}
-
-				if renameFile {
-					uploadedFile.NewFileName = fmt.Sprintf("%s%s", t.RandomString(25), filepath.Ext(hdr.Filename))
-				} else {
-					uploadedFile.NewFileName = hdr.Filename
-				}
-				uploadedFile.OriginalFileName = hdr.Filename
-
-				var outfile *os.File
-				defer outfile.Close()
-
-				if outfile, err = os.Create(filepath.Join(uploadDir, uploadedFile.NewFileName)); nil != err {
-					return nil, err
-				}
-				fileSize, err := io.Copy(outfile, infile)
-				if err != nil {
-					return nil, err
-				}
-				uploadedFile.FileSize = fileSize
-
-				uploadedFiles = append(uploadedFiles, &uploadedFile)
-
-				return uploadedFiles, nil
-			}(uploadedFiles)
-			if err != nil {
-				return uploadedFiles, err
-			}
+		return nil, err
+	}
+	defer outfile.Close()
+
+	// Put the sniffed bytes back in front of the rest of the stream, and read at most one byte
+	// more than the limit, so we know if it was exceeded.
+	maxSize := int64(opts.MaxFileSize)
+	content := io.LimitReader(io.MultiReader(bytes.NewReader(buff), src), maxSize+1)
+
+	// Hide the file's ReadFrom method, which would ignore our buffer and allocate its own.
+	copyBuf := getCopyBuffer(t.uploadCopyBufferSize())
+	defer copyBufferPool.Put(copyBuf)
+	fileSize, err := io.CopyBuffer(struct{ io.Writer }{outfile}, content, *copyBuf)
+	if err == nil && fileSize > maxSize {
+		err = &FileTooBigError{Limit: maxSize, Size: fileSize}
+	}
+	if err == nil && opts.ScanFunc != nil {
+		err = outfile.Close()
+		if err == nil {
+			err = scanFile(ctx, opts.ScanFunc, originalName, outfile.Name())
+		}
+		if err == nil {
+			err = os.Chmod(outfile.Name(), 0644)
+		}
+		if err == nil {
+			err = MoveFile(outfile.Name(), dst)
 		}
 	}
-	return uploadedFiles, nil
+	if err != nil {
+		_ = outfile.Close()
+		removeFile()
+		return nil, err
+	}
+	uploadedFile.FileSize = fileSize
+	uploadedFile.Width, uploadedFile.Height = imageDimensions(uploadedFile.ContentType, buff, dst)
+
+	if opts.NormalizeImages != nil && strings.HasPrefix(uploadedFile.ContentType, "image/") {
+		err = t.normalizeImage(dst, uploadedFile.ContentType, opts.NormalizeImages, &uploadedFile)
+		if err != nil {
+			_ = os.Remove(dst)
+			return nil, err
+		}
+	}
+	uploadedFile.SavedPath = filepath.Join(uploadDir, uploadedFile.NewFileName)
+
+	return &uploadedFile, nil
+}
+
+// fileTypeAllowed reports whether filetype matches one of the patterns in allowed, as decided by
+// mediaTypeMatches. If allowed is empty, every type is allowed.
+func fileTypeAllowed(allowed []string, filetype string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	for _, x := range allowed {
+		if mediaTypeMatches(x, filetype) {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaTypeMatches reports whether filetype, such as "text/plain; charset=utf-8", matches pattern,
+// ignoring case. A pattern may be an exact type, which must match filetype in full, or a wildcard,
+// "image/*" or "*/*", which matches any filetype of that type (or any filetype at all), whatever its
+// parameters.
+func mediaTypeMatches(pattern, filetype string) bool {
+	if strings.EqualFold(pattern, filetype) {
+		return true
+	}
+
+	patternType, patternSubtype, ok := strings.Cut(strings.TrimSpace(pattern), "/")
+	if !ok || patternSubtype != "*" {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(filetype)
+	if err != nil {
+		return false
+	}
+	fileType, _, ok := strings.Cut(mediaType, "/")
+	if !ok {
+		return false
+	}
+
+	return patternType == "*" || strings.EqualFold(patternType, fileType)
 }
 
 // CreateDirIfNotExist creates a directory, and all necessary parent directories, if it does not exist.
 func (t *Tools) CreateDirIfNotExist(path string) error {
 	const mode = 0755
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		err := os.MkdirAll(path, mode)
-		if err != nil {
-			return err
-		}
+	_, err := t.CreateDirReport(path, mode)
+	return err
+}
+
+// CreateDirReport is CreateDirIfNotExist, with the given mode, but also reports whether the directory
+// at path was created by this call, so that the caller can set up a new directory (for example, by
+// changing its owner) exactly once. The directory itself is created with a single os.Mkdir, so if
+// several calls race to create it, only one of them reports true. It is an error for path to exist,
+// but not be a directory.
+func (t *Tools) CreateDirReport(path string, mode os.FileMode) (bool, error) {
+	path = filepath.Clean(path)
+
+	err := os.MkdirAll(filepath.Dir(path), mode)
+	if err != nil {
+		return false, err
 	}
-	return nil
+
+	err = os.Mkdir(path, mode)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, fs.ErrExist) {
+		return false, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if !info.IsDir() {
+		return false, fmt.Errorf("cannot create directory %s: %w, and is not a directory", path, fs.ErrExist)
+	}
+
+	return false, nil
 }
 
-// Slugify is a (very) simple means of creating a slug from a provided string.
+// slugSeparators matches the runs of characters Slugify replaces with a single hyphen.
+var slugSeparators = regexp.MustCompile(`[^a-z\d]+`)
+
+// Slugify is a (very) simple means of creating a slug from a provided string. Accented letters are
+// replaced with plain ones (é becomes e) using SlugTransliterations, and then a built in table. For
+// slugs that are used as HTML ids, or other identifiers that can't start with a digit, set
+// SlugNoLeadingDigit, or SlugRequireLetter to reject slugs that are only digits.
 func (t *Tools) Slugify(s string) (string, error) {
 	if s == "" {
 		return "", errors.New("empty string not permitted")
 	}
-	var re = regexp.MustCompile(`[^a-z\d]+`)
-	slug := strings.Trim(re.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	slug := strings.Trim(slugSeparators.ReplaceAllString(transliterateForSlug(s, t.SlugTransliterations), "-"), "-")
 	if len(slug) == 0 {
 		return "", errors.New("after removing characters, slug is zero length")
 	}
 
+	if t.SlugRequireLetter && strings.Trim(slug, "0123456789-") == "" {
+		return "", errors.New("slug must contain at least one letter")
+	}
+
+	if t.SlugNoLeadingDigit && slug[0] >= '0' && slug[0] <= '9' {
+		prefix := t.SlugDigitPrefix
+		if prefix == "" {
+			prefix = defaultSlugDigitPrefix
+		}
+		slug = prefix + slug
+	}
+
 	return slug, nil
 }
 
 // WriteXML takes a response status code and arbitrary data and writes an XML response to the client.
-// The Content-Type header is set to application/xml.
+// The Content-Type header is set to application/xml, unless one is supplied in the optional headers
+// parameter.
 func (t *Tools) WriteXML(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
-	out, err := xml.Marshal(data)
+	return t.writeXML(w, "xml", status, data, headers...)
+}
+
+// writeXML is WriteXML, reporting the response to ResponseMetrics as kind.
+func (t *Tools) writeXML(w http.ResponseWriter, kind string, status int, data interface{}, headers ...http.Header) error {
+	start := time.Now()
+
+	out, err := t.marshalXML(data)
 	if err != nil {
 		return err
 	}
 
-	// If we have a value as the last parameter in the function call, then we are setting a custom header.
-	if len(headers) > 0 {
-		for key, value := range headers[0] {
-			w.Header()[key] = value
-		}
-	}
-
-	// Set the content type and send response. According to RFC 7303, text/xml and application/xml are to be
-	// treated as the same, so we'll just pick one.
-	w.Header().Set("Content-Type", "application/xml")
+	// Set the headers, and the content type, unless the caller gave one, and send response. According to
+	// RFC 7303, text/xml and application/xml are to be treated as the same, so we'll just pick one.
+	setHeaders(w, "application/xml", headers)
 	w.WriteHeader(status)
-
-	// Add the XML header.
-	xmlOut := []byte(xml.Header + string(out))
-	_, _ = w.Write(xmlOut)
+	n, _ := w.Write(out)
+	t.reportResponse(kind, status, n, start)
 
 	return nil
 }
 
-// ReadXML tries to read the body of an XML request into a variable. The third parameter, data,
-// is expected to be a pointer, so that we can read data into it.
+// ReadXML tries to read the body of an XML request into a variable. The third parameter, data, is
+// expected to be a pointer, so that we can read data into it; if it isn't, an
+// *InvalidDestinationError is returned. A UTF-8 byte order mark and whitespace before the XML itself
+// are ignored.
 func (t *Tools) ReadXML(w http.ResponseWriter, r *http.Request, data interface{}) error {
-	maxBytes := defaultMaxUpload
+	done := t.trackDecode(r, "xml")
 
-	// If MaxXMLSize is set, use that value instead of default.
-	if t.MaxXMLSize != 0 {
-		maxBytes = t.MaxXMLSize
+	if err := checkDestination("ReadXML", data); err != nil {
+		return done(err)
+	}
+
+	body, maxBytes, cancel, err := t.xmlBody(w, r)
+	defer cancel()
+	if err != nil {
+		return done(err)
 	}
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
 
-	dec := xml.NewDecoder(r.Body)
+	dec := xml.NewDecoder(body)
 
 	// Attempt to decode the data.
-	err := dec.Decode(data)
+	err = dec.Decode(data)
 	if err != nil {
-		return err
+		return done(xmlReadError(err, maxBytes))
 	}
 
 	err = dec.Decode(&struct{}{})
 	if err != io.EOF {
-		return errors.New("body must only contain a single XML value")
+		return done(xmlTrailingError(err, maxBytes))
 	}
 
-	return nil
+	return done(nil)
+}
+
+// xmlBody limits the body of r to the size set on the request context, MaxXMLSize, or a sensible
+// default, and to BodyReadTimeout, and returns it, positioned at the first '<', along with the limit,
+// and a function to call once the body has been read.
+func (t *Tools) xmlBody(w http.ResponseWriter, r *http.Request) (io.Reader, int, context.CancelFunc, error) {
+	maxBytes := t.maxXMLSize(r.Context())
+	if declaredTooLarge(w, r, maxBytes) {
+		return nil, maxBytes, func() {}, &BodyTooLargeError{Limit: maxBytes}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	cancel := func() {}
+	if t.BodyReadTimeout > 0 {
+		ctx, cancelRead := withReadTimeout(r.Context(), t.BodyReadTimeout)
+		var stopReading func()
+		r.Body, stopReading = newContextReader(ctx, w, r.Body)
+		cancel = func() {
+			stopReading()
+			cancelRead()
+		}
+	}
+
+	body, err := skipXMLPrelude(r.Body)
+	return body, maxBytes, cancel, err
+}
+
+// xmlReadError returns the error to give the caller for err, from decoding an XML body limited to
+// maxBytes: a *BodyTooLargeError if the limit was reached, or err itself.
+func xmlReadError(err error, maxBytes int) error {
+	if isMaxBytesError(err) {
+		return &BodyTooLargeError{Limit: maxBytes}
+	}
+	return err
+}
+
+// xmlTrailingError returns the error for a body with something other than io.EOF, err, after its
+// first XML value.
+func xmlTrailingError(err error, maxBytes int) error {
+	if isMaxBytesError(err) {
+		return &BodyTooLargeError{Limit: maxBytes}
+	}
+	return errors.New("body must only contain a single XML value")
+}
+
+// skipXMLPrelude skips a UTF-8 byte order mark and any whitespace at the start of body, which some
+// clients send before the XML declaration, and makes sure that what's left at least starts like XML.
+func skipXMLPrelude(body io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(body)
+
+	bom, err := br.Peek(3)
+	if err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		_, _ = br.Discard(3)
+	}
+
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			// Let the decoder report empty bodies and read errors as it always has.
+			return br, nil
+		}
+
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '<':
+			_ = br.UnreadByte()
+			return br, nil
+		default:
+			return nil, errors.New("body does not appear to be XML")
+		}
+	}
 }
 
 // ErrorXML takes an error, and optionally a response status code, and generates and sends
@@ -442,9 +1076,85 @@ func (t *Tools) ErrorXML(w http.ResponseWriter, err error, status ...int) error
 		statusCode = status[0]
 	}
 
-	var payload XMLResponse
-	payload.Error = true
-	payload.Message = err.Error()
+	payload := t.xmlEnvelope(true, t.errorMessage(err, statusCode, ""), nil)
+
+	return t.writeXML(w, "xml_error", statusCode, payload)
+}
+
+// ErrorXMLf is ErrorJSONf, for XML: the error made from format and args is sent with ErrorXML.
+func (t *Tools) ErrorXMLf(w http.ResponseWriter, status int, format string, args ...any) error {
+	if format == "" {
+		return errors.New("ErrorXMLf: format must not be empty")
+	}
+	return t.ErrorXML(w, fmt.Errorf(format, args...), status)
+}
+
+// errorMessage returns the message to send to the client for err. Normally, that's err.Error(), but
+// if SuppressInternalErrors is set, and Debug isn't, errors with a status of 500 or more are logged
+// (with requestID, if there is one), and replaced with InternalErrorMessage, since they may contain
+// details, like SQL or file paths, that clients shouldn't see.
+func (t *Tools) errorMessage(err error, statusCode int, requestID string) string {
+	if !t.SuppressInternalErrors || t.Debug || statusCode < http.StatusInternalServerError {
+		return err.Error()
+	}
+
+	switch {
+	case t.Logger != nil:
+		args := []any{"status", statusCode, "error", err.Error()}
+		if requestID != "" {
+			args = append(args, t.requestIDField(), requestID)
+		}
+		t.Logger.Error("internal error", args...)
+	case t.ErrorLog != nil:
+		if requestID != "" {
+			t.ErrorLog.Printf("internal error (status %d, %s %s): %v", statusCode, t.requestIDField(), requestID, err)
+		} else {
+			t.ErrorLog.Printf("internal error (status %d): %v", statusCode, err)
+		}
+	}
+
+	if t.InternalErrorMessage != "" {
+		return t.InternalErrorMessage
+	}
+	return "internal server error"
+}
+
+// isJSONMediaType reports whether the Content-Type header value contentType is one ReadJSON accepts:
+// application/json, any type with a +json suffix (e.g. application/vnd.api+json), or one of
+// AcceptedJSONTypes.
+func (t *Tools) isJSONMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	_, subtype, _ := strings.Cut(mediaType, "/")
+	if len(subtype) > len("+json") && strings.HasSuffix(subtype, "+json") {
+		return true
+	}
+
+	return hasMediaType(contentType, append([]string{"application/json"}, t.AcceptedJSONTypes...)...)
+}
+
+// jsonMediaTypeError returns the *UnsupportedMediaTypeError for a JSON body sent with a Content-Type
+// of contentType, which isJSONMediaType doesn't accept, saying what would have been.
+func (t *Tools) jsonMediaTypeError(contentType string) error {
+	accepted := append([]string{"application/json", "application/*+json"}, t.AcceptedJSONTypes...)
+	return &UnsupportedMediaTypeError{ContentType: contentType, Accepted: accepted}
+}
+
+// hasMediaType reports whether the Content-Type header value contentType is one of types, ignoring
+// case and any parameters, such as charset.
+func hasMediaType(contentType string, types ...string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
 
-	return t.WriteXML(w, statusCode, payload)
+	for _, t := range types {
+		if strings.EqualFold(mediaType, t) {
+			return true
+		}
+	}
+	return false
 }
@@ -6,14 +6,16 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
-	"image"
-	"image/png"
 	"io"
-	"mime/multipart"
+	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 )
 
@@ -95,21 +97,32 @@ var jsonTests = []struct {
 	maxSize       int
 	allowUnknown  bool
 	contentType   string
+	acceptedTypes []string
+	expectedErr   error
 }{
 	{name: "good json", json: `{"foo": "bar"}`, errorExpected: false, maxSize: 1024, allowUnknown: false},
-	{name: "badly formatted json", json: `{"foo":"}`, errorExpected: true, maxSize: 1024, allowUnknown: false},
-	{name: "incorrect type", json: `{"foo": 1}`, errorExpected: true, maxSize: 1024, allowUnknown: false},
-	{name: "incorrect type", json: `{1: 1}`, errorExpected: true, maxSize: 1024, allowUnknown: false},
-	{name: "two json files", json: `{"foo": "bar"}{"alpha": "beta"}`, errorExpected: true, maxSize: 1024, allowUnknown: false},
-	{name: "empty body", json: ``, errorExpected: true, maxSize: 1024, allowUnknown: false},
-	{name: "syntax error in json", json: `{"foo": 1"}`, errorExpected: true, maxSize: 1024, allowUnknown: false},
-	{name: "unknown field in json", json: `{"fooo": "bar"}`, errorExpected: true, maxSize: 1024, allowUnknown: false},
-	{name: "incorrect type for field", json: `{"foo": 10.2}`, errorExpected: true, maxSize: 1024, allowUnknown: false},
+	{name: "badly formatted json", json: `{"foo":"}`, errorExpected: true, maxSize: 1024, allowUnknown: false, expectedErr: ErrBadlyFormedJSON},
+	{name: "incorrect type", json: `{"foo": 1}`, errorExpected: true, maxSize: 1024, allowUnknown: false, expectedErr: ErrWrongType},
+	{name: "incorrect type", json: `{1: 1}`, errorExpected: true, maxSize: 1024, allowUnknown: false, expectedErr: ErrBadlyFormedJSON},
+	{name: "two json files", json: `{"foo": "bar"}{"alpha": "beta"}`, errorExpected: true, maxSize: 1024, allowUnknown: false, expectedErr: ErrMultipleJSONValues},
+	{name: "empty body", json: ``, errorExpected: true, maxSize: 1024, allowUnknown: false, expectedErr: ErrEmptyBody},
+	{name: "syntax error in json", json: `{"foo": 1"}`, errorExpected: true, maxSize: 1024, allowUnknown: false, expectedErr: ErrBadlyFormedJSON},
+	{name: "unknown field in json", json: `{"fooo": "bar"}`, errorExpected: true, maxSize: 1024, allowUnknown: false, expectedErr: ErrUnknownField},
+	{name: "incorrect type for field", json: `{"foo": 10.2}`, errorExpected: true, maxSize: 1024, allowUnknown: false, expectedErr: ErrWrongType},
 	{name: "allow unknown field in json", json: `{"fooo": "bar"}`, errorExpected: false, maxSize: 1024, allowUnknown: true},
-	{name: "missing field name", json: `{jack: "bar"}`, errorExpected: true, maxSize: 1024, allowUnknown: false},
-	{name: "file too large", json: `{"foo": "bar"}`, errorExpected: true, maxSize: 5, allowUnknown: false},
-	{name: "not json", json: `Hello, world`, errorExpected: true, maxSize: 1024, allowUnknown: false},
-	{name: "wrong header", json: `{"foo": "bar"}`, errorExpected: true, maxSize: 1024, allowUnknown: false, contentType: "application/xml"},
+	{name: "missing field name", json: `{jack: "bar"}`, errorExpected: true, maxSize: 1024, allowUnknown: false, expectedErr: ErrBadlyFormedJSON},
+	{name: "file too large", json: `{"foo": "bar"}`, errorExpected: true, maxSize: 5, allowUnknown: false, expectedErr: ErrBodyTooLarge},
+	{name: "not json", json: `Hello, world`, errorExpected: true, maxSize: 1024, allowUnknown: false, expectedErr: ErrBadlyFormedJSON},
+	{name: "wrong header", json: `{"foo": "bar"}`, errorExpected: true, maxSize: 1024, allowUnknown: false, contentType: "application/xml", expectedErr: ErrUnsupportedMediaType},
+	{name: "charset parameter", json: `{"foo": "bar"}`, errorExpected: false, maxSize: 1024, allowUnknown: false, contentType: "application/json; charset=utf-8"},
+	{name: "merge patch", json: `{"foo": "bar"}`, errorExpected: false, maxSize: 1024, allowUnknown: false, contentType: "application/merge-patch+json"},
+	{name: "vendor type", json: `{"foo": "bar"}`, errorExpected: false, maxSize: 1024, allowUnknown: false, contentType: "application/vnd.mycompany.v2+json"},
+	{name: "json api", json: `{"foo": "bar"}`, errorExpected: false, maxSize: 1024, allowUnknown: false, contentType: "application/vnd.api+json; charset=utf-8"},
+	{name: "problem details", json: `{"foo": "bar"}`, errorExpected: false, maxSize: 1024, allowUnknown: false, contentType: "Application/Problem+JSON"},
+	{name: "bare suffix", json: `{"foo": "bar"}`, errorExpected: true, maxSize: 1024, allowUnknown: false, contentType: "application/+json", expectedErr: ErrUnsupportedMediaType},
+	{name: "plain text", json: `{"foo": "bar"}`, errorExpected: true, maxSize: 1024, allowUnknown: false, contentType: "text/plain", expectedErr: ErrUnsupportedMediaType},
+	{name: "text/json not accepted", json: `{"foo": "bar"}`, errorExpected: true, maxSize: 1024, allowUnknown: false, contentType: "text/json", expectedErr: ErrUnsupportedMediaType},
+	{name: "text/json accepted", json: `{"foo": "bar"}`, errorExpected: false, maxSize: 1024, allowUnknown: false, contentType: "text/json", acceptedTypes: []string{"text/json"}},
 }
 
 func TestTools_ReadJSON(t *testing.T) {
@@ -121,6 +134,9 @@ func TestTools_ReadJSON(t *testing.T) {
 		// allow/disallow unknown fields.
 		testTools.AllowUnknownFields = e.allowUnknown
 
+		// accept other JSON types.
+		testTools.AcceptedJSONTypes = e.acceptedTypes
+
 		// declare a variable to read the decoded json into.
 		var decodedJSON struct {
 			Foo string `json:"foo"`
@@ -153,10 +169,196 @@ func TestTools_ReadJSON(t *testing.T) {
 		if !e.errorExpected && err != nil {
 			t.Errorf("%s: error not expected, but one received: %s \n%s", e.name, err.Error(), e.json)
 		}
+
+		// the error should say what kind it is.
+		if e.expectedErr != nil && !errors.Is(err, e.expectedErr) {
+			t.Errorf("%s: expected an error matching %q, but got %v", e.name, e.expectedErr, err)
+		}
 		req.Body.Close()
 	}
 }
 
+func TestTools_ReadJSONReadError(t *testing.T) {
+	var testTools Tools
+
+	var readErrorTests = []struct {
+		name   string
+		json   string
+		kind   error
+		field  string
+		offset int64
+	}{
+		{name: "wrong type", json: `{"foo": 1}`, kind: ErrWrongType, field: "foo", offset: 9},
+		{name: "unknown field", json: `{"bar": "baz"}`, kind: ErrUnknownField, field: "bar"},
+		{name: "syntax", json: `{"foo": 1"}`, kind: ErrBadlyFormedJSON, offset: 10},
+	}
+
+	for _, e := range readErrorTests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.json))
+
+		var decoded struct {
+			Foo string `json:"foo"`
+		}
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+		var readErr *ReadError
+		if !errors.As(err, &readErr) {
+			t.Errorf("%s: expected a *ReadError, but got %v", e.name, err)
+			continue
+		}
+		if readErr.Kind != e.kind || readErr.Field != e.field || readErr.Offset != e.offset {
+			t.Errorf("%s: wrong error: %+v", e.name, readErr)
+		}
+	}
+}
+
+var readJSONRawTests = []struct {
+	name          string
+	body          string
+	maxBytes      int
+	errorExpected bool
+}{
+	{name: "good", body: `{"foo": "bar"}`},
+	{name: "fails early in a long body", body: `{"foo": x` + strings.Repeat(" ", 100000) + `}`, errorExpected: true},
+	{name: "wrong type", body: `{"foo": 1}`, errorExpected: true},
+	{name: "unknown field", body: `{"foo": "bar", "baz": true}`, errorExpected: true},
+	{name: "too large", body: `{"foo": "` + strings.Repeat("a", 100) + `"}`, maxBytes: 50, errorExpected: true},
+}
+
+func TestTools_ReadJSONRaw(t *testing.T) {
+	for _, e := range readJSONRawTests {
+		testTools := Tools{MaxJSONSize: e.maxBytes}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.body))
+		req.Header.Set("Content-Type", "application/json")
+		// Leave the length unknown, so that bodies that are too large are actually read.
+		req.ContentLength = -1
+
+		var payload struct {
+			Foo string `json:"foo"`
+		}
+		raw, err := testTools.ReadJSONRaw(httptest.NewRecorder(), req, &payload)
+
+		if e.errorExpected != (err != nil) {
+			t.Errorf("%s: expected an error to be %v, but got %v", e.name, e.errorExpected, err)
+		}
+
+		expected := e.body
+		if e.maxBytes > 0 {
+			expected = e.body[:e.maxBytes]
+		}
+		if string(raw) != expected {
+			t.Errorf("%s: expected the %d bytes sent, but got %d: %.40q", e.name, len(expected), len(raw), raw)
+		}
+	}
+
+	var testTools Tools
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"foo": "bar"}`))
+	req.Header.Set("Content-Type", "text/plain")
+	var payload map[string]string
+	if raw, err := testTools.ReadJSONRaw(httptest.NewRecorder(), req, &payload); err == nil || raw != nil {
+		t.Errorf("expected an error, and no body, for the wrong Content-Type, but got %q, %v", raw, err)
+	}
+}
+
+func TestReadJSONInto(t *testing.T) {
+	var testTools Tools
+
+	newRequest := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	type note struct {
+		To string `json:"to"`
+	}
+	n, err := ReadJSONInto[note](&testTools, httptest.NewRecorder(), newRequest(`{"to":"John Smith"}`))
+	if err != nil || n.To != "John Smith" {
+		t.Errorf("struct: expected John Smith, but got %+v, %v", n, err)
+	}
+
+	m, err := ReadJSONInto[map[string]int](&testTools, httptest.NewRecorder(), newRequest(`{"a":1,"b":2}`))
+	if err != nil || len(m) != 2 || m["b"] != 2 {
+		t.Errorf("map: expected two keys, but got %v, %v", m, err)
+	}
+
+	s, err := ReadJSONInto[[]string](&testTools, httptest.NewRecorder(), newRequest(`["x","y","z"]`))
+	if err != nil || len(s) != 3 || s[2] != "z" {
+		t.Errorf("slice: expected three values, but got %v, %v", s, err)
+	}
+
+	// Errors are ReadJSON's, and come with the zero value.
+	n, err = ReadJSONInto[note](&testTools, httptest.NewRecorder(), newRequest(`{"to":"John Smith","from":"Jane"}`))
+	if !errors.Is(err, ErrUnknownField) || n != (note{}) {
+		t.Errorf("expected an unknown field error and the zero value, but got %+v, %v", n, err)
+	}
+}
+
+var allowEmptyBodyTests = []struct {
+	name        string
+	body        string
+	contentType string
+	allow       bool
+	err         error
+}{
+	{name: "empty, by default", body: "", err: ErrEmptyBody},
+	{name: "whitespace, by default", body: " \n\t", contentType: "application/json", err: ErrEmptyBody},
+	{name: "empty", body: "", allow: true},
+	{name: "empty, as json", body: "", contentType: "application/json", allow: true},
+	{name: "whitespace", body: " \r\n\t ", contentType: "application/json", allow: true},
+	{name: "empty, wrong content type", body: "", contentType: "text/plain", allow: true, err: ErrUnsupportedMediaType},
+	{name: "not empty", body: `{"title":"new"}`, allow: true},
+	{name: "still malformed", body: ` {"title": `, allow: true, err: ErrBadlyFormedJSON},
+}
+
+func TestTools_ReadJSONAllowEmptyBody(t *testing.T) {
+	for _, e := range allowEmptyBodyTests {
+		testTools := Tools{AllowEmptyBody: e.allow}
+		req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(e.body))
+		if e.contentType != "" {
+			req.Header.Set("Content-Type", e.contentType)
+		}
+
+		patch := struct {
+			Title string `json:"title"`
+		}{Title: "old"}
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &patch)
+
+		if e.err == nil && err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+		}
+		if e.err != nil && !errors.Is(err, e.err) {
+			t.Errorf("%s: expected %v, but got %v", e.name, e.err, err)
+		}
+
+		expected := "old"
+		if strings.TrimSpace(e.body) != "" && err == nil {
+			expected = "new"
+		}
+		if patch.Title != expected {
+			t.Errorf("%s: expected the title to be %q, but got %q", e.name, expected, patch.Title)
+		}
+	}
+}
+
+func TestTools_ReadJSONWrongContentType(t *testing.T) {
+	testTools := Tools{AcceptedJSONTypes: []string{"text/json"}}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"foo": "bar"}`))
+	req.Header.Set("Content-Type", "text/plain")
+
+	var decoded struct {
+		Foo string `json:"foo"`
+	}
+	err := testTools.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+	expected := `the Content-Type header is "text/plain", but only application/json, application/*+json, text/json are accepted`
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong error; expected %q, but got %v", expected, err)
+	}
+}
+
 func TestTools_ReadJSONAndMarshal(t *testing.T) {
 	// set max file size
 	var testTools Tools
@@ -176,13 +378,51 @@ func TestTools_ReadJSONAndMarshal(t *testing.T) {
 	err = testTools.ReadJSON(rr, req, nil)
 
 	// we expect an error, but did not get one, so something went wrong
-	if err == nil {
-		t.Error("error expected, but none received")
+	var destErr *InvalidDestinationError
+	if !errors.As(err, &destErr) {
+		t.Errorf("expected an InvalidDestinationError, but got %v", err)
 	}
 
 	req.Body.Close()
 }
 
+var invalidDestinationTests = []struct {
+	name     string
+	data     any
+	expected string
+}{
+	{name: "struct by value", data: struct{ Foo string }{}, expected: "destination must be a non-nil pointer, got struct { Foo string }"},
+	{name: "string", data: "foo", expected: "destination must be a non-nil pointer, got string"},
+	{name: "nil pointer", data: (*struct{ Foo string })(nil), expected: "destination must be a non-nil pointer, got nil *struct { Foo string }"},
+	{name: "nil interface", data: nil, expected: "destination must be a non-nil pointer, got nil"},
+}
+
+func TestTools_ReadInvalidDestination(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range invalidDestinationTests {
+		for _, fn := range []string{"ReadJSON", "ReadXML"} {
+			var err error
+			if fn == "ReadJSON" {
+				req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"foo": "bar"}`))
+				err = testTools.ReadJSON(httptest.NewRecorder(), req, e.data)
+			} else {
+				req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`<foo>bar</foo>`))
+				err = testTools.ReadXML(httptest.NewRecorder(), req, e.data)
+			}
+
+			var destErr *InvalidDestinationError
+			if !errors.As(err, &destErr) || destErr.Func != fn {
+				t.Errorf("%s, %s: expected an InvalidDestinationError, but got %v", e.name, fn, err)
+				continue
+			}
+			if err.Error() != fn+": "+e.expected {
+				t.Errorf("%s, %s: expected %q, but got %q", e.name, fn, fn+": "+e.expected, err)
+			}
+		}
+	}
+}
+
 var writeJSONTests = []struct {
 	name          string
 	payload       any
@@ -222,6 +462,63 @@ func TestTools_WriteJSON(t *testing.T) {
 	}
 }
 
+var contentTypeTests = []struct {
+	name        string
+	configured  string
+	header      string
+	write       func(t *Tools, w http.ResponseWriter, headers ...http.Header) error
+	contentType string
+}{
+	{name: "json default", write: writeJSONPayload, contentType: "application/json"},
+	{name: "json configured", configured: "application/json; charset=utf-8", write: writeJSONPayload, contentType: "application/json; charset=utf-8"},
+	{name: "json override", configured: "application/json; charset=utf-8", header: "application/vnd.api+json", write: writeJSONPayload,
+		contentType: "application/vnd.api+json"},
+	{name: "error json configured", configured: "application/json; charset=utf-8", write: writeErrorJSONPayload,
+		contentType: "application/json; charset=utf-8"},
+	{name: "xml default", write: writeXMLPayload, contentType: "application/xml"},
+	{name: "xml override", header: "text/xml; charset=utf-8", write: writeXMLPayload, contentType: "text/xml; charset=utf-8"},
+}
+
+func writeJSONPayload(t *Tools, w http.ResponseWriter, headers ...http.Header) error {
+	return t.WriteJSON(w, http.StatusOK, JSONResponse{Message: "<ok>"}, headers...)
+}
+
+func writeErrorJSONPayload(t *Tools, w http.ResponseWriter, _ ...http.Header) error {
+	return t.ErrorJSON(w, errors.New("oops"))
+}
+
+func writeXMLPayload(t *Tools, w http.ResponseWriter, headers ...http.Header) error {
+	return t.WriteXML(w, http.StatusOK, XMLResponse{Message: "ok"}, headers...)
+}
+
+func TestTools_WriteContentType(t *testing.T) {
+	for _, e := range contentTypeTests {
+		testTools := Tools{JSONContentType: e.configured}
+
+		headers := http.Header{"X-Foo": {"bar"}}
+		if e.header != "" {
+			headers.Set("Content-Type", e.header)
+		}
+
+		rr := httptest.NewRecorder()
+		if err := e.write(&testTools, rr, headers); err != nil {
+			t.Errorf("%s: %v", e.name, err)
+			continue
+		}
+		if got := rr.Header().Values("Content-Type"); len(got) != 1 || got[0] != e.contentType {
+			t.Errorf("%s: expected Content-Type %s, but got %v", e.name, e.contentType, got)
+		}
+	}
+
+	// By default, the response is exactly what it has always been.
+	var testTools Tools
+	rr := httptest.NewRecorder()
+	_ = writeJSONPayload(&testTools, rr)
+	if len(rr.Header()) != 1 || rr.Body.String() != `{"error":false,"message":"\u003cok\u003e"}` {
+		t.Errorf("expected the response to be unchanged, but got %v %s", rr.Header(), rr.Body)
+	}
+}
+
 func TestTools_ErrorJSON(t *testing.T) {
 	var testTools Tools
 
@@ -247,6 +544,211 @@ func TestTools_ErrorJSON(t *testing.T) {
 	}
 }
 
+var suppressInternalErrorsTests = []struct {
+	name    string
+	tools   Tools
+	status  int
+	message string
+	logged  bool
+	withID  bool
+	xml     bool
+}{
+	{name: "suppressed", tools: Tools{SuppressInternalErrors: true}, status: http.StatusInternalServerError, message: "internal server error", logged: true},
+	{name: "custom message", tools: Tools{SuppressInternalErrors: true, InternalErrorMessage: "oops"}, status: http.StatusBadGateway, message: "oops", logged: true},
+	{name: "with request id", tools: Tools{SuppressInternalErrors: true}, status: http.StatusInternalServerError, message: "internal server error", logged: true, withID: true},
+	{name: "xml", tools: Tools{SuppressInternalErrors: true}, status: http.StatusInternalServerError, message: "internal server error", logged: true, xml: true},
+	{name: "client error", tools: Tools{SuppressInternalErrors: true}, status: http.StatusBadRequest, message: "password=hunter2 in /srv/app/db.go"},
+	{name: "debug", tools: Tools{SuppressInternalErrors: true, Debug: true}, status: http.StatusInternalServerError, message: "password=hunter2 in /srv/app/db.go"},
+	{name: "not suppressed", status: http.StatusInternalServerError, message: "password=hunter2 in /srv/app/db.go"},
+}
+
+func TestTools_ErrorJSONSuppressInternalErrors(t *testing.T) {
+	secret := errors.New("password=hunter2 in /srv/app/db.go")
+
+	for _, e := range suppressInternalErrorsTests {
+		var logBuf bytes.Buffer
+		e.tools.ErrorLog = log.New(&logBuf, "", 0)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if e.withID {
+			req = req.WithContext(WithRequestID(req.Context(), "abc123"))
+		}
+
+		rr := httptest.NewRecorder()
+		var message string
+		if e.xml {
+			_ = e.tools.ErrorXMLCtx(rr, req, secret, e.status)
+			var payload XMLResponse
+			_ = xml.Unmarshal(rr.Body.Bytes(), &payload)
+			message = payload.Message
+		} else {
+			_ = e.tools.ErrorJSONCtx(rr, req, secret, e.status)
+			var payload JSONResponse
+			_ = json.Unmarshal(rr.Body.Bytes(), &payload)
+			message = payload.Message
+		}
+
+		if rr.Code != e.status || message != e.message {
+			t.Errorf("%s: expected %d %q, but got %d %q", e.name, e.status, e.message, rr.Code, message)
+		}
+
+		logged := strings.Contains(logBuf.String(), secret.Error())
+		if logged != e.logged {
+			t.Errorf("%s: expected logged to be %v, but the log has %q", e.name, e.logged, logBuf.String())
+		}
+		if e.withID && !strings.Contains(logBuf.String(), "request_id abc123") {
+			t.Errorf("%s: expected the request ID in the log, but got %q", e.name, logBuf.String())
+		}
+	}
+}
+
+func TestTools_ErrorJSONSuppressInternalErrorsSlog(t *testing.T) {
+	var logBuf bytes.Buffer
+	testTools := Tools{SuppressInternalErrors: true, Logger: slog.New(slog.NewTextHandler(&logBuf, nil))}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(WithRequestID(req.Context(), "abc123"))
+
+	rr := httptest.NewRecorder()
+	_ = testTools.ErrorJSONCtx(rr, req, errors.New("pq: relation users does not exist"), http.StatusInternalServerError)
+
+	if strings.Contains(rr.Body.String(), "pq:") {
+		t.Errorf("error detail leaked to the client: %s", rr.Body.String())
+	}
+	if !strings.Contains(logBuf.String(), `error="pq: relation users does not exist"`) || !strings.Contains(logBuf.String(), "request_id=abc123") {
+		t.Errorf("expected the error and request ID in the log, but got %q", logBuf.String())
+	}
+}
+
+func TestTools_ErrorJSONf(t *testing.T) {
+	var logBuf bytes.Buffer
+	testTools := Tools{ErrorLog: log.New(&logBuf, "", 0)}
+
+	rr := httptest.NewRecorder()
+	if err := testTools.ErrorJSONf(rr, http.StatusNotFound, "no order with id %d", 42); err != nil {
+		t.Fatal(err)
+	}
+	var payload JSONResponse
+	_ = json.Unmarshal(rr.Body.Bytes(), &payload)
+	if rr.Code != http.StatusNotFound || !payload.Error || payload.Message != "no order with id 42" {
+		t.Errorf("expected a 404 with the formatted message, but got %d %s", rr.Code, rr.Body)
+	}
+
+	rr = httptest.NewRecorder()
+	_ = testTools.ErrorXMLf(rr, http.StatusConflict, "order %s is %s", "A1", "closed")
+	var xmlPayload XMLResponse
+	_ = xml.Unmarshal(rr.Body.Bytes(), &xmlPayload)
+	if rr.Code != http.StatusConflict || xmlPayload.Message != "order A1 is closed" {
+		t.Errorf("expected a 409 with the formatted message, but got %d %s", rr.Code, rr.Body)
+	}
+
+	// Internal errors are suppressed, and logged, just as they are by ErrorJSON.
+	testTools.SuppressInternalErrors = true
+	rr = httptest.NewRecorder()
+	_ = testTools.ErrorJSONf(rr, http.StatusInternalServerError, "query failed: %s", "password=hunter2")
+	payload = JSONResponse{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &payload)
+	if rr.Code != http.StatusInternalServerError || payload.Message != "internal server error" {
+		t.Errorf("expected the message to be suppressed, but got %d %s", rr.Code, rr.Body)
+	}
+	if !strings.Contains(logBuf.String(), "query failed: password=hunter2") {
+		t.Errorf("expected the formatted message to be logged, but the log has %q", logBuf.String())
+	}
+
+	for name, fn := range map[string]func(w http.ResponseWriter) error{
+		"json": func(w http.ResponseWriter) error { return testTools.ErrorJSONf(w, http.StatusBadRequest, "") },
+		"xml":  func(w http.ResponseWriter) error { return testTools.ErrorXMLf(w, http.StatusBadRequest, "") },
+	} {
+		rr = httptest.NewRecorder()
+		if err := fn(rr); err == nil || rr.Body.Len() != 0 {
+			t.Errorf("%s: expected an error, and nothing sent, for an empty format, but got %v", name, err)
+		}
+	}
+}
+
+// failingWriter is an http.ResponseWriter whose Write method always fails.
+type failingWriter struct {
+	header http.Header
+	status int
+}
+
+func (f *failingWriter) Header() http.Header {
+	if f.header == nil {
+		f.header = make(http.Header)
+	}
+	return f.header
+}
+
+func (f *failingWriter) WriteHeader(status int) { f.status = status }
+
+func (f *failingWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+var writeStringTests = []struct {
+	name        string
+	html        bool
+	headers     http.Header
+	contentType string
+}{
+	{name: "plain text", html: false, contentType: "text/plain; charset=utf-8"},
+	{name: "html", html: true, contentType: "text/html; charset=utf-8"},
+	{name: "override content type", html: false, headers: http.Header{"Content-Type": {"text/csv"}}, contentType: "text/csv"},
+}
+
+func TestTools_WriteString(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range writeStringTests {
+		rr := httptest.NewRecorder()
+
+		headers := http.Header{"Foo": {"bar"}}
+		for k, v := range e.headers {
+			headers[k] = v
+		}
+
+		var err error
+		if e.html {
+			err = testTools.WriteHTML(rr, http.StatusTeapot, "<p>hello</p>", headers)
+		} else {
+			err = testTools.WriteString(rr, http.StatusTeapot, "hello", headers)
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+		}
+
+		if rr.Code != http.StatusTeapot {
+			t.Errorf("%s: wrong status code; expected 418 but got %d", e.name, rr.Code)
+		}
+
+		if rr.Header().Get("Content-Type") != e.contentType {
+			t.Errorf("%s: wrong content type; expected %s but got %s", e.name, e.contentType, rr.Header().Get("Content-Type"))
+		}
+
+		if rr.Header().Get("Foo") != "bar" {
+			t.Errorf("%s: custom header not merged", e.name)
+		}
+	}
+
+	// A Content-Type in headers wins over one already set on the response, as it does for WriteJSON.
+	rr := httptest.NewRecorder()
+	rr.Header().Set("Content-Type", "application/xml")
+	_ = testTools.WriteString(rr, http.StatusOK, "a,b", http.Header{"Content-Type": {"text/csv"}})
+	if rr.Header().Get("Content-Type") != "text/csv" {
+		t.Errorf("expected the Content-Type from headers, but got %s", rr.Header().Get("Content-Type"))
+	}
+
+	rr = httptest.NewRecorder()
+	rr.Header().Set("Content-Type", "application/xml")
+	_ = testTools.WriteHTML(rr, http.StatusOK, "<p>hello</p>")
+	if rr.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("expected the HTML Content-Type, but got %s", rr.Header().Get("Content-Type"))
+	}
+
+	// a failing writer should have its error returned
+	if err := testTools.WriteString(&failingWriter{}, http.StatusOK, "hello"); err == nil {
+		t.Error("expected error from failing writer, but none received")
+	}
+}
+
 func TestTools_RandomString(t *testing.T) {
 	var testTools Tools
 
@@ -293,46 +795,19 @@ var uploadTests = []struct {
 	{name: "allowed rename", allowedTypes: []string{"image/jpeg", "image/png"}, renameFile: true, errorExpected: false, maxSize: 0, uploadDir: ""},
 	{name: "allowed no filetype specified", allowedTypes: []string{}, renameFile: true, errorExpected: false, maxSize: 0, uploadDir: ""},
 	{name: "not allowed", allowedTypes: []string{"image/jpeg"}, errorExpected: true, maxSize: 0, uploadDir: ""},
+	{name: "allowed wildcard", allowedTypes: []string{"image/*"}, renameFile: true},
+	{name: "allowed any type", allowedTypes: []string{"*/*"}, renameFile: true},
+	{name: "not allowed wildcard", allowedTypes: []string{"video/*"}, errorExpected: true},
 	{name: "too big", allowedTypes: []string{"image/jpeg,", "image/png"}, errorExpected: true, maxSize: 10, uploadDir: ""},
 	{name: "invalid directory", allowedTypes: []string{"image/jpeg,", "image/png"}, errorExpected: true, maxSize: 0, uploadDir: "//"},
 }
 
 func TestTools_UploadFiles(t *testing.T) {
 	for _, e := range uploadTests {
-		// set up a pipe to avoid buffering
-		pr, pw := io.Pipe()
-		writer := multipart.NewWriter(pw)
-		wg := sync.WaitGroup{}
-		wg.Add(1)
-		go func() {
-			defer writer.Close()
-			defer wg.Done()
-
-			// create the form data field 'file'
-			part, err := writer.CreateFormFile("file", "./testdata/img.png")
-			if err != nil {
-				t.Error(err)
-			}
-
-			f, err := os.Open("./testdata/img.png")
-			if err != nil {
-				t.Error(err)
-			}
-			defer f.Close()
-			img, _, err := image.Decode(f)
-			if err != nil {
-				t.Error("error decoding image", err)
-			}
-
-			err = png.Encode(part, img)
-			if err != nil {
-				t.Error(err)
-			}
-		}()
-
-		// read from the pipe which receives data
-		request := httptest.NewRequest("POST", "/", pr)
-		request.Header.Add("Content-Type", writer.FormDataContentType())
+		request, err := NewMultipartRequest("/", map[string]string{"file": "./testdata/img.png"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		var testTools Tools
 		testTools.AllowedFileTypes = e.allowedTypes
@@ -362,9 +837,6 @@ func TestTools_UploadFiles(t *testing.T) {
 		if e.errorExpected && err == nil {
 			t.Errorf("%s: error expected, but none received", e.name)
 		}
-
-		// we're running table tests, so have to use a waitgroup
-		wg.Wait()
 	}
 }
 
@@ -377,40 +849,40 @@ var uploadOneTests = []struct {
 	{name: "invalid", uploadDir: "//", errorExpected: true},
 }
 
-func TestTools_UploadOneFile(t *testing.T) {
-	for _, e := range uploadOneTests {
-		// set up a pipe to avoid buffering
-		pr, pw := io.Pipe()
-		writer := multipart.NewWriter(pw)
-
-		go func() {
-			defer writer.Close()
-
-			// create the form data field 'file'
-			part, err := writer.CreateFormFile("file", "./testdata/img.png")
-			if err != nil {
-				t.Error(err)
-			}
-
-			f, err := os.Open("./testdata/img.png")
-			if err != nil {
-				t.Error(err)
-			}
-			defer f.Close()
-			img, _, err := image.Decode(f)
-			if err != nil {
-				t.Error("error decoding image", err)
-			}
+var mediaTypeMatchesTests = []struct {
+	pattern  string
+	filetype string
+	matches  bool
+}{
+	{pattern: "image/png", filetype: "image/png", matches: true},
+	{pattern: "IMAGE/PNG", filetype: "image/png", matches: true},
+	{pattern: "image/png", filetype: "image/jpeg", matches: false},
+	{pattern: "text/plain; charset=utf-8", filetype: "text/plain; charset=utf-8", matches: true},
+	{pattern: "text/plain", filetype: "text/plain; charset=utf-8", matches: false},
+	{pattern: "image/*", filetype: "image/png", matches: true},
+	{pattern: "image/*", filetype: "image/jpeg", matches: true},
+	{pattern: "image/*", filetype: "application/pdf", matches: false},
+	{pattern: "Text/*", filetype: "text/plain; charset=utf-8", matches: true},
+	{pattern: "*/*", filetype: "application/pdf", matches: true},
+	{pattern: "image*", filetype: "image/png", matches: false},
+	{pattern: "*/png", filetype: "image/png", matches: false},
+	{pattern: "image/*", filetype: "image", matches: false},
+}
 
-			err = png.Encode(part, img)
-			if err != nil {
-				t.Error(err)
-			}
-		}()
+func TestMediaTypeMatches(t *testing.T) {
+	for _, e := range mediaTypeMatchesTests {
+		if got := mediaTypeMatches(e.pattern, e.filetype); got != e.matches {
+			t.Errorf("%s against %s: expected %t, but got %t", e.pattern, e.filetype, e.matches, got)
+		}
+	}
+}
 
-		// read from the pipe which receives data
-		request := httptest.NewRequest("POST", "/", pr)
-		request.Header.Add("Content-Type", writer.FormDataContentType())
+func TestTools_UploadOneFile(t *testing.T) {
+	for _, e := range uploadOneTests {
+		request, err := NewMultipartRequest("/", map[string]string{"file": "./testdata/img.png"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		var testTools Tools
 		testTools.AllowedFileTypes = []string{"image/png"}
@@ -447,6 +919,60 @@ func TestTools_CreateDirIfNotExist(t *testing.T) {
 	_ = os.Remove("./testdata/myDir")
 }
 
+func TestTools_CreateDirReport(t *testing.T) {
+	var testTool Tools
+	dir := t.TempDir()
+
+	nested := filepath.Join(dir, "tenant", "uploads", "2024")
+	created, err := testTool.CreateDirReport(nested, 0755)
+	if err != nil || !created {
+		t.Errorf("expected a fresh nested directory to be created, but got %v, %v", created, err)
+	}
+
+	created, err = testTool.CreateDirReport(nested, 0755)
+	if err != nil || created {
+		t.Errorf("expected an existing directory to be reported as not created, but got %v, %v", created, err)
+	}
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	created, err = testTool.CreateDirReport(file, 0755)
+	if err == nil || created {
+		t.Errorf("expected an error for a file at the path, but got %v, %v", created, err)
+	}
+	if err := testTool.CreateDirIfNotExist(file); err == nil {
+		t.Error("expected CreateDirIfNotExist to fail for a file at the path, but it didn't")
+	}
+}
+
+func TestTools_CreateDirReportConcurrent(t *testing.T) {
+	var testTool Tools
+	path := filepath.Join(t.TempDir(), "a", "b")
+
+	var wg sync.WaitGroup
+	var createdCount atomic.Int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			created, err := testTool.CreateDirReport(path, 0755)
+			if err != nil {
+				t.Error(err)
+			}
+			if created {
+				createdCount.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if createdCount.Load() != 1 {
+		t.Errorf("expected exactly one call to report creating the directory, but %d did", createdCount.Load())
+	}
+}
+
 func TestTools_CreateDirIfNotExistInvalidDirectory(t *testing.T) {
 	var testTool Tools
 
@@ -553,6 +1079,21 @@ var xmlTests = []struct {
 						<?xml version="1.0" encoding="UTF-8"?><note><to>Luke Skywalker</to><from>R2D2</from></note>`,
 		errorExpected: true,
 	},
+	{
+		name:          "leading whitespace",
+		xml:           "\n\n  \t" + `<?xml version="1.0" encoding="UTF-8"?><note><to>John Smith</to><from>Jane Jones</from></note>`,
+		errorExpected: false,
+	},
+	{
+		name:          "byte order mark",
+		xml:           "\xef\xbb\xbf\n" + `<?xml version="1.0" encoding="UTF-8"?><note><to>John Smith</to><from>Jane Jones</from></note>`,
+		errorExpected: false,
+	},
+	{
+		name:          "not xml",
+		xml:           `{"to": "John Smith"}`,
+		errorExpected: true,
+	},
 }
 
 func TestTools_ReadXML(t *testing.T) {
@@ -588,6 +1129,58 @@ func TestTools_ReadXML(t *testing.T) {
 	}
 }
 
+func TestTools_ReadBodyTooLarge(t *testing.T) {
+	tools := Tools{MaxJSONSize: 10, MaxXMLSize: 10}
+
+	read := map[string]func(w http.ResponseWriter, r *http.Request) error{
+		"xml": func(w http.ResponseWriter, r *http.Request) error {
+			var note struct {
+				To string `xml:"to"`
+			}
+			return tools.ReadXML(w, r, &note)
+		},
+		"json": func(w http.ResponseWriter, r *http.Request) error {
+			var payload struct {
+				To string `json:"to"`
+			}
+			return tools.ReadJSON(w, r, &payload)
+		},
+	}
+	bodies := map[string]string{
+		"xml":  `<note><to>John Smith</to></note>`,
+		"json": `{"to": "John Smith"}`,
+	}
+
+	for kind, fn := range read {
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(bodies[kind]))
+
+		err := fn(httptest.NewRecorder(), req)
+		if err == nil || err.Error() != "body must not be larger than 10 bytes" {
+			t.Errorf("%s: expected the size limit message, but got %v", kind, err)
+		}
+		if !errors.Is(err, ErrBodyTooLarge) {
+			t.Errorf("%s: expected errors.Is(err, ErrBodyTooLarge)", kind)
+		}
+
+		var tooLarge *BodyTooLargeError
+		if !errors.As(err, &tooLarge) || tooLarge.Limit != 10 {
+			t.Errorf("%s: expected a *BodyTooLargeError with the limit, but got %#v", kind, err)
+		}
+	}
+}
+
+func TestTools_ReadXMLNotXML(t *testing.T) {
+	var tools Tools
+
+	req, _ := http.NewRequest("POST", "/", bytes.NewReader([]byte(`{"to": "John Smith"}`)))
+
+	var note struct{}
+	err := tools.ReadXML(httptest.NewRecorder(), req, &note)
+	if err == nil || err.Error() != "body does not appear to be XML" {
+		t.Errorf("expected clear error for a non-XML body, but got %v", err)
+	}
+}
+
 func TestTools_ErrorXML(t *testing.T) {
 	var testTools Tools
 
@@ -0,0 +1,191 @@
+package toolbox
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// trashSidecarSuffix is added to the name of a trashed file to get the name of the file holding its
+// metadata.
+const trashSidecarSuffix = ".trash.json"
+
+// trashRecord is the metadata kept next to a trashed file, so that RestoreFile can put it back where
+// it was, and EmptyTrash knows when it was deleted.
+type trashRecord struct {
+	OriginalPath     string    `json:"original_path"`
+	OriginalFileName string    `json:"original_file_name"`
+	FieldName        string    `json:"field_name,omitempty"`
+	FileSize         int64     `json:"file_size"`
+	ContentType      string    `json:"content_type"`
+	Width            int       `json:"width,omitempty"`
+	Height           int       `json:"height,omitempty"`
+	DeletedAt        time.Time `json:"deleted_at"`
+}
+
+// TrashFile deletes an uploaded file recoverably, by moving it into trashDir with MoveFile (so trashDir
+// may be on another file system), next to a small JSON sidecar holding where it came from, its
+// details, and when it was deleted. If trashDir already has a file with the same name, -1, -2, and so
+// on is added to it. The file's SavedPath is updated to its place in the trash. Trashed files are put
+// back with RestoreFile, and removed for good with EmptyTrash.
+func (t *Tools) TrashFile(file *UploadedFile, trashDir string) error {
+	trashDir, err := t.uploadPath(trashDir)
+	if err != nil {
+		return err
+	}
+
+	err = t.CreateDirIfNotExist(trashDir)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(file.SavedPath); err != nil {
+		return err
+	}
+
+	dst := filepath.Join(trashDir, availableFileName(trashDir, filepath.Base(file.SavedPath)))
+
+	// Write the sidecar first, so that a trashed file can never be found without one.
+	err = t.WriteJSONFile(dst+trashSidecarSuffix, trashRecord{
+		OriginalPath:     file.SavedPath,
+		OriginalFileName: file.OriginalFileName,
+		FieldName:        file.FieldName,
+		FileSize:         file.FileSize,
+		ContentType:      file.ContentType,
+		Width:            file.Width,
+		Height:           file.Height,
+		DeletedAt:        time.Now().UTC(),
+	}, 0644, false)
+	if err != nil {
+		return err
+	}
+
+	err = MoveFile(file.SavedPath, dst)
+	if err != nil {
+		_ = os.Remove(dst + trashSidecarSuffix)
+		return err
+	}
+
+	file.SavedPath = dst
+
+	return nil
+}
+
+// RestoreFile moves the file called name (the base name of its SavedPath after TrashFile) out of
+// trashDir, back to the directory it was trashed from, and removes its sidecar. If that directory
+// has since been given another file with the same name, -1, -2, and so on is added to the restored
+// file's name, as for a truncated upload. The directory is created again if it has gone.
+func (t *Tools) RestoreFile(trashDir, name string) (*UploadedFile, error) {
+	trashDir, err := t.uploadPath(trashDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" || name != filepath.Base(name) || strings.HasSuffix(name, trashSidecarSuffix) {
+		return nil, fmt.Errorf("invalid name for a trashed file: %q", name)
+	}
+
+	src := filepath.Join(trashDir, name)
+
+	var record trashRecord
+	err = t.ReadJSONFile(src+trashSidecarSuffix, &record)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(record.OriginalPath)
+	err = t.CreateDirIfNotExist(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	dst := filepath.Join(dir, availableFileName(dir, filepath.Base(record.OriginalPath)))
+	err = MoveFile(src, dst)
+	if err != nil {
+		return nil, err
+	}
+	_ = os.Remove(src + trashSidecarSuffix)
+
+	return &UploadedFile{
+		NewFileName:      filepath.Base(dst),
+		OriginalFileName: record.OriginalFileName,
+		FieldName:        record.FieldName,
+		FileSize:         record.FileSize,
+		ContentType:      record.ContentType,
+		SavedPath:        dst,
+		Width:            record.Width,
+		Height:           record.Height,
+	}, nil
+}
+
+// EmptyTrash permanently removes the files in trashDir that were trashed more than olderThan ago, as
+// recorded in their sidecars, along with the sidecars, and returns how many files were removed. For
+// a sidecar that can't be read, the time it was last modified is used instead. Like
+// CleanupTempFiles, it leaves anything it didn't put there alone.
+func (t *Tools) EmptyTrash(trashDir string, olderThan time.Duration) (int, error) {
+	trashDir, err := t.uploadPath(trashDir)
+	if err != nil {
+		return 0, err
+	}
+
+	entries, err := os.ReadDir(trashDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), trashSidecarSuffix) {
+			continue
+		}
+
+		sidecar := filepath.Join(trashDir, entry.Name())
+
+		var deletedAt time.Time
+		var record trashRecord
+		if err := t.ReadJSONFile(sidecar, &record); err == nil {
+			deletedAt = record.DeletedAt
+		} else if info, err := entry.Info(); err == nil {
+			deletedAt = info.ModTime()
+		} else {
+			continue
+		}
+
+		if !deletedAt.Before(cutoff) {
+			continue
+		}
+
+		err := os.Remove(strings.TrimSuffix(sidecar, trashSidecarSuffix))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err == nil {
+			removed++
+		}
+		_ = os.Remove(sidecar)
+	}
+
+	return removed, nil
+}
+
+// availableFileName returns name, or, if dir already has a file called name (or a trash sidecar for
+// one), name with -1, -2, and so on added before the extension, whichever is the first not taken.
+func availableFileName(dir, name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	candidate := name
+	for i := 1; fileExists(filepath.Join(dir, candidate)) || fileExists(filepath.Join(dir, candidate+trashSidecarSuffix)); i++ {
+		candidate = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+
+	return candidate
+}
@@ -0,0 +1,150 @@
+package toolbox
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTools_TrashAndRestoreFile(t *testing.T) {
+	var testTools Tools
+
+	uploadDir := t.TempDir()
+	trashDir := filepath.Join(t.TempDir(), "trash")
+
+	request, err := NewMultipartRequest("/", map[string]string{"file": "./testdata/img.png"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := testTools.UploadFiles(request, uploadDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := files[0]
+	originalPath, name := file.SavedPath, file.NewFileName
+
+	before := time.Now().UTC()
+	err = testTools.TrashFile(file, trashDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := dirNames(t, uploadDir); len(names) != 0 {
+		t.Errorf("expected an empty upload directory, but found %v", names)
+	}
+	if names := dirNames(t, trashDir); !slices.Equal(names, []string{name, name + trashSidecarSuffix}) {
+		t.Errorf("wrong trash contents: %v", names)
+	}
+	if file.SavedPath != filepath.Join(trashDir, name) {
+		t.Errorf("wrong SavedPath after trashing: %s", file.SavedPath)
+	}
+
+	var record trashRecord
+	err = testTools.ReadJSONFile(file.SavedPath+trashSidecarSuffix, &record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.OriginalPath != originalPath || record.OriginalFileName != "img.png" || record.ContentType != "image/png" ||
+		record.FileSize != file.FileSize || record.Width == 0 || record.DeletedAt.Before(before.Add(-time.Second)) {
+		t.Errorf("wrong sidecar: %+v", record)
+	}
+
+	// Something else has taken the name in the meantime, so the restored file gets another.
+	err = os.WriteFile(originalPath, []byte("new"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := testTools.RestoreFile(trashDir, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ext := filepath.Ext(name)
+	expectedName := name[:len(name)-len(ext)] + "-1" + ext
+	if restored.NewFileName != expectedName || restored.SavedPath != filepath.Join(uploadDir, expectedName) ||
+		restored.OriginalFileName != "img.png" || restored.FileSize != file.FileSize {
+		t.Errorf("wrong restored file: %+v", restored)
+	}
+	if names := dirNames(t, uploadDir); !slices.Equal(names, []string{expectedName, name}) {
+		t.Errorf("wrong upload directory contents: %v", names)
+	}
+	if names := dirNames(t, trashDir); len(names) != 0 {
+		t.Errorf("expected an empty trash, but found %v", names)
+	}
+
+	for _, bad := range []string{"", "../" + name, name + trashSidecarSuffix, "missing.png"} {
+		if _, err := testTools.RestoreFile(trashDir, bad); err == nil {
+			t.Errorf("expected an error restoring %q", bad)
+		}
+	}
+}
+
+func TestTools_EmptyTrash(t *testing.T) {
+	var testTools Tools
+
+	uploadDir := t.TempDir()
+	trashDir := t.TempDir()
+
+	var trashed []*UploadedFile
+	for _, name := range []string{"old.txt", "new.txt", "same.txt"} {
+		path := filepath.Join(uploadDir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		file := &UploadedFile{NewFileName: name, OriginalFileName: name, SavedPath: path}
+		if err := testTools.TrashFile(file, trashDir); err != nil {
+			t.Fatal(err)
+		}
+		trashed = append(trashed, file)
+	}
+
+	// Trashing a second file with the same name keeps both.
+	path := filepath.Join(uploadDir, "same.txt")
+	if err := os.WriteFile(path, []byte("again"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := testTools.TrashFile(&UploadedFile{SavedPath: path}, trashDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Backdate old.txt, by its sidecar rather than its modification time.
+	var record trashRecord
+	sidecar := trashed[0].SavedPath + trashSidecarSuffix
+	if err := testTools.ReadJSONFile(sidecar, &record); err != nil {
+		t.Fatal(err)
+	}
+	record.DeletedAt = time.Now().Add(-31 * 24 * time.Hour)
+	if err := testTools.WriteJSONFile(sidecar, record, 0644, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Something that isn't trash is left alone.
+	if err := os.WriteFile(filepath.Join(trashDir, "readme.txt"), []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := testTools.EmptyTrash(trashDir, 30*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("expected 1 file to be removed, but %d were", removed)
+	}
+
+	expected := []string{"new.txt", "new.txt" + trashSidecarSuffix, "readme.txt", "same-1.txt", "same-1.txt" + trashSidecarSuffix, "same.txt", "same.txt" + trashSidecarSuffix}
+	if names := dirNames(t, trashDir); !slices.Equal(names, expected) {
+		t.Errorf("wrong trash contents: %v", names)
+	}
+
+	removed, err = testTools.EmptyTrash(trashDir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := dirNames(t, trashDir); removed != 3 || !slices.Equal(names, []string{"readme.txt"}) {
+		t.Errorf("expected 3 files removed, leaving readme.txt, but %d were, leaving %v", removed, names)
+	}
+}
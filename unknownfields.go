@@ -0,0 +1,174 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// UnknownFieldsError is returned by ReadJSON, when ReportAllUnknownFields is set (and
+// AllowUnknownFields isn't), for a body with keys the destination has no field for. It lists every
+// one of them, so that a client can fix its payload at once, rather than a key at a time. It matches
+// ErrUnknownField with errors.Is, as the *ReadError for a single unknown key does, and since it
+// unwraps to FieldErrors, with the code unknown, ErrorJSON sends the list to the client.
+type UnknownFieldsError struct {
+	// Fields are the unknown keys, by their paths in the JSON, such as nickname, author.nickname or
+	// items[2].colour; the keys of each object are in alphabetical order.
+	Fields []string
+}
+
+// Error satisfies the error interface.
+func (e *UnknownFieldsError) Error() string {
+	return "body contains unknown keys: " + strings.Join(e.Fields, ", ")
+}
+
+// Is makes errors.Is(err, ErrUnknownField) true.
+func (e *UnknownFieldsError) Is(target error) bool {
+	return target == ErrUnknownField
+}
+
+// Unwrap returns the unknown keys as FieldErrors.
+func (e *UnknownFieldsError) Unwrap() error {
+	fieldErrs := make(FieldErrors, len(e.Fields))
+	for i, field := range e.Fields {
+		fieldErrs[i] = FieldError{Field: field, Code: "unknown", Message: "is not a known field"}
+	}
+	return fieldErrs
+}
+
+// jsonUnmarshalerType is the type of json.Unmarshaler, for checking whether a type decodes itself.
+var jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// checkUnknownJSONFields returns an *UnknownFieldsError if body has any key, at any depth, that
+// decoding it into data would reject as unknown. It is called before body is decoded into data, so
+// that the error lists all of them, where encoding/json would stop at the first. Since it decodes the
+// whole body, body should come through limitJSONDepth, whose error is returned as it is, so that a
+// body that is too deep is turned away before it is built in memory.
+func checkUnknownJSONFields(body io.Reader, data any) error {
+	var doc any
+	if err := json.NewDecoder(body).Decode(&doc); err != nil {
+		if errors.Is(err, ErrJSONTooDeep) {
+			return err
+		}
+		// Leave it to decoding into data to describe what is wrong with the body.
+		return nil
+	}
+
+	var unknown []string
+	findUnknownJSONFields(reflect.TypeOf(data), doc, "", &unknown)
+	if len(unknown) > 0 {
+		return &UnknownFieldsError{Fields: unknown}
+	}
+	return nil
+}
+
+// findUnknownJSONFields adds to unknown the path of each key in value, the decoded JSON for a value
+// of type rt at path, that has no field to go into, looking inside structs, slices, arrays and maps.
+// Types that decode themselves, with UnmarshalJSON, are left alone, since they decide for themselves
+// what is allowed.
+func findUnknownJSONFields(rt reflect.Type, value any, path string, unknown *[]string) {
+	for rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+	if reflect.PointerTo(rt).Implements(jsonUnmarshalerType) {
+		return
+	}
+
+	switch rt.Kind() {
+	case reflect.Struct:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return
+		}
+		fields := knownJSONFields(rt)
+		for _, key := range sortedKeys(obj) {
+			keyPath := joinJSONPath(path, key)
+			if ft, ok := lookupJSONField(fields, key); ok {
+				findUnknownJSONFields(ft, obj[key], keyPath, unknown)
+			} else {
+				*unknown = append(*unknown, keyPath)
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		items, _ := value.([]any)
+		for i, item := range items {
+			findUnknownJSONFields(rt.Elem(), item, fmt.Sprintf("%s[%d]", path, i), unknown)
+		}
+
+	case reflect.Map:
+		obj, _ := value.(map[string]any)
+		for _, key := range sortedKeys(obj) {
+			findUnknownJSONFields(rt.Elem(), obj[key], joinJSONPath(path, key), unknown)
+		}
+	}
+}
+
+// knownJSONFields returns the types of the fields of the struct type rt, by the names encoding/json
+// decodes them from, including those promoted from embedded structs. Where names clash, the field
+// that is least deeply embedded wins, as it does for encoding/json.
+func knownJSONFields(rt reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	depths := map[string]int{}
+
+	var collect func(rt reflect.Type, depth int, seen map[reflect.Type]bool)
+	collect = func(rt reflect.Type, depth int, seen map[reflect.Type]bool) {
+		if seen[rt] {
+			return
+		}
+		seen[rt] = true
+
+		for i := 0; i < rt.NumField(); i++ {
+			field := rt.Field(i)
+			tag := field.Tag.Get("json")
+			if (!field.IsExported() && !field.Anonymous) || tag == "-" {
+				continue
+			}
+
+			name, _, _ := strings.Cut(tag, ",")
+
+			// The fields of an embedded struct without a name of its own are promoted into this one.
+			if field.Anonymous && name == "" {
+				ft := field.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if ft.Kind() == reflect.Struct {
+					collect(ft, depth+1, seen)
+					continue
+				}
+			}
+			if !field.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+
+			if d, ok := depths[name]; !ok || depth < d {
+				fields[name] = field.Type
+				depths[name] = depth
+			}
+		}
+	}
+	collect(rt, 0, map[reflect.Type]bool{})
+
+	return fields
+}
+
+// lookupJSONField returns the type of the field in fields that encoding/json would decode key into:
+// the one with exactly that name, or else one whose name matches it ignoring case.
+func lookupJSONField(fields map[string]reflect.Type, key string) (reflect.Type, bool) {
+	if ft, ok := fields[key]; ok {
+		return ft, true
+	}
+	for name, ft := range fields {
+		if strings.EqualFold(name, key) {
+			return ft, true
+		}
+	}
+	return nil, false
+}
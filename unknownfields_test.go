@@ -0,0 +1,145 @@
+package toolbox
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type unknownAudit struct {
+	CreatedBy string `json:"created_by"`
+}
+
+type unknownAuthor struct {
+	Name string `json:"name"`
+}
+
+type unknownItem struct {
+	SKU string `json:"sku"`
+}
+
+type unknownPost struct {
+	unknownAudit
+	Title   string                 `json:"title"`
+	Author  *unknownAuthor         `json:"author"`
+	Items   []unknownItem          `json:"items"`
+	ByLang  map[string]unknownItem `json:"by_lang"`
+	Extra   map[string]any         `json:"extra"`
+	Raw     json.RawMessage        `json:"raw"`
+	Created time.Time              `json:"created"`
+	Ignored string                 `json:"-"`
+}
+
+var unknownFieldsTests = []struct {
+	name    string
+	body    string
+	unknown []string
+}{
+	{name: "all known", body: `{"created_by":"x","title":"t","author":{"name":"a"},"items":[{"sku":"s"}],"extra":{"any":1},"raw":{"any":2}}`},
+	{name: "case insensitive keys", body: `{"Created_By":"x","TITLE":"t","author":{"Name":"a"}}`},
+	{name: "top level", body: `{"title":"t","subtitle":"s","colour":"red"}`, unknown: []string{"colour", "subtitle"}},
+	{name: "ignored field", body: `{"Ignored":"x"}`, unknown: []string{"Ignored"}},
+	{name: "nested", body: `{"author":{"name":"a","nickname":"n"},"tags":[]}`, unknown: []string{"author.nickname", "tags"}},
+	{name: "slice of structs", body: `{"items":[{"sku":"a"},{"sku":"b","qty":2},{"colour":"red"}]}`, unknown: []string{"items[1].qty", "items[2].colour"}},
+	{name: "map of structs", body: `{"by_lang":{"fr":{"sku":"a","qty":1},"en":{"size":"l"}}}`, unknown: []string{"by_lang.en.size", "by_lang.fr.qty"}},
+	{name: "embedded", body: `{"created_by":"x","unknownAudit":{}}`, unknown: []string{"unknownAudit"}},
+}
+
+func TestTools_ReadJSONReportAllUnknownFields(t *testing.T) {
+	testTools := Tools{ReportAllUnknownFields: true}
+
+	for _, e := range unknownFieldsTests {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.body))
+		var post unknownPost
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &post)
+
+		if len(e.unknown) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", e.name, err)
+			}
+			continue
+		}
+
+		var unknownErr *UnknownFieldsError
+		if !errors.Is(err, ErrUnknownField) || !errors.As(err, &unknownErr) {
+			t.Errorf("%s: expected an *UnknownFieldsError, but got %v", e.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(unknownErr.Fields, e.unknown) {
+			t.Errorf("%s: expected %v to be unknown, but got %v", e.name, e.unknown, unknownErr.Fields)
+		}
+	}
+}
+
+func TestTools_ReadJSONReportAllUnknownFieldsErrorJSON(t *testing.T) {
+	testTools := Tools{ReportAllUnknownFields: true}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"a":1,"b":2,"author":{"c":3}}`))
+	var post unknownPost
+	err := testTools.ReadJSON(httptest.NewRecorder(), req, &post)
+	if err == nil || err.Error() != "body contains unknown keys: a, author.c, b" {
+		t.Fatalf("wrong error: %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	_ = testTools.ErrorJSON(rr, err, http.StatusBadRequest)
+
+	var body struct {
+		Errors []FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Errors) != 3 || body.Errors[1].Field != "author.c" || body.Errors[1].Code != "unknown" {
+		t.Errorf("expected the unknown keys in the response, but got %s", rr.Body)
+	}
+}
+
+func TestTools_ReadJSONReportAllUnknownFieldsOff(t *testing.T) {
+	// By default, only the first unknown key is reported, and AllowUnknownFields still wins.
+	body := `{"title":"t","subtitle":"s","colour":"red"}`
+
+	var testTools Tools
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	var post unknownPost
+	err := testTools.ReadJSON(httptest.NewRecorder(), req, &post)
+
+	var readErr *ReadError
+	if !errors.As(err, &readErr) || readErr.Kind != ErrUnknownField || readErr.Field != "subtitle" {
+		t.Errorf("expected a *ReadError for the first unknown key, but got %v", err)
+	}
+
+	testTools = Tools{ReportAllUnknownFields: true, AllowUnknownFields: true}
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	if err := testTools.ReadJSON(httptest.NewRecorder(), req, &post); err != nil || post.Title != "t" {
+		t.Errorf("expected unknown keys to be allowed, but got %v", err)
+	}
+}
+
+func TestTools_ReadJSONReportAllUnknownFieldsTooDeep(t *testing.T) {
+	// The depth limit applies before the body is decoded to look for unknown keys, so a deep body
+	// costs little more to turn away than it does without ReportAllUnknownFields.
+	body := `{"title":` + nestedJSON(9000) + `}`
+	read := func(testTools Tools) error {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		var post unknownPost
+		return testTools.ReadJSON(httptest.NewRecorder(), req, &post)
+	}
+
+	plain := Tools{MaxJSONDepth: 64}
+	reportAll := Tools{MaxJSONDepth: 64, ReportAllUnknownFields: true}
+	if err := read(reportAll); !errors.Is(err, ErrJSONTooDeep) {
+		t.Fatalf("expected ErrJSONTooDeep, but got %v", err)
+	}
+
+	plainAllocs := testing.AllocsPerRun(10, func() { _ = read(plain) })
+	reportAllAllocs := testing.AllocsPerRun(10, func() { _ = read(reportAll) })
+	if reportAllAllocs > 2*plainAllocs {
+		t.Errorf("expected about %v allocations, but got %v", plainAllocs, reportAllAllocs)
+	}
+}
@@ -0,0 +1,667 @@
+package toolbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileScanner checks the content of an uploaded file, read from r, and returns an error if it should
+// be rejected; name is the name the client sent. It should stop when ctx is done.
+type FileScanner func(ctx context.Context, name string, r io.Reader) error
+
+// Errors returned, possibly wrapped, when an uploaded file is rejected.
+var (
+	// ErrUploadRejectedByScan is returned, wrapping the scanner's error, when ScanFunc rejects an uploaded file.
+	ErrUploadRejectedByScan = errors.New("the uploaded file was rejected by the scanner")
+
+	// ErrUploadTooBig is returned, wrapped with the limit, when an uploaded file is larger than MaxFileSize.
+	ErrUploadTooBig = errors.New("the uploaded file is too big")
+
+	// ErrUploadTypeNotAllowed is returned when the content of an uploaded file isn't one of the allowed types.
+	ErrUploadTypeNotAllowed = errors.New("the uploaded file type is not permitted")
+
+	// ErrUploadExtensionNotAllowed is returned when the name of an uploaded file doesn't have an allowed extension.
+	ErrUploadExtensionNotAllowed = errors.New("the uploaded file extension is not permitted")
+
+	// ErrFileTooBig is another name for ErrUploadTooBig. The error returned is a *FileTooBigError.
+	ErrFileTooBig = ErrUploadTooBig
+
+	// ErrFileTypeNotPermitted is another name for ErrUploadTypeNotAllowed. The error returned is a
+	// *FileTypeError.
+	ErrFileTypeNotPermitted = ErrUploadTypeNotAllowed
+
+	// ErrInvalidMultipartForm is returned, wrapping the parser's error, when a request's multipart form
+	// can't be read.
+	ErrInvalidMultipartForm = errors.New("error parsing form data")
+
+	// ErrTooManyFiles is returned, wrapped with the limit, when a request has more than MaxFiles files.
+	ErrTooManyFiles = errors.New("too many files uploaded")
+
+	// ErrTotalSizeExceeded is returned, wrapping the parser's error, when a request's multipart form is
+	// larger than allowed, for example by an http.MaxBytesReader set up by middleware.
+	ErrTotalSizeExceeded = errors.New("the request is too large")
+)
+
+// FileTooBigError is returned when an uploaded file is larger than Limit bytes. It matches
+// ErrFileTooBig (and ErrUploadTooBig) with errors.Is.
+type FileTooBigError struct {
+	Limit int64 // the limit that was exceeded, in bytes
+	Size  int64 // the size of the file, or how much of it was read (one byte more than Limit), if not known
+}
+
+// Error satisfies the error interface.
+func (e *FileTooBigError) Error() string {
+	return fmt.Sprintf("%s, and must be less than %d", ErrFileTooBig, e.Limit)
+}
+
+// Is makes errors.Is(err, ErrFileTooBig) true.
+func (e *FileTooBigError) Is(target error) bool {
+	return target == ErrFileTooBig
+}
+
+// FileTypeError is returned when the content of an uploaded file, sniffed as ContentType, is not one
+// of the allowed types. It matches ErrFileTypeNotPermitted (and ErrUploadTypeNotAllowed) with errors.Is.
+type FileTypeError struct {
+	ContentType  string // the type detected from the file's content
+	DeclaredType string // the type the client said the file was, by SaveDataURI, if it isn't allowed or doesn't match
+}
+
+// Error satisfies the error interface.
+func (e *FileTypeError) Error() string {
+	return ErrFileTypeNotPermitted.Error()
+}
+
+// Is makes errors.Is(err, ErrFileTypeNotPermitted) true.
+func (e *FileTypeError) Is(target error) bool {
+	return target == ErrFileTypeNotPermitted
+}
+
+// formError wraps an error from reading a multipart form in ErrTotalSizeExceeded, if the form was too
+// large, or ErrInvalidMultipartForm, keeping the parser's error too.
+func formError(err error) error {
+	if isMaxBytesError(err) || errors.Is(err, multipart.ErrMessageTooLarge) {
+		return fmt.Errorf("%w: %w", ErrTotalSizeExceeded, err)
+	}
+	return fmt.Errorf("%w: %w", ErrInvalidMultipartForm, err)
+}
+
+// UploadErrorReason says why a file failed, in an UploadError.
+type UploadErrorReason string
+
+// The reasons a file can fail.
+const (
+	UploadTooBig              UploadErrorReason = "too_big"
+	UploadTypeNotAllowed      UploadErrorReason = "type_not_allowed"
+	UploadExtensionNotAllowed UploadErrorReason = "extension_not_allowed"
+	UploadRejectedByScan      UploadErrorReason = "rejected_by_scan"
+	UploadFailed              UploadErrorReason = "failed" // anything else, such as failing to write the file
+)
+
+// UploadError describes a single file that UploadFilesPartial could not save.
+type UploadError struct {
+	FieldName string            // the form field the file was sent in
+	FileName  string            // the name the client sent
+	Reason    UploadErrorReason // why the file failed
+	Err       error             // the underlying error
+}
+
+// Error satisfies the error interface.
+func (e UploadError) Error() string {
+	return fmt.Sprintf("%s: %s", e.FileName, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e UploadError) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON includes the error message, so that failures can be sent straight to the client with
+// WriteJSON.
+func (e UploadError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		FieldName string            `json:"field_name"`
+		FileName  string            `json:"file_name"`
+		Reason    UploadErrorReason `json:"reason"`
+		Message   string            `json:"message"`
+	}{e.FieldName, e.FileName, e.Reason, e.Err.Error()})
+}
+
+// uploadErrorReason classifies an error from saving an uploaded file.
+func uploadErrorReason(err error) UploadErrorReason {
+	switch {
+	case errors.Is(err, ErrUploadTooBig):
+		return UploadTooBig
+	case errors.Is(err, ErrUploadTypeNotAllowed):
+		return UploadTypeNotAllowed
+	case errors.Is(err, ErrUploadExtensionNotAllowed):
+		return UploadExtensionNotAllowed
+	case errors.Is(err, ErrUploadRejectedByScan):
+		return UploadRejectedByScan
+	default:
+		return UploadFailed
+	}
+}
+
+// UploadOptions overrides the Tools upload settings for a single call to UploadFilesWithOptions, so
+// that different endpoints can have different rules without needing separate Tools. Zero values
+// fall back to the Tools settings, or to the same defaults UploadFiles uses.
+type UploadOptions struct {
+	KeepOriginalName  bool                             // save files with the names sent by the client, instead of random names
+	RenameFunc        func(originalName string) string // if set, and KeepOriginalName is not, chooses the name each file is saved as
+	AllowedTypes      []string                         // allowed file types (e.g. image/jpeg, or image/*), instead of AllowedFileTypes
+	AllowedExtensions []string                         // allowed file extensions (e.g. .jpg); if empty, any extension is allowed
+	MaxFileSize       int                              // maximum size of each file in bytes, instead of MaxFileSize
+	MaxFiles          int                              // maximum number of files in the request; if zero, there is no limit
+	FieldNames        []string                         // if set, only files in these form fields are saved; others are ignored
+	MaxFileNameLength int                              // longest name, in bytes, a file is saved with, instead of MaxFileNameLength
+	MaxUploadDuration time.Duration                    // how long the body may take to read, instead of MaxUploadDuration
+	MinUploadRate     int64                            // the slowest the body may be sent, in bytes a second, instead of MinUploadRate
+
+	// ScanFunc, if set, is used instead of the Tools ScanFunc.
+	ScanFunc FileScanner
+
+	// NormalizeImages, if set, is used instead of the Tools NormalizeImages.
+	NormalizeImages *ImageNormalization
+
+	// ContinueOnError saves every valid file, instead of stopping at the first one that fails. The
+	// failures are returned by UploadFilesPartial, and the call only fails if no file was saved, or
+	// if RequireAllFiles is also set, and any file failed.
+	ContinueOnError bool
+	RequireAllFiles bool
+
+	// Progress, if set, is told how much of the body has been read as the upload goes on, and when it
+	// ends; TrackUploadProgress makes one that records it in a ProgressTracker. If the form has
+	// already been parsed, there is only the final call.
+	Progress ProgressFunc
+}
+
+// defaultUploadCopyBufferSize is the size of the buffer used to write uploaded files, unless
+// UploadCopyBufferSize is set.
+const defaultUploadCopyBufferSize = 128 * 1024
+
+// sniffBufferPool and copyBufferPool hold the buffers saveFile uses, so that they aren't allocated for
+// every file. They hold *[]byte, so that putting a buffer back doesn't allocate either. Buffers are
+// not cleared between uses, so their contents must never end up in anything saveFile returns.
+var (
+	sniffBufferPool = sync.Pool{New: func() any {
+		b := make([]byte, 512)
+		return &b
+	}}
+	copyBufferPool sync.Pool
+)
+
+// getCopyBuffer returns a buffer of size bytes from copyBufferPool, or a new one if the pool has
+// none that big. Return it with copyBufferPool.Put when done.
+func getCopyBuffer(size int) *[]byte {
+	if b, ok := copyBufferPool.Get().(*[]byte); ok && cap(*b) >= size {
+		*b = (*b)[:size]
+		return b
+	}
+
+	b := make([]byte, size)
+	return &b
+}
+
+// uploadCopyBufferSize returns UploadCopyBufferSize, or the default if it is not set.
+func (t *Tools) uploadCopyBufferSize() int {
+	if t.UploadCopyBufferSize > 0 {
+		return t.UploadCopyBufferSize
+	}
+	return defaultUploadCopyBufferSize
+}
+
+// uploadOptions returns opts, with anything not set filled in from the limits set on ctx, then from
+// t, or from the defaults.
+func (t *Tools) uploadOptions(ctx context.Context, opts UploadOptions) UploadOptions {
+	if len(opts.AllowedTypes) == 0 {
+		opts.AllowedTypes, _ = ctx.Value(allowedFileTypesKey{}).([]string)
+	}
+	if len(opts.AllowedTypes) == 0 {
+		opts.AllowedTypes = t.AllowedFileTypes
+	}
+
+	if opts.MaxFileSize == 0 {
+		opts.MaxFileSize = firstLimit(contextLimit(ctx, maxFileSizeKey{}), t.MaxFileSize)
+	}
+
+	if opts.MaxFileNameLength <= 0 {
+		opts.MaxFileNameLength = t.MaxFileNameLength
+	}
+	if opts.MaxFileNameLength <= 0 {
+		opts.MaxFileNameLength = defaultMaxFileNameLength
+	}
+
+	if opts.MaxUploadDuration <= 0 {
+		opts.MaxUploadDuration = t.MaxUploadDuration
+	}
+	if opts.MinUploadRate <= 0 {
+		opts.MinUploadRate = t.MinUploadRate
+	}
+
+	if opts.ScanFunc == nil {
+		opts.ScanFunc = t.ScanFunc
+	}
+
+	if opts.NormalizeImages == nil {
+		opts.NormalizeImages = t.NormalizeImages
+	}
+
+	return opts
+}
+
+// UploadFilesWithOptions is UploadFiles, with the rules for this call given by opts rather than by
+// the Tools settings, which are neither used (where opts has its own value) nor changed.
+func (t *Tools) UploadFilesWithOptions(r *http.Request, uploadDir string, opts UploadOptions) ([]*UploadedFile, error) {
+	uploadedFiles, _, err := t.UploadFilesPartial(r, uploadDir, opts)
+	return uploadedFiles, err
+}
+
+// UploadFilesPartial is UploadFilesWithOptions, but also returns an UploadError for each file that
+// failed, saying which file it was and why. This is most useful with opts.ContinueOnError, so that
+// one bad file doesn't stop the rest of a batch from being saved, and the handler can tell the client
+// which files were (and weren't) stored.
+//
+// The body is streamed, rather than buffered with ParseMultipartForm, and files are returned in the
+// order they were sent, whichever fields they were sent in; GroupUploadsByField sorts them by field.
+// The form's text values are still available, from r.FormValue or r.MultipartForm, afterwards. If
+// the form has already been parsed, its files are saved instead, in no particular order of fields.
+//
+// If MaxUploadDuration or MinUploadRate is set, in opts or on t, a body that takes too long to
+// arrive, or trickles in, is abandoned with an error wrapping ErrUploadTimeout, and the files saved
+// from it are removed.
+func (t *Tools) UploadFilesPartial(r *http.Request, uploadDir string, opts UploadOptions) (files []*UploadedFile, failures []UploadError, err error) {
+	if t.logsOperations() {
+		defer t.logUpload(r, time.Now(), &files, &err)
+	}
+
+	opts = t.uploadOptions(r.Context(), opts)
+
+	progress := newProgressReader(r, opts.Progress)
+	defer progress.finish()
+
+	uploadDir, err = t.uploadPath(uploadDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Create the upload directory if it does not exist.
+	err = t.CreateDirIfNotExist(uploadDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if r.MultipartForm != nil && r.MultipartForm.File != nil {
+		return t.uploadParsedFiles(r, uploadDir, opts)
+	}
+
+	watch := watchUpload(r.Context(), opts)
+	defer watch.stop()
+
+	files, failures, err = t.streamUploads(r, uploadDir, opts, progress, watch)
+	return files, failures, watch.err(err)
+}
+
+// logUpload logs an upload that started at start, for LogOperations, once UploadFilesPartial has
+// set the files saved and the error it returns.
+func (t *Tools) logUpload(r *http.Request, start time.Time, files *[]*UploadedFile, err *error) {
+	var size int64
+	for _, f := range *files {
+		size += f.FileSize
+	}
+
+	t.logOperation(r.Context(), slog.LevelInfo, "UploadFiles", size, time.Since(start), 0, *err, slog.Int("files", len(*files)))
+}
+
+// streamUploads does the work of UploadFilesPartial for a form that hasn't been parsed, reading the
+// body, as it arrives, for only as long as watch allows.
+func (t *Tools) streamUploads(r *http.Request, uploadDir string, opts UploadOptions, progress *progressReader, watch *uploadWatch) ([]*UploadedFile, []UploadError, error) {
+	// Reject a request that says it is too big before reading any of it.
+	if t.MaxTotalUploadSize > 0 {
+		if declaredTooLarge(nil, r, t.MaxTotalUploadSize) {
+			return nil, nil, fmt.Errorf("%w: %w", ErrTotalSizeExceeded, &BodyTooLargeError{Limit: t.MaxTotalUploadSize})
+		}
+		r.Body = http.MaxBytesReader(nil, r.Body, int64(t.MaxTotalUploadSize))
+	}
+	r.Body = progress.wrap(r.Body)
+	r.Body = watch.wrap(r.Body)
+
+	verifyDigest, err := t.verifyBodyDigest(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, nil, formError(err)
+	}
+
+	u := uploader{t: t, r: r.WithContext(watch.context(r.Context())), uploadDir: uploadDir, opts: opts}
+	values := map[string][]string{}
+	valuesSize := 0
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			u.cleanup()
+			return nil, nil, formError(err)
+		}
+
+		field := part.FormName()
+		switch {
+		case field == "":
+		case part.FileName() == "":
+			// Keep the text values for r.FormValue, within the same limit as any other form.
+			var value []byte
+			value, err = io.ReadAll(io.LimitReader(part, int64(t.maxFormSize()-valuesSize+1)))
+			valuesSize += len(value)
+			if err == nil && valuesSize > t.maxFormSize() {
+				err = multipart.ErrMessageTooLarge
+			}
+			if err == nil {
+				values[field] = append(values[field], string(value))
+			}
+		case fieldAllowed(opts.FieldNames, field):
+			err = u.save(field, part.FileName(), -1, func() (io.ReadCloser, error) { return part, nil })
+		}
+
+		_ = part.Close()
+		if err != nil {
+			u.cleanup()
+			if errors.Is(err, ErrTooManyFiles) || len(u.failures) > 0 {
+				return nil, u.failures, err
+			}
+			return nil, nil, formError(err)
+		}
+	}
+
+	// Don't keep anything from a body that was corrupted on the way.
+	err = verifyDigest()
+	if err != nil {
+		u.cleanup()
+		return nil, nil, err
+	}
+
+	// Leave the text values where r.FormValue will find them, as ParseMultipartForm would have.
+	_ = r.ParseForm()
+	for field, vs := range values {
+		r.Form[field] = append(r.Form[field], vs...)
+		r.PostForm[field] = append(r.PostForm[field], vs...)
+	}
+	r.MultipartForm = &multipart.Form{Value: values}
+
+	return u.result()
+}
+
+// uploadParsedFiles saves the files in r.MultipartForm, for when the form was parsed before
+// UploadFilesPartial was called. The fields are taken in order of their names, since the order they
+// were sent in is no longer known.
+func (t *Tools) uploadParsedFiles(r *http.Request, uploadDir string, opts UploadOptions) ([]*UploadedFile, []UploadError, error) {
+	fields := make([]string, 0, len(r.MultipartForm.File))
+	for field := range r.MultipartForm.File {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	u := uploader{t: t, r: r, uploadDir: uploadDir, opts: opts}
+	for _, field := range fields {
+		if !fieldAllowed(opts.FieldNames, field) {
+			continue
+		}
+
+		for _, hdr := range r.MultipartForm.File[field] {
+			err := u.save(field, hdr.Filename, hdr.Size, func() (io.ReadCloser, error) { return hdr.Open() })
+			if err != nil {
+				u.cleanup()
+				return nil, u.failures, err
+			}
+		}
+	}
+
+	return u.result()
+}
+
+// uploader holds the state of a single call to UploadFilesPartial.
+type uploader struct {
+	t         *Tools
+	r         *http.Request
+	uploadDir string
+	opts      UploadOptions
+	count     int
+	saved     []*UploadedFile
+	failures  []UploadError
+}
+
+// save saves the file called fileName, sent in field, read from whatever open returns. The size of
+// the file is size, or -1 if it isn't known until it has been read. The error returned is the one
+// that should end the upload, which, with ContinueOnError, is only too many files.
+func (u *uploader) save(field, fileName string, size int64, open func() (io.ReadCloser, error)) error {
+	u.count++
+	if u.opts.MaxFiles > 0 && u.count > u.opts.MaxFiles {
+		return fmt.Errorf("%w; at most %d are allowed", ErrTooManyFiles, u.opts.MaxFiles)
+	}
+
+	start := time.Now()
+	uploadedFile, err := func() (*UploadedFile, error) {
+		infile, err := open()
+		if err != nil {
+			return nil, err
+		}
+		defer infile.Close()
+
+		if size > int64(u.opts.MaxFileSize) {
+			return nil, &FileTooBigError{Limit: int64(u.opts.MaxFileSize), Size: size}
+		}
+
+		body := &countingReader{ReadCloser: infile}
+		uploadedFile, err := u.t.saveFile(u.r.Context(), body, fileName, u.uploadDir, u.opts)
+
+		// Read the rest of a streamed file that was too big, which has to be done anyway to get to
+		// the next, so that the error can say how big it was.
+		var tooBig *FileTooBigError
+		if size < 0 && errors.As(err, &tooBig) {
+			_, _ = io.Copy(io.Discard, body)
+			tooBig.Size = body.read
+		}
+
+		return uploadedFile, err
+	}()
+	if isMaxBytesError(err) {
+		// It's the request that's too big, not this file.
+		return err
+	}
+	if u.t.logsOperations() {
+		var saved int64
+		if uploadedFile != nil {
+			saved = uploadedFile.FileSize
+		}
+		u.t.logOperation(u.r.Context(), slog.LevelDebug, "UploadFiles", saved, time.Since(start), 0, err, slog.String("field", field), slog.String("file", fileName))
+	}
+	if err != nil {
+		u.failures = append(u.failures, UploadError{FieldName: field, FileName: fileName, Reason: uploadErrorReason(err), Err: err})
+		if !u.opts.ContinueOnError {
+			return err
+		}
+		return nil
+	}
+
+	uploadedFile.FieldName = field
+	u.saved = append(u.saved, uploadedFile)
+
+	return nil
+}
+
+// cleanup removes the files saved so far, when the upload as a whole has failed.
+func (u *uploader) cleanup() {
+	for _, f := range u.saved {
+		_ = os.Remove(f.SavedPath)
+	}
+	u.saved = nil
+}
+
+// result returns what UploadFilesPartial returns once every file has been tried.
+func (u *uploader) result() ([]*UploadedFile, []UploadError, error) {
+	if len(u.failures) > 0 && (len(u.saved) == 0 || u.opts.RequireAllFiles) {
+		return u.saved, u.failures, fmt.Errorf("%d of %d uploaded files could not be saved: %w",
+			len(u.failures), len(u.failures)+len(u.saved), uploadFieldErrors(u.failures))
+	}
+
+	return u.saved, u.failures, nil
+}
+
+// uploadFieldErrors returns failures as FieldErrors, with the reason for each as its code.
+func uploadFieldErrors(failures []UploadError) FieldErrors {
+	fieldErrs := make(FieldErrors, len(failures))
+	for i, f := range failures {
+		fieldErrs[i] = FieldError{Field: f.FieldName, Code: string(f.Reason), Message: f.Error(), Err: f}
+	}
+	return fieldErrs
+}
+
+// GroupUploadsByField returns files grouped by the form field they were sent in, keeping their order
+// within each field.
+func GroupUploadsByField(files []*UploadedFile) map[string][]*UploadedFile {
+	groups := make(map[string][]*UploadedFile)
+	for _, f := range files {
+		groups[f.FieldName] = append(groups[f.FieldName], f)
+	}
+	return groups
+}
+
+// extensionAllowed reports whether ext is in allowed, which may list extensions with or without the
+// leading dot. If allowed is empty, every extension is allowed.
+func extensionAllowed(allowed []string, ext string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	ext = strings.TrimPrefix(ext, ".")
+	for _, x := range allowed {
+		if strings.EqualFold(ext, strings.TrimPrefix(x, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldAllowed reports whether files in the form field named field should be saved.
+func fieldAllowed(fieldNames []string, field string) bool {
+	if len(fieldNames) == 0 {
+		return true
+	}
+
+	for _, x := range fieldNames {
+		if x == field {
+			return true
+		}
+	}
+	return false
+}
+
+// scanFile runs scan over the file at path. If ctx is done before scan returns, we don't wait for it.
+// Either way, the error returned wraps ErrUploadRejectedByScan.
+func scanFile(ctx context.Context, scan FileScanner, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- scan(ctx, name, f)
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUploadRejectedByScan, err)
+	}
+	return nil
+}
+
+// MoveFile renames src to dst, which is atomic when they are on the same file system. When they are
+// not, src is copied to a temporary file next to dst, which is synced and renamed over dst, so that
+// dst is replaced atomically even then, and src is removed. As with os.Rename, an existing dst is
+// replaced; if the move fails, it is left as it was.
+func MoveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !isCrossDeviceError(err) {
+		return err
+	}
+	return moveByCopy(src, dst)
+}
+
+// moveByCopy does MoveFile's work when src and dst are on different file systems.
+func moveByCopy(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	// Make sure the temp file does not outlive a failure.
+	ok := false
+	defer func() {
+		if !ok {
+			_ = tmp.Close()
+			_ = os.Remove(tmp.Name())
+		}
+	}()
+
+	_, err = io.Copy(tmp, in)
+	if err != nil {
+		return err
+	}
+
+	err = tmp.Sync()
+	if err != nil {
+		return err
+	}
+
+	err = tmp.Chmod(info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), dst)
+	if err != nil {
+		return err
+	}
+	ok = true
+
+	return os.Remove(src)
+}
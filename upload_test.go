@@ -0,0 +1,669 @@
+package toolbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+var uploadWithOptionsTests = []struct {
+	name          string
+	files         map[string]string
+	opts          UploadOptions
+	expectedFiles int
+	errorExpected string
+}{
+	{name: "types overridden", files: map[string]string{"file": "./testdata/img.png"}, opts: UploadOptions{AllowedTypes: []string{"image/png"}}, expectedFiles: 1},
+	{name: "type not allowed by options", files: map[string]string{"file": "./testdata/tgg.jpg"}, opts: UploadOptions{AllowedTypes: []string{"image/png"}}, errorExpected: "the uploaded file type is not permitted"},
+	{name: "extension allowed", files: map[string]string{"file": "./testdata/img.png"}, opts: UploadOptions{AllowedTypes: []string{"image/png"}, AllowedExtensions: []string{"PNG"}}, expectedFiles: 1},
+	{name: "extension not allowed", files: map[string]string{"file": "./testdata/img.png"}, opts: UploadOptions{AllowedTypes: []string{"image/png"}, AllowedExtensions: []string{".gif"}}, errorExpected: "extension is not permitted"},
+	{name: "too big", files: map[string]string{"file": "./testdata/img.png"}, opts: UploadOptions{AllowedTypes: []string{"image/png"}, MaxFileSize: 10}, errorExpected: "too big"},
+	{name: "too many files", files: map[string]string{"a": "./testdata/img.png", "b": "./testdata/img.png"}, opts: UploadOptions{AllowedTypes: []string{"image/png"}, MaxFiles: 1}, errorExpected: "too many files"},
+	{name: "field names", files: map[string]string{"avatar": "./testdata/img.png", "other": "./testdata/tgg.jpg"}, opts: UploadOptions{AllowedTypes: []string{"image/png"}, FieldNames: []string{"avatar"}, MaxFiles: 1}, expectedFiles: 1},
+}
+
+func TestTools_UploadFilesWithOptions(t *testing.T) {
+	for _, e := range uploadWithOptionsTests {
+		request, err := NewMultipartRequest("/", e.files, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The Tools setting would reject every test file, so a passing test shows it wasn't used.
+		testTools := Tools{AllowedFileTypes: []string{"application/pdf"}}
+
+		uploadedFiles, err := testTools.UploadFilesWithOptions(request, t.TempDir(), e.opts)
+
+		if !reflect.DeepEqual(testTools.AllowedFileTypes, []string{"application/pdf"}) || testTools.MaxFileSize != 0 {
+			t.Errorf("%s: Tools settings were changed: %+v", e.name, testTools)
+		}
+
+		if e.errorExpected != "" {
+			if err == nil || !strings.Contains(err.Error(), e.errorExpected) {
+				t.Errorf("%s: expected error containing %q, but got %v", e.name, e.errorExpected, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		if len(uploadedFiles) != e.expectedFiles {
+			t.Errorf("%s: expected %d files, but got %d", e.name, e.expectedFiles, len(uploadedFiles))
+		}
+	}
+}
+
+var uploadSentinelTests = []struct {
+	name     string
+	opts     UploadOptions
+	maxBytes int64 // if set, the request body is limited to this many bytes, as middleware might
+	notForm  bool
+	expected error
+}{
+	{name: "too big", opts: UploadOptions{MaxFileSize: 10}, expected: ErrFileTooBig},
+	{name: "type not permitted", opts: UploadOptions{AllowedTypes: []string{"image/jpeg"}}, expected: ErrFileTypeNotPermitted},
+	{name: "not a multipart form", notForm: true, expected: ErrInvalidMultipartForm},
+	{name: "too many files", opts: UploadOptions{MaxFiles: 1}, expected: ErrTooManyFiles},
+	{name: "request too large", maxBytes: 100, expected: ErrTotalSizeExceeded},
+}
+
+func TestTools_UploadFilesSentinelErrors(t *testing.T) {
+	var testTools Tools
+
+	pngInfo, err := os.Stat("./testdata/img.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range uploadSentinelTests {
+		for _, one := range []bool{false, true} {
+			if one && e.expected == ErrTooManyFiles {
+				// UploadOneFile has no limit on the number of files.
+				continue
+			}
+
+			files := map[string]string{"a": "./testdata/img.png"}
+			if e.expected == ErrTooManyFiles {
+				files["b"] = "./testdata/img.png"
+			}
+			request, err := NewMultipartRequest("/", files, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e.notForm {
+				request.Header.Set("Content-Type", "application/json")
+			}
+			if e.maxBytes > 0 {
+				request.Body = http.MaxBytesReader(httptest.NewRecorder(), request.Body, e.maxBytes)
+			}
+
+			if one {
+				testTools := Tools{MaxFileSize: e.opts.MaxFileSize, AllowedFileTypes: e.opts.AllowedTypes}
+				_, err = testTools.UploadOneFile(request, t.TempDir())
+			} else {
+				_, err = testTools.UploadFilesWithOptions(request, t.TempDir(), e.opts)
+			}
+
+			if !errors.Is(err, e.expected) {
+				t.Errorf("%s (UploadOneFile: %t): expected %q, but got %v", e.name, one, e.expected, err)
+				continue
+			}
+
+			var tooBig *FileTooBigError
+			if errors.As(err, &tooBig) && (tooBig.Limit != 10 || tooBig.Size != pngInfo.Size()) {
+				t.Errorf("%s: expected a limit of 10 and a size of %d, but got %+v", e.name, pngInfo.Size(), tooBig)
+			}
+			var typeErr *FileTypeError
+			if errors.As(err, &typeErr) && typeErr.ContentType != "image/png" {
+				t.Errorf("%s: expected the detected type, image/png, but got %q", e.name, typeErr.ContentType)
+			}
+			if (tooBig != nil) != (e.expected == ErrFileTooBig) || (typeErr != nil) != (e.expected == ErrFileTypeNotPermitted) {
+				t.Errorf("%s: expected an error carrying details, but got %T", e.name, err)
+			}
+		}
+	}
+}
+
+func TestTools_UploadFileTooBigWhileCopying(t *testing.T) {
+	var testTools Tools
+
+	// Without a size in the form, the limit is only noticed while the file is copied.
+	_, err := testTools.saveFile(context.Background(), strings.NewReader(strings.Repeat("a", 100)), "a.txt", t.TempDir(), UploadOptions{MaxFileSize: 10})
+
+	var tooBig *FileTooBigError
+	if !errors.As(err, &tooBig) || tooBig.Limit != 10 || tooBig.Size != 11 || !errors.Is(err, ErrUploadTooBig) {
+		t.Errorf("expected a FileTooBigError with a limit of 10, after reading 11 bytes, but got %v", err)
+	}
+	if err != nil && err.Error() != "the uploaded file is too big, and must be less than 10" {
+		t.Errorf("unexpected message: %s", err)
+	}
+}
+
+func TestTools_UploadFilesWithOptionsNames(t *testing.T) {
+	var testTools Tools
+	uploadDir := t.TempDir()
+
+	request, _ := NewMultipartRequest("/", map[string]string{"file": "./testdata/img.png"}, nil)
+	uploadedFiles, err := testTools.UploadFilesWithOptions(request, uploadDir, UploadOptions{
+		RenameFunc: func(originalName string) string { return "avatar-" + originalName },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uploadedFiles[0].NewFileName != "avatar-img.png" {
+		t.Errorf("RenameFunc not used; got %s", uploadedFiles[0].NewFileName)
+	}
+
+	if _, err := os.Stat(filepath.Join(uploadDir, "avatar-img.png")); err != nil {
+		t.Error(err)
+	}
+
+	request, _ = NewMultipartRequest("/", map[string]string{"file": "./testdata/img.png"}, nil)
+	uploadedFiles, err = testTools.UploadFilesWithOptions(request, uploadDir, UploadOptions{KeepOriginalName: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uploadedFiles[0].NewFileName != "img.png" {
+		t.Errorf("expected original name to be kept, but got %s", uploadedFiles[0].NewFileName)
+	}
+}
+
+func TestTools_UploadFilesLongNames(t *testing.T) {
+	var testTools Tools
+	uploadDir := t.TempDir()
+
+	ascii := strings.Repeat("a", 300) + ".txt"
+	multibyte := strings.Repeat("日", 100) + ".txt"
+	files := []MultipartFile{
+		{FieldName: "ascii", FileName: ascii, Content: strings.NewReader("hello")},
+		{FieldName: "multibyte", FileName: multibyte, Content: strings.NewReader("hello")},
+		// The same as ascii once truncated.
+		{FieldName: "similar", FileName: strings.Repeat("a", 300) + "b.txt", Content: strings.NewReader("hello")},
+	}
+
+	request, err := NewMultipartRequestFromReaders("/", files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploadedFiles, _, err := testTools.UploadFilesPartial(request, uploadDir, UploadOptions{KeepOriginalName: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploadedFiles) != 3 {
+		t.Fatalf("expected 3 files, but got %d", len(uploadedFiles))
+	}
+
+	names := make(map[string]bool)
+	for _, f := range uploadedFiles {
+		if len(f.NewFileName) > 255 || !strings.HasSuffix(f.NewFileName, ".txt") || !utf8.ValidString(f.NewFileName) {
+			t.Errorf("%s: bad stored name %q (%d bytes)", f.OriginalFileName, f.NewFileName, len(f.NewFileName))
+		}
+		if f.OriginalFileName != ascii && f.OriginalFileName != multibyte && !strings.HasSuffix(f.OriginalFileName, "b.txt") {
+			t.Errorf("expected the original name to be kept, but got %q", f.OriginalFileName)
+		}
+		if names[f.NewFileName] {
+			t.Errorf("%s: stored name %q was used twice", f.OriginalFileName, f.NewFileName)
+		}
+		names[f.NewFileName] = true
+
+		if _, err := os.Stat(filepath.Join(uploadDir, f.NewFileName)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if len(dirNames(t, uploadDir)) != 3 {
+		t.Errorf("expected 3 files on disk, but got %v", dirNames(t, uploadDir))
+	}
+}
+
+func TestTools_UploadFilesDimensions(t *testing.T) {
+	var testTools Tools
+
+	png, err := os.ReadFile("./testdata/img.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jpg, err := os.ReadFile("./testdata/tgg.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := []MultipartFile{
+		{FieldName: "png", FileName: "img.png", Content: bytes.NewReader(png)},
+		{FieldName: "jpeg", FileName: "tgg.jpg", Content: bytes.NewReader(jpg)},
+		{FieldName: "text", FileName: "notes.txt", Content: strings.NewReader("hello")},
+		// Sniffed as a PNG, but not one.
+		{FieldName: "broken", FileName: "broken.png", Content: bytes.NewReader(append(png[:16:16], "garbage"...))},
+	}
+
+	request, err := NewMultipartRequestFromReaders("/", files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploadedFiles, err := testTools.UploadFilesWithOptions(request, t.TempDir(), UploadOptions{KeepOriginalName: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][2]int{
+		"img.png":    {640, 426},
+		"tgg.jpg":    {1920, 1080},
+		"notes.txt":  {0, 0},
+		"broken.png": {0, 0},
+	}
+	if len(uploadedFiles) != len(expected) {
+		t.Fatalf("expected %d files, but got %d", len(expected), len(uploadedFiles))
+	}
+	for _, f := range uploadedFiles {
+		if got := [2]int{f.Width, f.Height}; got != expected[f.NewFileName] {
+			t.Errorf("%s: expected dimensions %v, but got %v", f.NewFileName, expected[f.NewFileName], got)
+		}
+	}
+}
+
+func TestTools_UploadFilesWildcardTypes(t *testing.T) {
+	png, err := os.ReadFile("./testdata/img.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jpg, err := os.ReadFile("./testdata/tgg.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf := []byte("%PDF-1.4\n%fake")
+
+	for _, allowed := range [][]string{{"image/*"}, {"application/json", "image/png", "IMAGE/*"}} {
+		testTools := Tools{AllowedFileTypes: allowed}
+
+		for name, content := range map[string][]byte{"img.png": png, "tgg.jpg": jpg, "doc.pdf": pdf} {
+			request, err := NewMultipartRequestFromReaders("/", []MultipartFile{{FieldName: "file", FileName: name, Content: bytes.NewReader(content)}}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = testTools.UploadFiles(request, t.TempDir())
+			if name == "doc.pdf" {
+				var typeErr *FileTypeError
+				if !errors.As(err, &typeErr) || typeErr.ContentType != "application/pdf" {
+					t.Errorf("%v: expected %s to be rejected as application/pdf, but got %v", allowed, name, err)
+				}
+			} else if err != nil {
+				t.Errorf("%v: expected %s to be allowed, but got %v", allowed, name, err)
+			}
+		}
+	}
+}
+
+// markerScanner rejects any file containing the string INFECTED, like a virus scanner would.
+func markerScanner(ctx context.Context, name string, r io.Reader) error {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(content, []byte("INFECTED")) {
+		return errors.New("found INFECTED")
+	}
+	return nil
+}
+
+func TestTools_UploadFilesScan(t *testing.T) {
+	uploadDir := t.TempDir()
+	testTools := Tools{ScanFunc: markerScanner, TempDir: t.TempDir()}
+
+	request, _ := NewMultipartRequestFromReaders("/", []MultipartFile{
+		{FieldName: "file", FileName: "clean.txt", Content: strings.NewReader("all good")},
+	}, nil)
+	uploadedFiles, err := testTools.UploadFiles(request, uploadDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(uploadDir, uploadedFiles[0].NewFileName)); err != nil {
+		t.Errorf("expected clean file to be saved: %v", err)
+	}
+
+	infectedDir := t.TempDir()
+	request, _ = NewMultipartRequestFromReaders("/", []MultipartFile{
+		{FieldName: "file", FileName: "bad.txt", Content: strings.NewReader("this file is INFECTED")},
+	}, nil)
+	_, err = testTools.UploadFiles(request, infectedDir)
+	if !errors.Is(err, ErrUploadRejectedByScan) || !strings.Contains(err.Error(), "found INFECTED") {
+		t.Errorf("expected ErrUploadRejectedByScan wrapping the scanner's error, but got %v", err)
+	}
+
+	for _, dir := range []string{infectedDir, testTools.TempDir} {
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 0 {
+			t.Errorf("expected %s to be empty, but found %d files", dir, len(entries))
+		}
+	}
+}
+
+func TestTools_UploadFilesScanCancelled(t *testing.T) {
+	uploadDir := t.TempDir()
+	release := make(chan struct{})
+	defer close(release)
+
+	// This scanner ignores its context, so we rely on saveFile not waiting for it.
+	testTools := Tools{TempDir: t.TempDir(), ScanFunc: func(ctx context.Context, name string, r io.Reader) error {
+		<-release
+		return nil
+	}}
+
+	request, _ := NewMultipartRequestFromReaders("/", []MultipartFile{
+		{FieldName: "file", FileName: "slow.txt", Content: strings.NewReader("content")},
+	}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := testTools.UploadFiles(request.WithContext(ctx), uploadDir)
+	if !errors.Is(err, ErrUploadRejectedByScan) || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancelled scan, but got %v", err)
+	}
+
+	entries, _ := os.ReadDir(uploadDir)
+	if len(entries) != 0 {
+		t.Errorf("expected upload directory to be empty, but found %d files", len(entries))
+	}
+}
+
+func TestTools_UploadFilesPartial(t *testing.T) {
+	uploadDir := t.TempDir()
+	png, _ := os.ReadFile("./testdata/img.png")
+
+	request, _ := NewMultipartRequestFromReaders("/", []MultipartFile{
+		{FieldName: "photos", FileName: "good.png", Content: bytes.NewReader(png)},
+		{FieldName: "photos", FileName: "huge.png", Content: bytes.NewReader(append(png, make([]byte, len(png))...))},
+	}, nil)
+
+	var testTools Tools
+	opts := UploadOptions{AllowedTypes: []string{"image/png"}, MaxFileSize: len(png) + 100, ContinueOnError: true}
+
+	uploadedFiles, failures, err := testTools.UploadFilesPartial(request, uploadDir, opts)
+	if err != nil {
+		t.Fatalf("expected no error when one file was saved, but got %v", err)
+	}
+
+	if len(uploadedFiles) != 1 || uploadedFiles[0].OriginalFileName != "good.png" {
+		t.Errorf("expected good.png to be saved, but got %+v", uploadedFiles)
+	}
+
+	if len(failures) != 1 {
+		t.Fatalf("expected one failure, but got %+v", failures)
+	}
+	f := failures[0]
+	if f.FileName != "huge.png" || f.FieldName != "photos" || f.Reason != UploadTooBig || !errors.Is(f, ErrUploadTooBig) {
+		t.Errorf("wrong failure: %+v", f)
+	}
+
+	out, _ := json.Marshal(f)
+	if !strings.Contains(string(out), `"reason":"too_big"`) || !strings.Contains(string(out), `"message":"the uploaded file is too big`) {
+		t.Errorf("wrong JSON for failure: %s", out)
+	}
+
+	entries, _ := os.ReadDir(uploadDir)
+	if len(entries) != 1 {
+		t.Errorf("expected 1 file on disk, but found %d", len(entries))
+	}
+
+	// With RequireAllFiles, the same upload is an error, although the good file is still saved.
+	request, _ = NewMultipartRequestFromReaders("/", []MultipartFile{
+		{FieldName: "photos", FileName: "good.png", Content: bytes.NewReader(png)},
+		{FieldName: "photos", FileName: "notes.txt", Content: strings.NewReader("not a picture")},
+	}, nil)
+	opts.RequireAllFiles = true
+
+	uploadedFiles, failures, err = testTools.UploadFilesPartial(request, t.TempDir(), opts)
+	if !errors.Is(err, ErrUploadTypeNotAllowed) || !strings.Contains(err.Error(), "1 of 2 uploaded files") {
+		t.Errorf("expected an error for the text file, but got %v", err)
+	}
+	if len(uploadedFiles) != 1 || len(failures) != 1 || failures[0].Reason != UploadTypeNotAllowed {
+		t.Errorf("wrong result: %+v, %+v", uploadedFiles, failures)
+	}
+}
+
+func TestTools_UploadFilesBaseUploadDir(t *testing.T) {
+	root := t.TempDir()
+	base := filepath.Join(root, "data")
+	testTools := Tools{BaseUploadDir: base}
+
+	for _, dir := range []string{"tenant1/photos", "/etc", "../outside"} {
+		request, _ := NewMultipartRequest("/", map[string]string{"file": "./testdata/img.png"}, nil)
+
+		uploadedFiles, err := testTools.UploadFiles(request, dir)
+
+		if dir == "tenant1/photos" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", dir, err)
+				continue
+			}
+			if _, err := os.Stat(filepath.Join(base, dir, uploadedFiles[0].NewFileName)); err != nil {
+				t.Errorf("%s: expected the file inside the base directory: %v", dir, err)
+			}
+			continue
+		}
+
+		if !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("%s: expected ErrUnsafePath, but got %v", dir, err)
+		}
+	}
+
+	// Nothing should have been created outside the base directory.
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 1 || entries[0].Name() != "data" {
+		t.Errorf("expected only the base directory in %s, but found %v", root, entries)
+	}
+}
+
+func TestTools_UploadFilesConcurrent(t *testing.T) {
+	uploadDir := t.TempDir()
+	testTools := Tools{UploadCopyBufferSize: 1024}
+
+	// Each upload has different content, of different lengths, so any mix up of pooled buffers
+	// between uploads would show in the saved files.
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			content := bytes.Repeat([]byte{byte('a' + i)}, 100+i*700)
+			request, _ := NewMultipartRequestFromReaders("/", []MultipartFile{
+				{FieldName: "file", FileName: fmt.Sprintf("%d.txt", i), Content: bytes.NewReader(content)},
+			}, nil)
+
+			uploadedFiles, err := testTools.UploadFiles(request, uploadDir)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			saved, err := os.ReadFile(filepath.Join(uploadDir, uploadedFiles[0].NewFileName))
+			if err != nil {
+				errs <- err
+				return
+			}
+			if !bytes.Equal(saved, content) {
+				errs <- fmt.Errorf("file %d was not saved as sent", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// benchmarkSaveFile saves a 50MB file with the given copy buffer size; a size of 32KB is what io.Copy
+// uses, and so what saveFile did before the buffer could be configured.
+func benchmarkSaveFile(b *testing.B, bufferSize int) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 50*1024*1024/16)
+	uploadDir := b.TempDir()
+
+	testTools := Tools{UploadCopyBufferSize: bufferSize}
+	opts := testTools.uploadOptions(context.Background(), UploadOptions{MaxFileSize: len(content), KeepOriginalName: true})
+
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := testTools.saveFile(context.Background(), bytes.NewReader(content), "big.txt", uploadDir, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTools_SaveFile32KB(b *testing.B)  { benchmarkSaveFile(b, 32*1024) }
+func BenchmarkTools_SaveFile128KB(b *testing.B) { benchmarkSaveFile(b, 128*1024) }
+
+func TestTools_UploadFilesOrder(t *testing.T) {
+	var testTools Tools
+
+	png, _ := os.ReadFile("./testdata/img.png")
+	jpg, _ := os.ReadFile("./testdata/tgg.jpg")
+
+	newRequest := func() *http.Request {
+		req, err := NewMultipartRequestFromReaders("/", []MultipartFile{
+			{FieldName: "attachments", FileName: "one.png", Content: bytes.NewReader(png)},
+			{FieldName: "front_image", FileName: "front.jpg", Content: bytes.NewReader(jpg)},
+			{FieldName: "attachments", FileName: "two.jpg", Content: bytes.NewReader(jpg)},
+		}, map[string]string{"title": "Hello"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+
+	req := newRequest()
+	files, err := testTools.UploadFiles(req, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var order []string
+	for _, f := range files {
+		order = append(order, f.FieldName+"/"+f.OriginalFileName)
+	}
+	if strings.Join(order, ",") != "attachments/one.png,front_image/front.jpg,attachments/two.jpg" {
+		t.Errorf("files not in the order sent: %v", order)
+	}
+
+	groups := GroupUploadsByField(files)
+	if len(groups) != 2 || len(groups["attachments"]) != 2 || groups["attachments"][0].OriginalFileName != "one.png" ||
+		groups["attachments"][1].OriginalFileName != "two.jpg" || len(groups["front_image"]) != 1 {
+		t.Errorf("wrong groups: %v", groups)
+	}
+
+	// The text fields are still there for the handler.
+	if req.FormValue("title") != "Hello" || req.MultipartForm.Value["title"][0] != "Hello" {
+		t.Errorf("expected the title to be kept, but got %q", req.FormValue("title"))
+	}
+
+	// A form parsed beforehand still has its files saved, grouped by field.
+	req = newRequest()
+	_ = req.FormValue("title")
+	files, err = testTools.UploadFiles(req, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	order = nil
+	for _, f := range files {
+		order = append(order, f.FieldName+"/"+f.OriginalFileName)
+	}
+	if strings.Join(order, ",") != "attachments/one.png,attachments/two.jpg,front_image/front.jpg" {
+		t.Errorf("wrong files from a parsed form: %v", order)
+	}
+}
+
+func TestMoveFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.txt")
+	dst := filepath.Join(dir, "dst.txt")
+	for name, content := range map[string]string{src: "new", dst: "old"} {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := MoveFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := os.ReadFile(dst); string(saved) != "new" {
+		t.Errorf("expected dst to be replaced, but it has %q", saved)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("expected src to be removed")
+	}
+
+	// A rename that fails for any reason but the paths being on different file systems isn't turned
+	// into a copy.
+	if err := os.WriteFile(src, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	full := filepath.Join(dir, "full")
+	if err := os.MkdirAll(filepath.Join(full, "child"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := MoveFile(src, full); err == nil {
+		t.Error("expected an error moving a file over a directory")
+	}
+	if _, err := os.Stat(src); err != nil {
+		t.Error("expected src to be left alone")
+	}
+}
+
+func TestMoveByCopy(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.txt")
+	dst := filepath.Join(dir, "dst.txt")
+	for name, content := range map[string]string{src: "new", dst: "old"} {
+		if err := os.WriteFile(name, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := moveByCopy(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(dst)
+	if saved, _ := os.ReadFile(dst); string(saved) != "new" || err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected dst to be replaced, with src's mode, but it has %q (%v)", saved, info.Mode())
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("expected src to be removed")
+	}
+
+	// If the copy fails, dst is left as it was, and nothing else is left behind.
+	if err := moveByCopy(t.TempDir(), dst); err == nil {
+		t.Error("expected an error copying a directory")
+	}
+	if saved, _ := os.ReadFile(dst); string(saved) != "new" {
+		t.Errorf("expected dst to be left alone, but it has %q", saved)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected only dst to be left, but found %d files", len(entries))
+	}
+}
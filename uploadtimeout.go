@@ -0,0 +1,144 @@
+package toolbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// ErrUploadTimeout is returned (wrapped) by UploadFiles and friends when a request body takes longer
+// than MaxUploadDuration to arrive, or is sent more slowly than MinUploadRate.
+var ErrUploadTimeout = errors.New("the upload took too long")
+
+// uploadRateWindow is how far back MinUploadRate is measured, and uploadRateBuckets how many parts
+// it is measured in, which is how often the rate is checked. They are variables so that tests can
+// use a shorter window.
+var (
+	uploadRateWindow  = 5 * time.Second
+	uploadRateBuckets = 5
+)
+
+// uploadWatch stops an upload that is taking too long, or arriving too slowly, by cancelling the
+// context its body is read with, with an error wrapping ErrUploadTimeout as the cause. A nil
+// *uploadWatch does nothing.
+type uploadWatch struct {
+	ctx      context.Context
+	cancel   context.CancelCauseFunc
+	timer    *time.Timer
+	received atomic.Int64
+}
+
+// watchUpload returns an uploadWatch for the limits in opts, or nil if neither is set.
+func watchUpload(parent context.Context, opts UploadOptions) *uploadWatch {
+	if opts.MaxUploadDuration <= 0 && opts.MinUploadRate <= 0 {
+		return nil
+	}
+
+	w := &uploadWatch{}
+	w.ctx, w.cancel = context.WithCancelCause(parent)
+
+	if d := opts.MaxUploadDuration; d > 0 {
+		w.timer = time.AfterFunc(d, func() {
+			w.cancel(fmt.Errorf("%w: it took longer than %s", ErrUploadTimeout, d))
+		})
+	}
+	if opts.MinUploadRate > 0 {
+		go w.checkRate(opts.MinUploadRate)
+	}
+
+	return w
+}
+
+// checkRate cancels the upload if, over any uploadRateWindow, less than minRate bytes a second were
+// received. It returns when the upload ends.
+func (w *uploadWatch) checkRate(minRate int64) {
+	ticker := time.NewTicker(uploadRateWindow / time.Duration(uploadRateBuckets))
+	defer ticker.Stop()
+
+	buckets := make([]int64, uploadRateBuckets)
+	var last int64
+	for tick := 0; ; tick++ {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		total := w.received.Load()
+		buckets[tick%len(buckets)] = total - last
+		last = total
+
+		// Don't judge the upload until it has had a whole window.
+		if tick+1 < len(buckets) {
+			continue
+		}
+
+		var sum int64
+		for _, n := range buckets {
+			sum += n
+		}
+		if float64(sum)/uploadRateWindow.Seconds() < float64(minRate) {
+			w.cancel(fmt.Errorf("%w: it was sent at less than %d bytes a second", ErrUploadTimeout, minRate))
+			return
+		}
+	}
+}
+
+// context returns the context the upload should be done with: the watch's, or parent.
+func (w *uploadWatch) context(parent context.Context) context.Context {
+	if w == nil {
+		return parent
+	}
+	return w.ctx
+}
+
+// wrap returns body, counted for MinUploadRate, and read only for as long as the upload may go on.
+func (w *uploadWatch) wrap(body io.ReadCloser) io.ReadCloser {
+	if w == nil {
+		return body
+	}
+	// There is no http.ResponseWriter to set a read deadline with, so reads are done in a goroutine,
+	// which finishes once the watch is stopped.
+	body, _ = newContextReader(w.ctx, nil, &watchedBody{ReadCloser: body, w: w})
+	return body
+}
+
+// err returns the error the upload should fail with, given that it failed with err: the reason the
+// watch stopped it, if it did, or err.
+func (w *uploadWatch) err(err error) error {
+	if w == nil || err == nil {
+		return err
+	}
+
+	if cause := context.Cause(w.ctx); errors.Is(cause, ErrUploadTimeout) {
+		return cause
+	}
+	return err
+}
+
+// stop releases the watch's timer and goroutine.
+func (w *uploadWatch) stop() {
+	if w == nil {
+		return
+	}
+
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.cancel(nil)
+}
+
+// watchedBody counts the bytes read from an upload for its uploadWatch.
+type watchedBody struct {
+	io.ReadCloser
+	w *uploadWatch
+}
+
+func (b *watchedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.w.received.Add(int64(n))
+	return n, err
+}
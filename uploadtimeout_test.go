@@ -0,0 +1,96 @@
+package toolbox
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+// dribbleBody sends the first fast bytes of its content at once, and the rest a byte at a time,
+// every interval.
+type dribbleBody struct {
+	r        io.Reader
+	fast     int
+	interval time.Duration
+}
+
+func (b *dribbleBody) Read(p []byte) (int, error) {
+	if b.fast > 0 {
+		n, err := b.r.Read(p[:min(len(p), b.fast)])
+		b.fast -= n
+		return n, err
+	}
+	time.Sleep(b.interval)
+	return b.r.Read(p[:1])
+}
+
+func (b *dribbleBody) Close() error {
+	return nil
+}
+
+func TestTools_UploadFilesTimeouts(t *testing.T) {
+	uploadRateWindow = 100 * time.Millisecond
+	defer func() { uploadRateWindow = 5 * time.Second }()
+
+	tests := []struct {
+		name  string
+		tools Tools
+		opts  UploadOptions
+		bound time.Duration
+	}{
+		{name: "max duration on tools", tools: Tools{MaxUploadDuration: 150 * time.Millisecond}, bound: 150 * time.Millisecond},
+		{name: "max duration on options", tools: Tools{MaxUploadDuration: time.Hour}, opts: UploadOptions{MaxUploadDuration: 150 * time.Millisecond}, bound: 150 * time.Millisecond},
+		{name: "min rate", tools: Tools{MinUploadRate: 1000}, bound: uploadRateWindow},
+	}
+
+	png, _ := os.ReadFile("./testdata/img.png")
+
+	for _, e := range tests {
+		req, err := NewMultipartRequestFromReaders("/", []MultipartFile{
+			{FieldName: "first", FileName: "first.png", Content: bytes.NewReader(png[:1024])},
+			{FieldName: "second", FileName: "second.png", Content: bytes.NewReader(png)},
+		}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(req.Body)
+
+		// The first file arrives at once, and the second at 50 bytes a second.
+		req.Body = &dribbleBody{r: bytes.NewReader(body), fast: 2048, interval: 20 * time.Millisecond}
+
+		uploadDir := t.TempDir()
+		e.opts.ContinueOnError = true
+		start := time.Now()
+		files, _, err := e.tools.UploadFilesPartial(req, uploadDir, e.opts)
+		elapsed := time.Since(start)
+
+		if !errors.Is(err, ErrUploadTimeout) {
+			t.Errorf("%s: expected ErrUploadTimeout, but got %v", e.name, err)
+		}
+		if elapsed < e.bound || elapsed > e.bound+time.Second {
+			t.Errorf("%s: expected the upload to stop at about %s, but it took %s", e.name, e.bound, elapsed)
+		}
+		if entries, _ := os.ReadDir(uploadDir); len(files) != 0 || len(entries) != 0 {
+			t.Errorf("%s: expected nothing to be kept, but got %d files and %d entries", e.name, len(files), len(entries))
+		}
+	}
+}
+
+func TestTools_UploadFilesFastEnough(t *testing.T) {
+	png, _ := os.ReadFile("./testdata/img.png")
+	req, err := NewMultipartRequestFromReaders("/", []MultipartFile{
+		{FieldName: "file", FileName: "img.png", Content: bytes.NewReader(png)},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testTools := Tools{MaxUploadDuration: time.Minute, MinUploadRate: 1}
+	files, err := testTools.UploadFiles(req, t.TempDir())
+	if err != nil || len(files) != 1 {
+		t.Errorf("expected the upload to succeed, but got %d files and %v", len(files), err)
+	}
+}
@@ -0,0 +1,58 @@
+package toolbox
+
+import "errors"
+
+// Validator is implemented by types that can check themselves. When ReadJSON decodes a body into
+// a Validator, it calls Validate afterwards, unless SkipValidation is set, so that handlers don't
+// have to:
+//
+//	func (p *Post) Validate() error {
+//		var errs toolbox.FieldErrors
+//		if p.Title == "" {
+//			errs.Add("title", "required", "is required")
+//		}
+//		return errs.Err()
+//	}
+type Validator interface {
+	Validate() error
+}
+
+// ErrValidation matches, with errors.Is, the *ValidationError returned when the Validate method of
+// a decoded value fails, so that a handler can respond with 422 Unprocessable Entity, rather than
+// 400 Bad Request, for a body that was read fine, but isn't acceptable.
+var ErrValidation = errors.New("validation failed")
+
+// ValidationError is returned by ReadJSON when the decoded value's Validate method returns an error.
+// Its message is that of the error from Validate, which can be reached with errors.As as well; in
+// particular, FieldErrors returned by Validate are sent in full by ErrorJSON.
+type ValidationError struct {
+	Err error // the error returned by Validate
+}
+
+// Error satisfies the error interface.
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+// Is makes errors.Is(err, ErrValidation) true.
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// Unwrap returns the error returned by Validate.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// validate calls the Validate method of data, if it has one, and SkipValidation isn't set.
+func (t *Tools) validate(data any) error {
+	v, ok := data.(Validator)
+	if !ok || t.SkipValidation {
+		return nil
+	}
+
+	if err := v.Validate(); err != nil {
+		return &ValidationError{Err: err}
+	}
+	return nil
+}
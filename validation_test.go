@@ -0,0 +1,85 @@
+package toolbox
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type validatedPost struct {
+	Title string `json:"title"`
+	Views int    `json:"views"`
+}
+
+func (p *validatedPost) Validate() error {
+	var errs FieldErrors
+	if p.Title == "" {
+		errs.Add("title", "required", "is required")
+	}
+	if p.Views < 0 {
+		errs.Add("views", "invalid", "must not be negative")
+	}
+	return errs.Err()
+}
+
+var validationTests = []struct {
+	name           string
+	body           string
+	skip           bool
+	expectedFields int
+	errorExpected  bool
+}{
+	{name: "valid", body: `{"title":"Hello","views":1}`},
+	{name: "invalid", body: `{"views":-1}`, expectedFields: 2, errorExpected: true},
+	{name: "skipped", body: `{"views":-1}`, skip: true},
+	{name: "badly formed", body: `{"title":`, errorExpected: true},
+}
+
+func TestTools_ReadJSONValidate(t *testing.T) {
+	for _, e := range validationTests {
+		testTools := Tools{SkipValidation: e.skip}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.body))
+		req.Header.Set("Content-Type", "application/json")
+
+		var post validatedPost
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &post)
+
+		if !e.errorExpected {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", e.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error, but got none", e.name)
+			continue
+		}
+
+		// Only a body that was read, but failed Validate, is a validation error.
+		if errors.Is(err, ErrValidation) != (e.expectedFields > 0) {
+			t.Errorf("%s: wrong errors.Is(err, ErrValidation) for %v", e.name, err)
+		}
+
+		var fieldErrs FieldErrors
+		if e.expectedFields > 0 && (!errors.As(err, &fieldErrs) || len(fieldErrs) != e.expectedFields) {
+			t.Errorf("%s: expected %d field errors, but got %v", e.name, e.expectedFields, err)
+		}
+	}
+}
+
+func TestTools_ReadJSONValidateMessage(t *testing.T) {
+	var testTools Tools
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+
+	var post validatedPost
+	err := testTools.ReadJSON(httptest.NewRecorder(), req, &post)
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || err.Error() != "title: is required" {
+		t.Errorf("expected a *ValidationError with the message from Validate, but got %v", err)
+	}
+}
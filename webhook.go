@@ -0,0 +1,133 @@
+package toolbox
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultWebhookTolerance is the default maximum age of a timestamped webhook signature.
+const defaultWebhookTolerance = 5 * time.Minute
+
+// ErrInvalidSignature is returned when a webhook signature is missing or does not match.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// ErrSignatureExpired is returned when the timestamp of a webhook is outside the allowed tolerance.
+var ErrSignatureExpired = errors.New("webhook timestamp outside of tolerance")
+
+// WebhookOptions describes how a webhook request is signed. For GitHub, use
+// WebhookOptions{Header: "X-Hub-Signature-256", SignaturePrefix: "sha256="}, and for Stripe,
+// use WebhookOptions{Header: "Stripe-Signature", SignaturePrefix: "v1=", TimestampKey: "t"}.
+type WebhookOptions struct {
+	Header          string        // the header holding the signature(s)
+	Algorithm       string        // "sha256" (the default), or "sha1" for legacy senders
+	SignaturePrefix string        // prefix on each hex encoded signature in the header, e.g. "sha256="
+	TimestampKey    string        // if set, the key of a unix timestamp in the signature header, e.g. "t"
+	TimestampHeader string        // if set, a separate header holding a unix timestamp
+	Tolerance       time.Duration // maximum age of a timestamped signature (defaults to 5 minutes)
+	Canonical       bool          // if true, the body is signed in its canonical form (see CanonicalJSON), as SignJSON does
+}
+
+// VerifyWebhookSignature reads the body of r (up to MaxJSONSize bytes), and checks it against the
+// HMAC signature in the header described by opts. The signature header may hold several comma separated
+// signatures (e.g. during secret rotation), and the request is accepted if any of them match. If the
+// request is timestamped, the signed message is "timestamp.body", and the timestamp must be within
+// opts.Tolerance of now. On success, the raw body is returned, and r.Body is reset so that it can be
+// read again (for example, by ReadJSON). If opts.Canonical is set, the signature is checked against
+// the canonical form of the body, so a sender using SignJSON may re-encode it in transit.
+func (t *Tools) VerifyWebhookSignature(r *http.Request, secret []byte, opts WebhookOptions) ([]byte, error) {
+	var newHash func() hash.Hash
+	switch strings.ToLower(opts.Algorithm) {
+	case "", "sha256":
+		newHash = sha256.New
+	case "sha1":
+		newHash = sha1.New
+	default:
+		return nil, fmt.Errorf("unsupported webhook signature algorithm %s", opts.Algorithm)
+	}
+
+	maxBytes := t.maxJSONSize(r.Context())
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxBytes {
+		return nil, &BodyTooLargeError{Limit: maxBytes}
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Split the header into signatures, and possibly a timestamp.
+	var signatures []string
+	var timestamp string
+	for _, part := range strings.Split(r.Header.Get(opts.Header), ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case opts.TimestampKey != "" && strings.HasPrefix(part, opts.TimestampKey+"="):
+			timestamp = strings.TrimPrefix(part, opts.TimestampKey+"=")
+		case strings.HasPrefix(part, opts.SignaturePrefix) && part != "":
+			signatures = append(signatures, strings.TrimPrefix(part, opts.SignaturePrefix))
+		}
+	}
+	if opts.TimestampHeader != "" {
+		timestamp = r.Header.Get(opts.TimestampHeader)
+	}
+
+	if len(signatures) == 0 {
+		return nil, fmt.Errorf("%w: no signature found in %s header", ErrInvalidSignature, opts.Header)
+	}
+
+	message := body
+	if opts.Canonical {
+		message, err = CanonicalJSON(json.RawMessage(body))
+		if err != nil {
+			return nil, fmt.Errorf("%w: body is not valid JSON", ErrInvalidSignature)
+		}
+	}
+
+	if opts.TimestampKey != "" || opts.TimestampHeader != "" {
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: missing or invalid timestamp", ErrInvalidSignature)
+		}
+
+		tolerance := defaultWebhookTolerance
+		if opts.Tolerance != 0 {
+			tolerance = opts.Tolerance
+		}
+
+		age := time.Since(time.Unix(ts, 0))
+		if age > tolerance || age < -tolerance {
+			return nil, ErrSignatureExpired
+		}
+
+		message = append([]byte(timestamp+"."), message...)
+	}
+
+	mac := hmac.New(newHash, secret)
+	mac.Write(message)
+	expected := mac.Sum(nil)
+
+	for _, sig := range signatures {
+		decoded, err := hex.DecodeString(sig)
+		if err != nil {
+			continue
+		}
+		if hmac.Equal(decoded, expected) {
+			return body, nil
+		}
+	}
+
+	return nil, ErrInvalidSignature
+}
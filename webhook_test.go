@@ -0,0 +1,91 @@
+package toolbox
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// sign returns the hex encoded HMAC-SHA256 of message using secret.
+func sign(secret, message string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestTools_VerifyWebhookSignature(t *testing.T) {
+	body := `{"foo": "bar"}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	github := WebhookOptions{Header: "X-Hub-Signature-256", SignaturePrefix: "sha256="}
+	stripe := WebhookOptions{Header: "Stripe-Signature", SignaturePrefix: "v1=", TimestampKey: "t"}
+	canonical := WebhookOptions{Header: "X-Signature", Canonical: true}
+
+	var tests = []struct {
+		name   string
+		opts   WebhookOptions
+		header string
+		err    error
+	}{
+		{name: "github", opts: github, header: "sha256=" + sign("secret", body)},
+		{name: "github wrong secret", opts: github, header: "sha256=" + sign("wrong", body), err: ErrInvalidSignature},
+		{name: "missing signature", opts: github, header: "", err: ErrInvalidSignature},
+		{name: "stripe", opts: stripe, header: fmt.Sprintf("t=%s,v1=%s", now, sign("secret", now+"."+body))},
+		{name: "stripe rotated secret", opts: stripe, header: fmt.Sprintf("t=%s,v1=%s,v1=%s", now, sign("old", now+"."+body), sign("secret", now+"."+body))},
+		{name: "canonical", opts: canonical, header: sign("secret", `{"foo":"bar"}`)},
+		{name: "canonical signed raw", opts: canonical, header: sign("secret", body), err: ErrInvalidSignature},
+		{name: "stripe expired", opts: stripe, header: fmt.Sprintf("t=%s,v1=%s", old, sign("secret", old+"."+body)), err: ErrSignatureExpired},
+	}
+
+	for _, e := range tests {
+		var testTools Tools
+
+		req := httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+		req.Header.Set(e.opts.Header, e.header)
+
+		raw, err := testTools.VerifyWebhookSignature(req, []byte("secret"), e.opts)
+		if e.err != nil {
+			if !errors.Is(err, e.err) {
+				t.Errorf("%s: expected %v, but got %v", e.name, e.err, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+
+		if string(raw) != body {
+			t.Errorf("%s: wrong body returned: %s", e.name, raw)
+		}
+
+		// the body should still be readable by ReadJSON
+		var decoded struct {
+			Foo string `json:"foo"`
+		}
+		err = testTools.ReadJSON(httptest.NewRecorder(), req, &decoded)
+		if err != nil || decoded.Foo != "bar" {
+			t.Errorf("%s: could not read JSON after verification: %v", e.name, err)
+		}
+	}
+}
+
+func TestTools_VerifyWebhookSignatureUnknownAlgorithm(t *testing.T) {
+	var testTools Tools
+
+	req, _ := http.NewRequest("POST", "/", bytes.NewBufferString("{}"))
+	_, err := testTools.VerifyWebhookSignature(req, []byte("secret"), WebhookOptions{Header: "X-Sig", Algorithm: "md5"})
+	if err == nil {
+		t.Error("expected error for unsupported algorithm, but none received")
+	}
+}
@@ -0,0 +1,138 @@
+package toolbox
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxXMLMapDepth is how deeply elements may be nested in a body read by ReadXMLMap.
+const maxXMLMapDepth = 100
+
+// ReadXMLMap reads an XML request body of any shape, for when there is no struct to decode it into,
+// with the same MaxXMLSize limit and errors as ReadXML. Elements may be nested at most 100 deep. The
+// result has a single key, the name of the root element, and each element becomes:
+//
+//   - its text, as a string, if it has no attributes and no child elements;
+//   - otherwise, a map[string]any, with each attribute under its name prefixed with @, each child
+//     element under its name, and any text under #text.
+//
+// A child element that appears more than once becomes a []any, in document order; one that appears
+// once is not wrapped in a slice. Text is trimmed of surrounding whitespace, and names are used
+// without their namespace. So
+//
+//	<order id="7"><item sku="a">Pen</item><item sku="b">Ink</item><note>Rush</note></order>
+//
+// becomes
+//
+//	map[order:map[@id:7 item:[map[#text:Pen @sku:a] map[#text:Ink @sku:b]] note:Rush]]
+func (t *Tools) ReadXMLMap(w http.ResponseWriter, r *http.Request) (map[string]any, error) {
+	done := t.trackDecode(r, "xml")
+
+	body, maxBytes, cancel, err := t.xmlBody(w, r)
+	defer cancel()
+	if err != nil {
+		return nil, done(err)
+	}
+
+	dec := xml.NewDecoder(body)
+
+	root, err := nextXMLElement(dec)
+	if err == io.EOF {
+		return nil, done(errors.New("body must not be empty"))
+	}
+	if err != nil {
+		return nil, done(xmlReadError(err, maxBytes))
+	}
+
+	value, err := readXMLElement(dec, root, 1)
+	if err != nil {
+		return nil, done(xmlReadError(err, maxBytes))
+	}
+
+	_, err = nextXMLElement(dec)
+	if err != io.EOF {
+		return nil, done(xmlTrailingError(err, maxBytes))
+	}
+
+	return map[string]any{root.Name.Local: value}, done(nil)
+}
+
+// nextXMLElement skips the declaration, comments and whitespace that may come before (or after) the
+// root element, and returns the root element's start tag, or io.EOF if there is none. Text is an error.
+func nextXMLElement(dec *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			return tok, nil
+		case xml.CharData:
+			if len(bytes.TrimSpace(tok)) > 0 {
+				return xml.StartElement{}, errors.New("body has text outside of the root element")
+			}
+		}
+	}
+}
+
+// readXMLElement reads the rest of the element that starts with start, at depth, and returns
+// its value, as described for ReadXMLMap.
+func readXMLElement(dec *xml.Decoder, start xml.StartElement, depth int) (any, error) {
+	if depth > maxXMLMapDepth {
+		return nil, fmt.Errorf("body must not have elements nested more than %d deep", maxXMLMapDepth)
+	}
+
+	fields := map[string]any{}
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			continue
+		}
+		fields["@"+attr.Name.Local] = attr.Value
+	}
+
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			child, err := readXMLElement(dec, tok, depth+1)
+			if err != nil {
+				return nil, err
+			}
+
+			name := tok.Name.Local
+			switch existing := fields[name].(type) {
+			case nil:
+				fields[name] = child
+			case []any:
+				fields[name] = append(existing, child)
+			default:
+				fields[name] = []any{existing, child}
+			}
+
+		case xml.CharData:
+			text.Write(tok)
+
+		case xml.EndElement:
+			value := strings.TrimSpace(text.String())
+			if len(fields) == 0 {
+				return value, nil
+			}
+			if value != "" {
+				fields["#text"] = value
+			}
+			return fields, nil
+		}
+	}
+}
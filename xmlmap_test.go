@@ -0,0 +1,100 @@
+package toolbox
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var readXMLMapTests = []struct {
+	name          string
+	xml           string
+	maxBytes      int
+	expected      map[string]any
+	errorExpected bool
+}{
+	{
+		name: "nested repeated elements and attributes",
+		xml: `<?xml version="1.0" encoding="UTF-8"?>
+<order id="7" xmlns="urn:example">
+	<!-- from the partner -->
+	<customer><name>Jane</name></customer>
+	<item sku="a" qty="1">Pen</item>
+	<item sku="b"><part>cap</part><part>nib</part></item>
+	<item>Ink</item>
+	<note/>
+</order>`,
+		expected: map[string]any{"order": map[string]any{
+			"@id":      "7",
+			"customer": map[string]any{"name": "Jane"},
+			"item": []any{
+				map[string]any{"@sku": "a", "@qty": "1", "#text": "Pen"},
+				map[string]any{"@sku": "b", "part": []any{"cap", "nib"}},
+				"Ink",
+			},
+			"note": "",
+		}},
+	},
+	{name: "text only", xml: `<greeting>hello</greeting>`, expected: map[string]any{"greeting": "hello"}},
+	{name: "too big", xml: `<note><to>John Smith</to></note>`, maxBytes: 10, errorExpected: true},
+	{name: "malformed", xml: `<note><to>John Smith</from></note>`, errorExpected: true},
+	{name: "unterminated", xml: `<note><to>John Smith</to>`, errorExpected: true},
+	{name: "empty", xml: ``, errorExpected: true},
+	{name: "two roots", xml: `<a/><b/>`, errorExpected: true},
+	{name: "not xml", xml: `{"to": "John Smith"}`, errorExpected: true},
+	{name: "too deep", xml: strings.Repeat("<a>", maxXMLMapDepth+1) + strings.Repeat("</a>", maxXMLMapDepth+1), errorExpected: true},
+}
+
+func TestTools_ReadXMLMap(t *testing.T) {
+	for _, e := range readXMLMapTests {
+		tools := Tools{MaxXMLSize: e.maxBytes}
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(e.xml))
+		got, err := tools.ReadXMLMap(httptest.NewRecorder(), req)
+
+		if e.errorExpected {
+			if err == nil {
+				t.Errorf("%s: expected an error, but got %v", e.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, e.expected) {
+			t.Errorf("%s: expected %v, but got %v", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestTools_ReadXMLMapErrors(t *testing.T) {
+	tools := Tools{MaxXMLSize: 10}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`<note><to>John Smith</to></note>`))
+	_, err := tools.ReadXMLMap(httptest.NewRecorder(), req)
+	if !errors.Is(err, ErrBodyTooLarge) || err.Error() != "body must not be larger than 10 bytes" {
+		t.Errorf("expected the same size error as ReadXML, but got %v", err)
+	}
+
+	// A body that doesn't say how long it is is stopped once it has been read that far.
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`<note><to>John Smith</to></note>`))
+	req.ContentLength = -1
+	if _, err = tools.ReadXMLMap(httptest.NewRecorder(), req); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("expected a size error while reading, but got %v", err)
+	}
+
+	tools.MaxXMLSize = 0
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"to": "John Smith"}`))
+	if _, err = tools.ReadXMLMap(httptest.NewRecorder(), req); err == nil || err.Error() != "body does not appear to be XML" {
+		t.Errorf("expected the same non-XML error as ReadXML, but got %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`<a/><b/>`))
+	if _, err = tools.ReadXMLMap(httptest.NewRecorder(), req); err == nil || err.Error() != "body must only contain a single XML value" {
+		t.Errorf("expected the same trailing value error as ReadXML, but got %v", err)
+	}
+}